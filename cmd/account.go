@@ -53,7 +53,7 @@ func addAccount(cmd *cobra.Command, args []string) {
 	pr := terminalPasswordReader{}
 	credentials, err := getCredentials(pr, os.Stdin)
 	if err != nil {
-		log.Fatalln(err)
+		exitWithError(err)
 	}
 	hashedPassword, err := bcrypt.GenerateFromPassword(credentials.password, 10)
 	if err != nil {
@@ -61,7 +61,7 @@ func addAccount(cmd *cobra.Command, args []string) {
 	}
 	err = AccountDB.CreateAccount(credentials.username, hashedPassword)
 	if err != nil {
-		log.Fatalf("Failed creating new account, error msg: %v", err)
+		exitWithError(fmt.Errorf("Failed creating new account, error msg: %w", err))
 	}
 }
 
@@ -69,21 +69,26 @@ func deleteAccount(cmd *cobra.Command, args []string) {
 	pr := terminalPasswordReader{}
 	credentials, err := getCredentials(pr, os.Stdin)
 	if err != nil {
-		log.Fatalln(err)
+		exitWithError(err)
 	}
 	account, err := AccountDB.GetAccount(credentials.username)
 	if err != nil {
-		log.Fatalf("Could not delete account for user: %v", credentials.username)
+		exitWithError(fmt.Errorf("Could not delete account for user: %v, error msg: %w", credentials.username, err))
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), credentials.password); err != nil {
 		log.Fatalln("Username and password don't match")
 	}
-	AccountDB.DeleteAccount(credentials.username)
+	if err := AccountDB.DeleteAccount(credentials.username); err != nil {
+		exitWithError(fmt.Errorf("Could not delete account for user: %v, error msg: %w", credentials.username, err))
+	}
 	fmt.Printf("Account for user: %v deleted", credentials.username)
 }
 
 func getAccounts(cmd *cobra.Command, args []string) {
-	usernames := AccountDB.GetUsernames()
+	usernames, err := AccountDB.GetUsernames()
+	if err != nil {
+		exitWithError(fmt.Errorf("Could not retrieve accounts, error msg: %w", err))
+	}
 	if len(usernames) == 0 {
 		fmt.Println("DB is empty")
 		return
@@ -118,7 +123,7 @@ func getCredentials(pr passwordReader, input io.Reader) (*credentials, error) {
 	fmt.Print("Enter Password: ")
 	bytePassword, err := pr.ReadPassword(int(syscall.Stdin))
 	if err != nil {
-		return &credentials{}, fmt.Errorf("Failed reading password, error msg: %v", err)
+		return &credentials{}, fmt.Errorf("Failed reading password, error msg: %w", err)
 	}
 
 	password := string(bytePassword)
@@ -76,7 +76,7 @@ func TestGetCredentials(t *testing.T) {
 
 	for _, c := range cases {
 		cred, err := getCredentials(c.fpr, c.input)
-		if !reflect.DeepEqual(c.err, err) {
+		if !sameError(c.err, err) {
 			t.Errorf("Expected err to be %q but it was %q", c.err, err)
 		}
 		if !reflect.DeepEqual(c.cred, cred) {
@@ -89,16 +89,16 @@ type mockAccountDB struct {
 	repository.AccountRepository
 }
 
-func (mDB mockAccountDB) GetUsernames() []string {
-	return []string{"username1", "username2"}
+func (mDB mockAccountDB) GetUsernames() ([]string, error) {
+	return []string{"username1", "username2"}, nil
 }
 
 type mockAccountDBReturnEmpty struct {
 	repository.AccountRepository
 }
 
-func (mDB mockAccountDBReturnEmpty) GetUsernames() []string {
-	return []string{}
+func (mDB mockAccountDBReturnEmpty) GetUsernames() ([]string, error) {
+	return []string{}, nil
 }
 
 type FakePasswordReader struct {
@@ -5,7 +5,7 @@ import (
 	"github.com/nicolasmanic/tefter/model"
 	"github.com/nicolasmanic/tefter/repository"
 	"github.com/spf13/cobra"
-	"log"
+	"strings"
 )
 
 var addNoteCmd = &cobra.Command{
@@ -14,33 +14,42 @@ var addNoteCmd = &cobra.Command{
 	Long: "A note consist of 4 parts:" +
 		" 1) Title, is set through -t flag (optional) \n" +
 		" 2) Tags, is set through --tags flag (optional) \n" +
-		" 3) Notebook title, if notebook does not exist it will be created,\n" +
-		"    is set through -n flag (optional), if not set note will be inserted to the default notebook \n" +
-		" 4) Memo, is inserted via VI editor\n",
-	Example: "add -t title_1 --tags tag1,tag2 -n notebook_1",
+		" 3) Notebook title or path like work/infra, if notebook does not exist it will be created,\n" +
+		"    is set through -n flag (optional), if not set note will be inserted to the default_notebook\n" +
+		"    of the config file or to the default notebook \n" +
+		" 4) Memo, is inserted via the editor of the config file, $VISUAL, $EDITOR or vim,\n" +
+		"    if input is piped the memo is read from stdin\n",
+	Example: "add -t title_1 --tags tag1,tag2 -n notebook_1\n" +
+		"echo 'memo' | tefter add -t title_1",
 	Run:     addWrapper,
 }
 
 func init() {
 	rootCmd.AddCommand(addNoteCmd)
 	addNoteCmd.Flags().StringP("title", "t", "", "Notes title.")
-	addNoteCmd.Flags().StringSlice("tags", []string{}, "Comma-separated tags of note.")
-	addNoteCmd.Flags().StringP("notebook", "n", "", "Notebook that this note belongs to")
+	addNoteCmd.Flags().StringSlice("tags", []string{}, "Comma-separated tags of note, use / for sub tags eg: lang/go")
+	addNoteCmd.Flags().StringP("notebook", "n", "", "Path of the notebook that this note belongs to, eg: work/infra")
 }
 
 func addWrapper(cmd *cobra.Command, args []string) {
 	title, _ := cmd.Flags().GetString("title")
 	tags, _ := cmd.Flags().GetStringSlice("tags")
 	notebookTitle, _ := cmd.Flags().GetString("notebook")
-	editor := &viEditor{}
-	err := add(title, tags, notebookTitle, editor)
+	editor, err := newEditor()
 	if err != nil {
-		log.Fatalln(err)
+		exitWithError(err)
+	}
+	err = add(title, tags, notebookTitle, editor)
+	if err != nil {
+		exitWithError(err)
 	}
 }
 
 func add(title string, tags []string, notebookTitle string, editor Editor) error {
-	memo := editor.edit("")
+	memo, err := editor.edit("")
+	if err != nil {
+		return err
+	}
 
 	jNote := &jsonNote{
 		Title:         title,
@@ -55,42 +64,73 @@ func addJSONNote(jNote *jsonNote) error {
 	//All newNotes will be inserted to default notebook
 	//In next steps the notebook may change see addNotebookToNote for more.
 	note := model.NewNote(jNote.Title, jNote.Memo, repository.DEFAULT_NOTEBOOK_ID, jNote.Tags)
-	err := addNotebookToNote(note, jNote.NotebookTitle)
-	if err != nil {
-		return fmt.Errorf("Error while finding corresponding notebook for note, error msg: %v", err)
-	}
+	//Notebook creation and note insertion run in one transaction so that a failed save
+	//does not leave behind an empty notebook.
+	return withTx(func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+		err := addNotebookToNote(notebookDB, note, jNote.NotebookTitle)
+		if err != nil {
+			return fmt.Errorf("Error while finding corresponding notebook for note, error msg: %w", err)
+		}
 
-	_, err = NoteDB.SaveNote(note)
-	if err != nil {
-		return fmt.Errorf("Error while saving note, error msg: %v", err)
-	}
-	return nil
+		_, err = noteDB.SaveNote(note)
+		if err != nil {
+			return fmt.Errorf("Error while saving note, error msg: %w", err)
+		}
+		return nil
+	})
 }
 
 //addNotebookToNote finds the corresponting notebook for given notebook title
 //If notebookTitle exists it will be inserted there.
-//If notebookTitle is empty it will be inserted to the default notebook.
+//If notebookTitle is empty it will be inserted to the default_notebook of the config,
+//or if that is not set, to the default notebook.
 //If notebookTitle does not exists notebook will be created and note will be there.
-func addNotebookToNote(note *model.Note, notebookTitle string) error {
+func addNotebookToNote(notebookDB repository.NotebookRepository, note *model.Note, notebookTitle string) error {
+	if notebookTitle == "" {
+		notebookTitle = Config.DefaultNotebook
+	}
 	if notebookTitle == "" {
 		note.NotebookID = repository.DEFAULT_NOTEBOOK_ID
 		return nil
 	}
 
-	notebook, err := NotebookDB.GetNotebookByTitle(notebookTitle)
+	id, err := createNotebookPath(notebookDB, notebookTitle)
 	if err != nil {
 		return err
 	}
+	note.UpdateNotebook(id)
+	return nil
+}
 
-	if notebook == nil {
-		newNotebook := model.NewNotebook(notebookTitle)
-		id, err := NotebookDB.SaveNotebook(newNotebook)
+//createNotebookPath returns the id of the notebook at path, the notebooks missing from the path are created
+//so that add -n work/infra works even if work does not exist yet.
+func createNotebookPath(notebookDB repository.NotebookRepository, path string) (int64, error) {
+	notebook, err := notebookDB.GetNotebookByTitle(path)
+	if err != nil {
+		return 0, err
+	}
+	if notebook != nil {
+		return notebook.ID, nil
+	}
+	titles := repository.SplitNotebookPath(path)
+	if len(titles) == 0 {
+		return 0, fmt.Errorf("Notebook path: %q contains no title, error msg: %w", path, repository.ErrValidation)
+	}
+	var parentID int64
+	for i, title := range titles {
+		notebook, err := notebookDB.GetNotebookByTitle(strings.Join(titles[:i+1], repository.NotebookPathSeparator))
 		if err != nil {
-			return err
+			return 0, err
+		}
+		if notebook != nil {
+			parentID = notebook.ID
+			continue
+		}
+		newNotebook := model.NewNotebook(title)
+		newNotebook.ParentID = parentID
+		if parentID, err = notebookDB.SaveNotebook(newNotebook); err != nil {
+			return 0, err
 		}
-		note.UpdateNotebook(id)
-	} else {
-		note.UpdateNotebook(notebook.ID)
 	}
-	return nil
+	return parentID, nil
 }
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"github.com/nicolasmanic/tefter/model"
 	"github.com/nicolasmanic/tefter/repository"
-	"reflect"
 	"testing"
 )
 
@@ -85,7 +84,7 @@ func TestAdd(t *testing.T) {
 		}()
 
 		err := add(c.noteTitle, c.tags, c.notebookTitle, c.editor)
-		if !reflect.DeepEqual(c.expectedErr, err) {
+		if !sameError(c.expectedErr, err) {
 			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
 		}
 	}
@@ -165,12 +164,31 @@ func TestAddJSONNote(t *testing.T) {
 		}()
 
 		err := addJSONNote(c.jNote)
-		if !reflect.DeepEqual(c.expectedErr, err) {
+		if !sameError(c.expectedErr, err) {
 			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
 		}
 	}
 }
 
+func TestAddJSONNoteRollsBack(t *testing.T) {
+	oldStore, oldNoteDB, oldNotebookDB := Store, NoteDB, NotebookDB
+	Store = repository.NewMemoryStore()
+	NoteDB, NotebookDB = Store.Notes(), Store.Notebooks()
+	defer func() {
+		Store, NoteDB, NotebookDB = oldStore, oldNoteDB, oldNotebookDB
+	}()
+
+	//empty memo fails the save, the notebook created for the note should not be kept
+	err := addJSONNote(&jsonNote{Title: "title", NotebookTitle: "notebook"})
+	if !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected validation error but it was %q", err)
+	}
+	notebook, _ := NotebookDB.GetNotebookByTitle("notebook")
+	if notebook != nil {
+		t.Error("Notebook should not exist after failed save")
+	}
+}
+
 type mockNoteDBAdd struct {
 	repository.NoteRepository
 	id  int64
@@ -200,6 +218,6 @@ type fakeEditor struct {
 	returnedText string
 }
 
-func (fe fakeEditor) edit(text string) string {
-	return fe.returnedText
+func (fe fakeEditor) edit(text string) (string, error) {
+	return fe.returnedText, nil
 }
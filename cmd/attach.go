@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+//jsonAttachment is the json representation of a file attached to a note, see model.Attachment.
+type jsonAttachment struct {
+	ID      int64     `json:"id"`
+	NoteID  int64     `json:"note_id"`
+	Name    string    `json:"name"`
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+	//Content is only set for exported notes, it is base64 encoded
+	Content []byte `json:"content,omitempty"`
+}
+
+var (
+	attachCmd = &cobra.Command{
+		Use:   "attach",
+		Short: "Attach files to a note",
+		Long: "Attach one or more files, eg: screenshots, PDFs or logs, to a note. Files are stored in the DB,\n" +
+			"a file attached to many notes is stored once. Attachments are moved to the trash and purged along with their note.",
+		Example: "attach 42 screenshot.png app.log",
+		Args:    cobra.MinimumNArgs(2),
+		Run:     attachWrapper,
+	}
+	attachmentsCmd = &cobra.Command{
+		Use:     "attachments",
+		Short:   "List the attachments of a note",
+		Long:    "List the files attached to a note with their attachment id, use extract to save them.",
+		Example: "attachments 42",
+		Args:    cobra.ExactArgs(1),
+		Run:     attachmentsWrapper,
+	}
+	extractCmd = &cobra.Command{
+		Use:   "extract",
+		Short: "Save attachments to files",
+		Long: "Save attachments, given by their id, to files named after them in the current or --output directory.\n" +
+			"Existing files are not overwritten.",
+		Example: "extract 3 4 -o ~/Downloads",
+		Args:    cobra.MinimumNArgs(1),
+		Run:     extractWrapper,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(attachCmd)
+	rootCmd.AddCommand(attachmentsCmd)
+	rootCmd.AddCommand(extractCmd)
+	extractCmd.Flags().StringP("output", "o", ".", "Directory to save the attachments to")
+}
+
+func attachWrapper(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		exitWithError(fmt.Errorf("ID could not be converted to integer, error msg: %w", err))
+	}
+	jAttachments, err := attachFiles(fileSystemReader{}, id, args[1:])
+	if err != nil {
+		exitWithError(err)
+	}
+	for _, jAttachment := range jAttachments {
+		fmt.Printf("Attached %s to note %d as attachment %d\n", jAttachment.Name, id, jAttachment.ID)
+	}
+}
+
+func attachmentsWrapper(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		exitWithError(fmt.Errorf("ID could not be converted to integer, error msg: %w", err))
+	}
+	jAttachments, err := listAttachments(id)
+	if err != nil {
+		exitWithError(err)
+	}
+	if len(jAttachments) == 0 {
+		fmt.Printf("Note %d has no attachments\n", id)
+		return
+	}
+	fmt.Printf("> Attachments of note %d:\n", id)
+	for _, jAttachment := range jAttachments {
+		fmt.Printf(" - %d %s (%d bytes)\n", jAttachment.ID, jAttachment.Name, jAttachment.Size)
+	}
+}
+
+func extractWrapper(cmd *cobra.Command, args []string) {
+	dir, _ := cmd.Flags().GetString("output")
+	ids := make([]int64, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			exitWithError(fmt.Errorf("ID could not be converted to integer, error msg: %w", err))
+		}
+		ids = append(ids, id)
+	}
+	paths, err := extract(ids, dir)
+	for _, path := range paths {
+		fmt.Printf("Saved %s\n", path)
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+}
+
+//attachFiles attaches the files at paths to the note, either all of them or none.
+func attachFiles(fr fileReader, noteID int64, paths []string) ([]*jsonAttachment, error) {
+	attachments := make([]*model.Attachment, 0, len(paths))
+	for _, path := range paths {
+		content, err := fr.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error while reading file, error msg: %w", err)
+		}
+		attachments = append(attachments, model.NewAttachment(noteID, path, content))
+	}
+	return addAttachments(attachments)
+}
+
+//addAttachments stores the attachments in a single transaction. Only the base name of the attachments is kept,
+//so that extracting them can not write outside the output directory.
+func addAttachments(attachments []*model.Attachment) ([]*jsonAttachment, error) {
+	err := withTx(func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+		for _, attachment := range attachments {
+			attachment.Name = filepath.Base(attachment.Name)
+			if _, err := noteDB.AddAttachment(attachment); err != nil {
+				return fmt.Errorf("Error while attaching %v, error msg: %w", attachment.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transformAttachments2JSONAttachments(attachments), nil
+}
+
+func listAttachments(noteID int64) ([]*jsonAttachment, error) {
+	attachments, err := NoteDB.GetAttachments(noteID)
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving attachments, error msg: %w", err)
+	}
+	return transformAttachments2JSONAttachments(attachments), nil
+}
+
+func retrieveAttachment(id int64) (*model.Attachment, error) {
+	attachment, err := NoteDB.GetAttachment(id)
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving attachment, error msg: %w", err)
+	}
+	return attachment, nil
+}
+
+//extract saves the attachments to dir and returns the paths of the saved files, it stops at the first attachment
+//that can not be saved. Existing files are never overwritten.
+func extract(ids []int64, dir string) ([]string, error) {
+	paths := []string{}
+	for _, id := range ids {
+		attachment, err := retrieveAttachment(id)
+		if err != nil {
+			return paths, err
+		}
+		path := filepath.Join(dir, filepath.Base(attachment.Name))
+		if err := writeNewFile(path, attachment.Content); err != nil {
+			return paths, fmt.Errorf("Error while saving attachment %d, error msg: %w", id, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+//writeNewFile writes content to a file at path, it fails if the file exists.
+func writeNewFile(path string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func transformAttachments2JSONAttachments(attachments []*model.Attachment) []*jsonAttachment {
+	jAttachments := make([]*jsonAttachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		jAttachments = append(jAttachments, &jsonAttachment{
+			ID:      attachment.ID,
+			NoteID:  attachment.NoteID,
+			Name:    attachment.Name,
+			Hash:    attachment.Hash,
+			Size:    attachment.Size,
+			Created: attachment.Created,
+		})
+	}
+	return jAttachments
+}
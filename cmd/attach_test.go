@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAttachFiles(t *testing.T) {
+	defer useMemoryStore(t, model.NewNote("title", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{}))()
+
+	fsr := fakeFileSystemReader{rawBytes: []byte("content")}
+	jAttachments, err := attachFiles(fsr, 1, []string{"logs/app.log", "screenshot.png"})
+	if err != nil {
+		t.Fatalf("Could not attach files, error msg: %v", err)
+	}
+	if len(jAttachments) != 2 || jAttachments[0].Name != "app.log" || jAttachments[1].Name != "screenshot.png" {
+		t.Fatalf("Unexpected attachments: %v", jAttachments)
+	}
+	if _, err := attachFiles(fsr, 42, []string{"app.log"}); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got: %v", err)
+	}
+	fsr = fakeFileSystemReader{err: errors.New("Unexpected error")}
+	if _, err := attachFiles(fsr, 1, []string{"app.log"}); err == nil {
+		t.Errorf("Expected an error for a file that can not be read")
+	}
+
+	listed, err := listAttachments(1)
+	if err != nil || len(listed) != 2 || listed[0].Size != 7 || listed[0].Content != nil {
+		t.Errorf("Unexpected attachments: %v, error msg: %v", listed, err)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	defer useMemoryStore(t, model.NewNote("title", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{}))()
+	NoteDB.AddAttachment(model.NewAttachment(1, "app.log", []byte("line 1")))
+	dir, err := ioutil.TempDir("", "tefter")
+	if err != nil {
+		t.Fatalf("Could not create directory, error msg: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	paths, err := extract([]int64{1}, dir)
+	if err != nil || len(paths) != 1 || paths[0] != filepath.Join(dir, "app.log") {
+		t.Fatalf("Unexpected paths: %v, error msg: %v", paths, err)
+	}
+	if content, _ := ioutil.ReadFile(paths[0]); string(content) != "line 1" {
+		t.Errorf("Unexpected content of extracted file: %q", content)
+	}
+	if _, err := extract([]int64{1}, dir); !os.IsExist(errors.Unwrap(err)) {
+		t.Errorf("Existing file should not be overwritten, got: %v", err)
+	}
+	if _, err := extract([]int64{42}, dir); !errors.Is(err, repository.ErrAttachmentNotFound) {
+		t.Errorf("Expected ErrAttachmentNotFound, got: %v", err)
+	}
+}
+
+func TestExportImportAttachments(t *testing.T) {
+	restore := useMemoryStore(t, model.NewNote("title", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{}))
+	defer func() {
+		restore()
+		os.Remove("notes.json")
+	}()
+	NoteDB.AddAttachment(model.NewAttachment(1, "app.log", []byte("line 1")))
+	NoteDB.AddAttachment(model.NewAttachment(1, "empty.txt", []byte{}))
+	if err := export([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{}, exportOptions{Format: exportFormatJSON}); err != nil {
+		t.Fatalf("Could not export notes, error msg: %v", err)
+	}
+
+	defer useMemoryStore(t)()
+	if _, err := importNotes(fileSystemReader{}, "notes.json", defaultImportOptions); err != nil {
+		t.Fatalf("Could not import notes, error msg: %v", err)
+	}
+	attachments, err := NoteDB.GetAttachments(1)
+	if err != nil || len(attachments) != 2 || attachments[0].Name != "app.log" || attachments[1].Size != 0 {
+		t.Fatalf("Unexpected imported attachments: %v, error msg: %v", attachments, err)
+	}
+	if attachment, _ := NoteDB.GetAttachment(attachments[0].ID); string(attachment.Content) != "line 1" {
+		t.Errorf("Unexpected content of imported attachment: %q", attachment.Content)
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/nicolasmanic/tefter/config"
+	"github.com/nicolasmanic/tefter/model"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetConfig(t *testing.T) {
+	originalConfig := Config
+	Config = config.Default()
+	dir, _ := ioutil.TempDir("", "tefter")
+	defer func() {
+		Config = originalConfig
+		os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "config.yaml")
+
+	if err := setConfig(path, "editor", "nano"); err != nil {
+		t.Errorf("Could not set config, error msg: %v", err)
+	}
+	saved, _ := config.Load(path)
+	if saved.Editor != "nano" {
+		t.Error("Could not persist config")
+	}
+	if err := setConfig(path, "unknown", "value"); err == nil {
+		t.Error("Setting unknown key should fail")
+	}
+}
+
+func TestListConfig(t *testing.T) {
+	var out bytes.Buffer
+	listConfig(&out)
+	for _, key := range config.Keys() {
+		if !strings.Contains(out.String(), key+" = ") {
+			t.Errorf("Expected key %v to be listed", key)
+		}
+	}
+}
+
+func TestAddNotebookToNoteUsesDefaultNotebook(t *testing.T) {
+	originalConfig := Config
+	oldNotebookDB := NotebookDB
+	Config = config.Default()
+	Config.DefaultNotebook = "inbox"
+	NotebookDB = mockNotebookDBAdd{
+		notebook: &model.Notebook{ID: 5, Title: "inbox"},
+	}
+	defer func() {
+		Config = originalConfig
+		NotebookDB = oldNotebookDB
+	}()
+
+	note := model.NewNote("title", "memo", 1, []string{})
+	if err := addNotebookToNote(NotebookDB, note, ""); err != nil {
+		t.Errorf("Could not add notebook to note, error msg: %v", err)
+	}
+	if note.NotebookID != 5 {
+		t.Error("Note should have been inserted to the default_notebook of config")
+	}
+}
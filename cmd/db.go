@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nicolasmanic/tefter/repository"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+var (
+	//MigrationDB exposed the available actions for the DB schema.
+	MigrationDB repository.Migrator
+
+	dbCmd = &cobra.Command{
+		Use:   "db",
+		Short: "Migrate/Rollback/Print DB schema version",
+		Long: "Every tefter command migrates the DB to the latest schema version before running,\n" +
+			"the db commands allow managing the schema explicitly, eg: rolling back before\n" +
+			"downgrading to an older tefter version.",
+		//Overrides the root PersistentPreRun so that the DB is not migrated implicitly.
+		PersistentPreRun: connectMigrator,
+	}
+	migrateDBCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply all pending migrations",
+		Args:  cobra.NoArgs,
+		Run:   migrateDBWrapper,
+	}
+	statusDBCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show applied and pending migrations",
+		Args:  cobra.NoArgs,
+		Run:   statusDBWrapper,
+	}
+	rollbackDBCmd = &cobra.Command{
+		Use:   "rollback",
+		Short: "Revert the latest applied migration",
+		Long: "Revert the latest applied migration. Rolling back migrations that delete data, eg: the initial schema\n" +
+			"deletes every note, note revisions the history of notes and attachments every attached file, is refused\n" +
+			"unless --force is set and confirmed. Migrations that can not be undone, eg: normalize tags, are only rolled\n" +
+			"back with --force, their changes are kept.",
+		Args: cobra.NoArgs,
+		Run:  rollbackDBWrapper,
+	}
+)
+
+func init() {
+	dbCmd.AddCommand(migrateDBCmd)
+	dbCmd.AddCommand(statusDBCmd)
+	dbCmd.AddCommand(rollbackDBCmd)
+	rollbackDBCmd.Flags().Bool("force", false, "Roll back the initial schema, deleting every note, and irreversible migrations")
+	rootCmd.AddCommand(dbCmd)
+}
+
+func connectMigrator(cmd *cobra.Command, args []string) {
+	initDB()
+	if MigrationDB != nil {
+		return
+	}
+	if Config.DSN != "" {
+		MigrationDB = repository.NewPostgresMigrator(Config.DSN)
+	} else {
+		MigrationDB = repository.NewMigrator(dbPath)
+	}
+}
+
+func migrateDBWrapper(cmd *cobra.Command, args []string) {
+	version, err := migrateDB()
+	if err != nil {
+		exitWithError(err)
+	}
+	fmt.Printf("DB is at version: %d\n", version)
+}
+
+func rollbackDBWrapper(cmd *cobra.Command, args []string) {
+	force, _ := cmd.Flags().GetBool("force")
+	version, err := rollbackDB(force, os.Stdin)
+	if err != nil {
+		exitWithError(err)
+	}
+	fmt.Printf("DB rolled back to version: %d\n", version)
+}
+
+func statusDBWrapper(cmd *cobra.Command, args []string) {
+	if err := printDBStatus(os.Stdout); err != nil {
+		exitWithError(err)
+	}
+}
+
+func migrateDB() (int, error) {
+	version, err := MigrationDB.Migrate()
+	if err != nil {
+		return version, fmt.Errorf("Error while migrating DB, error msg: %w", err)
+	}
+	return version, nil
+}
+
+//rollbackDB reverts the latest applied migration, a rollback deleting every note needs force and a confirmation
+//read from input. Rolling back past an irreversible migration needs force.
+func rollbackDB(force bool, input io.Reader) (int, error) {
+	version, err := MigrationDB.Rollback(false)
+	if errors.Is(err, repository.ErrDestructiveRollback) {
+		if !force {
+			return version, fmt.Errorf("Error while rolling back DB, use --force to roll back anyway, error msg: %w", err)
+		}
+		if !confirm(input, fmt.Sprintf("%v, type yes to continue: ", err)) {
+			return version, errors.New("Rollback aborted")
+		}
+		version, err = MigrationDB.Rollback(true)
+	} else if errors.Is(err, repository.ErrIrreversibleMigration) {
+		if !force {
+			return version, fmt.Errorf("Error while rolling back DB, use --force to roll back keeping its changes, error msg: %w", err)
+		}
+		version, err = MigrationDB.Rollback(true)
+	}
+	if err != nil {
+		return version, fmt.Errorf("Error while rolling back DB, error msg: %w", err)
+	}
+	return version, nil
+}
+
+func printDBStatus(w io.Writer) error {
+	statuses, err := MigrationDB.Status()
+	if err != nil {
+		return fmt.Errorf("Error while retrieving DB status, error msg: %w", err)
+	}
+	version := 0
+	pending := 0
+	for _, status := range statuses {
+		if status.Applied {
+			version = status.Version
+		} else {
+			pending++
+		}
+	}
+	fmt.Fprintf(w, "> Current version: %d\n", version)
+	fmt.Fprintf(w, "> Pending migrations: %d\n", pending)
+	for _, status := range statuses {
+		state := "pending"
+		if status.Applied {
+			state = "applied " + status.AppliedAt.Local().Format("Jan 2 2006 15:04")
+		}
+		fmt.Fprintf(w, " - %d %v (%v)\n", status.Version, status.Description, state)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/nicolasmanic/tefter/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintDBStatus(t *testing.T) {
+	originalMigrationDB := MigrationDB
+	MigrationDB = mockMigrationDB{
+		statuses: []repository.MigrationStatus{
+			{Version: 1, Description: "initial schema", Applied: true, AppliedAt: time.Now()},
+			{Version: 2, Description: "next schema", Applied: false},
+		},
+	}
+	defer func() {
+		MigrationDB = originalMigrationDB
+	}()
+
+	var out bytes.Buffer
+	if err := printDBStatus(&out); err != nil {
+		t.Errorf("Could not print DB status, error msg: %v", err)
+	}
+	if !strings.Contains(out.String(), "> Current version: 1") {
+		t.Errorf("Expected current version 1, got: %v", out.String())
+	}
+	if !strings.Contains(out.String(), "> Pending migrations: 1") {
+		t.Errorf("Expected 1 pending migration, got: %v", out.String())
+	}
+}
+
+func TestMigrateAndRollbackDB(t *testing.T) {
+	cases := []struct {
+		migrationDB mockMigrationDB
+		expectedErr error
+	}{
+		{
+			migrationDB: mockMigrationDB{version: 1},
+			expectedErr: nil,
+		}, {
+			migrationDB: mockMigrationDB{err: errors.New("Unexpected error")},
+			expectedErr: errors.New("Error while migrating DB, error msg: Unexpected error"),
+		},
+	}
+
+	for _, c := range cases {
+		originalMigrationDB := MigrationDB
+		MigrationDB = c.migrationDB
+		defer func() {
+			MigrationDB = originalMigrationDB
+		}()
+
+		_, err := migrateDB()
+		if !sameError(c.expectedErr, err) {
+			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
+		}
+		_, err = rollbackDB(false, strings.NewReader(""))
+		if (err == nil) != (c.expectedErr == nil) {
+			t.Errorf("Expected rollback err to be %q but it was %q", c.expectedErr, err)
+		}
+	}
+}
+
+func TestRollbackInitialSchema(t *testing.T) {
+	originalMigrationDB := MigrationDB
+	MigrationDB = mockMigrationDB{version: 1, destructive: true}
+	defer func() {
+		MigrationDB = originalMigrationDB
+	}()
+
+	if version, err := rollbackDB(false, strings.NewReader("yes\n")); !errors.Is(err, repository.ErrDestructiveRollback) || version != 1 {
+		t.Errorf("Expected rollback of the initial schema to be refused without force, got version: %d, error msg: %v", version, err)
+	}
+	if version, err := rollbackDB(true, strings.NewReader("no\n")); err == nil || version != 1 {
+		t.Errorf("Expected rollback of the initial schema to be aborted without confirmation, got version: %d, error msg: %v", version, err)
+	}
+	if version, err := rollbackDB(true, strings.NewReader("yes\n")); err != nil || version != 0 {
+		t.Errorf("Expected forced rollback to version 0, got version: %d, error msg: %v", version, err)
+	}
+}
+
+func TestRollbackIrreversibleMigration(t *testing.T) {
+	originalMigrationDB := MigrationDB
+	MigrationDB = mockMigrationDB{version: 6, irreversible: true}
+	defer func() {
+		MigrationDB = originalMigrationDB
+	}()
+
+	if version, err := rollbackDB(false, strings.NewReader("")); !errors.Is(err, repository.ErrIrreversibleMigration) || version != 6 {
+		t.Errorf("Expected rollback of an irreversible migration to stop without force, got version: %d, error msg: %v", version, err)
+	}
+	if version, err := rollbackDB(true, strings.NewReader("")); err != nil || version != 5 {
+		t.Errorf("Expected forced rollback to version 5, got version: %d, error msg: %v", version, err)
+	}
+}
+
+type mockMigrationDB struct {
+	repository.Migrator
+	statuses     []repository.MigrationStatus
+	version      int
+	destructive  bool
+	irreversible bool
+	err          error
+}
+
+func (mDB mockMigrationDB) Migrate() (int, error) {
+	return mDB.version, mDB.err
+}
+
+func (mDB mockMigrationDB) Rollback(force bool) (int, error) {
+	if mDB.destructive && !force {
+		return mDB.version, fmt.Errorf("Rolling back migration 1 (initial schema) deletes every note of the DB, error msg: %w", repository.ErrDestructiveRollback)
+	}
+	if mDB.irreversible && !force {
+		return mDB.version, fmt.Errorf("Migration 6 (normalize tags) can not be undone, error msg: %w", repository.ErrIrreversibleMigration)
+	}
+	if mDB.destructive || mDB.irreversible {
+		return mDB.version - 1, nil
+	}
+	return mDB.version, mDB.err
+}
+
+func (mDB mockMigrationDB) Status() ([]repository.MigrationStatus, error) {
+	return mDB.statuses, mDB.err
+}
@@ -3,13 +3,13 @@ package cmd
 import (
 	"fmt"
 	"github.com/spf13/cobra"
-	"log"
 	"strconv"
 )
 
 var deleteNoteCmd = &cobra.Command{
 	Use:     "delete",
 	Short:   "Delete one or more notes based on ID(s)",
+	Long:    "Delete one or more notes based on ID(s), deleted notes are moved to the trash and can be restored with trash restore.",
 	Args:    cobra.MinimumNArgs(1),
 	Example: "delete 1,2,...",
 	Run:     deleteWrapper,
@@ -21,7 +21,7 @@ func init() {
 
 func deleteWrapper(cmd *cobra.Command, args []string) {
 	if err := deleteArgs(args); err != nil {
-		log.Fatalln(err)
+		exitWithError(err)
 	}
 }
 
@@ -41,7 +41,7 @@ func deleteArgs(args []string) error {
 func delete(ids []int64) error {
 	err := NoteDB.DeleteNotes(ids)
 	if err != nil {
-		return fmt.Errorf("Error while deleting notes, error msg: %v", err)
+		return fmt.Errorf("Error while deleting notes, error msg: %w", err)
 	}
 	return nil
 }
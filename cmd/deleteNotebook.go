@@ -3,39 +3,104 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
 	"github.com/spf13/cobra"
-	"log"
 )
 
 var deleteNotebooksCmd = &cobra.Command{
-	Use:     "deleteNotebook",
-	Short:   "Delete one or more notebooks based on title",
-	Example: "deleteNotebook notebook notebook2...",
+	Use:   "deleteNotebook",
+	Short: "Delete one or more notebooks based on title",
+	Long: "Delete one or more notebooks based on title, deleted notebooks are moved to the trash along with their notes\n" +
+		"and can be restored with trash restore -n.\n" +
+		"Notebooks with child notebooks are deleted only with --cascade, which deletes the children too,\n" +
+		"or with --reparent, which moves the children under the parent of the deleted notebook.",
+	Example: "deleteNotebook notebook notebook2...\ndeleteNotebook -c work/infra",
 	Args:    cobra.MinimumNArgs(1),
 	Run:     deleteNotebooksWrapper,
 }
 
 func deleteNotebooksWrapper(cmd *cobra.Command, args []string) {
-	if err := deleteNotebooks(args); err != nil {
-		log.Fatalln(err)
+	cascade, _ := cmd.Flags().GetBool("cascade")
+	reparent, _ := cmd.Flags().GetBool("reparent")
+	if err := deleteNotebooks(args, cascade, reparent); err != nil {
+		exitWithError(err)
 	}
 }
 
-func deleteNotebooks(titles []string) error {
+//deleteNotebooks moves the notebooks with the given titles to the trash. With cascade their child notebooks
+//are deleted too, with reparent the children are moved under the parent of the deleted notebook.
+func deleteNotebooks(titles []string, cascade, reparent bool) error {
 	if len(titles) <= 0 {
 		return errors.New("No argument passed, at least one notebook title should be provided")
 	}
+	if cascade && reparent {
+		return errors.New("Only one of cascade and reparent can be set")
+	}
+
+	//All notebooks are deleted in one transaction, if one of them fails none is deleted.
+	return withTx(func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+		deleted := make(map[int64]*model.Notebook, len(titles))
+		ids := make([]int64, 0, len(titles))
+		for _, notebookTitle := range titles {
+			notebook, err := notebookDB.GetNotebookByTitle(notebookTitle)
+			if err != nil {
+				return fmt.Errorf("Could not retrieve notebook for title: %v error msg: %w", notebookTitle, err)
+			}
+			if notebook == nil {
+				return fmt.Errorf("Could not retrieve notebook for title: %v error msg: %w", notebookTitle, repository.ErrNotebookNotFound)
+			}
+			if _, ok := deleted[notebook.ID]; !ok {
+				deleted[notebook.ID] = notebook
+				ids = append(ids, notebook.ID)
+			}
+		}
+		if cascade {
+			paths, err := notebookDB.GetAllNotebooksTitle()
+			if err != nil {
+				return fmt.Errorf("Could not retrieve notebook paths, error msg: %w", err)
+			}
+			ids = append(ids, descendantsByPath(paths, ids)...)
+		}
+		if reparent {
+			notebooks, err := notebookDB.GetNotebooks([]int64{})
+			if err != nil {
+				return fmt.Errorf("Could not retrieve notebooks, error msg: %w", err)
+			}
+			if err := reparentChildren(notebookDB, notebooks, deleted); err != nil {
+				return err
+			}
+		}
+		if err := notebookDB.DeleteNotebooks(ids); err != nil {
+			return fmt.Errorf("Could not delete notebooks with titles: %v error msg: %w", titles, err)
+		}
+		return nil
+	})
+}
 
-	for _, notebookTitle := range titles {
-		notebook, err := NotebookDB.GetNotebookByTitle(notebookTitle)
-		if err != nil || notebook == nil {
-			return fmt.Errorf("Could not retrieve notebook for title: %v error msg: %v", notebookTitle, err)
+//reparentChildren moves the children of the deleted notebooks under their closest ancestor that is not deleted.
+func reparentChildren(notebookDB repository.NotebookRepository, notebooks []*model.Notebook, deleted map[int64]*model.Notebook) error {
+	for _, notebook := range notebooks {
+		if _, ok := deleted[notebook.ID]; ok {
+			continue
+		}
+		parent, ok := deleted[notebook.ParentID]
+		if !ok {
+			continue
+		}
+		for ok {
+			notebook.ParentID = parent.ParentID
+			parent, ok = deleted[notebook.ParentID]
+		}
+		if err := notebookDB.UpdateNotebook(notebook); err != nil {
+			return fmt.Errorf("Could not move notebook: %v error msg: %w", notebook.Title, err)
 		}
-		NotebookDB.DeleteNotebook(notebook.ID)
 	}
 	return nil
 }
 
 func init() {
+	deleteNotebooksCmd.Flags().BoolP("cascade", "c", false, "Delete the child notebooks along with their notes too")
+	deleteNotebooksCmd.Flags().BoolP("reparent", "r", false, "Move the child notebooks under the parent of the deleted notebook")
 	rootCmd.AddCommand(deleteNotebooksCmd)
 }
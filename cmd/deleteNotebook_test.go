@@ -4,7 +4,6 @@ import (
 	"errors"
 	"github.com/nicolasmanic/tefter/model"
 	"github.com/nicolasmanic/tefter/repository"
-	"reflect"
 	"testing"
 )
 
@@ -13,6 +12,8 @@ func TestDeleteNotebooks(t *testing.T) {
 		mDB         mockNotebookDBDelete
 		expectedErr error
 		titles      []string
+		cascade     bool
+		reparent    bool
 	}{
 		{
 			mDB: mockNotebookDBDelete{
@@ -32,6 +33,12 @@ func TestDeleteNotebooks(t *testing.T) {
 			},
 			expectedErr: errors.New("Could not retrieve notebook for title: title1 error msg: Unexpected error"),
 			titles:      []string{"title1"},
+		}, {
+			mDB:         mockNotebookDBDelete{},
+			expectedErr: errors.New("Only one of cascade and reparent can be set"),
+			titles:      []string{"title1"},
+			cascade:     true,
+			reparent:    true,
 		},
 	}
 
@@ -42,20 +49,66 @@ func TestDeleteNotebooks(t *testing.T) {
 			NotebookDB = oldNotebookDB
 		}()
 
-		err := deleteNotebooks(c.titles)
-		if !reflect.DeepEqual(err, c.expectedErr) {
+		err := deleteNotebooks(c.titles, c.cascade, c.reparent)
+		if !sameError(c.expectedErr, err) {
 			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
 		}
 	}
 }
 
+func TestDeleteNotebooksWithChildren(t *testing.T) {
+	cases := []struct {
+		cascade       bool
+		reparent      bool
+		expectedErr   error
+		expectedPaths []string
+	}{
+		{
+			expectedErr:   repository.ErrNotebookHasChildren,
+			expectedPaths: []string{"Default Notebook", "work", "work/infra", "work/infra/k8s"},
+		}, {
+			cascade:       true,
+			expectedPaths: []string{"Default Notebook", "work"},
+		}, {
+			reparent:      true,
+			expectedPaths: []string{"Default Notebook", "work", "work/k8s"},
+		},
+	}
+
+	for _, c := range cases {
+		oldStore, oldNoteDB, oldNotebookDB := Store, NoteDB, NotebookDB
+		Store = repository.NewMemoryStore()
+		NoteDB, NotebookDB = Store.Notes(), Store.Notebooks()
+		defer func() {
+			Store, NoteDB, NotebookDB = oldStore, oldNoteDB, oldNotebookDB
+		}()
+		if _, err := createNotebookPath(NotebookDB, "work/infra/k8s"); err != nil {
+			t.Fatalf("Could not create notebooks, error msg: %v", err)
+		}
+
+		err := deleteNotebooks([]string{"work/infra"}, c.cascade, c.reparent)
+		if !errors.Is(err, c.expectedErr) {
+			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
+		}
+		paths, _ := NotebookDB.GetAllNotebooksTitle()
+		if len(paths) != len(c.expectedPaths) {
+			t.Errorf("Expected notebooks %v, got: %v", c.expectedPaths, paths)
+		}
+		for _, path := range c.expectedPaths {
+			if notebook, _ := NotebookDB.GetNotebookByTitle(path); notebook == nil {
+				t.Errorf("Expected notebook %v to exist, got: %v", path, paths)
+			}
+		}
+	}
+}
+
 type mockNotebookDBDelete struct {
 	repository.NotebookRepository
 	notebook *model.Notebook
 	err      error
 }
 
-func (mDB mockNotebookDBDelete) DeleteNotebook(notebooksID int64) error {
+func (mDB mockNotebookDBDelete) DeleteNotebooks(notebooksIDs []int64) error {
 	return mDB.err
 }
 
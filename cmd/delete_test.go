@@ -3,7 +3,6 @@ package cmd
 import (
 	"errors"
 	"github.com/nicolasmanic/tefter/repository"
-	"reflect"
 	"testing"
 )
 
@@ -47,7 +46,7 @@ func TestDeleteArgs(t *testing.T) {
 		}()
 
 		err := deleteArgs(c.args)
-		if !reflect.DeepEqual(c.expectedErr, err) {
+		if !sameError(c.expectedErr, err) {
 			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
 		}
 	}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/spf13/cobra"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//diffContext is the number of unchanged lines around the changes of a hunk
+const diffContext = 3
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show the changes to the memo of a note between two revisions",
+	Long: "Show a unified diff of the memo of a note between two revisions, see history for the revisions of a note.\n" +
+		"Without revisions the latest change is shown, with a single revision the changes since that revision are shown.",
+	Example: "diff 1\n" +
+		"diff 1 2\n" +
+		"diff 1 2 4",
+	Args: cobra.RangeArgs(1, 3),
+	Run:  diffWrapper,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
+
+func diffWrapper(cmd *cobra.Command, args []string) {
+	diff, err := diffArgs(args)
+	if err != nil {
+		exitWithError(err)
+	}
+	if diff == "" {
+		fmt.Println("No changes")
+		return
+	}
+	fmt.Print(diff)
+}
+
+func diffArgs(args []string) (string, error) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("ID could not be converted to integer, error msg: %w", err)
+	}
+	revisions := make([]int, 0, 2)
+	for _, argument := range args[1:] {
+		revision, err := strconv.Atoi(argument)
+		if err != nil {
+			return "", fmt.Errorf("Revision could not be converted to integer, error msg: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	from, to := 0, 0
+	if len(revisions) > 0 {
+		from = revisions[0]
+	}
+	if len(revisions) > 1 {
+		to = revisions[1]
+	}
+	return diffRevisions(id, from, to)
+}
+
+//diffRevisions returns the unified diff of the memos of revisions from and to of the note. If to is 0 the
+//latest revision is used, if from is 0 the revision before to is used.
+func diffRevisions(id int64, from, to int) (string, error) {
+	if to == 0 {
+		revisions, err := NoteDB.GetRevisions(id)
+		if err != nil {
+			return "", fmt.Errorf("Error while retrieving revisions, error msg: %w", err)
+		}
+		to = revisions[len(revisions)-1].Number
+	}
+	if from == 0 {
+		from = maxInt(1, to-1)
+	}
+	fromRevision, err := NoteDB.GetRevision(id, from)
+	if err != nil {
+		return "", fmt.Errorf("Error while retrieving revision, error msg: %w", err)
+	}
+	toRevision, err := NoteDB.GetRevision(id, to)
+	if err != nil {
+		return "", fmt.Errorf("Error while retrieving revision, error msg: %w", err)
+	}
+	return unifiedDiff(revisionLabel(fromRevision), revisionLabel(toRevision), fromRevision.Memo, toRevision.Memo), nil
+}
+
+func revisionLabel(revision *model.Revision) string {
+	return fmt.Sprintf("revision %d\t%s", revision.Number, revision.Created.Format(time.RFC3339))
+}
+
+//diffLine is a line of a diff, kind is ' ' for unchanged, '-' for removed and '+' for added lines.
+//oldLine and newLine are the 0 based numbers of the line, or of the next line, in the old and new text.
+type diffLine struct {
+	kind             byte
+	text             string
+	oldLine, newLine int
+}
+
+//unifiedDiff returns the changes from text a to text b in the unified format of diff -u, with fromName and
+//toName as file names. The diff of equal texts is empty.
+func unifiedDiff(fromName, toName, a, b string) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+	var diff strings.Builder
+	for i := 0; i < len(lines); {
+		if lines[i].kind == ' ' {
+			i++
+			continue
+		}
+		//extend the hunk while the next change is close enough for their context to overlap
+		start, last := maxInt(0, i-diffContext), i
+		for j := i; j < len(lines) && j-last <= 2*diffContext+1; j++ {
+			if lines[j].kind != ' ' {
+				last = j
+			}
+		}
+		end := minInt(len(lines), last+diffContext+1)
+		if diff.Len() == 0 {
+			fmt.Fprintf(&diff, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		writeHunk(&diff, lines[start:end])
+		i = end
+	}
+	return diff.String()
+}
+
+func writeHunk(diff *strings.Builder, lines []diffLine) {
+	oldCount, newCount := 0, 0
+	for _, line := range lines {
+		if line.kind != '+' {
+			oldCount++
+		}
+		if line.kind != '-' {
+			newCount++
+		}
+	}
+	fmt.Fprintf(diff, "@@ -%s +%s @@\n", hunkRange(lines[0].oldLine, oldCount), hunkRange(lines[0].newLine, newCount))
+	for _, line := range lines {
+		diff.WriteByte(line.kind)
+		diff.WriteString(line.text)
+		diff.WriteByte('\n')
+	}
+}
+
+//hunkRange returns the range of a hunk as diff does, the start line of an empty range is the line before it.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return strconv.Itoa(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+//diffLines returns the lines of a and b as unchanged, removed or added lines, unchanged lines are a longest
+//common subsequence of a and b.
+func diffLines(a, b []string) []diffLine {
+	//common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = maxInt(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	lines := make([]diffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+	return lines
+}
+
+//splitLines splits text to lines, a trailing new line doesn't start an empty line.
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected string
+	}{
+		{
+			a:        "same\n",
+			b:        "same\n",
+			expected: "",
+		}, {
+			a: "",
+			b: "first\nsecond\n",
+			expected: "--- a\n+++ b\n" +
+				"@@ -0,0 +1,2 @@\n" +
+				"+first\n" +
+				"+second\n",
+		}, {
+			a: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b: "1\n2\n3\n4\nfive\n6\n7\n8\n",
+			expected: "--- a\n+++ b\n" +
+				"@@ -2,7 +2,7 @@\n" +
+				" 2\n 3\n 4\n" +
+				"-5\n" +
+				"+five\n" +
+				" 6\n 7\n 8\n",
+		}, {
+			a: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b: "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			expected: "--- a\n+++ b\n" +
+				"@@ -1,4 +1,4 @@\n" +
+				"-1\n" +
+				"+one\n" +
+				" 2\n 3\n 4\n" +
+				"@@ -9,4 +9,3 @@\n" +
+				" 9\n 10\n 11\n" +
+				"-12\n",
+		}, {
+			//changes 6 lines apart share a hunk
+			a: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b: "one\n2\n3\n4\n5\n6\n7\neight\n",
+			expected: "--- a\n+++ b\n" +
+				"@@ -1,8 +1,8 @@\n" +
+				"-1\n" +
+				"+one\n" +
+				" 2\n 3\n 4\n 5\n 6\n 7\n" +
+				"-8\n" +
+				"+eight\n",
+		},
+	}
+	for _, c := range cases {
+		if diff := unifiedDiff("a", "b", c.a, c.b); diff != c.expected {
+			t.Errorf("Expected diff of %q and %q to be:\n%v\nbut it was:\n%v", c.a, c.b, c.expected, diff)
+		}
+	}
+}
+
+func TestDiffArgs(t *testing.T) {
+	revisions := []*model.Revision{
+		{NoteID: 1, Number: 1, Memo: "first\n"},
+		{NoteID: 1, Number: 2, Memo: "second\n"},
+		{NoteID: 1, Number: 3, Memo: "third\n"},
+	}
+	cases := []struct {
+		args         []string
+		expectedDiff string
+		expectedErr  error
+	}{
+		{args: []string{"1"}, expectedDiff: "-second\n+third\n"},
+		{args: []string{"1", "1"}, expectedDiff: "-first\n+third\n"},
+		{args: []string{"1", "3", "1"}, expectedDiff: "-third\n+first\n"},
+		{args: []string{"1", "4"}, expectedErr: repository.ErrRevisionNotFound},
+		{args: []string{"2"}, expectedErr: repository.ErrNoteNotFound},
+		{args: []string{"a"}, expectedErr: errors.New("ID could not be converted to integer, error msg: strconv.ParseInt: parsing \"a\": invalid syntax")},
+		{args: []string{"1", "b"}, expectedErr: errors.New("Revision could not be converted to integer, error msg: strconv.Atoi: parsing \"b\": invalid syntax")},
+	}
+
+	oldNoteDB := NoteDB
+	NoteDB = mockNoteDBRevisions{revisions: revisions}
+	defer func() {
+		NoteDB = oldNoteDB
+	}()
+	for _, c := range cases {
+		diff, err := diffArgs(c.args)
+		if c.expectedErr != nil {
+			if !errors.Is(err, c.expectedErr) && !sameError(c.expectedErr, err) {
+				t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Could not diff revisions, error msg: %v", err)
+		}
+		if !strings.HasSuffix(diff, c.expectedDiff) {
+			t.Errorf("Expected diff of %v to end with %q but it was %q", c.args, c.expectedDiff, diff)
+		}
+	}
+}
+
+//mockNoteDBRevisions keeps the revisions of note 1.
+type mockNoteDBRevisions struct {
+	repository.NoteRepository
+	revisions []*model.Revision
+}
+
+func (mDB mockNoteDBRevisions) GetRevisions(noteID int64) ([]*model.Revision, error) {
+	if noteID != 1 {
+		return nil, repository.ErrNoteNotFound
+	}
+	return mDB.revisions, nil
+}
+
+func (mDB mockNoteDBRevisions) GetRevision(noteID int64, revision int) (*model.Revision, error) {
+	revisions, err := mDB.GetRevisions(noteID)
+	if err != nil {
+		return nil, err
+	}
+	if revision < 1 || revision > len(revisions) {
+		return nil, repository.ErrRevisionNotFound
+	}
+	return revisions[revision-1], nil
+}
+
+func (mDB mockNoteDBRevisions) RestoreRevision(noteID int64, revision int) (*model.Note, error) {
+	restored, err := mDB.GetRevision(noteID, revision)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Note{ID: noteID, Title: restored.Title, Memo: restored.Memo, LastUpdated: time.Now()}, nil
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh/terminal"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+//Editor interface
+type Editor interface {
+	edit(text string) (string, error)
+}
+
+//commandEditor opens the text in an external editor program, eg: vim or "code --wait".
+type commandEditor struct {
+	command   string
+	args      []string
+	extension string
+}
+
+//StdinEditor is a non interactive Editor, the memo is read from the input instead of being edited.
+//It allows piping memos to tefter, eg: echo "memo" | tefter add -t title
+type StdinEditor struct {
+	input io.Reader
+	//keepOnEmpty keeps the edited text if the input is empty, eg: updating a note without piping a memo
+	keepOnEmpty bool
+}
+
+//unchangedEditor is a non interactive Editor that keeps the text as is.
+type unchangedEditor struct{}
+
+//newEditor returns the Editor for the current session. If stdin is not a terminal the memo is read
+//from stdin, otherwise the text is edited with the editor of resolveEditorCommand.
+func newEditor() (Editor, error) {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return &StdinEditor{input: os.Stdin}, nil
+	}
+	return newCommandEditor(resolveEditorCommand(), Config.EditorExtension)
+}
+
+//newUpdateEditor returns the Editor of an existing memo. If readStdin is set the memo is read from stdin, otherwise
+//it is edited with the editor of resolveEditorCommand when stdin is a terminal. A memo piped or redirected from a
+//file replaces the memo unless it is empty, any other stdin, eg: /dev/null of cron jobs, keeps the memo.
+func newUpdateEditor(stdin *os.File, readStdin bool) (Editor, error) {
+	if readStdin {
+		return &StdinEditor{input: stdin}, nil
+	}
+	if terminal.IsTerminal(int(stdin.Fd())) {
+		return newCommandEditor(resolveEditorCommand(), Config.EditorExtension)
+	}
+	info, err := stdin.Stat()
+	if err == nil && (info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular()) {
+		return &StdinEditor{input: stdin, keepOnEmpty: true}, nil
+	}
+	return unchangedEditor{}, nil
+}
+
+//resolveEditorCommand returns the editor command, in order of precedence:
+//the editor of the config file, $VISUAL, $EDITOR and finally vim.
+func resolveEditorCommand() string {
+	for _, editor := range []string{Config.Editor, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if strings.TrimSpace(editor) != "" {
+			return editor
+		}
+	}
+	return "vim"
+}
+
+func newCommandEditor(command, extension string) (*commandEditor, error) {
+	fields, err := splitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("Editor command is empty")
+	}
+	if extension != "" && !strings.HasPrefix(extension, ".") {
+		extension = "." + extension
+	}
+	return &commandEditor{
+		command:   fields[0],
+		args:      fields[1:],
+		extension: extension,
+	}, nil
+}
+
+func (ce commandEditor) edit(text string) (string, error) {
+	path, err := exec.LookPath(ce.command)
+	if err != nil {
+		return "", fmt.Errorf("Could not find editor: %v, set $EDITOR or run 'tefter config set editor <command>'", ce.command)
+	}
+
+	//Every session gets its own tmp file so that concurrent sessions do not overwrite each other.
+	f, err := ioutil.TempFile("", "tefter-*"+ce.extension)
+	if err != nil {
+		return "", fmt.Errorf("Could not create tmp file for memo, error msg: %v", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, strings.NewReader(text))
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("Failed copying memo to tmp file, error msg: %v", err)
+	}
+
+	cmd := exec.Command(path, append(ce.args, f.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Error while editing memo with %v, error msg: %v", ce.command, err)
+	}
+
+	memo, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return "", fmt.Errorf("Could not read tmp file, error msg: %v", err)
+	}
+	return string(memo), nil
+}
+
+func (se StdinEditor) edit(text string) (string, error) {
+	memo, err := ioutil.ReadAll(se.input)
+	if err != nil {
+		return "", fmt.Errorf("Could not read memo from stdin, error msg: %v", err)
+	}
+	if len(memo) == 0 && se.keepOnEmpty {
+		return text, nil
+	}
+	return string(memo), nil
+}
+
+func (ue unchangedEditor) edit(text string) (string, error) {
+	return text, nil
+}
+
+//splitCommand splits a command line into its fields, single and double quotes
+//can be used for arguments containing spaces, eg: "'/opt/my editor/bin/edit' --wait"
+func splitCommand(command string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	var quote rune
+	inField := false
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			field.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case unicode.IsSpace(r):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated quote in editor command: %v", command)
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"github.com/nicolasmanic/tefter/config"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    "vim",
+			expected: []string{"vim"},
+		}, {
+			input:    "code --wait",
+			expected: []string{"code", "--wait"},
+		}, {
+			input:    `  "/opt/my editor/edit"   -n 'two words' `,
+			expected: []string{"/opt/my editor/edit", "-n", "two words"},
+		}, {
+			input:    "",
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		result, err := splitCommand(test.input)
+		if err != nil {
+			t.Errorf("Could not split command: %v, error msg: %v", test.input, err)
+		}
+		if !reflect.DeepEqual(test.expected, result) {
+			t.Errorf("Expected: %q, got: %q", test.expected, result)
+		}
+	}
+
+	if _, err := splitCommand(`vim "unterminated`); err == nil {
+		t.Error("Splitting command with unterminated quote should fail")
+	}
+}
+
+func TestResolveEditorCommand(t *testing.T) {
+	originalConfig := Config
+	originalVisual := os.Getenv("VISUAL")
+	originalEditor := os.Getenv("EDITOR")
+	Config = config.Default()
+	defer func() {
+		Config = originalConfig
+		os.Setenv("VISUAL", originalVisual)
+		os.Setenv("EDITOR", originalEditor)
+	}()
+
+	os.Setenv("VISUAL", "")
+	os.Setenv("EDITOR", "")
+	if resolveEditorCommand() != "vim" {
+		t.Error("Expected vim when no editor is set")
+	}
+	os.Setenv("EDITOR", "nano")
+	if resolveEditorCommand() != "nano" {
+		t.Error("Expected $EDITOR")
+	}
+	os.Setenv("VISUAL", "code --wait")
+	if resolveEditorCommand() != "code --wait" {
+		t.Error("Expected $VISUAL to take precedence over $EDITOR")
+	}
+	Config.Editor = "emacs"
+	if resolveEditorCommand() != "emacs" {
+		t.Error("Expected editor of config to take precedence over environment")
+	}
+}
+
+func TestCommandEditor(t *testing.T) {
+	//the tmp file is passed as $0 to the shell script.
+	editor, err := newCommandEditor(`sh -c 'echo edited >> "$0"'`, "md")
+	if err != nil {
+		t.Errorf("Could not create editor, error msg: %v", err)
+	}
+	if editor.extension != ".md" {
+		t.Errorf("Expected extension .md, got: %v", editor.extension)
+	}
+
+	memo, err := editor.edit("original\n")
+	if err != nil {
+		t.Errorf("Could not edit memo, error msg: %v", err)
+	}
+	if memo != "original\nedited\n" {
+		t.Errorf("Unexpected edited memo: %q", memo)
+	}
+}
+
+func TestCommandEditorMissingProgram(t *testing.T) {
+	editor, _ := newCommandEditor("not-existing-editor --wait", ".txt")
+	if _, err := editor.edit(""); err == nil {
+		t.Error("Editing with missing program should fail")
+	}
+	if _, err := newCommandEditor("  ", ".txt"); err == nil {
+		t.Error("Creating editor with empty command should fail")
+	}
+}
+
+func TestStdinEditor(t *testing.T) {
+	editor := &StdinEditor{input: strings.NewReader("piped memo")}
+	memo, err := editor.edit("ignored")
+	if err != nil {
+		t.Errorf("Could not read memo, error msg: %v", err)
+	}
+	if memo != "piped memo" {
+		t.Errorf("Expected piped memo, got: %q", memo)
+	}
+}
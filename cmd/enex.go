@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Evernote exports a notebook to an .enex file, the content of a note is ENML, see enmlToMarkdown, and the files
+//embedded in a note are base64 encoded resources:
+//
+//	<en-export>
+//	  <note>
+//	    <title>Bali 2018</title>
+//	    <content><![CDATA[<en-note><div>photos</div><en-media hash="md5 of the file" type="image/png"/></en-note>]]></content>
+//	    <created>20180319T185829Z</created>
+//	    <updated>20180319T185829Z</updated>
+//	    <tag>vacation</tag>
+//	    <resource>
+//	      <data encoding="base64">...</data>
+//	      <mime>image/png</mime>
+//	      <resource-attributes><file-name>photo.png</file-name></resource-attributes>
+//	    </resource>
+//	  </note>
+//	</en-export>
+const (
+	enexExtension  = ".enex"
+	enexTimeLayout = "20060102T150405Z"
+)
+
+type enexNote struct {
+	Title     string         `xml:"title"`
+	Content   string         `xml:"content"`
+	Created   string         `xml:"created"`
+	Updated   string         `xml:"updated"`
+	Tags      []string       `xml:"tag"`
+	Resources []enexResource `xml:"resource"`
+}
+
+type enexResource struct {
+	Data     string `xml:"data"`
+	Mime     string `xml:"mime"`
+	FileName string `xml:"resource-attributes>file-name"`
+}
+
+//importENEXFile imports the notes of the ENEX file at path to the notebook at notebookTitle, named after the file
+//by default, see importENEX.
+func importENEXFile(path, notebookTitle string, options importOptions) (*importReport, error) {
+	if notebookTitle == "" {
+		notebookTitle = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading file, error msg: %w", err)
+	}
+	defer file.Close()
+	return importENEX(file, notebookTitle, options)
+}
+
+//importENEX imports the notes of the ENEX read from r to the notebook at notebookTitle according to options, see
+//runImport. Notes are decoded one at a time so that large exports are never loaded in memory, notes that can not
+//be converted, eg: with invalid resources, are rejected. Notes always keep the created and updated times of the ENEX.
+func importENEX(r io.Reader, notebookTitle string, options importOptions) (*importReport, error) {
+	options.PreserveTimestamps = true
+	return runImport(options, func(imp *importer) error {
+		decoder := xml.NewDecoder(r)
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("Could not parse ENEX, error msg: %w", err)
+			}
+			start, ok := token.(xml.StartElement)
+			if !ok || start.Name.Local != "note" {
+				continue
+			}
+			var eNote enexNote
+			if err := decoder.DecodeElement(&eNote, &start); err != nil {
+				return fmt.Errorf("Could not parse ENEX, error msg: %w", err)
+			}
+			jNote, err := eNote.toJSONNote(notebookTitle)
+			if err != nil {
+				err = imp.rejectNext("", jNote, err)
+			} else {
+				err = imp.add(jNote)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+//toJSONNote returns the note as a record of the notebook at notebookTitle, the memo is the content converted to
+//markdown and the resources are attachments. Only the title is set if the note can not be converted.
+func (eNote enexNote) toJSONNote(notebookTitle string) (jsonNote, error) {
+	jNote := jsonNote{Title: eNote.Title}
+	jAttachments := make([]*jsonAttachment, 0, len(eNote.Resources))
+	//en-media elements refer to resources by the md5 hash of their content
+	resources := make(map[string]*jsonAttachment, len(eNote.Resources))
+	for _, resource := range eNote.Resources {
+		content, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(resource.Data), ""))
+		if err != nil {
+			return jNote, fmt.Errorf("Invalid data of resource %v, error msg: %v", resource.FileName, err)
+		}
+		jAttachment := &jsonAttachment{Name: resource.name(), Content: content}
+		hash := md5.Sum(content)
+		resources[hex.EncodeToString(hash[:])] = jAttachment
+		jAttachments = append(jAttachments, jAttachment)
+	}
+	memo, err := enmlToMarkdown(eNote.Content, resources)
+	if err != nil {
+		return jNote, fmt.Errorf("Invalid content, error msg: %v", err)
+	}
+
+	jNote.Memo = memo
+	jNote.Tags = eNote.Tags
+	jNote.NotebookTitle = notebookTitle
+	jNote.Attachments = jAttachments
+	if created, err := time.Parse(enexTimeLayout, eNote.Created); err == nil {
+		jNote.Created = created
+		jNote.LastUpdated = created
+	}
+	if updated, err := time.Parse(enexTimeLayout, eNote.Updated); err == nil {
+		jNote.LastUpdated = updated
+	}
+	return jNote, nil
+}
+
+//name returns the file name of the resource, resources without one are named after their mime type,
+//eg: attachment.png.
+func (resource enexResource) name() string {
+	if name := filepath.Base(strings.TrimSpace(resource.FileName)); name != "." && name != "/" {
+		return name
+	}
+	if i := strings.Index(resource.Mime, "/"); i >= 0 && i < len(resource.Mime)-1 {
+		return "attachment." + resource.Mime[i+1:]
+	}
+	return "attachment"
+}
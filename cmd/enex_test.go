@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"github.com/nicolasmanic/tefter/repository"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestENMLToMarkdown(t *testing.T) {
+	resources := map[string]*jsonAttachment{"a1b2": {Name: "beach photo.png"}}
+	cases := []struct {
+		enml     string
+		expected string
+	}{
+		{`<?xml version="1.0" encoding="UTF-8"?><!DOCTYPE en-note SYSTEM "http://xml.evernote.com/pub/enml2.dtd">` +
+			`<en-note><div>first   line</div><div><br/></div><div>second&nbsp;line</div></en-note>`, "first line\n\nsecond line\n"},
+		{`<en-note><h2>Plan</h2><p>Go to <b>Bali</b>, <a href="https://bali.com">book</a> it</p></en-note>`,
+			"## Plan\n\nGo to **Bali**, [book](https://bali.com) it\n"},
+		{`<en-note><ul><li>milk</li><li>eggs<ol><li>white</li></ol></li></ul></en-note>`, "- milk\n- eggs\n  1. white\n"},
+		{`<en-note><div><en-todo checked="true"/>book flight</div><div><en-todo/>pack</div></en-note>`,
+			"- [x] book flight\n- [ ] pack\n"},
+		{`<en-note><div>photo: <en-media hash="a1b2" type="image/png"/></div><en-media hash="missing" type="image/png"/></en-note>`,
+			"photo: ![beach photo.png](beach%20photo.png)\n"},
+		{`<en-note><pre>if a  {
+  b()
+}</pre><en-crypt hint="pin">c2VjcmV0</en-crypt></en-note>`, "```\nif a  {\n  b()\n}\n```\n\n[encrypted content]\n"},
+		{`<en-note><div> </div></en-note>`, ""},
+	}
+	for _, c := range cases {
+		markdown, err := enmlToMarkdown(c.enml, resources)
+		if err != nil || markdown != c.expected {
+			t.Errorf("Expected markdown of %v to be %q, got: %q, error msg: %v", c.enml, c.expected, markdown, err)
+		}
+	}
+}
+
+func TestImportENEX(t *testing.T) {
+	defer useMemoryStore(t)()
+	photo := base64.StdEncoding.EncodeToString([]byte("png"))
+	enex := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-export SYSTEM "http://xml.evernote.com/pub/evernote-export3.dtd">
+<en-export export-date="20180320T101010Z" application="Evernote" version="Evernote Mac 7.0">
+  <note>
+    <title>Bali 2018</title>
+    <content><![CDATA[<?xml version="1.0" encoding="UTF-8"?><en-note><div>photos <en-media hash="bff139fa05ac583f685a523ab3d110a0" type="image/png"/></div></en-note>]]></content>
+    <created>20180319T185829Z</created>
+    <updated>20180320T090000Z</updated>
+    <tag>Vacation</tag>
+    <tag>summer</tag>
+    <resource>
+      <data encoding="base64">
+` + photo + `
+      </data>
+      <mime>image/png</mime>
+      <resource-attributes><file-name>photo.png</file-name></resource-attributes>
+    </resource>
+  </note>
+  <note>
+    <title>Empty</title>
+    <content><![CDATA[<en-note><div><br/></div></en-note>]]></content>
+  </note>
+  <note>
+    <title>Broken</title>
+    <content><![CDATA[<en-note><div>text</en-note>]]></content>
+    <resource><data encoding="base64">not base64!</data></resource>
+  </note>
+</en-export>`
+
+	dryRun := defaultImportOptions
+	dryRun.DryRun = true
+	report, err := importENEX(strings.NewReader(enex), "lists/travel", dryRun)
+	if err != nil {
+		t.Fatalf("Could not import ENEX, error msg: %v", err)
+	}
+	//notes without memo are only rejected when saved
+	checkImportReport(t, report, 2, 0, 0, 1)
+	if jNotes, _, _ := retrieveJSONNotes([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{}); len(jNotes) != 0 {
+		t.Fatalf("Expected no notes to be saved by a dry run, got: %v", jNotes)
+	}
+
+	report, err = importENEX(strings.NewReader(enex), "lists/travel", defaultImportOptions)
+	if err != nil {
+		t.Fatalf("Could not import ENEX, error msg: %v", err)
+	}
+	checkImportReport(t, report, 1, 0, 0, 2)
+	rejected := map[string]int{}
+	for _, record := range report.Rejected {
+		rejected[record.Title] = record.Index
+	}
+	if !reflect.DeepEqual(rejected, map[string]int{"Empty": 1, "Broken": 2}) {
+		t.Fatalf("Expected notes Empty and Broken to be rejected, got: %+v", report.Rejected)
+	}
+	jNotes, _, err := retrieveJSONNotes([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{})
+	if err != nil || len(jNotes) != 1 {
+		t.Fatalf("Expected 1 imported note, got: %v, error msg: %v", jNotes, err)
+	}
+	jNote := jNotes[0]
+	sort.Strings(jNote.Tags)
+	if jNote.Title != "Bali 2018" || jNote.Memo != "photos ![photo.png](photo.png)\n" || jNote.NotebookTitle != "lists/travel" ||
+		!reflect.DeepEqual(jNote.Tags, []string{"summer", "vacation"}) {
+		t.Errorf("Unexpected imported note: %+v", jNote)
+	}
+	if !jNote.Created.Equal(time.Date(2018, 3, 19, 18, 58, 29, 0, time.UTC)) || !jNote.LastUpdated.Equal(time.Date(2018, 3, 20, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected timestamps of the ENEX, got created: %v, updated: %v", jNote.Created, jNote.LastUpdated)
+	}
+	if attachments, _ := NoteDB.GetAttachments(jNote.ID); len(attachments) != 1 || attachments[0].Name != "photo.png" || attachments[0].Size != 3 {
+		t.Errorf("Unexpected imported attachments: %v", attachments)
+	}
+
+	//notes are matched by the hash of their title and memo, importing the ENEX again changes nothing
+	report, err = importENEX(strings.NewReader(enex), "lists/travel", defaultImportOptions)
+	if err != nil {
+		t.Fatalf("Could not import ENEX again, error msg: %v", err)
+	}
+	checkImportReport(t, report, 0, 0, 1, 2)
+
+	atomic := defaultImportOptions
+	atomic.Atomic = true
+	if _, err := importENEX(strings.NewReader(enex), "lists/travel", atomic); err == nil {
+		t.Errorf("Expected an atomic import to fail on the rejected notes")
+	}
+	if _, err := importENEX(strings.NewReader("<en-export><note><title>"), "travel", defaultImportOptions); err == nil {
+		t.Errorf("Expected an error for an invalid ENEX")
+	}
+}
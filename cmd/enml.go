@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//enmlList is a list being converted, items of ordered lists are numbered.
+type enmlList struct {
+	ordered bool
+	items   int
+}
+
+//enmlConverter converts ENML, the XHTML subset of Evernote notes, to markdown. Elements without a markdown
+//equivalent, eg: span or font, are dropped and their text is kept.
+type enmlConverter struct {
+	out       []byte
+	resources map[string]*jsonAttachment
+	lists     []enmlList
+	//links holds the href of the open anchors, anchors without href are not converted to links
+	links []string
+	//cells is the number of cells of the current table row
+	cells int
+	pre   int
+	//encrypted is true inside en-crypt, whose content can not be read without the passphrase
+	encrypted bool
+}
+
+//enmlToMarkdown converts the ENML content of an Evernote note to markdown. resources maps the md5 hash of the
+//resources of the note to their attachment, so that embedded files are linked by their name.
+func enmlToMarkdown(content string, resources map[string]*jsonAttachment) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	//ENML is XHTML, it may use HTML entities like &nbsp; and elements like <br> without closing them
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	converter := &enmlConverter{resources: resources}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			converter.start(token)
+		case xml.EndElement:
+			converter.end(token.Name.Local)
+		case xml.CharData:
+			converter.text(string(token))
+		}
+	}
+	markdown := strings.TrimSpace(string(converter.out))
+	if markdown == "" {
+		return "", nil
+	}
+	return markdown + "\n", nil
+}
+
+func (c *enmlConverter) start(element xml.StartElement) {
+	name := element.Name.Local
+	if level := headingLevel(name); level > 0 {
+		c.blankLine()
+		c.write(strings.Repeat("#", level) + " ")
+		return
+	}
+	switch name {
+	case "p":
+		c.blankLine()
+	case "div", "blockquote", "table":
+		c.lineBreak()
+	case "br":
+		c.newLine()
+	case "hr":
+		c.blankLine()
+		c.write("---")
+		c.blankLine()
+	case "ul", "ol":
+		c.lineBreak()
+		c.lists = append(c.lists, enmlList{ordered: name == "ol"})
+	case "li":
+		c.lineBreak()
+		if len(c.lists) == 0 {
+			c.write("- ")
+			return
+		}
+		list := &c.lists[len(c.lists)-1]
+		list.items++
+		c.write(strings.Repeat("  ", len(c.lists)-1))
+		if list.ordered {
+			c.write(strconv.Itoa(list.items) + ". ")
+		} else {
+			c.write("- ")
+		}
+	case "tr":
+		c.lineBreak()
+		c.cells = 0
+	case "td", "th":
+		if c.cells > 0 {
+			c.write(" | ")
+		}
+		c.cells++
+	case "pre":
+		c.blankLine()
+		c.write("```\n")
+		c.pre++
+	case "code":
+		if c.pre == 0 {
+			c.write("`")
+		}
+	case "b", "strong":
+		c.write("**")
+	case "i", "em":
+		c.write("_")
+	case "s", "strike", "del":
+		c.write("~~")
+	case "a":
+		href := attribute(element, "href")
+		c.links = append(c.links, href)
+		if href != "" {
+			c.write("[")
+		}
+	case "en-todo":
+		if c.atLineStart() && len(c.lists) == 0 {
+			c.write("- ")
+		}
+		if attribute(element, "checked") == "true" {
+			c.write("[x] ")
+		} else {
+			c.write("[ ] ")
+		}
+	case "en-media":
+		c.media(attribute(element, "hash"), attribute(element, "type"))
+	case "en-crypt":
+		c.write("[encrypted content]")
+		c.encrypted = true
+	}
+}
+
+func (c *enmlConverter) end(name string) {
+	if headingLevel(name) > 0 {
+		c.blankLine()
+		return
+	}
+	switch name {
+	case "p":
+		c.blankLine()
+	case "div", "blockquote", "table", "tr", "li":
+		c.lineBreak()
+	case "ul", "ol":
+		if len(c.lists) > 0 {
+			c.lists = c.lists[:len(c.lists)-1]
+		}
+		c.lineBreak()
+	case "pre":
+		if c.pre > 0 {
+			c.pre--
+		}
+		c.lineBreak()
+		c.write("```")
+		c.blankLine()
+	case "code":
+		if c.pre == 0 {
+			c.write("`")
+		}
+	case "b", "strong":
+		c.write("**")
+	case "i", "em":
+		c.write("_")
+	case "s", "strike", "del":
+		c.write("~~")
+	case "a":
+		if len(c.links) == 0 {
+			return
+		}
+		href := c.links[len(c.links)-1]
+		c.links = c.links[:len(c.links)-1]
+		if href != "" {
+			c.write("](" + href + ")")
+		}
+	case "en-crypt":
+		c.encrypted = false
+	}
+}
+
+//text writes the text of an element, whitespace is collapsed like browsers do except in preformatted text.
+func (c *enmlConverter) text(text string) {
+	if c.encrypted {
+		return
+	}
+	if c.pre > 0 {
+		c.write(text)
+		return
+	}
+	fields := strings.Fields(text)
+	first, _ := utf8.DecodeRuneInString(text)
+	last, _ := utf8.DecodeLastRuneInString(text)
+	if (len(fields) == 0 || unicode.IsSpace(first)) && !c.atLineStart() && !bytes.HasSuffix(c.out, []byte(" ")) {
+		c.write(" ")
+	}
+	if len(fields) == 0 {
+		return
+	}
+	c.write(strings.Join(fields, " "))
+	if unicode.IsSpace(last) {
+		c.write(" ")
+	}
+}
+
+//media writes a link to the attachment of an embedded file, images are embedded in markdown as well.
+func (c *enmlConverter) media(hash, mimeType string) {
+	jAttachment, ok := c.resources[hash]
+	if !ok {
+		return
+	}
+	if strings.HasPrefix(mimeType, "image/") {
+		c.write("!")
+	}
+	c.write("[" + jAttachment.Name + "](" + url.PathEscape(jAttachment.Name) + ")")
+}
+
+func (c *enmlConverter) write(s string) {
+	c.out = append(c.out, s...)
+}
+
+func (c *enmlConverter) atLineStart() bool {
+	return len(c.out) == 0 || c.out[len(c.out)-1] == '\n'
+}
+
+//newLine ends the current line, trailing spaces are dropped.
+func (c *enmlConverter) newLine() {
+	c.out = bytes.TrimRight(c.out, " ")
+	c.write("\n")
+}
+
+//lineBreak ends the current line unless it is empty.
+func (c *enmlConverter) lineBreak() {
+	if !c.atLineStart() {
+		c.newLine()
+	}
+}
+
+//blankLine separates the next block, eg: a paragraph, with an empty line.
+func (c *enmlConverter) blankLine() {
+	c.lineBreak()
+	if len(c.out) > 0 && !bytes.HasSuffix(c.out, []byte("\n\n")) {
+		c.write("\n")
+	}
+}
+
+//headingLevel returns the level of h1 to h6 elements and 0 for any other element.
+func headingLevel(name string) int {
+	if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+		return int(name[1] - '0')
+	}
+	return 0
+}
+
+func attribute(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/repository"
+	"log"
+	"net/http"
+	"os"
+)
+
+//Exit codes of tefter commands, scripts can rely on them to tell failures apart.
+const (
+	exitFailure    = 1
+	exitValidation = 2
+	exitNotFound   = 3
+	exitConflict   = 4
+)
+
+//exitCode maps err to the exit code of the command based on the repository error it wraps.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrValidation):
+		return exitValidation
+	case isNotFound(err):
+		return exitNotFound
+	case isConflict(err):
+		return exitConflict
+	default:
+		return exitFailure
+	}
+}
+
+//exitWithError logs err and exits with the corresponding exit code.
+func exitWithError(err error) {
+	log.Println(err)
+	os.Exit(exitCode(err))
+}
+
+//httpStatus maps err to the status code of the rest API response based on the repository error it wraps.
+func httpStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case isNotFound(err):
+		return http.StatusNotFound
+	case isConflict(err):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, repository.ErrNoteNotFound) ||
+		errors.Is(err, repository.ErrRevisionNotFound) ||
+		errors.Is(err, repository.ErrNotebookNotFound) ||
+		errors.Is(err, repository.ErrTagNotFound) ||
+		errors.Is(err, repository.ErrAttachmentNotFound) ||
+		errors.Is(err, repository.ErrAccountNotFound)
+}
+
+func isConflict(err error) bool {
+	return errors.Is(err, repository.ErrNotebookExists) ||
+		errors.Is(err, repository.ErrAccountExists) ||
+		errors.Is(err, repository.ErrDefaultNotebookProtected) ||
+		errors.Is(err, repository.ErrNotebookHasChildren) ||
+		errors.Is(err, repository.ErrTagExists)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nicolasmanic/tefter/repository"
+	"net/http"
+	"testing"
+)
+
+func TestExitCodeAndHTTPStatus(t *testing.T) {
+	cases := []struct {
+		err        error
+		exitCode   int
+		httpStatus int
+	}{
+		{errors.New("Unexpected error"), exitFailure, http.StatusInternalServerError},
+		{repository.ErrValidation, exitValidation, http.StatusUnprocessableEntity},
+		{fmt.Errorf("Error while saving note, error msg: %w", repository.ErrValidation), exitValidation, http.StatusUnprocessableEntity},
+		{&repository.Error{Kind: repository.ErrNoteNotFound, Msg: "Could find note with id: 1"}, exitNotFound, http.StatusNotFound},
+		{repository.ErrNotebookNotFound, exitNotFound, http.StatusNotFound},
+		{repository.ErrAccountNotFound, exitNotFound, http.StatusNotFound},
+		{repository.ErrNotebookExists, exitConflict, http.StatusConflict},
+		{repository.ErrAccountExists, exitConflict, http.StatusConflict},
+		{repository.ErrDefaultNotebookProtected, exitConflict, http.StatusConflict},
+		{repository.ErrNotebookHasChildren, exitConflict, http.StatusConflict},
+		{repository.ErrTagNotFound, exitNotFound, http.StatusNotFound},
+		{repository.ErrTagExists, exitConflict, http.StatusConflict},
+		{repository.ErrAttachmentNotFound, exitNotFound, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		if code := exitCode(c.err); code != c.exitCode {
+			t.Errorf("Expected exit code %v for %q, got: %v", c.exitCode, c.err, code)
+		}
+		if status := httpStatus(c.err); status != c.httpStatus {
+			t.Errorf("Expected http status %v for %q, got: %v", c.httpStatus, c.err, status)
+		}
+	}
+}
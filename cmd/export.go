@@ -1,11 +1,19 @@
 package cmd
 
 import (
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"github.com/nicolasmanic/tefter/repository"
 	"github.com/spf13/cobra"
+	"io"
 	"io/ioutil"
-	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -17,27 +25,83 @@ type jsonNote struct {
 	LastUpdated   time.Time `json:"updated"`
 	Tags          []string  `json:"tags"`
 	NotebookTitle string    `json:"notebook_title"`
+	//Rank, HighlightedTitle and Snippet are only set for search results, see repository.SearchHit
+	Rank             float64 `json:"rank,omitempty"`
+	HighlightedTitle string  `json:"highlighted_title,omitempty"`
+	Snippet          string  `json:"snippet,omitempty"`
+	//Attachments are only set for exported notes, along with their content
+	Attachments []*jsonAttachment `json:"attachments,omitempty"`
+}
+
+//jsonNotebook is the json representation of a notebook in exports, Title is the path of the notebook.
+type jsonNotebook struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+//exportMetadata describes an export so that imports can check that they can read it.
+type exportMetadata struct {
+	SchemaVersion int       `json:"schema_version"`
+	Exported      time.Time `json:"exported"`
+	ToolVersion   string    `json:"tool_version"`
+}
+
+//Exported json is an object with the metadata of the export, the notebooks and the notes:
+//
+//	{"metadata": {"schema_version": 8, "exported": "...", "tool_version": "v1.2.0"}, "notebooks": [...], "notes": [...]}
+//
+//Exported json lines hold an object per line, with the metadata first and then the notebooks and the notes:
+//
+//	{"metadata": {...}}
+//	{"notebook": {"id": 1, "title": "Default Notebook"}}
+//	{"note": {...}}
+type jsonLine struct {
+	Metadata *exportMetadata `json:"metadata,omitempty"`
+	Notebook *jsonNotebook   `json:"notebook,omitempty"`
+	Note     *jsonNote       `json:"note,omitempty"`
 }
 
 var exportCmd = &cobra.Command{
 	Use:   "export",
-	Short: "Exports notes to json format",
+	Short: "Exports notes to json or markdown format",
 	Long: "There are 4 ways to export a set of notes\n" +
 		" 1) Give a comma separated list of note ids\n" +
 		" 2) Give a comma separated list of notebook titles\n" +
 		" 3) Give a comma separated list of tags,\n" +
-		" 4) If -a or --all flag is set all notes will be printed\n",
+		" 4) If -a or --all flag is set all notes will be printed\n" +
+		"Use -q to export only the notes matching a query, see search for the query syntax\n" +
+		"Notes are sorted by --sort and --order, use --limit and --page to export a page of notes\n" +
+		"Attachments of the notes are exported along with them\n" +
+		"Notes are written to notes.json, or to the --output file, - for stdout. The json holds the schema version of the DB,\n" +
+		"the export time and the tefter version, so that import can check it, and the notebooks of the notes, every notebook\n" +
+		"when exporting all notes. --pretty indents the json, --format jsonl writes an object per line for huge exports\n" +
+		"and --compress gzip or zip compresses the output, by default it is compressed if --output ends with .gz or .zip\n" +
+		"With --format md every note is written to a markdown file with a yaml front matter under the --output directory,\n" +
+		"notes by default, in a directory per notebook, see import\n",
 	Example: "export -i 1,2,... -n notebook1,notebook2,... -t tag1,tag2,...\n " +
-		"export -a",
+		"export -a\n " +
+		"export -a --limit 100 --page 3\n " +
+		"export -q 'notebook:work updated:>2026-10-01 -tag:done'\n " +
+		"export -a --pretty -o backup.json\n " +
+		"export -a --format jsonl -o - | gzip > notes.jsonl.gz\n " +
+		"export -a -o notes.json.gz\n " +
+		"export -a --format md -o ~/notes",
 	Run: exportWrapper,
 }
 
 func init() {
 	rootCmd.AddCommand(exportCmd)
 	exportCmd.Flags().IntSliceP("ids", "i", []int{}, "Comma separated list of note ids.")
-	exportCmd.Flags().StringSlice("tags", []string{}, "Comma-separated tags of note.")
-	exportCmd.Flags().StringSliceP("notebook", "n", []string{}, "Comma separated list of notebook titles")
+	exportCmd.Flags().StringSlice("tags", []string{}, "Comma-separated tags of note, a tag includes its sub tags eg: lang for lang/go")
+	exportCmd.Flags().StringSliceP("notebook", "n", []string{}, "Comma separated list of notebook paths, notes of their child notebooks are included")
 	exportCmd.Flags().BoolP("all", "a", false, "Export all notes")
+	exportCmd.Flags().StringP("query", "q", "", "Export notes matching the query")
+	exportCmd.Flags().String("format", exportFormatJSON, "Format of the exported notes: json, jsonl or md")
+	exportCmd.Flags().StringP("output", "o", "", "File to write to, - for stdout, or directory of the markdown notes")
+	exportCmd.Flags().String("out", "", "Alias of --output")
+	exportCmd.Flags().Bool("pretty", false, "Indent the exported json")
+	exportCmd.Flags().String("compress", "", "Compress the exported json with gzip or zip")
+	addNoteQueryFlags(exportCmd, repository.SortByCreated)
 }
 
 func exportWrapper(cmd *cobra.Command, args []string) {
@@ -45,35 +109,336 @@ func exportWrapper(cmd *cobra.Command, args []string) {
 	notebookTitles, _ := cmd.Flags().GetStringSlice("notebook")
 	tags, _ := cmd.Flags().GetStringSlice("tags")
 	all, _ := cmd.Flags().GetBool("all")
-	if err := export(ids, notebookTitles, tags, all); err != nil {
-		log.Fatalln(err)
+	queryText, _ := cmd.Flags().GetString("query")
+	options := exportOptions{}
+	options.Format, _ = cmd.Flags().GetString("format")
+	options.Output, _ = cmd.Flags().GetString("output")
+	if options.Output == "" {
+		options.Output, _ = cmd.Flags().GetString("out")
+	}
+	options.Pretty, _ = cmd.Flags().GetBool("pretty")
+	options.Compress, _ = cmd.Flags().GetString("compress")
+	query, err := noteQueryFromFlags(cmd)
+	if err != nil {
+		exitWithError(err)
+	}
+	if err := export(ids, notebookTitles, tags, all, queryText, query, options); err != nil {
+		exitWithError(err)
+	}
+}
+
+//Formats of exported notes
+const (
+	exportFormatJSON      = "json"
+	exportFormatJSONLines = "jsonl"
+	exportFormatMarkdown  = "md"
+)
+
+//Compressions of exported json
+const (
+	compressNone = "none"
+	compressGzip = "gzip"
+	compressZip  = "zip"
+)
+
+//stdoutPath is the output path writing to stdout
+const stdoutPath = "-"
+
+type exportOptions struct {
+	Format string
+	//Output is the file, or the directory of markdown notes, to write to, see output
+	Output string
+	Pretty bool
+	//Compress is one of none, gzip or zip, if empty it is inferred from the extension of Output
+	Compress string
+}
+
+func (options exportOptions) validate() error {
+	if options.Format != exportFormatJSON && options.Format != exportFormatJSONLines && options.Format != exportFormatMarkdown {
+		return fmt.Errorf("Unknown format: %q, expected json, jsonl or md, error msg: %w", options.Format, repository.ErrValidation)
+	}
+	if options.Compress != "" && options.Compress != compressNone && options.Compress != compressGzip && options.Compress != compressZip {
+		return fmt.Errorf("Unknown compression: %q, expected none, gzip or zip, error msg: %w", options.Compress, repository.ErrValidation)
+	}
+	if options.Pretty && options.Format != exportFormatJSON {
+		return fmt.Errorf("Only json can be pretty printed, error msg: %w", repository.ErrValidation)
+	}
+	if options.Format == exportFormatMarkdown && (options.compression() != compressNone || options.Output == stdoutPath) {
+		return fmt.Errorf("Markdown notes can not be compressed or written to stdout, error msg: %w", repository.ErrValidation)
 	}
+	return nil
 }
 
-func export(ids []int, notebookTitles, tags []string, getAll bool) error {
-	jNotes, err := retrieveJSONNotes(ids, notebookTitles, tags, getAll)
+//output returns the path to write to, by default notes.json, notes.jsonl or the notes directory of markdown notes
+//with the extension of the compression, eg: notes.json.gz.
+func (options exportOptions) output() string {
+	if options.Output != "" {
+		return options.Output
+	}
+	switch options.Format {
+	case exportFormatMarkdown:
+		return "notes"
+	case exportFormatJSONLines:
+		return "notes.jsonl" + compressionExtension(options.Compress)
+	}
+	return "notes.json" + compressionExtension(options.Compress)
+}
+
+//compression returns the compression of the output, see exportOptions.Compress.
+func (options exportOptions) compression() string {
+	if options.Compress != "" {
+		return options.Compress
+	}
+	switch strings.ToLower(filepath.Ext(options.Output)) {
+	case ".gz":
+		return compressGzip
+	case ".zip":
+		return compressZip
+	}
+	return compressNone
+}
+
+func compressionExtension(compression string) string {
+	switch compression {
+	case compressGzip:
+		return ".gz"
+	case compressZip:
+		return ".zip"
+	}
+	return ""
+}
+
+//export writes the notes according to options. Exports of all notes include every notebook, the rest only
+//include the notebooks of the exported notes.
+func export(ids []int, notebookTitles, tags []string, getAll bool, queryText string, query repository.NoteQuery, options exportOptions) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+	jNotes, _, err := retrieveJSONNotes(ids, notebookTitles, tags, getAll, queryText, query)
+	if err != nil {
+		return err
+	}
+	if options.Format == exportFormatMarkdown {
+		if err := addJSONAttachments(jNotes); err != nil {
+			return err
+		}
+		return writeMarkdownNotes(jNotes, options.output(), getAll && queryText == "" && query.Limit == 0)
+	}
+	jNotebooks, err := exportedNotebooks(jNotes, getAll && queryText == "")
 	if err != nil {
 		return err
 	}
-	return writeNotes(jNotes)
+	return writeExport(jNotes, jNotebooks, options)
 }
 
-func retrieveJSONNotes(ids []int, notebookTitles, tags []string, getAll bool) ([]*jsonNote, error) {
-	notes, err := collectNotesFromDB(ids, notebookTitles, tags, getAll)
+//exportedNotebooks returns every notebook, or only the notebooks of the notes, sorted by path.
+func exportedNotebooks(jNotes []*jsonNote, all bool) ([]*jsonNotebook, error) {
+	notebookTitles, err := NotebookDB.GetAllNotebooksTitle()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Error while retrieving Notebooks titles, error msg: %w", err)
+	}
+	ofNotes := make(map[string]bool, len(jNotes))
+	for _, jNote := range jNotes {
+		ofNotes[jNote.NotebookTitle] = true
+	}
+	jNotebooks := []*jsonNotebook{}
+	for id, title := range notebookTitles {
+		if all || ofNotes[title] {
+			jNotebooks = append(jNotebooks, &jsonNotebook{ID: id, Title: title})
+		}
 	}
-	jNotes, err := transformNotes2JSONNotes(noteMap2Slice(notes))
+	sort.Slice(jNotebooks, func(i, j int) bool { return jNotebooks[i].Title < jNotebooks[j].Title })
+	return jNotebooks, nil
+}
+
+//writeExport writes the notes along with the notebooks and the metadata of the export to the output of options.
+func writeExport(jNotes []*jsonNote, jNotebooks []*jsonNotebook, options exportOptions) (err error) {
+	var w io.Writer = os.Stdout
+	output := options.output()
+	if output != stdoutPath {
+		//notes are written to a temporary file renamed to output once complete, a failed export leaves no partial file
+		var file *os.File
+		if file, err = ioutil.TempFile(filepath.Dir(output), "."+filepath.Base(output)+".*"); err != nil {
+			return fmt.Errorf("Error while creating file, error msg: %w", err)
+		}
+		defer commitFile(file, output, &err)
+		w = file
+	}
+	buffered := bufio.NewWriter(w)
+	w = buffered
+
+	var compressor io.Closer
+	switch options.compression() {
+	case compressGzip:
+		gzipWriter := gzip.NewWriter(w)
+		compressor = gzipWriter
+		w = gzipWriter
+	case compressZip:
+		archive := zip.NewWriter(w)
+		compressor = archive
+		if w, err = archive.Create(zipEntryName(output, options.Format)); err != nil {
+			return fmt.Errorf("Error while compressing notes, error msg: %w", err)
+		}
+	}
+
+	metadata := &exportMetadata{SchemaVersion: repository.SchemaVersion, Exported: time.Now().UTC(), ToolVersion: Version}
+	if options.Format == exportFormatJSONLines {
+		err = writeJSONLines(w, metadata, jNotebooks, jNotes)
+	} else {
+		err = writeJSON(w, metadata, jNotebooks, jNotes, options.Pretty)
+	}
+	if err != nil {
+		return err
+	}
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			return fmt.Errorf("Error while compressing notes, error msg: %w", err)
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("Error while writing notes, error msg: %w", err)
+	}
+	return nil
+}
+
+//commitFile closes the temporary file and renames it to output, the file is removed instead if err is set or if
+//closing or renaming it fails, in which case err is set unless it is already set.
+func commitFile(file *os.File, output string, err *error) {
+	if closeErr := file.Close(); closeErr != nil && *err == nil {
+		*err = fmt.Errorf("Error while writing notes, error msg: %w", closeErr)
+	}
+	if *err == nil {
+		if chmodErr := os.Chmod(file.Name(), 0644); chmodErr != nil {
+			*err = fmt.Errorf("Error while creating file, error msg: %w", chmodErr)
+		} else if renameErr := os.Rename(file.Name(), output); renameErr != nil {
+			*err = fmt.Errorf("Error while creating file, error msg: %w", renameErr)
+		}
+	}
+	if *err != nil {
+		os.Remove(file.Name())
+	}
+}
+
+//zipEntryName returns the name of the exported file in a zip archive, eg: notes.json for notes.json.zip.
+func zipEntryName(output, format string) string {
+	name := strings.TrimSuffix(filepath.Base(output), filepath.Ext(output))
+	if output == stdoutPath || filepath.Ext(name) == "" {
+		return "notes." + format
+	}
+	return name
+}
+
+//writeJSON writes the export as a single json object, notes are written one at a time along with the content of
+//their attachments.
+func writeJSON(w io.Writer, metadata *exportMetadata, jNotebooks []*jsonNotebook, jNotes []*jsonNote, pretty bool) error {
+	marshal := func(v interface{}, indent string) ([]byte, error) {
+		if pretty {
+			return json.MarshalIndent(v, indent, "  ")
+		}
+		return json.Marshal(v)
+	}
+	newLine, indent, separator := "", "", ":"
+	if pretty {
+		newLine, indent, separator = "\n", "  ", ": "
+	}
+	marshalledMetadata, err := marshal(metadata, indent)
 	if err != nil {
+		return fmt.Errorf("Error while marshalling Notes, error msg: %w", err)
+	}
+	marshalledNotebooks, err := marshal(jNotebooks, indent)
+	if err != nil {
+		return fmt.Errorf("Error while marshalling Notes, error msg: %w", err)
+	}
+	fmt.Fprintf(w, "{%s%s\"metadata\"%s%s,%s%s\"notebooks\"%s%s,%s%s\"notes\"%s[",
+		newLine, indent, separator, marshalledMetadata, newLine, indent, separator, marshalledNotebooks, newLine, indent, separator)
+	for i, jNote := range jNotes {
+		marshalledNote, err := marshalNoteWithAttachments(jNote, func(v interface{}) ([]byte, error) { return marshal(v, indent+indent) })
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprintf(w, "%s%s%s", newLine, indent+indent, marshalledNote)
+	}
+	if len(jNotes) > 0 {
+		fmt.Fprintf(w, "%s%s", newLine, indent)
+	}
+	_, err = fmt.Fprintf(w, "]%s}%s", newLine, newLine)
+	if err != nil {
+		return fmt.Errorf("Error while writing notes, error msg: %w", err)
+	}
+	return nil
+}
+
+//writeJSONLines writes the export as json lines, see jsonLine.
+func writeJSONLines(w io.Writer, metadata *exportMetadata, jNotebooks []*jsonNotebook, jNotes []*jsonNote) error {
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(jsonLine{Metadata: metadata}); err != nil {
+		return fmt.Errorf("Error while writing notes, error msg: %w", err)
+	}
+	for _, jNotebook := range jNotebooks {
+		if err := encoder.Encode(jsonLine{Notebook: jNotebook}); err != nil {
+			return fmt.Errorf("Error while writing notes, error msg: %w", err)
+		}
+	}
+	for _, jNote := range jNotes {
+		marshalledLine, err := marshalNoteWithAttachments(jNote, func(v interface{}) ([]byte, error) {
+			return json.Marshal(jsonLine{Note: v.(*jsonNote)})
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", marshalledLine); err != nil {
+			return fmt.Errorf("Error while writing notes, error msg: %w", err)
+		}
+	}
+	return nil
+}
+
+//marshalNoteWithAttachments marshals the note along with the content of its attachments, which is released once
+//the note is marshalled so that only the attachments of a single note are kept in memory.
+func marshalNoteWithAttachments(jNote *jsonNote, marshal func(v interface{}) ([]byte, error)) ([]byte, error) {
+	if err := addJSONAttachments([]*jsonNote{jNote}); err != nil {
 		return nil, err
 	}
-	return jNotes, nil
+	defer func() { jNote.Attachments = nil }()
+	marshalledNote, err := marshal(jNote)
+	if err != nil {
+		return nil, fmt.Errorf("Error while marshalling Notes, error msg: %w", err)
+	}
+	return marshalledNote, nil
 }
 
-func writeNotes(jsonNotes []*jsonNote) error {
-	marshalledNotes, err := json.Marshal(jsonNotes)
+//addJSONAttachments sets the attachments of the notes along with their content.
+func addJSONAttachments(jNotes []*jsonNote) error {
+	for _, jNote := range jNotes {
+		attachments, err := NoteDB.GetAttachments(jNote.ID)
+		if err != nil {
+			return fmt.Errorf("Error while retrieving attachments, error msg: %w", err)
+		}
+		for i, attachment := range attachments {
+			if attachments[i], err = retrieveAttachment(attachment.ID); err != nil {
+				return err
+			}
+		}
+		jNote.Attachments = transformAttachments2JSONAttachments(attachments)
+		for i, jAttachment := range jNote.Attachments {
+			jAttachment.Content = attachments[i].Content
+		}
+	}
+	return nil
+}
+
+//retrieveJSONNotes returns a page of notes, see collectNotesFromDB, and the cursor of the next page.
+func retrieveJSONNotes(ids []int, notebookTitles, tags []string, getAll bool, queryText string, query repository.NoteQuery) ([]*jsonNote, string, error) {
+	notes, next, err := collectNotesFromDB(ids, notebookTitles, tags, getAll, queryText, query)
+	if err != nil {
+		return nil, "", err
+	}
+	jNotes, err := transformNotes2JSONNotes(notes)
 	if err != nil {
-		return fmt.Errorf("Error while marshalling Notes, error msg: %v", err)
+		return nil, "", err
 	}
-	return ioutil.WriteFile("notes.json", marshalledNotes, 0644)
+	return jNotes, next, nil
 }
@@ -18,13 +18,13 @@ func TestExportImport(t *testing.T) {
 		NoteDB = oldNoteDB
 		os.Remove("notes.json")
 	}()
-	jsonNotes, err := retrieveJSONNotes([]int{1}, []string{"test"}, []string{"test"}, false)
+	jsonNotes, _, err := retrieveJSONNotes([]int{1}, []string{"test"}, []string{"test"}, false, "", repository.NoteQuery{})
 	if err != nil {
 		t.Errorf("retrieveJSONNotes failed, error msg: %v", err)
 	}
-	writeNotes(jsonNotes)
+	writeExport(jsonNotes, []*jsonNotebook{}, exportOptions{Format: exportFormatJSON})
 	fsr := fileSystemReader{}
-	importNotes(fsr, "notes.json")
+	importNotes(fsr, "notes.json", defaultImportOptions)
 }
 
 func TestImportNoArguments(t *testing.T) {
@@ -57,20 +57,25 @@ func (mDB mockNotebookDBExportImport) GetAllNotebooksTitle() (map[int64]string,
 	return map[int64]string{1: "testTitle"}, nil
 }
 
-func (mDB mockNoteDBExportImport) GetNotesByTag(tags []string) ([]*model.Note, error) {
+func (mDB mockNoteDBExportImport) ListNotes(filter repository.NoteFilter, query repository.NoteQuery) (*repository.NotePage, error) {
 	note1 := model.NewNote("testTitle", "testMemo", repository.DEFAULT_NOTEBOOK_ID, []string{})
 	note1.ID = 1
 	note2 := model.NewNote("testTitle2", "testMemo2", repository.DEFAULT_NOTEBOOK_ID, []string{})
 	note2.ID = 2
-	return []*model.Note{note1, note2}, nil
+	note4 := model.NewNote("testTitle4", "testMemo", repository.DEFAULT_NOTEBOOK_ID, []string{})
+	note4.ID = 4
+	return &repository.NotePage{Notes: []*model.Note{note4, note2, note1}}, nil
+}
+
+func (mDB mockNoteDBExportImport) GetNotes(noteIDs []int64) ([]*model.Note, error) {
+	page, err := mDB.ListNotes(repository.NoteFilter{IDs: noteIDs}, repository.NoteQuery{})
+	return page.Notes, err
 }
 
 func (mDB mockNoteDBExportImport) SaveNote(note *model.Note) (int64, error) {
 	return 1, nil
 }
 
-func (mDB mockNoteDBExportImport) GetNotes(noteIDs []int64) ([]*model.Note, error) {
-	note := model.NewNote("testTitle4", "testMemo", repository.DEFAULT_NOTEBOOK_ID, []string{})
-	note.ID = 4
-	return []*model.Note{note}, nil
+func (mDB mockNoteDBExportImport) GetAttachments(noteID int64) ([]*model.Attachment, error) {
+	return []*model.Attachment{}, nil
 }
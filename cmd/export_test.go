@@ -2,11 +2,15 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
 	"github.com/nicolasmanic/tefter/model"
 	"github.com/nicolasmanic/tefter/repository"
+	"io/ioutil"
 	"os"
-	"reflect"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestExport(t *testing.T) {
@@ -59,13 +63,130 @@ func TestExport(t *testing.T) {
 			os.Remove("notes.json")
 		}()
 
-		err := export(c.ids, c.notebookTitles, c.tags, c.getAll)
-		if !reflect.DeepEqual(c.expectedErr, err) {
+		err := export(c.ids, c.notebookTitles, c.tags, c.getAll, "", repository.NoteQuery{}, exportOptions{Format: exportFormatJSON})
+		if !sameError(c.expectedErr, err) {
 			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
 		}
 	}
 }
 
+func TestExportFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tefter")
+	if err != nil {
+		t.Fatalf("Could not create directory, error msg: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cases := []exportOptions{
+		{Format: exportFormatJSON, Output: "notes.json", Pretty: true},
+		{Format: exportFormatJSONLines, Output: "notes.jsonl"},
+		{Format: exportFormatJSON, Output: "notes.json.gz"},
+		{Format: exportFormatJSONLines, Output: "notes.jsonl.zip"},
+		{Format: exportFormatJSON, Output: "notes.backup", Compress: compressGzip},
+	}
+	for _, options := range cases {
+		options.Output = filepath.Join(dir, options.Output)
+		restore := useMemoryStore(t, model.NewNote("Bali 2018", "photos", repository.DEFAULT_NOTEBOOK_ID, []string{"vacation"}))
+		NoteDB.AddAttachment(model.NewAttachment(1, "photo.png", []byte("png")))
+		createNotebookPath(NotebookDB, "archive/2018")
+		if err := export([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{}, options); err != nil {
+			t.Fatalf("Could not export notes to %v, error msg: %v", options.Output, err)
+		}
+		restore()
+
+		defer useMemoryStore(t)()
+		report, err := importNotes(fileSystemReader{}, options.Output, defaultImportOptions)
+		if err != nil {
+			t.Fatalf("Could not import notes of %v, error msg: %v", options.Output, err)
+		}
+		checkImportReport(t, report, 1, 0, 0, 0)
+		jNotes, _, _ := retrieveJSONNotes([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{})
+		if len(jNotes) != 1 || jNotes[0].Title != "Bali 2018" || jNotes[0].Memo != "photos" {
+			t.Errorf("Unexpected notes imported from %v: %v", options.Output, jNotes)
+		} else if attachments, _ := NoteDB.GetAttachments(jNotes[0].ID); len(attachments) != 1 || attachments[0].Size != 3 {
+			t.Errorf("Unexpected attachments imported from %v: %v", options.Output, attachments)
+		}
+		if notebook, _ := NotebookDB.GetNotebookByTitle("archive/2018"); notebook == nil {
+			t.Errorf("Expected notebook without notes to be imported from %v", options.Output)
+		}
+	}
+
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "notes.json"))
+	if !strings.Contains(string(content), fmt.Sprintf("{\n  \"metadata\": {\n    \"schema_version\": %d,", repository.SchemaVersion)) {
+		t.Errorf("Expected pretty json starting with the metadata, got: %s", content)
+	}
+}
+
+func TestWriteExportReplacesOutput(t *testing.T) {
+	defer useMemoryStore(t, model.NewNote("Bali", "photos", repository.DEFAULT_NOTEBOOK_ID, []string{}))()
+	dir, err := ioutil.TempDir("", "tefter")
+	if err != nil {
+		t.Fatalf("Could not create directory, error msg: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "notes.json")
+	ioutil.WriteFile(output, []byte("previous export"), 0644)
+	options := exportOptions{Format: exportFormatJSON, Output: output}
+
+	//times after year 9999 can not be written as json
+	invalid := []*jsonNote{{ID: 1, Title: "Bali", Memo: "photos", Created: time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)}}
+	if err := writeExport(invalid, []*jsonNotebook{}, options); err == nil {
+		t.Fatalf("Expected an error for a note that can not be written")
+	}
+	if content, _ := ioutil.ReadFile(output); string(content) != "previous export" {
+		t.Errorf("Expected a failed export to keep the previous file, got: %s", content)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected a failed export to leave no partial file, got: %v", files)
+	}
+
+	if err := writeExport([]*jsonNote{{ID: 1, Title: "Bali", Memo: "photos"}}, []*jsonNotebook{}, options); err != nil {
+		t.Fatalf("Could not export notes, error msg: %v", err)
+	}
+	if content, _ := ioutil.ReadFile(output); !strings.Contains(string(content), `"title":"Bali"`) {
+		t.Errorf("Expected the export to replace the previous file, got: %s", content)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 || files[0].Mode().Perm() != 0644 {
+		t.Errorf("Expected only the exported file, got: %v", files)
+	}
+}
+
+func TestExportOptions(t *testing.T) {
+	cases := []struct {
+		options        exportOptions
+		expectedOutput string
+		valid          bool
+	}{
+		{exportOptions{Format: exportFormatJSON}, "notes.json", true},
+		{exportOptions{Format: exportFormatJSONLines, Compress: compressGzip}, "notes.jsonl.gz", true},
+		{exportOptions{Format: exportFormatJSON, Output: stdoutPath, Pretty: true}, stdoutPath, true},
+		{exportOptions{Format: exportFormatMarkdown}, "notes", true},
+		{exportOptions{Format: "xml"}, "notes.json", false},
+		{exportOptions{Format: exportFormatJSON, Compress: "rar"}, "notes.json", false},
+		{exportOptions{Format: exportFormatJSONLines, Pretty: true}, "notes.jsonl", false},
+		{exportOptions{Format: exportFormatMarkdown, Output: stdoutPath}, stdoutPath, false},
+		{exportOptions{Format: exportFormatMarkdown, Output: "notes.zip"}, "notes.zip", false},
+	}
+	for _, c := range cases {
+		if output := c.options.output(); output != c.expectedOutput {
+			t.Errorf("Expected output of %+v to be %v, got: %v", c.options, c.expectedOutput, output)
+		}
+		if err := c.options.validate(); (err == nil) != c.valid || (err != nil && !errors.Is(err, repository.ErrValidation)) {
+			t.Errorf("Unexpected validation of %+v, error msg: %v", c.options, err)
+		}
+	}
+}
+
+func TestImportNewerExport(t *testing.T) {
+	defer useMemoryStore(t)()
+	raw := fmt.Sprintf(`{"metadata":{"schema_version":%d,"tool_version":"v9"},"notes":[{"title":"one","memo":"1"}]}`, repository.SchemaVersion+1)
+	if _, err := importNotes(fakeFileSystemReader{rawBytes: []byte(raw)}, "notes.json", defaultImportOptions); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected a validation error for an export of a newer schema, got: %v", err)
+	}
+	if jNotes, _, _ := retrieveJSONNotes([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{}); len(jNotes) != 0 {
+		t.Errorf("Expected no imported notes, got: %v", jNotes)
+	}
+}
+
 type mockNotebookDBExport struct {
 	repository.NotebookRepository
 	notebook       *model.Notebook
@@ -88,14 +209,10 @@ func (mDB mockNotebookDBExport) GetAllNotebooksTitle() (map[int64]string, error)
 	return mDB.notebookTitles, mDB.err
 }
 
-func (mDB mockNoteDBExport) GetNotesByTag(tags []string) ([]*model.Note, error) {
-	return mDB.notes, mDB.err
+func (mDB mockNoteDBExport) ListNotes(filter repository.NoteFilter, query repository.NoteQuery) (*repository.NotePage, error) {
+	return &repository.NotePage{Notes: mDB.notes}, mDB.err
 }
 
 func (mDB mockNoteDBExport) SaveNote(note *model.Note) (int64, error) {
 	return 1, nil
 }
-
-func (mDB mockNoteDBExport) GetNotes(noteIDs []int64) ([]*model.Note, error) {
-	return mDB.notes, mDB.err
-}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/spf13/cobra"
+	"strconv"
+	"time"
+)
+
+//jsonRevision is the json representation of a revision of a note, see model.Revision.
+type jsonRevision struct {
+	Revision int       `json:"revision"`
+	Title    string    `json:"title"`
+	Memo     string    `json:"memo"`
+	Created  time.Time `json:"created"`
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the revisions of a note",
+	Long: "Every update of the title or memo of a note is kept as a revision, the latest revision is the current note.\n" +
+		"Use diff to compare revisions and restore to bring back an older revision.",
+	Example: "history 1",
+	Args:    cobra.ExactArgs(1),
+	Run:     historyWrapper,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
+
+func historyWrapper(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		exitWithError(fmt.Errorf("ID could not be converted to integer, error msg: %w", err))
+	}
+	jRevisions, err := history(id)
+	if err != nil {
+		exitWithError(err)
+	}
+	printHistory(id, jRevisions)
+}
+
+func history(id int64) ([]*jsonRevision, error) {
+	revisions, err := NoteDB.GetRevisions(id)
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving revisions, error msg: %w", err)
+	}
+	return transformRevisions2JSONRevisions(revisions), nil
+}
+
+func transformRevisions2JSONRevisions(revisions []*model.Revision) []*jsonRevision {
+	jRevisions := make([]*jsonRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		jRevisions = append(jRevisions, &jsonRevision{
+			Revision: revision.Number,
+			Title:    revision.Title,
+			Memo:     revision.Memo,
+			Created:  revision.Created,
+		})
+	}
+	return jRevisions
+}
+
+func printHistory(id int64, jRevisions []*jsonRevision) {
+	fmt.Printf("> Revisions of note %d:\n", id)
+	for _, jRevision := range jRevisions {
+		fmt.Printf(" - %d %s %s (%d lines)\n", jRevision.Revision, jRevision.Created.Local().Format("2006-01-02 15:04:05"),
+			jRevision.Title, len(splitLines(jRevision.Memo)))
+	}
+}
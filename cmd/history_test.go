@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	created := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	oldNoteDB := NoteDB
+	NoteDB = mockNoteDBRevisions{revisions: []*model.Revision{
+		{NoteID: 1, Number: 1, Title: "title", Memo: "first", Created: created},
+		{NoteID: 1, Number: 2, Title: "new title", Memo: "second", Created: created.Add(time.Hour)},
+	}}
+	defer func() {
+		NoteDB = oldNoteDB
+	}()
+
+	jRevisions, err := history(1)
+	if err != nil {
+		t.Fatalf("Could not retrieve history, error msg: %v", err)
+	}
+	expected := []*jsonRevision{
+		{Revision: 1, Title: "title", Memo: "first", Created: created},
+		{Revision: 2, Title: "new title", Memo: "second", Created: created.Add(time.Hour)},
+	}
+	if !reflect.DeepEqual(jRevisions, expected) {
+		t.Errorf("Expected revisions %v but they were %v", expected, jRevisions)
+	}
+	if _, err := history(2); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound but it was %q", err)
+	}
+}
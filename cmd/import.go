@@ -1,34 +1,141 @@
 package cmd
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/nicolasmanic/tefter/model"
 	"github.com/nicolasmanic/tefter/repository"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+	"io"
 	"io/ioutil"
-	"log"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 var importCmd = &cobra.Command{
 	Use:   "import",
-	Short: "Import notes from json file",
-	Long: "Provide a path of a .json file be imported.\n" +
-		"[{\n\t'title':'',\n\t'memo':' ',\n\t'created':'2018-03-19T18:58:29.5553579+02:00',\n\t'updated':'2018-03-19T18:58:29.5553579+02:00',\n\t'tags':[tag1, tag2],\n\t'notebook_title':''\n}]",
+	Short: "Import notes from json file, markdown directory or Evernote export",
+	Long: "Provide a path of a .json file, of a directory of markdown notes, see export --format md, or of an Evernote\n" +
+		".enex file to be imported.\n" +
+		"By default the notes of the DB with the same --key, hash of title and memo or id for markdown notes, are updated\n" +
+		"and the rest are created, so importing an export again changes nothing. Use --mode append to create every note or\n" +
+		"--mode skip-existing to create only the notes not in the DB yet. Ids only match notes with the same creation time,\n" +
+		"ids of an export of another DB belong to unrelated notes. Created notes get new ids, use --preserve-timestamps\n" +
+		"to keep their created and updated times and --dry-run to print the plan without saving anything.\n" +
+		"Notes are saved in transactions of --batch-size notes, or all in one transaction with --atomic. Records that can\n" +
+		"not be imported, eg: notes without memo, are rejected and listed, or written along with a summary to the --report\n" +
+		"json file. If an import fails the notes up to its checkpoint are saved, --resume from the checkpoint imports the rest.\n" +
+		"Markdown notes are imported to the notebook of their front matter or of their directory, notes without\n" +
+		"front matter are titled after their file name. Directories starting with a dot, eg: .git, are skipped.\n" +
+		"Notes of an .enex file are imported to the --notebook notebook, named after the file by default, with their\n" +
+		"content converted to markdown, their embedded files as attachments and their created and updated times.\n" +
+		"An export of tefter, see export, in json or json lines, optionally compressed with gzip or zip, is imported along\n" +
+		"with its notebooks, exports of a newer schema version than the DB are refused. A .json file may also hold an array of notes:\n" +
+		"[{\n\t'title':'',\n\t'memo':' ',\n\t'created':'2018-03-19T18:58:29.5553579+02:00',\n\t'updated':'2018-03-19T18:58:29.5553579+02:00',\n\t'tags':[tag1, tag2],\n\t'notebook_title':'',\n" +
+		"\t'attachments':[{'name':'', 'created':'2018-03-19T18:58:29.5553579+02:00', 'content':'base64 encoded content'}]\n}]",
 	Args:    cobra.ExactArgs(1),
-	Example: "import /c/documents/notes.json ",
+	Example: "import /c/documents/notes.json \n import ~/notes \n import Travel.enex -n lists/travel \n import notes.json --mode upsert --preserve-timestamps --dry-run \n import notes.json --atomic --report report.json \n import notes.jsonl.gz",
 	Run:     importNotesWrapper,
 }
 
 func importNotesWrapper(cmd *cobra.Command, args []string) {
 	fsr := fileSystemReader{}
-	if err := importNotes(fsr, args[0]); err != nil {
-		log.Fatalln(err)
+	path := args[0]
+	options, err := importOptionsFromFlags(cmd)
+	if err != nil {
+		exitWithError(err)
+	}
+	if terminal.IsTerminal(int(os.Stderr.Fd())) {
+		options.Progress = func(records int) {
+			fmt.Fprintf(os.Stderr, "\rRead %d records", records)
+		}
+	}
+	var report *importReport
+	if info, statErr := os.Stat(path); statErr == nil && info.IsDir() {
+		report, err = importMarkdownNotes(fsr, path, options)
+	} else if strings.EqualFold(filepath.Ext(path), enexExtension) {
+		notebookTitle, _ := cmd.Flags().GetString("notebook")
+		report, err = importENEXFile(path, notebookTitle, options)
+	} else {
+		report, err = importNotes(fsr, path, options)
+	}
+	if options.Progress != nil {
+		fmt.Fprintln(os.Stderr)
+	}
+	reportPath, _ := cmd.Flags().GetString("report")
+	if report != nil {
+		if writeErr := writeImportReport(report, reportPath, options, err); writeErr != nil {
+			exitWithError(writeErr)
+		}
+	}
+	if err != nil {
+		exitWithError(err)
 	}
 }
 
+func importOptionsFromFlags(cmd *cobra.Command) (importOptions, error) {
+	options := importOptions{}
+	var err error
+	if options.Mode, err = cmd.Flags().GetString("mode"); err != nil {
+		return options, fmt.Errorf("Error while parsing mode, error msg: %w", err)
+	}
+	if options.Key, err = cmd.Flags().GetString("key"); err != nil {
+		return options, fmt.Errorf("Error while parsing key, error msg: %w", err)
+	}
+	if options.PreserveTimestamps, err = cmd.Flags().GetBool("preserve-timestamps"); err != nil {
+		return options, fmt.Errorf("Error while parsing preserve-timestamps, error msg: %w", err)
+	}
+	if options.DryRun, err = cmd.Flags().GetBool("dry-run"); err != nil {
+		return options, fmt.Errorf("Error while parsing dry-run, error msg: %w", err)
+	}
+	if options.Atomic, err = cmd.Flags().GetBool("atomic"); err != nil {
+		return options, fmt.Errorf("Error while parsing atomic, error msg: %w", err)
+	}
+	if options.BatchSize, err = cmd.Flags().GetInt("batch-size"); err != nil {
+		return options, fmt.Errorf("Error while parsing batch-size, error msg: %w", err)
+	}
+	if options.Resume, err = cmd.Flags().GetInt("resume"); err != nil {
+		return options, fmt.Errorf("Error while parsing resume, error msg: %w", err)
+	}
+	return options, nil
+}
+
+//writeImportReport prints a summary of the import and writes the report as json to reportPath, without a report
+//path the rejected records are printed instead. importErr is the error that stopped the import, if any.
+func writeImportReport(report *importReport, reportPath string, options importOptions, importErr error) error {
+	if reportPath == "" {
+		for _, rejected := range report.Rejected {
+			fmt.Fprintf(os.Stderr, "Rejected record %d %s%q: %v\n", rejected.Index, rejected.Path, rejected.Title, rejected.Error)
+		}
+	} else {
+		marshalledReport, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Error while marshalling report, error msg: %w", err)
+		}
+		if err := ioutil.WriteFile(reportPath, marshalledReport, 0644); err != nil {
+			return fmt.Errorf("Error while writing report, error msg: %w", err)
+		}
+	}
+	if options.DryRun {
+		fmt.Printf("Plan: %d to create, %d to update, %d to skip and %d rejected\n", report.Created, report.Updated, report.Skipped, len(report.Rejected))
+		return nil
+	}
+	fmt.Printf("Created %d, updated %d, skipped %d and rejected %d notes\n", report.Created, report.Updated, report.Skipped, len(report.Rejected))
+	if importErr != nil && !options.Atomic {
+		fmt.Printf("Records up to checkpoint %d are imported, use --resume %d to import the rest\n", report.Checkpoint, report.Checkpoint)
+	}
+	return nil
+}
+
 type fileReader interface {
 	ReadFile(string) ([]byte, error)
+	Open(string) (io.ReadCloser, error)
 }
 
 type fileSystemReader struct{}
@@ -37,31 +144,222 @@ func (fsr fileSystemReader) ReadFile(filepath string) ([]byte, error) {
 	return ioutil.ReadFile(filepath)
 }
 
-func importNotes(fr fileReader, path string) error {
-	raw, err := fr.ReadFile(path)
+func (fsr fileSystemReader) Open(filepath string) (io.ReadCloser, error) {
+	return os.Open(filepath)
+}
+
+//importNotes imports the notes of the file at path according to options, see runImport. The file is either a json
+//array of notes or an export, see export, in json or json lines, the notebooks of an export are created even if they
+//have no notes. Gzip and zip files are decompressed. Records are decoded one at a time so that large files are never
+//loaded in memory.
+func importNotes(fr fileReader, path string, options importOptions) (*importReport, error) {
+	file, err := fr.Open(path)
 	if err != nil {
-		return fmt.Errorf("Error while reading file, error msg: %v", err)
+		return nil, fmt.Errorf("Error while reading file, error msg: %w", err)
+	}
+	defer file.Close()
+	r, err := decompress(file)
+	if err != nil {
+		return nil, fmt.Errorf("Could not decompress file at path: %v, error msg: %w", path, err)
 	}
 
-	var jsonNotes []jsonNote
-	if err = json.Unmarshal(raw, &jsonNotes); err != nil {
-		return fmt.Errorf("Could not unmarshal file at path: %v, error msg: %v", path, err)
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("Could not unmarshal file at path: %v, error msg: %w", path, err)
 	}
+	switch token {
+	case json.Delim('['):
+		return runImport(options, func(imp *importer) error {
+			return decodeNotes(decoder, path, imp)
+		})
+	case json.Delim('{'):
+		//an export starts with its metadata so that it is checked before anything is imported
+		if token, err := decoder.Token(); err != nil || token != "metadata" {
+			return nil, fmt.Errorf("Could not unmarshal file at path: %v, error msg: expected the metadata of an export", path)
+		}
+		if err := decodeExportMetadata(decoder, path); err != nil {
+			return nil, err
+		}
+		return runImport(options, func(imp *importer) error {
+			return decodeExport(decoder, path, imp)
+		})
+	}
+	return nil, fmt.Errorf("Could not unmarshal file at path: %v, error msg: expected an array of notes or an export", path)
+}
 
-	for _, jsonNote := range jsonNotes {
-		note := model.NewNote(jsonNote.Title, jsonNote.Memo, repository.DEFAULT_NOTEBOOK_ID, jsonNote.Tags)
-		err = addNotebookToNote(note, jsonNote.NotebookTitle)
+//decompress returns the content of a gzip file, or of the first file of a zip archive, other files are returned as is.
+func decompress(file io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(buffered)
+	case bytes.Equal(magic, []byte("PK\x03\x04")):
+		archive, err := openZip(file, buffered)
 		if err != nil {
+			return nil, err
+		}
+		for _, entry := range archive.File {
+			if !entry.FileInfo().IsDir() {
+				return entry.Open()
+			}
+		}
+		return nil, errors.New("zip archive contains no file")
+	}
+	return buffered, nil
+}
+
+//openZip reads the zip archive of file, archives of other readers than files are read in memory.
+func openZip(file io.Reader, buffered io.Reader) (*zip.Reader, error) {
+	if osFile, ok := file.(*os.File); ok {
+		if info, err := osFile.Stat(); err == nil && info.Mode().IsRegular() {
+			return zip.NewReader(osFile, info.Size())
+		}
+	}
+	content, err := ioutil.ReadAll(buffered)
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(content), int64(len(content)))
+}
+
+//decodeExport decodes the objects of an export, the opening brace and the metadata of the first one are already
+//read. A json export is a single object while json lines hold an object per line, see jsonLine.
+func decodeExport(decoder *json.Decoder, path string, imp *importer) error {
+	for {
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return fmt.Errorf("Could not unmarshal file at path: %v, error msg: %w", path, err)
+			}
+			switch token {
+			case "metadata":
+				if err := decodeExportMetadata(decoder, path); err != nil {
+					return err
+				}
+			case "notebooks":
+				if err := expectDelim(decoder, '[', path); err != nil {
+					return err
+				}
+				for decoder.More() {
+					if err := decodeNotebook(decoder, path, imp); err != nil {
+						return err
+					}
+				}
+				if err := expectDelim(decoder, ']', path); err != nil {
+					return err
+				}
+			case "notebook":
+				if err := decodeNotebook(decoder, path, imp); err != nil {
+					return err
+				}
+			case "notes":
+				if err := expectDelim(decoder, '[', path); err != nil {
+					return err
+				}
+				if err := decodeNotes(decoder, path, imp); err != nil {
+					return err
+				}
+				if err := expectDelim(decoder, ']', path); err != nil {
+					return err
+				}
+			case "note":
+				if err := decodeNote(decoder, path, imp); err != nil {
+					return err
+				}
+			default:
+				//fields added by later versions are skipped
+				var skipped json.RawMessage
+				if err := decoder.Decode(&skipped); err != nil {
+					return fmt.Errorf("Could not unmarshal file at path: %v, error msg: %w", path, err)
+				}
+			}
+		}
+		if err := expectDelim(decoder, '}', path); err != nil {
 			return err
 		}
-		_, err = NoteDB.SaveNote(note)
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
 		if err != nil {
+			return fmt.Errorf("Could not unmarshal file at path: %v, error msg: %w", path, err)
+		}
+		if token != json.Delim('{') {
+			return fmt.Errorf("Could not unmarshal file at path: %v, error msg: expected an object, got: %v", path, token)
+		}
+	}
+}
+
+//decodeExportMetadata decodes the metadata of an export and returns an error if the export is of a newer schema
+//version than this tefter knows.
+func decodeExportMetadata(decoder *json.Decoder, path string) error {
+	var metadata exportMetadata
+	if err := decoder.Decode(&metadata); err != nil {
+		return fmt.Errorf("Could not unmarshal metadata of file at path: %v, error msg: %w", path, err)
+	}
+	if metadata.SchemaVersion > repository.SchemaVersion {
+		return fmt.Errorf("Export of schema version %d, tefter %v, is newer than schema version %d of tefter %v, error msg: %w",
+			metadata.SchemaVersion, metadata.ToolVersion, repository.SchemaVersion, Version, repository.ErrValidation)
+	}
+	return nil
+}
+
+func decodeNotebook(decoder *json.Decoder, path string, imp *importer) error {
+	var jNotebook jsonNotebook
+	if err := decoder.Decode(&jNotebook); err != nil {
+		return fmt.Errorf("Could not unmarshal notebook of file at path: %v, error msg: %w", path, err)
+	}
+	return imp.addNotebook(jNotebook.Title)
+}
+
+//decodeNotes decodes the notes of an array, its opening bracket is already read.
+func decodeNotes(decoder *json.Decoder, path string, imp *importer) error {
+	for decoder.More() {
+		if err := decodeNote(decoder, path, imp); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+//decodeNote decodes the next note, a record that is valid json but not a note is rejected, invalid json stops the import.
+func decodeNote(decoder *json.Decoder, path string, imp *importer) error {
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return fmt.Errorf("Could not unmarshal file at path: %v, error msg: %w", path, err)
+	}
+	var jNote jsonNote
+	if err := json.Unmarshal(raw, &jNote); err != nil {
+		return imp.rejectNext("", jNote, err)
+	}
+	return imp.add(jNote)
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim, path string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("Could not unmarshal file at path: %v, error msg: %w", path, err)
+	}
+	if token != delim {
+		return fmt.Errorf("Could not unmarshal file at path: %v, error msg: expected %v, got: %v", path, delim, token)
+	}
+	return nil
+}
+
+//defaultImportOptions are the options of an import without flags
+var defaultImportOptions = importOptions{Mode: importModeUpsert, BatchSize: 100}
+
 func init() {
 	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringP("notebook", "n", "", "Path of the notebook of the notes imported from an .enex file, eg: lists/travel")
+	importCmd.Flags().String("mode", defaultImportOptions.Mode, "How notes matching a note of the DB are imported: append, upsert or skip-existing")
+	importCmd.Flags().String("key", "", "Match notes with the notes of the DB by hash of their title and memo or by id, markdown notes by id by default")
+	importCmd.Flags().Bool("preserve-timestamps", false, "Keep the created and updated times of the imported notes")
+	importCmd.Flags().Bool("dry-run", false, "Print the notes that would be created, updated and skipped without saving them")
+	importCmd.Flags().Bool("atomic", false, "Import all notes or none of them in a single transaction")
+	importCmd.Flags().Int("batch-size", defaultImportOptions.BatchSize, "Number of notes saved per transaction, unless --atomic is set")
+	importCmd.Flags().Int("resume", 0, "Skip the records before the checkpoint of a failed import")
+	importCmd.Flags().String("report", "", "Write a json report of the import, with the rejected records, to a file")
 }
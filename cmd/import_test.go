@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"github.com/nicolasmanic/tefter/model"
 	"github.com/nicolasmanic/tefter/repository"
-	"reflect"
+	"io"
+	"io/ioutil"
 	"testing"
+	"time"
 )
 
 func TestImportNotes(t *testing.T) {
@@ -43,7 +47,7 @@ func TestImportNotes(t *testing.T) {
 				rawBytes: []byte(`[{"id":3,"title":"title1","memo":"test\r\n","created":"2018-04-28T20:15:34.0146423+03:00","updated":"2018-04-28T20:15:34.0146423+03:00","tags":["tag1"],"notebook_title":"Default Notebook"}]`),
 			},
 			path:        "test",
-			expectedErr: errors.New("Unexpected error"),
+			expectedErr: errors.New("Error while retrieving Notebooks titles, error msg: Unexpected error"),
 		},
 	}
 
@@ -58,8 +62,8 @@ func TestImportNotes(t *testing.T) {
 			NoteDB = oldNoteDB
 		}()
 
-		err := importNotes(c.fsr, c.path)
-		if !reflect.DeepEqual(c.expectedErr, err) {
+		_, err := importNotes(c.fsr, c.path, defaultImportOptions)
+		if !sameError(c.expectedErr, err) {
 			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
 		}
 	}
@@ -74,6 +78,10 @@ func (fsr fakeFileSystemReader) ReadFile(filepath string) ([]byte, error) {
 	return fsr.rawBytes, fsr.err
 }
 
+func (fsr fakeFileSystemReader) Open(filepath string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(fsr.rawBytes)), fsr.err
+}
+
 type mockNotebookDBImport struct {
 	repository.NotebookRepository
 	notebook       *model.Notebook
@@ -107,3 +115,163 @@ func (mDB mockNoteDBImport) SaveNote(note *model.Note) (int64, error) {
 func (mDB mockNoteDBImport) GetNotes(noteIDs []int64) ([]*model.Note, error) {
 	return mDB.notes, mDB.err
 }
+
+//importJSON imports the notes as a json file.
+func importJSON(t *testing.T, jNotes []jsonNote, options importOptions) (*importReport, error) {
+	t.Helper()
+	raw, err := json.Marshal(jNotes)
+	if err != nil {
+		t.Fatalf("Could not marshal notes, error msg: %v", err)
+	}
+	return importNotes(fakeFileSystemReader{rawBytes: raw}, "notes.json", options)
+}
+
+func checkImportReport(t *testing.T, report *importReport, created, updated, skipped, rejected int) {
+	t.Helper()
+	if report == nil || report.Created != created || report.Updated != updated || report.Skipped != skipped || len(report.Rejected) != rejected {
+		t.Errorf("Expected %d created, %d updated, %d skipped and %d rejected notes, got: %+v", created, updated, skipped, rejected, report)
+	}
+}
+
+func TestImportModes(t *testing.T) {
+	created := time.Date(2018, 3, 19, 18, 58, 29, 0, time.UTC)
+	existingNote := model.NewNote("Bali", "photos", repository.DEFAULT_NOTEBOOK_ID, []string{"vacation"})
+	existingNote.Created = created
+	defer useMemoryStore(t, existingNote)()
+	jNotes := []jsonNote{
+		{ID: 1, Title: "Bali", Memo: "photos and videos", Created: created, LastUpdated: created, Tags: []string{"vacation"}},
+		{ID: 7, Title: "Budget", Memo: "100$", Tags: []string{}},
+		{ID: 7, Title: "Budget", Memo: "200$", Tags: []string{}},
+	}
+	upsert := defaultImportOptions
+	upsert.Mode, upsert.Key, upsert.PreserveTimestamps = importModeUpsert, importKeyID, true
+	dryRun := upsert
+	dryRun.DryRun = true
+	skipExisting := defaultImportOptions
+	skipExisting.Mode, skipExisting.Key = importModeSkipExisting, importKeyHash
+
+	report, err := importJSON(t, jNotes, dryRun)
+	if err != nil {
+		t.Fatalf("Could not plan import, error msg: %v", err)
+	}
+	checkImportReport(t, report, 1, 1, 1, 0)
+	if note, _ := NoteDB.GetNote(1); note.Memo != "photos" {
+		t.Errorf("Dry run should not update notes, got memo: %q", note.Memo)
+	}
+	skipExisting.DryRun = true
+	report, _ = importJSON(t, jNotes, skipExisting)
+	checkImportReport(t, report, 3, 0, 0, 0)
+	skipExisting.DryRun = false
+
+	report, err = importJSON(t, jNotes, upsert)
+	if err != nil {
+		t.Fatalf("Could not import notes, error msg: %v", err)
+	}
+	checkImportReport(t, report, 1, 1, 1, 0)
+	if note, _ := NoteDB.GetNote(1); note.Memo != "photos and videos" || !note.Created.Equal(created) || !note.LastUpdated.Equal(created) {
+		t.Errorf("Expected note to be updated with its timestamps, got: %+v", note)
+	}
+	if notes, _ := NoteDB.GetNotes([]int64{}); len(notes) != 2 {
+		t.Errorf("Expected 2 notes, got: %v", notes)
+	}
+
+	exported, _, err := retrieveJSONNotes([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{})
+	if err != nil {
+		t.Fatalf("Could not retrieve notes, error msg: %v", err)
+	}
+	again := []jsonNote{}
+	for _, jNote := range exported {
+		again = append(again, *jNote)
+	}
+	report, _ = importJSON(t, again, upsert)
+	checkImportReport(t, report, 0, 0, 2, 0)
+	report, _ = importJSON(t, again, skipExisting)
+	checkImportReport(t, report, 0, 0, 2, 0)
+
+	if _, err := importJSON(t, jNotes, importOptions{Mode: "replace", Key: importKeyID, BatchSize: 1}); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected ErrValidation for an unknown mode, got: %v", err)
+	}
+	if _, err := importJSON(t, jNotes, importOptions{Mode: importModeUpsert, Key: "title", BatchSize: 1}); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected ErrValidation for an unknown key, got: %v", err)
+	}
+}
+
+func TestImportRejectedRecords(t *testing.T) {
+	defer useMemoryStore(t)()
+	raw := []byte(`[{"title":"one","memo":"1"},{"title":"empty","memo":""},{"title":"two","memo":"2"},{"title":3},{"title":"four","memo":"4"}]`)
+	options := defaultImportOptions
+	options.Mode, options.BatchSize = importModeAppend, 2
+
+	report, err := importNotes(fakeFileSystemReader{rawBytes: raw}, "notes.json", options)
+	if err != nil {
+		t.Fatalf("Could not import notes, error msg: %v", err)
+	}
+	checkImportReport(t, report, 3, 0, 0, 2)
+	if report.Checkpoint != 5 || report.Rejected[0].Index != 1 || report.Rejected[0].Title != "empty" || report.Rejected[1].Index != 3 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	options.Atomic = true
+	report, err = importNotes(fakeFileSystemReader{rawBytes: raw}, "notes.json", options)
+	if !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected atomic import to fail with ErrValidation, got: %v", err)
+	}
+	checkImportReport(t, report, 0, 0, 0, 1)
+	if notes, _ := NoteDB.GetNotes([]int64{}); len(notes) != 3 {
+		t.Errorf("Expected a failed atomic import to save no notes, got: %v", notes)
+	}
+
+	options.Atomic, options.Resume = false, 4
+	report, err = importNotes(fakeFileSystemReader{rawBytes: raw}, "notes.json", options)
+	if err != nil {
+		t.Fatalf("Could not resume import, error msg: %v", err)
+	}
+	checkImportReport(t, report, 1, 0, 0, 0)
+
+	if _, err := importNotes(fakeFileSystemReader{rawBytes: []byte(`[{"title":"one","memo":"1"},{`)}, "notes.json", defaultImportOptions); err == nil {
+		t.Errorf("Expected an error for invalid json")
+	}
+	if _, err := importNotes(fakeFileSystemReader{rawBytes: []byte(`{"title":"one"}`)}, "notes.json", defaultImportOptions); err == nil {
+		t.Errorf("Expected an error for a file that is not an array of notes")
+	}
+}
+
+func TestImportSameFileTwice(t *testing.T) {
+	defer useMemoryStore(t)()
+	raw := []byte(`[{"id":1,"title":"Bali","memo":"photos","tags":["vacation"]},{"id":2,"title":"Budget","memo":"100$"}]`)
+
+	report, err := importNotes(fakeFileSystemReader{rawBytes: raw}, "notes.json", defaultImportOptions)
+	if err != nil {
+		t.Fatalf("Could not import notes, error msg: %v", err)
+	}
+	checkImportReport(t, report, 2, 0, 0, 0)
+	report, err = importNotes(fakeFileSystemReader{rawBytes: raw}, "notes.json", defaultImportOptions)
+	if err != nil {
+		t.Fatalf("Could not import notes again, error msg: %v", err)
+	}
+	checkImportReport(t, report, 0, 0, 2, 0)
+	if notes, _ := NoteDB.GetNotes([]int64{}); len(notes) != 2 {
+		t.Errorf("Expected importing the same file twice to keep 2 notes, got: %v", notes)
+	}
+}
+
+func TestImportForeignExport(t *testing.T) {
+	defer useMemoryStore(t, model.NewNote("Groceries", "milk", repository.DEFAULT_NOTEBOOK_ID, []string{}))()
+	//note 1 of the other DB is unrelated to note 1 of this DB
+	raw := []byte(`[{"id":1,"title":"Bali","memo":"photos","created":"2018-03-19T18:58:29Z"}]`)
+	byID := defaultImportOptions
+	byID.Key = importKeyID
+
+	for _, options := range []importOptions{defaultImportOptions, byID} {
+		report, err := importNotes(fakeFileSystemReader{rawBytes: raw}, "notes.json", options)
+		if err != nil {
+			t.Fatalf("Could not import notes, error msg: %v", err)
+		}
+		if report.Updated != 0 {
+			t.Errorf("Expected no note of the DB to be updated with key %v, got: %+v", options.Key, report)
+		}
+		if note, _ := NoteDB.GetNote(1); note == nil || note.Title != "Groceries" || note.Memo != "milk" {
+			t.Errorf("Expected the note of the DB to be kept with key %v, got: %+v", options.Key, note)
+		}
+	}
+}
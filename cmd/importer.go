@@ -0,0 +1,493 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"reflect"
+	"strconv"
+)
+
+//Modes of import, notes are matched with the notes of the DB by their --key
+const (
+	//importModeAppend creates every imported note
+	importModeAppend = "append"
+	//importModeUpsert updates the matching notes and creates the rest
+	importModeUpsert = "upsert"
+	//importModeSkipExisting creates the notes without a match only
+	importModeSkipExisting = "skip-existing"
+)
+
+//Keys matching imported notes with the notes of the DB
+const (
+	importKeyID   = "id"
+	importKeyHash = "hash"
+)
+
+//Actions of the steps of an import plan
+const (
+	importActionCreate = "create"
+	importActionUpdate = "update"
+	importActionSkip   = "skip"
+)
+
+type importOptions struct {
+	Mode string
+	//Key is hash unless set, markdown notes are matched by id by default, see importMarkdownNotes
+	Key string
+	//PreserveTimestamps keeps the created and updated times of the imported notes instead of the import time
+	PreserveTimestamps bool
+	//DryRun only plans the import, nothing is saved
+	DryRun bool
+	//Atomic saves every note in a single transaction, nothing is saved if a record is rejected
+	Atomic bool
+	//BatchSize is the number of notes saved per transaction of an import that is not atomic
+	BatchSize int
+	//Resume skips the records before the checkpoint of a failed import, see importReport
+	Resume int
+	//Progress, if set, is called with the number of records read every progressInterval records
+	Progress func(records int)
+}
+
+//progressInterval is the number of records between two calls of importOptions.Progress
+const progressInterval = 100
+
+//importStep is the action taken for an imported record, ExistingID is the id of the matching note of the DB.
+type importStep struct {
+	Index      int
+	Action     string
+	ExistingID int64
+	Reason     string
+	Note       jsonNote
+}
+
+//importReport is the machine readable outcome of an import.
+type importReport struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+	//Checkpoint is the number of records, in file order, that are done. Batches are committed in order, so a failed
+	//import can be resumed from its checkpoint.
+	Checkpoint int              `json:"checkpoint"`
+	Rejected   []rejectedRecord `json:"rejected"`
+}
+
+//rejectedRecord is a record that could not be imported, Index is its position in the file starting from 0
+//and Path is set for records read from a file of their own, eg: markdown notes.
+type rejectedRecord struct {
+	Index int    `json:"index"`
+	Path  string `json:"path,omitempty"`
+	ID    int64  `json:"id,omitempty"`
+	Title string `json:"title"`
+	Error string `json:"error"`
+}
+
+//importer plans and saves the records fed to it, see runImport. Records are saved in batches of BatchSize notes per
+//transaction, a rejected record is left out of its batch which is then saved again.
+type importer struct {
+	options importOptions
+	report  *importReport
+	//records is the number of records fed so far
+	records int
+	//existing maps the key of the notes of the DB to the note, it is only loaded for upsert and skip-existing
+	existing map[string]*jsonNote
+	imported map[string]bool
+	batch    []importStep
+	//noteDB and notebookDB are the repositories of the transaction of an atomic import
+	noteDB     repository.NoteRepository
+	notebookDB repository.NotebookRepository
+}
+
+//runImport imports the records that feed passes to the importer. An error returned by feed, or by saving a batch,
+//stops the import, the batches committed before it are kept unless the import is atomic.
+func runImport(options importOptions, feed func(imp *importer) error) (*importReport, error) {
+	imp, err := newImporter(options)
+	if err != nil {
+		return nil, err
+	}
+	if !options.Atomic || options.DryRun {
+		if err := feed(imp); err != nil {
+			return imp.report, err
+		}
+		return imp.report, imp.flush()
+	}
+
+	err = withTx(func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+		imp.noteDB, imp.notebookDB = noteDB, notebookDB
+		return feed(imp)
+	})
+	if err != nil {
+		//the transaction is rolled back, only the rejected records are left in the report
+		imp.report = &importReport{Rejected: imp.report.Rejected}
+		return imp.report, err
+	}
+	imp.report.Checkpoint = imp.records
+	imp.progress(true)
+	return imp.report, nil
+}
+
+func newImporter(options importOptions) (*importer, error) {
+	if options.Mode != importModeAppend && options.Mode != importModeUpsert && options.Mode != importModeSkipExisting {
+		return nil, fmt.Errorf("Unknown mode: %q, expected append, upsert or skip-existing, error msg: %w", options.Mode, repository.ErrValidation)
+	}
+	if options.Key == "" {
+		options.Key = importKeyHash
+	}
+	if options.Key != importKeyID && options.Key != importKeyHash {
+		return nil, fmt.Errorf("Unknown key: %q, expected id or hash, error msg: %w", options.Key, repository.ErrValidation)
+	}
+	if options.BatchSize < 1 {
+		return nil, fmt.Errorf("Batch size should be positive, got: %d, error msg: %w", options.BatchSize, repository.ErrValidation)
+	}
+	imp := &importer{
+		options:    options,
+		report:     &importReport{Rejected: []rejectedRecord{}},
+		imported:   make(map[string]bool),
+		noteDB:     NoteDB,
+		notebookDB: NotebookDB,
+	}
+	if options.Mode == importModeAppend {
+		return imp, nil
+	}
+	notes, err := NoteDB.GetNotes([]int64{})
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving notes, error msg: %w", err)
+	}
+	existingNotes, err := transformNotes2JSONNotes(notes)
+	if err != nil {
+		return nil, err
+	}
+	imp.existing = make(map[string]*jsonNote, len(existingNotes))
+	for _, existingNote := range existingNotes {
+		imp.existing[importKey(*existingNote, options.Key)] = existingNote
+	}
+	return imp, nil
+}
+
+//add plans the import of the next record and saves it, a dry run prints the step instead.
+func (imp *importer) add(jNote jsonNote) error {
+	index := imp.next()
+	if index < imp.options.Resume {
+		return nil
+	}
+	step, err := imp.plan(index, jNote)
+	if err != nil {
+		return err
+	}
+	if imp.options.DryRun {
+		printImportStep(step)
+	}
+	switch {
+	case step.Action == importActionSkip:
+		imp.report.Skipped++
+	case imp.options.DryRun:
+		imp.count(step)
+	case imp.options.Atomic:
+		if err := saveImportStep(imp.noteDB, imp.notebookDB, step, imp.options); err != nil {
+			return imp.reject(index, "", step.Note, err)
+		}
+		imp.count(step)
+	default:
+		imp.batch = append(imp.batch, step)
+		if len(imp.batch) >= imp.options.BatchSize {
+			return imp.flush()
+		}
+	}
+	return nil
+}
+
+//addNotebook creates the notebook at path, if missing, so that notebooks without notes are imported as well.
+func (imp *importer) addNotebook(path string) error {
+	if imp.options.DryRun {
+		return nil
+	}
+	create := func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+		if _, err := createNotebookPath(notebookDB, path); err != nil {
+			return fmt.Errorf("Error while creating notebook %q, error msg: %w", path, err)
+		}
+		return nil
+	}
+	if imp.options.Atomic {
+		return create(imp.noteDB, imp.notebookDB)
+	}
+	return withTx(create)
+}
+
+//rejectNext reports the next record as rejected for err, an atomic import fails on the first rejected record.
+func (imp *importer) rejectNext(path string, jNote jsonNote, err error) error {
+	index := imp.next()
+	if index < imp.options.Resume {
+		return nil
+	}
+	return imp.reject(index, path, jNote, err)
+}
+
+func (imp *importer) reject(index int, path string, jNote jsonNote, err error) error {
+	imp.report.Rejected = append(imp.report.Rejected, rejectedRecord{
+		Index: index,
+		Path:  path,
+		ID:    jNote.ID,
+		Title: jNote.Title,
+		Error: err.Error(),
+	})
+	if imp.options.Atomic && !imp.options.DryRun {
+		return fmt.Errorf("Record %d is rejected, nothing is imported, error msg: %w", index, err)
+	}
+	return nil
+}
+
+//next returns the index of the next record and reports the progress.
+func (imp *importer) next() int {
+	imp.records++
+	imp.progress(false)
+	return imp.records - 1
+}
+
+func (imp *importer) progress(done bool) {
+	if imp.options.Progress != nil && (done || imp.records%progressInterval == 0) {
+		imp.options.Progress(imp.records)
+	}
+}
+
+func (imp *importer) count(step importStep) {
+	if step.Action == importActionCreate {
+		imp.report.Created++
+	} else {
+		imp.report.Updated++
+	}
+}
+
+//flush saves the batched records in a single transaction and moves the checkpoint past them. Records rejected by
+//the DB are left out of the batch which is saved again.
+func (imp *importer) flush() error {
+	for len(imp.batch) > 0 {
+		failed := -1
+		err := withTx(func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+			for i, step := range imp.batch {
+				if err := saveImportStep(noteDB, notebookDB, step, imp.options); err != nil {
+					failed = i
+					return err
+				}
+			}
+			return nil
+		})
+		if err == nil {
+			break
+		}
+		if failed < 0 || !isRejection(err) {
+			return err
+		}
+		step := imp.batch[failed]
+		imp.reject(step.Index, "", step.Note, err)
+		imp.batch = append(imp.batch[:failed], imp.batch[failed+1:]...)
+	}
+	for _, step := range imp.batch {
+		imp.count(step)
+	}
+	imp.batch = imp.batch[:0]
+	if !imp.options.DryRun {
+		imp.report.Checkpoint = imp.records
+	}
+	imp.progress(true)
+	return nil
+}
+
+//isRejection returns true if err is caused by the record being saved rather than by the DB.
+func isRejection(err error) bool {
+	return errors.Is(err, repository.ErrValidation) || errors.Is(err, repository.ErrNoteNotFound)
+}
+
+//plan returns the action taken for a record. Records are matched with the notes of the DB by id or by the hash
+//of their title and memo, see noteContentHash, records without id never match by id. Ids of other DBs belong to
+//unrelated notes, so a note only matches by id if it has the creation time of the record, see sameNote.
+//An upsert of a note that would not change it is skipped so that importing an export again changes nothing. Records
+//matching a record imported before them are skipped as duplicates.
+func (imp *importer) plan(index int, jNote jsonNote) (importStep, error) {
+	step := importStep{Index: index, Action: importActionCreate, Note: jNote}
+	if imp.options.Mode == importModeAppend {
+		return step, nil
+	}
+	key := importKey(jNote, imp.options.Key)
+	existingNote := imp.existing[key]
+	if existingNote != nil && imp.options.Key == importKeyID && !sameNote(existingNote, jNote) {
+		existingNote = nil
+	}
+	switch {
+	case key != "" && imp.imported[key]:
+		step.Action, step.Reason = importActionSkip, "duplicate"
+	case existingNote == nil:
+	case imp.options.Mode == importModeSkipExisting:
+		step.Action, step.ExistingID, step.Reason = importActionSkip, existingNote.ID, "exists"
+	default:
+		changed, err := changesNote(imp.noteDB, existingNote, jNote, imp.options)
+		if err != nil {
+			return step, err
+		}
+		step.Action, step.ExistingID = importActionUpdate, existingNote.ID
+		if !changed {
+			step.Action, step.Reason = importActionSkip, "unchanged"
+		}
+	}
+	if key != "" {
+		imp.imported[key] = true
+	}
+	return step, nil
+}
+
+func printImportStep(step importStep) {
+	switch step.Action {
+	case importActionCreate:
+		fmt.Printf("%s %q\n", step.Action, step.Note.Title)
+	case importActionUpdate:
+		fmt.Printf("%s %d %q\n", step.Action, step.ExistingID, step.Note.Title)
+	default:
+		fmt.Printf("%s %d %q (%s)\n", step.Action, step.ExistingID, step.Note.Title, step.Reason)
+	}
+}
+
+//importKey returns the key matching the note with the notes of the DB, notes without id have no id key.
+func importKey(jNote jsonNote, key string) string {
+	if key == importKeyHash {
+		return noteContentHash(jNote.Title, jNote.Memo)
+	}
+	if jNote.ID == 0 {
+		return ""
+	}
+	return strconv.FormatInt(jNote.ID, 10)
+}
+
+//sameNote returns true if jNote has the creation time of the existing note, records without creation time are
+//compared by title. Titles are not compared otherwise so that a note renamed since its export still matches.
+func sameNote(existingNote *jsonNote, jNote jsonNote) bool {
+	if jNote.Created.IsZero() {
+		return existingNote.Title == jNote.Title
+	}
+	return jNote.Created.Equal(existingNote.Created)
+}
+
+//noteContentHash returns the sha256 hash of the title and memo of a note.
+func noteContentHash(title, memo string) string {
+	return model.ContentHash([]byte(title + "\x00" + memo))
+}
+
+//changesNote returns true if updating the existing note with jNote changes it, notes keep their notebook
+//when jNote has none.
+func changesNote(noteDB repository.NoteRepository, existingNote *jsonNote, jNote jsonNote, options importOptions) (bool, error) {
+	if existingNote.Title != jNote.Title || existingNote.Memo != jNote.Memo {
+		return true, nil
+	}
+	if jNote.NotebookTitle != "" && existingNote.NotebookTitle != jNote.NotebookTitle {
+		return true, nil
+	}
+	if !reflect.DeepEqual(tagSlice2Map(jNote.Tags), tagSlice2Map(existingNote.Tags)) {
+		return true, nil
+	}
+	if options.PreserveTimestamps && (!jNote.Created.IsZero() && !jNote.Created.Equal(existingNote.Created) ||
+		!jNote.LastUpdated.IsZero() && !jNote.LastUpdated.Equal(existingNote.LastUpdated)) {
+		return true, nil
+	}
+	missing, err := missingAttachments(noteDB, existingNote.ID, jNote.Attachments)
+	return len(missing) > 0, err
+}
+
+//missingAttachments returns the attachments that the note does not have yet, attachments are compared by name
+//and content.
+func missingAttachments(noteDB repository.NoteRepository, noteID int64, jAttachments []*jsonAttachment) ([]*jsonAttachment, error) {
+	if len(jAttachments) == 0 {
+		return nil, nil
+	}
+	attachments, err := noteDB.GetAttachments(noteID)
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving attachments, error msg: %w", err)
+	}
+	existing := make(map[string]bool, len(attachments))
+	for _, attachment := range attachments {
+		existing[attachment.Name+"\x00"+attachment.Hash] = true
+	}
+	missing := []*jsonAttachment{}
+	for _, jAttachment := range jAttachments {
+		if !existing[jAttachment.Name+"\x00"+model.ContentHash(jAttachment.Content)] {
+			missing = append(missing, jAttachment)
+		}
+	}
+	return missing, nil
+}
+
+//saveImportStep creates or updates the note of the step along with its attachments, notebooks missing from the DB
+//are created.
+func saveImportStep(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository, step importStep, options importOptions) error {
+	if step.Action == importActionUpdate {
+		return updateImportedNote(noteDB, notebookDB, step.ExistingID, step.Note, options)
+	}
+	note := model.NewNote(step.Note.Title, step.Note.Memo, repository.DEFAULT_NOTEBOOK_ID, step.Note.Tags)
+	if options.PreserveTimestamps {
+		preserveTimestamps(note, step.Note)
+	}
+	return saveImportedNote(noteDB, notebookDB, note, step.Note.NotebookTitle, step.Note.Attachments)
+}
+
+//preserveTimestamps sets the created and updated times of jNote to the note, unless they are missing.
+func preserveTimestamps(note *model.Note, jNote jsonNote) {
+	if !jNote.Created.IsZero() {
+		note.Created = jNote.Created
+	}
+	if !jNote.LastUpdated.IsZero() {
+		note.LastUpdated = jNote.LastUpdated
+	}
+}
+
+//saveImportedNote saves the note to the notebook at notebookTitle along with its attachments.
+func saveImportedNote(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository, note *model.Note, notebookTitle string, jAttachments []*jsonAttachment) error {
+	//moving the note to its notebook is part of the import, the note keeps its last update time
+	lastUpdated := note.LastUpdated
+	if err := addNotebookToNote(notebookDB, note, notebookTitle); err != nil {
+		return err
+	}
+	note.LastUpdated = lastUpdated
+	if _, err := noteDB.SaveNote(note); err != nil {
+		return err
+	}
+	return addImportedAttachments(noteDB, note.ID, jAttachments)
+}
+
+//updateImportedNote updates the note with id to jNote, the attachments it does not have yet are added.
+func updateImportedNote(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository, id int64, jNote jsonNote, options importOptions) error {
+	note, err := noteDB.GetNote(id)
+	if err != nil {
+		return err
+	}
+	note.Title = jNote.Title
+	note.Tags = make(map[string]bool)
+	note.AddTags(jNote.Tags)
+	note.UpdateMemo(jNote.Memo)
+	if jNote.NotebookTitle != "" {
+		if err := addNotebookToNote(notebookDB, note, jNote.NotebookTitle); err != nil {
+			return err
+		}
+	}
+	if options.PreserveTimestamps {
+		preserveTimestamps(note, jNote)
+	}
+	if err := noteDB.UpdateNote(note); err != nil {
+		return err
+	}
+	missing, err := missingAttachments(noteDB, id, jNote.Attachments)
+	if err != nil {
+		return err
+	}
+	return addImportedAttachments(noteDB, id, missing)
+}
+
+func addImportedAttachments(noteDB repository.NoteRepository, noteID int64, jAttachments []*jsonAttachment) error {
+	for _, jAttachment := range jAttachments {
+		attachment := model.NewAttachment(noteID, jAttachment.Name, jAttachment.Content)
+		if !jAttachment.Created.IsZero() {
+			attachment.Created = jAttachment.Created
+		}
+		if _, err := noteDB.AddAttachment(attachment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/spf13/cobra"
+	"strconv"
+)
+
+var (
+	linksCmd = &cobra.Command{
+		Use:   "links",
+		Short: "List the notes that a note links to",
+		Long: "Notes link to other notes with [[title]] or [[#id]] in their memo, eg: see [[Bali 2018]] or [[#42]].\n" +
+			"Links by title match every note with that exact title, links that match no note are listed too.",
+		Example: "links 42",
+		Args:    cobra.ExactArgs(1),
+		Run:     linksWrapper,
+	}
+	backlinksCmd = &cobra.Command{
+		Use:     "backlinks",
+		Short:   "List the notes that link to a note",
+		Long:    "List the notes whose memo links to the note with [[title]] or [[#id]], see links.",
+		Example: "backlinks 42",
+		Args:    cobra.ExactArgs(1),
+		Run:     backlinksWrapper,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(linksCmd)
+	rootCmd.AddCommand(backlinksCmd)
+}
+
+func linksWrapper(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		exitWithError(fmt.Errorf("ID could not be converted to integer, error msg: %w", err))
+	}
+	jNotes, unresolved, err := links(id)
+	if err != nil {
+		exitWithError(err)
+	}
+	if len(jNotes) == 0 && len(unresolved) == 0 {
+		fmt.Printf("Note %d has no links\n", id)
+		return
+	}
+	fmt.Printf("> Links of note %d:\n", id)
+	printLinkedNotes(jNotes)
+	for _, link := range unresolved {
+		fmt.Printf(" - %s (no such note)\n", link)
+	}
+}
+
+func backlinksWrapper(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		exitWithError(fmt.Errorf("ID could not be converted to integer, error msg: %w", err))
+	}
+	jNotes, err := backlinks(id)
+	if err != nil {
+		exitWithError(err)
+	}
+	if len(jNotes) == 0 {
+		fmt.Printf("No note links to note %d\n", id)
+		return
+	}
+	fmt.Printf("> Backlinks of note %d:\n", id)
+	printLinkedNotes(jNotes)
+}
+
+//links returns the notes that the memo of the note links to, along with the links that match no note.
+func links(id int64) ([]*jsonNote, []model.Link, error) {
+	note, err := NoteDB.GetNote(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error while retrieving Note from DB, error msg: %w", err)
+	}
+	notes, err := NoteDB.GetLinks(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error while retrieving links, error msg: %w", err)
+	}
+	unresolved := []model.Link{}
+	for _, link := range model.ParseLinks(note.Memo) {
+		resolved := false
+		for _, linked := range notes {
+			resolved = resolved || link.LinksTo(linked)
+		}
+		if !resolved {
+			unresolved = append(unresolved, link)
+		}
+	}
+	jNotes, err := transformNotes2JSONNotes(notes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return jNotes, unresolved, nil
+}
+
+//backlinks returns the notes whose memo links to the note.
+func backlinks(id int64) ([]*jsonNote, error) {
+	notes, err := NoteDB.GetBacklinks(id)
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving backlinks, error msg: %w", err)
+	}
+	return transformNotes2JSONNotes(notes)
+}
+
+func printLinkedNotes(jNotes []*jsonNote) {
+	for _, jNote := range jNotes {
+		fmt.Printf(" - %d %s (%s)\n", jNote.ID, jNote.Title, jNote.NotebookTitle)
+	}
+}
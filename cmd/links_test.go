@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"reflect"
+	"testing"
+)
+
+func jsonNoteIDs(jNotes []*jsonNote) []int64 {
+	ids := []int64{}
+	for _, jNote := range jNotes {
+		ids = append(ids, jNote.ID)
+	}
+	return ids
+}
+
+func TestLinks(t *testing.T) {
+	defer useMemoryStore(t,
+		model.NewNote("Bali 2018", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+		model.NewNote("trip", "see [[Bali 2018]], [[#1]] and [[packing list]]", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+	)()
+
+	jNotes, unresolved, err := links(2)
+	if err != nil {
+		t.Fatalf("Could not retrieve links, error msg: %v", err)
+	}
+	if ids := jsonNoteIDs(jNotes); !reflect.DeepEqual(ids, []int64{1}) {
+		t.Errorf("Expected links to note 1, got: %v", ids)
+	}
+	if expected := []model.Link{{Title: "packing list"}}; !reflect.DeepEqual(unresolved, expected) {
+		t.Errorf("Expected unresolved links %v, got: %v", expected, unresolved)
+	}
+	jNotes, err = backlinks(1)
+	if ids := jsonNoteIDs(jNotes); err != nil || !reflect.DeepEqual(ids, []int64{2}) {
+		t.Errorf("Expected backlinks from note 2, got: %v, error msg: %v", ids, err)
+	}
+	if _, _, err := links(42); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got: %v", err)
+	}
+}
+
+func TestUpdateRewritesLinks(t *testing.T) {
+	cases := []struct {
+		rewriteLinks bool
+		expectedMemo string
+	}{
+		{rewriteLinks: false, expectedMemo: "see [[Bali 2018]] and [[#1]]"},
+		{rewriteLinks: true, expectedMemo: "see [[Bali trip]] and [[#1]]"},
+	}
+
+	for _, c := range cases {
+		restore := useMemoryStore(t,
+			model.NewNote("Bali 2018", "back to [[Bali 2018]]", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+			model.NewNote("trip", "see [[Bali 2018]] and [[#1]]", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+		)
+		err := updateJSONNote(&jsonNote{ID: 1, Title: "Bali trip", Memo: "back to [[Bali 2018]]"}, c.rewriteLinks)
+		if err != nil {
+			t.Errorf("Could not update note, error msg: %v", err)
+		}
+		linking, _ := NoteDB.GetNote(2)
+		renamed, _ := NoteDB.GetNote(1)
+		restore()
+		if linking.Memo != c.expectedMemo {
+			t.Errorf("Expected memo %q, got: %q", c.expectedMemo, linking.Memo)
+		}
+		if c.rewriteLinks && renamed.Memo != "back to [[Bali trip]]" {
+			t.Errorf("Expected the links of the note to itself to be rewritten, got: %q", renamed.Memo)
+		}
+	}
+}
+
+func TestShowLinkedNotes(t *testing.T) {
+	defer useMemoryStore(t,
+		model.NewNote("first", "see [[second]]", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+		model.NewNote("second", "see [[first]]", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+		model.NewNote("third", "see [[first]]", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+	)()
+	jNotes, _ := transformNotes2JSONNotes([]*model.Note{{ID: 1, Title: "first", Memo: "see [[second]]"}})
+
+	linkedNotes := constructLinkedNotes()
+	showLinkedNotes(linkedNotes, jNotes[0], func(jNote *jsonNote) {})
+	items := []string{}
+	for i := 0; i < linkedNotes.GetItemCount(); i++ {
+		text, _ := linkedNotes.GetItemText(i)
+		items = append(items, text)
+	}
+	if expected := []string{"<- third", "<- second", "-> second"}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected linked notes %v, got: %v", expected, items)
+	}
+}
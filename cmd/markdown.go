@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/nicolasmanic/tefter/repository"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Markdown notes are .md files with the fields of the note in a yaml front matter followed by the memo:
+//
+//	---
+//	id: 42
+//	title: Bali 2018
+//	notebook: lists/travel
+//	tags: [vacation, summer]
+//	created: 2018-03-19T18:58:29.5553579+02:00
+//	updated: 2018-03-19T18:58:29.5553579+02:00
+//	---
+//	memo
+//
+//Notes are grouped into a directory per notebook, eg: lists/travel/Bali 2018.md, and their attachments are
+//kept in a directory next to them named after the note, eg: lists/travel/Bali 2018.attachments/photo.png.
+const (
+	markdownExtension       = ".md"
+	attachmentsDirExtension = ".attachments"
+	frontMatterDelimiter    = "---"
+	maxFileNameLength       = 100
+)
+
+type markdownFrontMatter struct {
+	ID          int64     `yaml:"id,omitempty"`
+	Title       string    `yaml:"title"`
+	Notebook    string    `yaml:"notebook,omitempty"`
+	Tags        []string  `yaml:"tags,flow"`
+	Created     time.Time `yaml:"created,omitempty"`
+	Updated     time.Time `yaml:"updated,omitempty"`
+	Attachments []string  `yaml:"attachments,flow,omitempty"`
+}
+
+//unsafeFileNameChars are replaced in file names since they are not allowed by some file systems.
+var unsafeFileNameChars = strings.NewReplacer(
+	"/", "-", "\\", "-", ":", "-", "*", "-", "?", "-", "\"", "-", "<", "-", ">", "-", "|", "-")
+
+//writeMarkdownNotes writes every note to a markdown file under dir, in the directory of its notebook.
+//Existing files are overwritten so that exporting to a git repository again updates the notes, and the files of
+//a previous export that no longer map to a note are removed, see removeStaleMarkdownNotes. all is set if jNotes
+//are all the notes of the DB.
+func writeMarkdownNotes(jNotes []*jsonNote, dir string, all bool) error {
+	//notes are written oldest first so that a note keeps its file name whatever the sort of the export
+	sorted := append([]*jsonNote{}, jNotes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	usedPaths := make(map[string]bool)
+	exportedIDs := make(map[int64]bool, len(sorted))
+	for _, jNote := range sorted {
+		exportedIDs[jNote.ID] = true
+		notebookDir := dir
+		for _, title := range repository.SplitNotebookPath(jNote.NotebookTitle) {
+			notebookDir = filepath.Join(notebookDir, fileName(title, "notebook"))
+		}
+		if err := os.MkdirAll(notebookDir, 0755); err != nil {
+			return fmt.Errorf("Error while creating directory, error msg: %w", err)
+		}
+		path := uniqueNotePath(usedPaths, notebookDir, jNote)
+		if err := writeMarkdownNote(jNote, path); err != nil {
+			return fmt.Errorf("Error while writing note %d, error msg: %w", jNote.ID, err)
+		}
+	}
+	return removeStaleMarkdownNotes(dir, usedPaths, exportedIDs, all)
+}
+
+//removeStaleMarkdownNotes removes the markdown notes under dir that were not written to usedPaths along with their
+//attachments, eg: notes renamed or moved to another notebook since a previous export, so that importing dir does not
+//bring them back. Notes of ids that were not exported are only removed if all notes were exported, they were deleted
+//from the DB then. Files without the id of an exported note in their front matter are kept, eg: a README.md.
+func removeStaleMarkdownNotes(dir string, usedPaths map[string]bool, exportedIDs map[int64]bool, all bool) error {
+	stalePaths := []string{}
+	err := walkMarkdownNotes(dir, func(path, relativeDir string) error {
+		if usedPaths[strings.ToLower(path)] {
+			return nil
+		}
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		frontMatter, _, err := parseMarkdownNote(raw)
+		if err != nil || frontMatter == nil || frontMatter.ID == 0 {
+			return nil
+		}
+		if all || exportedIDs[frontMatter.ID] {
+			stalePaths = append(stalePaths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	//files are removed after the walk, removing them while walking would remove directories not walked yet
+	for _, path := range stalePaths {
+		if err := os.RemoveAll(strings.TrimSuffix(path, filepath.Ext(path)) + attachmentsDirExtension); err != nil {
+			return fmt.Errorf("Error while removing attachments of %v, error msg: %w", path, err)
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("Error while removing %v, error msg: %w", path, err)
+		}
+	}
+	return nil
+}
+
+//uniqueNotePath returns the path of the markdown file of the note, notes of the same notebook with the same title
+//get their id appended to the file name. Paths are compared case insensitively for case insensitive file systems.
+func uniqueNotePath(usedPaths map[string]bool, notebookDir string, jNote *jsonNote) string {
+	name := fileName(jNote.Title, "note "+strconv.FormatInt(jNote.ID, 10))
+	path := filepath.Join(notebookDir, name+markdownExtension)
+	if usedPaths[strings.ToLower(path)] {
+		path = filepath.Join(notebookDir, name+" "+strconv.FormatInt(jNote.ID, 10)+markdownExtension)
+	}
+	usedPaths[strings.ToLower(path)] = true
+	return path
+}
+
+func writeMarkdownNote(jNote *jsonNote, path string) error {
+	frontMatter := markdownFrontMatter{
+		ID:       jNote.ID,
+		Title:    jNote.Title,
+		Notebook: jNote.NotebookTitle,
+		Tags:     jNote.Tags,
+		Created:  jNote.Created,
+		Updated:  jNote.LastUpdated,
+	}
+	//attachments of a previous export are removed, the note may no longer have them
+	attachmentsDir := strings.TrimSuffix(path, markdownExtension) + attachmentsDirExtension
+	if err := os.RemoveAll(attachmentsDir); err != nil {
+		return err
+	}
+	if len(jNote.Attachments) > 0 {
+		if err := os.MkdirAll(attachmentsDir, 0755); err != nil {
+			return err
+		}
+		usedNames := make(map[string]bool, len(jNote.Attachments))
+		for _, jAttachment := range jNote.Attachments {
+			name := uniqueAttachmentName(usedNames, jAttachment)
+			if err := ioutil.WriteFile(filepath.Join(attachmentsDir, name), jAttachment.Content, 0644); err != nil {
+				return err
+			}
+			frontMatter.Attachments = append(frontMatter.Attachments, name)
+		}
+	}
+	marshalledFrontMatter, err := yaml.Marshal(frontMatter)
+	if err != nil {
+		return err
+	}
+	content := frontMatterDelimiter + "\n" + string(marshalledFrontMatter) + frontMatterDelimiter + "\n" + jNote.Memo
+	return ioutil.WriteFile(path, []byte(content), 0644)
+}
+
+//uniqueAttachmentName returns the file name of the attachment, attachments of the same note with the same name get
+//their id appended to the file name before its extension, eg: photo 12.png.
+func uniqueAttachmentName(usedNames map[string]bool, jAttachment *jsonAttachment) string {
+	id := strconv.FormatInt(jAttachment.ID, 10)
+	name := fileName(jAttachment.Name, "attachment "+id)
+	if usedNames[strings.ToLower(name)] {
+		extension := filepath.Ext(name)
+		name = strings.TrimSuffix(name, extension) + " " + id + extension
+	}
+	usedNames[strings.ToLower(name)] = true
+	return name
+}
+
+//fileName returns name without the characters that are not allowed in file names, or fallback if nothing is left.
+func fileName(name, fallback string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' {
+			return -1
+		}
+		return r
+	}, unsafeFileNameChars.Replace(name))
+	//names starting with a dot are hidden and skipped on import
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if runes := []rune(name); len(runes) > maxFileNameLength {
+		name = string(runes[:maxFileNameLength])
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		return fallback
+	}
+	return name
+}
+
+//importMarkdownNotes imports the markdown notes found under dir according to options, see runImport. Notes are
+//matched by the id of their front matter unless options set a key, so that notes exported and then edited update
+//their note of the DB. Files that can not be read as notes are rejected.
+func importMarkdownNotes(fr fileReader, dir string, options importOptions) (*importReport, error) {
+	if options.Key == "" {
+		options.Key = importKeyID
+	}
+	return runImport(options, func(imp *importer) error {
+		return walkMarkdownNotes(dir, func(path, relativeDir string) error {
+			jNote, err := readMarkdownNote(fr, path, relativeDir)
+			if err != nil {
+				return imp.rejectNext(path, jNote, fmt.Errorf("Could not read note at path: %v, error msg: %w", path, err))
+			}
+			return imp.add(jNote)
+		})
+	})
+}
+
+//walkMarkdownNotes calls fn for the markdown notes found under dir sorted by path, with the directory of the note
+//relative to dir. Directories starting with a dot, eg: .git, and the attachment directories of notes are skipped.
+func walkMarkdownNotes(dir string, fn func(path, relativeDir string) error) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("Error while reading directory, error msg: %w", err)
+		}
+		if info.IsDir() {
+			if path != dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if isAttachmentsDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), markdownExtension) {
+			return nil
+		}
+		relativeDir, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		return fn(path, relativeDir)
+	})
+}
+
+//isAttachmentsDir returns true if path is the attachment directory of a markdown note.
+func isAttachmentsDir(path string) bool {
+	if filepath.Ext(path) != attachmentsDirExtension {
+		return false
+	}
+	_, err := os.Stat(strings.TrimSuffix(path, attachmentsDirExtension) + markdownExtension)
+	return err == nil
+}
+
+//readMarkdownNote reads the note at path, relativeDir is the directory of the note relative to the imported
+//directory and is used as its notebook path unless the front matter sets one.
+func readMarkdownNote(fr fileReader, path, relativeDir string) (jsonNote, error) {
+	raw, err := fr.ReadFile(path)
+	if err != nil {
+		return jsonNote{}, err
+	}
+	frontMatter, memo, err := parseMarkdownNote(raw)
+	if err != nil {
+		return jsonNote{}, err
+	}
+	if frontMatter == nil {
+		frontMatter = &markdownFrontMatter{Title: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+	}
+	jNote := jsonNote{
+		ID:            frontMatter.ID,
+		Title:         frontMatter.Title,
+		Memo:          memo,
+		Created:       frontMatter.Created,
+		LastUpdated:   frontMatter.Updated,
+		Tags:          frontMatter.Tags,
+		NotebookTitle: frontMatter.Notebook,
+	}
+	if jNote.NotebookTitle == "" && relativeDir != "." {
+		jNote.NotebookTitle = filepath.ToSlash(relativeDir)
+	}
+	attachmentsDir := strings.TrimSuffix(path, filepath.Ext(path)) + attachmentsDirExtension
+	for _, name := range frontMatter.Attachments {
+		content, err := fr.ReadFile(filepath.Join(attachmentsDir, filepath.Base(name)))
+		if err != nil {
+			return jsonNote{}, err
+		}
+		jNote.Attachments = append(jNote.Attachments, &jsonAttachment{Name: name, Content: content})
+	}
+	return jNote, nil
+}
+
+//parseMarkdownNote splits a markdown note into its front matter and memo, the front matter is nil
+//if the note has none.
+func parseMarkdownNote(raw []byte) (*markdownFrontMatter, string, error) {
+	lines := bytes.SplitAfter(raw, []byte("\n"))
+	if !isFrontMatterDelimiter(lines[0]) {
+		return nil, string(raw), nil
+	}
+	for i := 1; i < len(lines); i++ {
+		if isFrontMatterDelimiter(lines[i]) {
+			frontMatter := &markdownFrontMatter{}
+			if err := yaml.Unmarshal(bytes.Join(lines[1:i], nil), frontMatter); err != nil {
+				return nil, "", fmt.Errorf("Invalid front matter, error msg: %w", err)
+			}
+			return frontMatter, string(bytes.Join(lines[i+1:], nil)), nil
+		}
+	}
+	return nil, "", fmt.Errorf("Front matter is not closed with %v", frontMatterDelimiter)
+}
+
+func isFrontMatterDelimiter(line []byte) bool {
+	return string(bytes.TrimRight(line, "\r\n")) == frontMatterDelimiter
+}
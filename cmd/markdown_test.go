@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFileName(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"Bali 2018", "Bali 2018"},
+		{"a/b: c?", "a-b- c-"},
+		{" .hidden\n", "hidden"},
+		{"...", "fallback"},
+		{strings.Repeat("α", 120), strings.Repeat("α", 100)},
+	}
+	for _, c := range cases {
+		if name := fileName(c.name, "fallback"); name != c.expected {
+			t.Errorf("Expected file name of %q to be %q, got: %q", c.name, c.expected, name)
+		}
+	}
+}
+
+func TestParseMarkdownNote(t *testing.T) {
+	frontMatter, memo, err := parseMarkdownNote([]byte("---\r\ntitle: trip\r\ntags: [a, b]\r\n---\r\nmemo\n---\n"))
+	if err != nil || frontMatter.Title != "trip" || !reflect.DeepEqual(frontMatter.Tags, []string{"a", "b"}) || memo != "memo\n---\n" {
+		t.Errorf("Unexpected front matter: %+v, memo: %q, error msg: %v", frontMatter, memo, err)
+	}
+	frontMatter, memo, err = parseMarkdownNote([]byte("# title\nmemo"))
+	if err != nil || frontMatter != nil || memo != "# title\nmemo" {
+		t.Errorf("Expected no front matter, got: %+v, memo: %q, error msg: %v", frontMatter, memo, err)
+	}
+	if _, _, err = parseMarkdownNote([]byte("---\ntitle: trip\nmemo")); err == nil {
+		t.Errorf("Expected an error for a front matter that is not closed")
+	}
+	if _, _, err = parseMarkdownNote([]byte("---\ntags: [\n---\nmemo")); err == nil {
+		t.Errorf("Expected an error for an invalid front matter")
+	}
+}
+
+func TestMarkdownExportImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tefter")
+	if err != nil {
+		t.Fatalf("Could not create directory, error msg: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	restore := useMemoryStore(t,
+		model.NewNote("Bali 2018", "photos\n", repository.DEFAULT_NOTEBOOK_ID, []string{"vacation"}),
+		model.NewNote("Bali 2018", "budget\n", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+	)
+	defer restore()
+	NoteDB.AddAttachment(model.NewAttachment(1, "photo.png", []byte("png")))
+	note, _ := NoteDB.GetNote(2)
+	if err := addNotebookToNote(NotebookDB, note, "lists/travel"); err != nil {
+		t.Fatalf("Could not add notebook, error msg: %v", err)
+	}
+	NoteDB.UpdateNote(note)
+	if err := export([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{}, exportOptions{Format: exportFormatMarkdown, Output: dir}); err != nil {
+		t.Fatalf("Could not export notes, error msg: %v", err)
+	}
+	for _, path := range []string{"Default Notebook/Bali 2018.md", "Default Notebook/Bali 2018.attachments/photo.png", "lists/travel/Bali 2018.md"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("Expected %v to be exported, error msg: %v", path, err)
+		}
+	}
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".git", "skipped.md"), []byte("skipped"), 0644)
+	os.MkdirAll(filepath.Join(dir, "work"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "work", "Standup.md"), []byte("no front matter"), 0644)
+
+	defer useMemoryStore(t)()
+	if _, err := importMarkdownNotes(fileSystemReader{}, dir, defaultImportOptions); err != nil {
+		t.Fatalf("Could not import notes, error msg: %v", err)
+	}
+	jNotes, _, err := retrieveJSONNotes([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{Sort: repository.SortByTitle})
+	if err != nil || len(jNotes) != 3 {
+		t.Fatalf("Expected 3 imported notes, got: %v, error msg: %v", jNotes, err)
+	}
+	imported := make(map[string]*jsonNote)
+	for _, jNote := range jNotes {
+		imported[jNote.NotebookTitle+"/"+jNote.Title] = jNote
+	}
+	if jNote := imported["Default Notebook/Bali 2018"]; jNote == nil || jNote.Memo != "photos\n" || !reflect.DeepEqual(jNote.Tags, []string{"vacation"}) {
+		t.Errorf("Unexpected imported note: %+v", jNote)
+	} else if attachments, _ := NoteDB.GetAttachments(jNote.ID); len(attachments) != 1 || attachments[0].Name != "photo.png" {
+		t.Errorf("Unexpected imported attachments: %v", attachments)
+	}
+	if jNote := imported["lists/travel/Bali 2018"]; jNote == nil || jNote.Memo != "budget\n" {
+		t.Errorf("Unexpected imported note: %+v", jNote)
+	}
+	if jNote := imported["work/Standup"]; jNote == nil || jNote.Memo != "no front matter" {
+		t.Errorf("Expected note without front matter to be titled after its file, got: %v", imported)
+	}
+}
+
+func TestMarkdownExportSameAttachmentNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tefter")
+	if err != nil {
+		t.Fatalf("Could not create directory, error msg: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	restore := useMemoryStore(t, model.NewNote("Bali 2018", "photos\n", repository.DEFAULT_NOTEBOOK_ID, []string{}))
+	defer restore()
+	NoteDB.AddAttachment(model.NewAttachment(1, "photo.png", []byte("beach")))
+	NoteDB.AddAttachment(model.NewAttachment(1, "photo.png", []byte("sunset")))
+	if err := export([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{}, exportOptions{Format: exportFormatMarkdown, Output: dir}); err != nil {
+		t.Fatalf("Could not export notes, error msg: %v", err)
+	}
+
+	defer useMemoryStore(t)()
+	if _, err := importMarkdownNotes(fileSystemReader{}, dir, defaultImportOptions); err != nil {
+		t.Fatalf("Could not import notes, error msg: %v", err)
+	}
+	attachments, _ := NoteDB.GetAttachments(1)
+	contents := []string{}
+	for _, attachment := range attachments {
+		if attachment, err := NoteDB.GetAttachment(attachment.ID); err == nil {
+			contents = append(contents, string(attachment.Content))
+		}
+	}
+	sort.Strings(contents)
+	if !reflect.DeepEqual(contents, []string{"beach", "sunset"}) {
+		t.Errorf("Expected attachments with the same name to keep their contents, got: %v", contents)
+	}
+}
+
+func TestMarkdownImportEditedNote(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tefter")
+	if err != nil {
+		t.Fatalf("Could not create directory, error msg: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer useMemoryStore(t, model.NewNote("Bali 2018", "photos\n", repository.DEFAULT_NOTEBOOK_ID, []string{"vacation"}))()
+	if err := export([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{}, exportOptions{Format: exportFormatMarkdown, Output: dir}); err != nil {
+		t.Fatalf("Could not export notes, error msg: %v", err)
+	}
+	path := filepath.Join(dir, "Default Notebook", "Bali 2018.md")
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read exported note, error msg: %v", err)
+	}
+	edited := strings.Replace(strings.Replace(string(raw), "title: Bali 2018", "title: Bali 2019", 1), "photos\n", "photos and videos\n", 1)
+	ioutil.WriteFile(path, []byte(edited), 0644)
+
+	report, err := importMarkdownNotes(fileSystemReader{}, dir, defaultImportOptions)
+	if err != nil {
+		t.Fatalf("Could not import notes, error msg: %v", err)
+	}
+	checkImportReport(t, report, 0, 1, 0, 0)
+	jNotes, _, _ := retrieveJSONNotes([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{})
+	if len(jNotes) != 1 || jNotes[0].Title != "Bali 2019" || jNotes[0].Memo != "photos and videos\n" {
+		t.Errorf("Expected the note to be updated with the edited markdown, got: %v", jNotes)
+	}
+}
+
+func TestMarkdownExportRemovesStaleNotes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tefter")
+	if err != nil {
+		t.Fatalf("Could not create directory, error msg: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer useMemoryStore(t,
+		model.NewNote("Bali 2018", "photos\n", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+		model.NewNote("Budget", "100$\n", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+		model.NewNote("Groceries", "milk\n", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+	)()
+	NoteDB.AddAttachment(model.NewAttachment(1, "photo.png", []byte("png")))
+	exportAll := func() {
+		t.Helper()
+		if err := export([]int{}, []string{}, []string{}, true, "", repository.NoteQuery{}, exportOptions{Format: exportFormatMarkdown, Output: dir}); err != nil {
+			t.Fatalf("Could not export notes, error msg: %v", err)
+		}
+	}
+	exportAll()
+	ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("# notes"), 0644)
+
+	note, _ := NoteDB.GetNote(1)
+	note.Title = "Bali 2019"
+	NoteDB.UpdateNote(note)
+	NoteDB.DeleteNotes([]int64{2})
+	if err := export([]int{3}, []string{}, []string{}, false, "", repository.NoteQuery{}, exportOptions{Format: exportFormatMarkdown, Output: dir}); err != nil {
+		t.Fatalf("Could not export notes, error msg: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Default Notebook", "Budget.md")); err != nil {
+		t.Errorf("Expected notes that were not exported to be kept, error msg: %v", err)
+	}
+
+	exportAll()
+	cases := []struct {
+		path   string
+		exists bool
+	}{
+		{"README.md", true},
+		{"Default Notebook/Bali 2019.md", true},
+		{"Default Notebook/Bali 2019.attachments/photo.png", true},
+		{"Default Notebook/Groceries.md", true},
+		{"Default Notebook/Bali 2018.md", false},
+		{"Default Notebook/Bali 2018.attachments", false},
+		{"Default Notebook/Budget.md", false},
+	}
+	for _, c := range cases {
+		if _, err := os.Stat(filepath.Join(dir, c.path)); (err == nil) != c.exists {
+			t.Errorf("Expected %v to exist: %v, error msg: %v", c.path, c.exists, err)
+		}
+	}
+}
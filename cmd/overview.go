@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"github.com/nicolasmanic/tefter/model"
 	"github.com/spf13/cobra"
-	"log"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 var overviewCmd = &cobra.Command{
-	Use:     "overview",
-	Short:   "Take a quick glance at the available notebooks and notes",
+	Use:   "overview",
+	Short: "Take a quick glance at the available notebooks and notes",
+	Long: "Print the notebooks and the tags as trees, the number of notes of a notebook or a tag includes the notes\n" +
+		"of its child notebooks or tags, eg: lang includes the notes tagged with lang/go",
 	Example: "overview -d",
 	Run:     overviewWrapper,
 }
@@ -24,26 +27,142 @@ func overviewWrapper(cmd *cobra.Command, args []string) {
 	deep, _ := cmd.Flags().GetBool("deep")
 	notebooks, err := NotebookDB.GetNotebooks([]int64{})
 	if err != nil {
-		log.Fatalln(err)
+		exitWithError(err)
 	}
 	printOverview(notebooks, deep)
 }
 
+//printOverview prints the notebooks as a tree sorted by title, the number of notes of a notebook
+//includes the notes of its child notebooks.
 func printOverview(notebooks []*model.Notebook, deep bool) {
 	if len(notebooks) == 0 {
 		fmt.Println("No notebooks available")
 		return
 	}
 	fmt.Println("> Notebooks:")
+	children := notebookChildren(notebooks)
+	for _, notebook := range children[0] {
+		printNotebookTree(notebook, children, deep, "")
+	}
+	tags := tagTree(notebooks)
+	if len(tags.children) > 0 {
+		fmt.Println("> Tags:")
+		for _, child := range tags.sortedChildren() {
+			printTagTree(child, "")
+		}
+	}
+}
+
+//tagNode is a level of the tag tree, notes are the ids of the notes tagged with the tag or its descendants.
+type tagNode struct {
+	name     string
+	notes    map[int64]bool
+	children map[string]*tagNode
+}
+
+func newTagNode(name string) *tagNode {
+	return &tagNode{name: name, notes: make(map[int64]bool), children: make(map[string]*tagNode)}
+}
+
+func (node *tagNode) sortedChildren() []*tagNode {
+	sorted := make([]*tagNode, 0, len(node.children))
+	for _, child := range node.children {
+		sorted = append(sorted, child)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+	return sorted
+}
+
+//tagTree returns the root of the tree of the tags of the notes of notebooks, tags are split on
+//model.TagSeparator and empty levels are skipped, eg: lang/go is a child go of lang.
+func tagTree(notebooks []*model.Notebook) *tagNode {
+	root := newTagNode("")
 	for _, notebook := range notebooks {
-		fmt.Println(" > " + notebook.Title)
-		if deep {
-			fmt.Println("  > notes:")
-			for _, note := range notebook.Notes {
-				fmt.Println("  - " + strconv.FormatInt(note.ID, 10) + " " + note.Title)
+		for _, note := range notebook.Notes {
+			for tag := range note.Tags {
+				node := root
+				for _, name := range strings.Split(tag, model.TagSeparator) {
+					if name == "" {
+						continue
+					}
+					if node.children[name] == nil {
+						node.children[name] = newTagNode(name)
+					}
+					node = node.children[name]
+					node.notes[note.ID] = true
+				}
 			}
-		} else {
-			fmt.Println("  > number of notes: " + strconv.Itoa(len(notebook.Notes)))
 		}
 	}
+	return root
+}
+
+func printTagTree(node *tagNode, indent string) {
+	fmt.Println(indent + " > " + node.name + " (" + strconv.Itoa(len(node.notes)) + " notes)")
+	for _, child := range node.sortedChildren() {
+		printTagTree(child, indent+"  ")
+	}
+}
+
+func printNotebookTree(notebook *model.Notebook, children map[int64][]*model.Notebook, deep bool, indent string) {
+	fmt.Println(indent + " > " + notebook.Title)
+	if deep {
+		fmt.Println(indent + "  > notes:")
+		for _, note := range sortedNotes(notebook.Notes) {
+			fmt.Println(indent + "  - " + strconv.FormatInt(note.ID, 10) + " " + note.Title)
+		}
+	} else {
+		fmt.Println(indent + "  > number of notes: " + strconv.Itoa(countNotes(notebook, children)))
+	}
+	for _, child := range childNotebooks(notebook, children) {
+		printNotebookTree(child, children, deep, indent+"  ")
+	}
+}
+
+//notebookChildren groups the notebooks by parent id sorted by title, notebooks whose parent is missing
+//are grouped with the top level notebooks under 0.
+func notebookChildren(notebooks []*model.Notebook) map[int64][]*model.Notebook {
+	byID := make(map[int64]bool, len(notebooks))
+	for _, notebook := range notebooks {
+		byID[notebook.ID] = true
+	}
+	children := make(map[int64][]*model.Notebook)
+	for _, notebook := range notebooks {
+		parentID := notebook.ParentID
+		if !byID[parentID] || parentID == notebook.ID {
+			parentID = 0
+		}
+		children[parentID] = append(children[parentID], notebook)
+	}
+	for _, siblings := range children {
+		sort.Slice(siblings, func(i, j int) bool { return siblings[i].Title < siblings[j].Title })
+	}
+	return children
+}
+
+//countNotes returns the number of notes of notebook and its descendants.
+func countNotes(notebook *model.Notebook, children map[int64][]*model.Notebook) int {
+	count := len(notebook.Notes)
+	for _, child := range childNotebooks(notebook, children) {
+		count += countNotes(child, children)
+	}
+	return count
+}
+
+//childNotebooks returns the children of notebook, a notebook without id is never a parent
+//since the top level notebooks are grouped under 0.
+func childNotebooks(notebook *model.Notebook, children map[int64][]*model.Notebook) []*model.Notebook {
+	if notebook.ID == 0 {
+		return nil
+	}
+	return children[notebook.ID]
+}
+
+func sortedNotes(notes map[int64]*model.Note) []*model.Note {
+	sorted := make([]*model.Note, 0, len(notes))
+	for _, note := range notes {
+		sorted = append(sorted, note)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
 }
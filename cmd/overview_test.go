@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/nicolasmanic/tefter/model"
+	"strings"
 	"testing"
 )
 
@@ -41,3 +42,71 @@ func TestPrintOverview(t *testing.T) {
 		printOverview(c.notebooks, c.deep)
 	}
 }
+
+func TestNotebookTreeCounts(t *testing.T) {
+	notes := func(ids ...int64) map[int64]*model.Note {
+		result := make(map[int64]*model.Note)
+		for _, id := range ids {
+			result[id] = &model.Note{ID: id}
+		}
+		return result
+	}
+	work := &model.Notebook{ID: 2, Title: "work", Notes: notes(1)}
+	infra := &model.Notebook{ID: 3, Title: "infra", ParentID: 2, Notes: notes(2, 3)}
+	k8s := &model.Notebook{ID: 4, Title: "k8s", ParentID: 3, Notes: notes(4)}
+	docs := &model.Notebook{ID: 5, Title: "docs", ParentID: 2}
+	orphan := &model.Notebook{ID: 6, Title: "archive", ParentID: 42}
+
+	children := notebookChildren([]*model.Notebook{k8s, work, infra, docs, orphan})
+	if len(children[0]) != 2 || children[0][0] != orphan || children[0][1] != work {
+		t.Errorf("Expected archive and work at the top level, got: %v", children[0])
+	}
+	if len(children[2]) != 2 || children[2][0] != docs || children[2][1] != infra {
+		t.Errorf("Expected children of work sorted by title, got: %v", children[2])
+	}
+	if count := countNotes(work, children); count != 4 {
+		t.Errorf("Expected 4 notes under work, got: %v", count)
+	}
+	if count := countNotes(infra, children); count != 3 {
+		t.Errorf("Expected 3 notes under infra, got: %v", count)
+	}
+}
+
+func TestTagTree(t *testing.T) {
+	notes := map[int64]*model.Note{}
+	for id, tags := range map[int64][]string{
+		1: {"lang/go", "lang/rust"},
+		2: {"lang/go/generics"},
+		3: {"lang", "todo"},
+	} {
+		notes[id] = model.NewNote("title", "memo", 1, tags)
+		notes[id].ID = id
+	}
+	root := tagTree([]*model.Notebook{{ID: 1, Title: "title", Notes: notes}})
+
+	cases := []struct {
+		path          []string
+		expectedNotes int
+		expectedNames []string
+	}{
+		{[]string{}, 0, []string{"lang", "todo"}},
+		{[]string{"lang"}, 3, []string{"go", "rust"}},
+		{[]string{"lang", "go"}, 2, []string{"generics"}},
+		{[]string{"lang", "rust"}, 1, []string{}},
+		{[]string{"todo"}, 1, []string{}},
+	}
+	for _, c := range cases {
+		node := root
+		for _, name := range c.path {
+			node = node.children[name]
+		}
+		names := []string{}
+		for _, child := range node.sortedChildren() {
+			names = append(names, child.name)
+		}
+		if len(node.notes) != c.expectedNotes || strings.Join(names, ",") != strings.Join(c.expectedNames, ",") {
+			t.Errorf("Expected %v notes and children %v under %v, got: %v and %v",
+				c.expectedNotes, c.expectedNames, c.path, len(node.notes), names)
+		}
+	}
+}
@@ -3,11 +3,11 @@ package cmd
 import (
 	"fmt"
 	"github.com/gdamore/tcell"
+	"github.com/nicolasmanic/tefter/repository"
 	"github.com/rivo/tview"
 	"github.com/spf13/cobra"
 	"log"
 	"os"
-	"sort"
 	"strconv"
 	"strings"
 )
@@ -17,25 +17,37 @@ var (
 	notebookTitles []string
 	tags           []string
 	printAll       bool
+	printQueryText string
+	printQuery     repository.NoteQuery
 	printCmd       = &cobra.Command{
 		Use:   "print",
 		Short: "Print notes",
 		Long: "There are 4 ways to print a set of notes" +
 			" 1) Give a comma separated list of note ids" +
 			" 2) Give a comma separated list of notebook titles" +
-			" 3) Give a comma separated list of tags," +
+			" 3) Give a comma separated list of tags, a tag includes its sub tags eg: lang for lang/go," +
 			" 4) If -a or --all flag is set all notes will be printed" +
-			"Press Esc to exit print mode",
-		Example: "print -i 1,2,... -n notebook1,notebook2,... -t tag1,tag2,... ",
+			" Use -q to print only the notes matching a query, see search for the query syntax" +
+			" Notes are sorted by --sort and --order, use --limit and --page to print a page of notes" +
+			" Press Esc to exit print mode",
+		Example: "print -i 1,2,... -n notebook1,notebook2,... -t tag1,tag2,... \n" +
+			"print -a --sort title --order asc --limit 20 --page 2\n" +
+			"print -n work -q 'tag:go -tag:done'",
 		Run: func(cmd *cobra.Command, args []string) {
 
 			ids, _ = cmd.Flags().GetIntSlice("ids")
 			notebookTitles, _ = cmd.Flags().GetStringSlice("notebook")
 			tags, _ = cmd.Flags().GetStringSlice("tags")
 			printAll, _ = cmd.Flags().GetBool("all")
-			jNotes, err := collectNotes(ids, notebookTitles, tags, printAll)
+			printQueryText, _ = cmd.Flags().GetString("query")
+			query, err := noteQueryFromFlags(cmd)
 			if err != nil {
-				log.Fatalln(err)
+				exitWithError(err)
+			}
+			printQuery = query
+			jNotes, err := collectNotes(ids, notebookTitles, tags, printAll, printQueryText, printQuery)
+			if err != nil {
+				exitWithError(err)
 			}
 			printNotes2Terminal(jNotes)
 		},
@@ -45,17 +57,19 @@ var (
 func init() {
 	rootCmd.AddCommand(printCmd)
 	printCmd.Flags().IntSliceP("ids", "i", []int{}, "Comma separated list of note ids.")
-	printCmd.Flags().StringSlice("tags", []string{}, "Comma-separated tags of note.")
-	printCmd.Flags().StringSliceP("notebook", "n", []string{}, "Comma separated list of notebook titles")
+	printCmd.Flags().StringSlice("tags", []string{}, "Comma-separated tags of note, a tag includes its sub tags eg: lang for lang/go")
+	printCmd.Flags().StringSliceP("notebook", "n", []string{}, "Comma separated list of notebook paths, notes of their child notebooks are included")
 	printCmd.Flags().BoolP("all", "a", false, "Print all notes")
+	printCmd.Flags().StringP("query", "q", "", "Print notes matching the query")
+	addNoteQueryFlags(printCmd, repository.SortByLastUpdated)
 }
 
-func collectNotes(ids []int, notebookTitles []string, tags []string, printAll bool) ([]*jsonNote, error) {
-	notes, err := collectNotesFromDB(ids, notebookTitles, tags, printAll)
+func collectNotes(ids []int, notebookTitles []string, tags []string, printAll bool, queryText string, query repository.NoteQuery) ([]*jsonNote, error) {
+	notes, _, err := collectNotesFromDB(ids, notebookTitles, tags, printAll, queryText, query)
 	if err != nil {
 		return nil, err
 	}
-	jNotes, err := transformNotes2JSONNotes(noteMap2Slice(notes))
+	jNotes, err := transformNotes2JSONNotes(notes)
 	if err != nil {
 		return nil, err
 	}
@@ -79,10 +93,7 @@ func createUI(jNotes []*jsonNote) *tview.Application {
 	if len(jNotes) == 0 {
 		return nil
 	}
-	//Sort By date (descending)
-	sort.Slice(jNotes, func(i, j int) bool {
-		return jNotes[i].LastUpdated.After(jNotes[j].LastUpdated)
-	})
+	//Notes are displayed in the order they were retrieved, see --sort
 	notesTable := constructNotesTable(jNotes)
 	notesFlex := tview.NewFlex()
 	notesFlex.SetDirection(tview.FlexRow)
@@ -90,41 +101,90 @@ func createUI(jNotes []*jsonNote) *tview.Application {
 	notesFlex.AddItem(notesTable, numberOfVisibleRows, 1, true)
 
 	memo := constructMemo(jNotes)
+	linkedNotes := constructLinkedNotes()
 	dates := constructDatesRow(jNotes)
 	help := constructHelpLine()
 
+	memoFlex := tview.NewFlex()
+	memoFlex.AddItem(memo, 0, 3, false)
+	memoFlex.AddItem(linkedNotes, 0, 1, false)
+
 	flexLayout := tview.NewFlex()
 	flexLayout.SetDirection(tview.FlexRow)
 	flexLayout.AddItem(notesFlex, numberOfVisibleRows, 1, true)
-	flexLayout.AddItem(memo, 0, 3, false)
+	flexLayout.AddItem(memoFlex, 0, 3, false)
 	flexLayout.AddItem(dates, 1, 1, false)
 	flexLayout.AddItem(help, 1, 1, false)
 
 	pages := tview.NewPages()
 	pages.AddPage("notes", flexLayout, true, true)
 
-	notesTable.SetSelectionChangedFunc(func(row, column int) {
-		if row >= 0 {
+	//jumpToNote selects the note in the table, notes that are not printed are added to the table first.
+	var jumpToNote func(jNote *jsonNote)
+	selectionChanged := func(row, column int) {
+		if row > 0 && row <= len(jNotes) {
 			selectedNote := jNotes[row-1]
 			memo.SetText(selectedNote.Memo)
 			dates.SetCell(0, 1, &tview.TableCell{Text: selectedNote.Created.Format("Jan 2 2006 15:04"), Align: tview.AlignLeft, Color: tcell.ColorDefault, Expansion: 2})
 			dates.SetCell(0, 3, &tview.TableCell{Text: selectedNote.LastUpdated.Format("Jan 2 2006 15:04"), Align: tview.AlignLeft, Color: tcell.ColorDefault, Expansion: 2})
+			showLinkedNotes(linkedNotes, selectedNote, jumpToNote)
 		}
-	})
+	}
+	jumpToNote = func(jNote *jsonNote) {
+		row := -1
+		for i := range jNotes {
+			if jNotes[i].ID == jNote.ID {
+				row = i + 1
+			}
+		}
+		if row < 0 {
+			jNotes = append(jNotes, jNote)
+			row = len(jNotes)
+			setNoteRow(notesTable, row, jNote, notesTable.GetColumnCount() > 4)
+		}
+		app.SetFocus(notesTable)
+		notesTable.Select(row, 0)
+	}
+	notesTable.SetSelectionChangedFunc(selectionChanged)
+	showLinkedNotes(linkedNotes, jNotes[0], jumpToNote)
 	app.SetRoot(pages, true)
 
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		//keys go to the confirmation of a deletion while it is shown
+		if pages.HasPage("delete") {
+			return event
+		}
 		switch event.Key() {
+		case tcell.KeyTab:
+			if notesTable.HasFocus() {
+				app.SetFocus(linkedNotes)
+			} else {
+				app.SetFocus(notesTable)
+			}
+			return nil
 		case tcell.KeyCtrlD:
 			row, _ := notesTable.GetSelection()
 			if notesTable.GetRowCount() > 1 {
 				noteIndex := row - 1
 				toBeDelete := jNotes[noteIndex]
-				jNotes = append(jNotes[:noteIndex], jNotes[noteIndex+1:]...)
-				delete([]int64{toBeDelete.ID})
-				notesFlex.RemoveItem(notesTable)
-				notesTable = constructNotesTable(jNotes)
-				notesFlex.AddItem(notesTable, numberOfVisibleRows, 1, true)
+				confirmModal := constructDeleteModal(toBeDelete)
+				confirmModal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+					pages.RemovePage("delete")
+					if buttonLabel != deleteButton {
+						return
+					}
+					if err := delete([]int64{toBeDelete.ID}); err != nil {
+						log.Println(err)
+						return
+					}
+					jNotes = append(jNotes[:noteIndex], jNotes[noteIndex+1:]...)
+					notesFlex.RemoveItem(notesTable)
+					notesTable = constructNotesTable(jNotes)
+					notesTable.SetSelectionChangedFunc(selectionChanged)
+					notesFlex.AddItem(notesTable, numberOfVisibleRows, 1, true)
+					app.SetFocus(notesTable)
+				})
+				pages.AddPage("delete", confirmModal, false, true)
 			}
 		case tcell.KeyCtrlU:
 			row, _ := notesTable.GetSelection()
@@ -137,6 +197,7 @@ func createUI(jNotes []*jsonNote) *tview.Application {
 					notebookTitleInputField := updateForm.GetFormItem(0).(*tview.InputField)
 					noteTitleInputField := updateForm.GetFormItem(1).(*tview.InputField)
 					tagsInputField := updateForm.GetFormItem(2).(*tview.InputField)
+					rewriteLinks := updateForm.GetFormItem(3).(*tview.Checkbox).IsChecked()
 
 					notebookTitle := notebookTitleInputField.GetText()
 					noteTitle := noteTitleInputField.GetText()
@@ -144,12 +205,17 @@ func createUI(jNotes []*jsonNote) *tview.Application {
 					tags := strings.Split(tagsStr, ",")
 
 					app.Suspend(func() {
-						editor := &viEditor{}
-						update(toBeUpdated.ID, noteTitle, tags, notebookTitle, editor)
+						editor, err := newCommandEditor(resolveEditorCommand(), Config.EditorExtension)
+						if err == nil {
+							err = update(toBeUpdated.ID, noteTitle, tags, notebookTitle, rewriteLinks, editor)
+						}
+						if err != nil {
+							log.Println(err)
+						}
 					})
 					app.Stop()
 
-					updatedJNotes, _ := collectNotes(ids, notebookTitles, tags, printAll)
+					updatedJNotes, _ := collectNotes(ids, notebookTitles, tags, printAll, printQueryText, printQuery)
 					printNotes2Terminal(updatedJNotes)
 
 				})
@@ -180,17 +246,45 @@ func constructNotesTable(jNotes []*jsonNote) *tview.Table {
 	notesTable.SetCell(0, 2, noteTitleCell)
 	tagsCell := &tview.TableCell{Text: "Tags", Align: tview.AlignLeft, Color: tcell.ColorBlue, Expansion: 2, NotSelectable: true}
 	notesTable.SetCell(0, 3, tagsCell)
+	//search results get a column with the part of the memo that matched
+	hasSnippets := false
+	for _, jNote := range jNotes {
+		hasSnippets = hasSnippets || jNote.Snippet != ""
+	}
+	if hasSnippets {
+		matchCell := &tview.TableCell{Text: "Match", Align: tview.AlignLeft, Color: tcell.ColorBlue, Expansion: 4, NotSelectable: true}
+		notesTable.SetCell(0, 4, matchCell)
+	}
 
 	for row := 0; row < len(jNotes); row++ {
-		notesTable.SetCell(row+1, 0, &tview.TableCell{Text: strconv.FormatInt(jNotes[row].ID, 10), Align: tview.AlignLeft, Color: tcell.ColorLimeGreen, Expansion: 1})
-		notesTable.SetCell(row+1, 1, &tview.TableCell{Text: jNotes[row].NotebookTitle, Align: tview.AlignLeft, Color: tcell.ColorLimeGreen, Expansion: 2})
-		notesTable.SetCell(row+1, 2, &tview.TableCell{Text: jNotes[row].Title, Align: tview.AlignLeft, Color: tcell.ColorLimeGreen, Expansion: 2})
-		notesTable.SetCell(row+1, 3, &tview.TableCell{Text: strings.Join(jNotes[row].Tags, ","), Align: tview.AlignLeft, Color: tcell.ColorLimeGreen, Expansion: 2})
+		setNoteRow(notesTable, row+1, jNotes[row], hasSnippets)
 	}
 
 	return notesTable
 }
 
+func setNoteRow(notesTable *tview.Table, row int, jNote *jsonNote, hasSnippets bool) {
+	title := jNote.Title
+	if jNote.HighlightedTitle != "" {
+		title = highlightTags(jNote.HighlightedTitle)
+	}
+	notesTable.SetCell(row, 0, &tview.TableCell{Text: strconv.FormatInt(jNote.ID, 10), Align: tview.AlignLeft, Color: tcell.ColorLimeGreen, Expansion: 1})
+	notesTable.SetCell(row, 1, &tview.TableCell{Text: jNote.NotebookTitle, Align: tview.AlignLeft, Color: tcell.ColorLimeGreen, Expansion: 2})
+	notesTable.SetCell(row, 2, &tview.TableCell{Text: title, Align: tview.AlignLeft, Color: tcell.ColorLimeGreen, Expansion: 2})
+	notesTable.SetCell(row, 3, &tview.TableCell{Text: strings.Join(jNote.Tags, ","), Align: tview.AlignLeft, Color: tcell.ColorLimeGreen, Expansion: 2})
+	if hasSnippets {
+		notesTable.SetCell(row, 4, &tview.TableCell{Text: highlightTags(jNote.Snippet), Align: tview.AlignLeft, Color: tcell.ColorLimeGreen, Expansion: 4})
+	}
+}
+
+//highlightTags converts the highlighted matches of search results to tview color tags, the rest of the text
+//is escaped so that it is not taken for tags. Line breaks are replaced since table cells are a single line.
+func highlightTags(text string) string {
+	text = tview.Escape(strings.Replace(text, "\n", " ", -1))
+	text = strings.Replace(text, repository.HighlightStart, "[yellow]", -1)
+	return strings.Replace(text, repository.HighlightEnd, "[-]", -1)
+}
+
 func constructMemo(jNotes []*jsonNote) *tview.TextView {
 	memo := tview.NewTextView()
 	memo.SetBorder(true)
@@ -202,6 +296,37 @@ func constructMemo(jNotes []*jsonNote) *tview.TextView {
 	return memo
 }
 
+//constructLinkedNotes returns the panel listing the notes that link to the selected note and the notes it links to.
+func constructLinkedNotes() *tview.List {
+	linkedNotes := tview.NewList()
+	linkedNotes.SetBorder(true)
+	linkedNotes.SetTitle("Links")
+	linkedNotes.ShowSecondaryText(false)
+	return linkedNotes
+}
+
+//showLinkedNotes lists the backlinks and the links of the note, selecting one of them jumps to it.
+func showLinkedNotes(linkedNotes *tview.List, jNote *jsonNote, jumpToNote func(jNote *jsonNote)) {
+	linkedNotes.Clear()
+	jBacklinks, err := backlinks(jNote.ID)
+	if err != nil {
+		log.Println(err)
+	}
+	jLinks, _, err := links(jNote.ID)
+	if err != nil {
+		log.Println(err)
+	}
+	for _, group := range []struct {
+		prefix string
+		jNotes []*jsonNote
+	}{{"<- ", jBacklinks}, {"-> ", jLinks}} {
+		for _, linked := range group.jNotes {
+			linked := linked
+			linkedNotes.AddItem(tview.Escape(group.prefix+linked.Title), "", 0, func() { jumpToNote(linked) })
+		}
+	}
+}
+
 func constructDatesRow(jNotes []*jsonNote) *tview.Table {
 	dates := tview.NewTable()
 	dates.SetSelectable(false, false)
@@ -215,18 +340,30 @@ func constructDatesRow(jNotes []*jsonNote) *tview.Table {
 
 func constructHelpLine() *tview.TextView {
 	help := tview.NewTextView()
-	help.SetText("Press Ctrl+C to espace, Ctrl+D to delete and Ctrl+U to update a note")
+	help.SetText("Press Ctrl+C to espace, Ctrl+D to move to the trash, Ctrl+U to update a note and Tab to switch to the links")
 	help.SetTextAlign(tview.AlignCenter)
 	help.SetTextColor(tcell.ColorRed)
 
 	return help
 }
 
+//deleteButton is the label of the button confirming the deletion of a note
+const deleteButton = "Move to trash"
+
+//constructDeleteModal asks for confirmation before moving a note to the trash.
+func constructDeleteModal(jNote *jsonNote) *tview.Modal {
+	modal := tview.NewModal()
+	modal.SetText(fmt.Sprintf("Move note %d %q to the trash?\nUse trash restore %d to bring it back.", jNote.ID, jNote.Title, jNote.ID))
+	modal.AddButtons([]string{deleteButton, "Cancel"})
+	return modal
+}
+
 func constructUpdateForm(jNote *jsonNote) *tview.Form {
 	form := tview.NewForm()
 	form.AddInputField("Notebook Title:", jNote.NotebookTitle, 30, nil, nil)
 	form.AddInputField("Note Title:", jNote.Title, 30, nil, nil)
 	form.AddInputField("Tags:", strings.Join(jNote.Tags, ","), 30, nil, nil)
+	form.AddCheckbox("Rewrite links to the title:", false, nil)
 
 	return form
 }
@@ -7,6 +7,7 @@ import (
 )
 
 func TestCreateUI(t *testing.T) {
+	defer useMemoryStore(t)()
 	notes := mockJSONNotes()
 	emptyNotes := []*jsonNote{}
 
@@ -120,3 +121,33 @@ func TestConstructNotesTable(t *testing.T) {
 		t.Errorf("Wrong tags: expected :%q got %q ", "tag1,tag2", notesTable.GetCell(1, 3).Text)
 	}
 }
+
+func TestConstructNotesTableSearchHits(t *testing.T) {
+	jNotes := []*jsonNote{
+		&jsonNote{
+			ID:               1,
+			Title:            "Learn Go",
+			HighlightedTitle: "Learn <mark>Go</mark>",
+			Snippet:          "about <mark>go</mark> [generics]\nand more",
+		},
+		&jsonNote{
+			ID:    2,
+			Title: "Go",
+		},
+	}
+
+	notesTable := constructNotesTable(jNotes)
+
+	if notesTable.GetColumnCount() != 5 || notesTable.GetCell(0, 4).Text != "Match" {
+		t.Fatalf("Expected a match column for search hits, got %v columns", notesTable.GetColumnCount())
+	}
+	if notesTable.GetCell(1, 2).Text != "Learn [yellow]Go[-]" {
+		t.Errorf("Wrong highlighted title: got %q", notesTable.GetCell(1, 2).Text)
+	}
+	if expected := "about [yellow]go[-] [generics[] and more"; notesTable.GetCell(1, 4).Text != expected {
+		t.Errorf("Wrong snippet: expected %q got %q", expected, notesTable.GetCell(1, 4).Text)
+	}
+	if notesTable.GetCell(2, 2).Text != "Go" {
+		t.Errorf("Wrong note title: expected %q got %q", "Go", notesTable.GetCell(2, 2).Text)
+	}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/nicolasmanic/tefter/repository"
+	"github.com/spf13/cobra"
+	"strconv"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore the title and memo of a note from a revision",
+	Long: "Restore the title and memo of a note from a revision, see history for the revisions of a note.\n" +
+		"The restored note is saved as a new revision, so a restore can be undone by restoring the previous revision.",
+	Example: "restore 1 2",
+	Args:    cobra.ExactArgs(2),
+	Run:     restoreWrapper,
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func restoreWrapper(cmd *cobra.Command, args []string) {
+	if err := restoreArgs(args); err != nil {
+		exitWithError(err)
+	}
+}
+
+func restoreArgs(args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("ID could not be converted to integer, error msg: %w", err)
+	}
+	revision, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("Revision could not be converted to integer, error msg: %w", err)
+	}
+	return restore(id, revision)
+}
+
+func restore(id int64, revision int) error {
+	return withTx(func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+		if _, err := noteDB.RestoreRevision(id, revision); err != nil {
+			return fmt.Errorf("Error while restoring revision, error msg: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"testing"
+)
+
+func TestRestoreArgs(t *testing.T) {
+	cases := []struct {
+		args        []string
+		expectedErr error
+	}{
+		{args: []string{"1", "1"}},
+		{args: []string{"1", "3"}, expectedErr: repository.ErrRevisionNotFound},
+		{args: []string{"2", "1"}, expectedErr: repository.ErrNoteNotFound},
+		{args: []string{"a", "1"}, expectedErr: errors.New("ID could not be converted to integer, error msg: strconv.ParseInt: parsing \"a\": invalid syntax")},
+		{args: []string{"1", "b"}, expectedErr: errors.New("Revision could not be converted to integer, error msg: strconv.Atoi: parsing \"b\": invalid syntax")},
+	}
+
+	oldNoteDB := NoteDB
+	NoteDB = mockNoteDBRevisions{revisions: []*model.Revision{
+		{NoteID: 1, Number: 1, Title: "title", Memo: "first"},
+		{NoteID: 1, Number: 2, Title: "title", Memo: "second"},
+	}}
+	defer func() {
+		NoteDB = oldNoteDB
+	}()
+	for _, c := range cases {
+		err := restoreArgs(c.args)
+		if !errors.Is(err, c.expectedErr) && !sameError(c.expectedErr, err) {
+			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
+		}
+	}
+}
@@ -2,25 +2,42 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/nicolasmanic/tefter/config"
 	"github.com/nicolasmanic/tefter/repository"
 	"github.com/spf13/cobra"
+	"log"
 	"os"
+	"path/filepath"
 )
 
 var (
+	//Config holds the user settings, it is loaded before any command runs.
+	Config = config.Default()
 	//NoteDB exposed the available DB actions for notes
 	NoteDB repository.NoteRepository
 	//NotebookDB exposed the available DB actions for notebooks.
 	NotebookDB repository.NotebookRepository
 	//AccountDB exposed the available DB actions for accounts.
 	AccountDB repository.AccountRepository
+	//Store owns the DB connection shared by the repositories, it is nil when the repositories are set directly.
+	Store repository.Store
+	//Version of tefter, set at build time with -ldflags "-X github.com/nicolasmanic/tefter/cmd.Version=v1.2.0".
+	Version = "dev"
 
+	dbPath  string
+	dbFlag  string
 	rootCmd = &cobra.Command{
-		Use:   "tefter",
-		Short: "Tefter is a simple memo book application",
+		Use:              "tefter",
+		Short:            "Tefter is a simple memo book application",
+		PersistentPreRun: connectRepositories,
 	}
 )
 
+func init() {
+	rootCmd.Version = Version
+	rootCmd.PersistentFlags().StringVar(&dbFlag, "db", "", "Path of the DB file (overrides $"+config.DBEnv+" and config file)")
+}
+
 //Execute add all commands to root.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
@@ -28,3 +45,62 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+//initConfig loads the config file and resolves the DB location.
+func initConfig() {
+	conf, err := config.Load(config.Path())
+	if err != nil {
+		exitWithError(err)
+	}
+	Config = conf
+	dbPath = Config.ResolveDB(dbFlag)
+	if Config.DSN == "" && Config.UsesLegacyDB(dbFlag) {
+		fmt.Fprintf(os.Stderr, "Using %v of the working directory, move it to %v or set db in the config file\n", config.LegacyDB, Config.DB)
+	}
+}
+
+//initDB loads the config and creates the directory of the DB file if missing.
+func initDB() {
+	initConfig()
+	if Config.DSN != "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		log.Fatalf("Could not create DB dir, error msg: %v", err)
+	}
+}
+
+//connectRepositories connects the repositories that have not been set yet,
+//connecting also migrates the DB to the latest schema version and purges the expired items of the trash.
+func connectRepositories(cmd *cobra.Command, args []string) {
+	initDB()
+	if Store == nil {
+		if Config.DSN != "" {
+			Store = repository.NewPostgresStore(Config.DSN)
+		} else {
+			Store = repository.NewStore(dbPath)
+		}
+	}
+	if NoteDB == nil {
+		NoteDB = Store.Notes()
+	}
+	if NotebookDB == nil {
+		NotebookDB = Store.Notebooks()
+	}
+	if AccountDB == nil {
+		AccountDB = Store.Accounts()
+	}
+	purgeExpiredTrash()
+}
+
+//withTx runs fn with note and notebook repositories that share a transaction,
+//so either all changes of fn are applied or none.
+//If no Store is set fn runs directly against NoteDB and NotebookDB.
+func withTx(fn func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error) error {
+	if Store == nil {
+		return fn(NoteDB, NotebookDB)
+	}
+	return Store.WithTx(func(tx repository.Store) error {
+		return fn(tx.Notes(), tx.Notebooks())
+	})
+}
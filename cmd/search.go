@@ -2,51 +2,72 @@ package cmd
 
 import (
 	"fmt"
-	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
 	"github.com/spf13/cobra"
-	"log"
+	"strings"
 )
 
 var (
 	searchCmd = &cobra.Command{
-		Use:     "search",
-		Short:   "Search notes given a keyword",
-		Long:    "Keyword is searched against title and content of the note, if no keyword is given all notes will be printed",
-		Example: "search myKeyword",
-		Run:     searchWrapper,
+		Use:   "search",
+		Short: "Search notes given a query",
+		Long: "Prints the notes matching the query, if no query is given all notes will be printed\n" +
+			"A query is a list of terms separated by spaces, a note must match all of them:\n" +
+			"  word, \"exact phrase\"   title or content contains the words, a word ending with * matches as prefix\n" +
+			"  tag:go                 note is tagged with go or one of its sub tags eg: go/generics\n" +
+			"  notebook:work/infra    note belongs to the notebook at path work/infra, quote paths with spaces eg: notebook:\"my work\"\n" +
+			"  title:plan             title contains plan\n" +
+			"  id:42                  note has id 42\n" +
+			"  created:2026-10-01     note was created that day, prefix the date with >, >=, < or <= for a range\n" +
+			"  updated:>2026-10-01    note was updated after that day\n" +
+			"Prefix a term with - to exclude the notes matching it, use OR and parentheses to match either of terms\n" +
+			"Notes are ranked by relevance to the words of the query, matches in the title count more than matches in the content\n" +
+			"and matched words are highlighted. Use --sort and --order to sort otherwise, --limit and --page to print a page of notes\n" +
+			"With --fuzzy words also match words containing them and misspelled words, in titles, contents, tags and notebook paths",
+		Example: "search myKeyword\n" +
+			"search myKeyword --sort created --limit 10\n" +
+			"search 'tag:go tag:todo notebook:work updated:>2026-10-01 \"exact phrase\" -tag:done'\n" +
+			"search '(tag:go OR tag:rust) -notebook:archive'\n" +
+			"search --fuzzy kube",
+		Run: searchWrapper,
 	}
 )
 
 func init() {
 	rootCmd.AddCommand(searchCmd)
+	addNoteQueryFlags(searchCmd, repository.SortByRank)
+	searchCmd.Flags().Bool("fuzzy", false, "Match words containing the words of the query and misspelled words")
 }
 
 func searchWrapper(cmd *cobra.Command, args []string) {
-	keyword := ""
-	if len(args) > 0 {
-		keyword = args[0]
+	//unquoted terms are given as separate arguments
+	queryText := strings.Join(args, " ")
+	query, err := noteQueryFromFlags(cmd)
+	if err != nil {
+		exitWithError(err)
 	}
-	notes, err := search(keyword)
+	fuzzy, _ := cmd.Flags().GetBool("fuzzy")
+	page, err := search(queryText, fuzzy, query)
 	if err != nil {
-		log.Fatalln(err)
+		exitWithError(err)
 	}
-	jNotes, err := transformNotes2JSONNotes(notes)
+	jNotes, err := transformSearchHits2JSONNotes(page.Hits)
 	if err != nil {
-		log.Fatalln(err)
+		exitWithError(err)
 	}
 	printNotes2Terminal(jNotes)
 }
 
-func search(keyword string) ([]*model.Note, error) {
-	var notes []*model.Note
-	var err error
-	if len(keyword) == 0 {
-		notes, err = NoteDB.GetNotes([]int64{})
-	} else {
-		notes, err = NoteDB.SearchNotesByKeyword(keyword)
+//search returns a page of the notes matching queryText ranked by relevance, or of all notes if queryText is empty.
+//If fuzzy is set the words of queryText match similar words, see repository.NoteFilter.
+func search(queryText string, fuzzy bool, query repository.NoteQuery) (*repository.SearchPage, error) {
+	expr, err := repository.ParseQuery(queryText)
+	if err != nil {
+		return nil, fmt.Errorf("Error while parsing query, error msg: %w", err)
 	}
+	page, err := NoteDB.SearchNotes(repository.NoteFilter{Query: expr, Fuzzy: fuzzy}, query)
 	if err != nil {
-		return nil, fmt.Errorf("Error retrieving Notes from DB, error msg: %v", err)
+		return nil, fmt.Errorf("Error retrieving Notes from DB, error msg: %w", err)
 	}
-	return notes, nil
+	return page, nil
 }
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"github.com/nicolasmanic/tefter/model"
 	"github.com/nicolasmanic/tefter/repository"
-	"reflect"
 	"testing"
 )
 
@@ -40,8 +39,8 @@ func TestSearch(t *testing.T) {
 			NoteDB = oldNoteDB
 		}()
 
-		_, err := search(c.keyword)
-		if !reflect.DeepEqual(c.expectedErr, err) {
+		_, err := search(c.keyword, false, repository.NoteQuery{})
+		if !sameError(c.expectedErr, err) {
 			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
 		}
 	}
@@ -53,10 +52,10 @@ type mockNoteDBSearch struct {
 	err   error
 }
 
-func (mDB mockNoteDBSearch) GetNotes(noteIDs []int64) ([]*model.Note, error) {
-	return mDB.notes, mDB.err
-}
-
-func (mDB mockNoteDBSearch) SearchNotesByKeyword(keyword string) ([]*model.Note, error) {
-	return mDB.notes, mDB.err
+func (mDB mockNoteDBSearch) SearchNotes(filter repository.NoteFilter, query repository.NoteQuery) (*repository.SearchPage, error) {
+	page := &repository.SearchPage{}
+	for _, note := range mDB.notes {
+		page.Hits = append(page.Hits, &repository.SearchHit{Note: note, Title: note.Title})
+	}
+	return page, mDB.err
 }
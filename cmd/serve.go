@@ -1,35 +1,105 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/nicolasmanic/tefter/repository"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+)
+
+//demoUsername is the account created when serving with --ephemeral.
+const demoUsername = "demo"
 
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Initiate rest API interface",
 	Long: "Run a http server for managing notes/notebooks via REST calls\n" +
-		"If no -p flag is not set the default port will be 8080\n" +
+		"If no -p flag is set the port of the config file will be used (default 8080)\n" +
+		"With --ephemeral flag notes are kept in memory and lost when the server stops,\n" +
+		"a \"demo\" account with a random password is created and printed on startup\n" +
 		"Available endpoints:\n" +
 		"POST /addNote \n" +
-		"PUT /updateNote \n" +
+		"PUT /updateNote (?rewriteLinks=true rewrites the [[title]] links of other notes to a renamed note) \n" +
 		"GET /getNotesByID/{ids} (comma separated IDs) \n" +
 		"GET /getNotesByNotebookTitle/{notebookTitles} (comma separated notebook titles) \n" +
-		"GET /getNotesByTags/{Tags} (comma separated tags) \n" +
+		"GET /getNotesByNotebookTitle?notebooks= (comma separated notebook paths, eg: work/infra) \n" +
+		"GET /getNotesByTags/{tags} (comma separated tags, a tag includes its sub tags eg: lang for lang/go) \n" +
 		"GET /getAllNotes \n" +
 		"DELETE /deleteNotes/{ids} (comma separated IDs)\n" +
-		"GET /searchBy/{keyword} \n" +
+		"GET /searchBy/{keyword} (keyword is a query, see search, notes are ranked and have rank, highlighted_title and snippet fields,\n" +
+		"  add ?fuzzy=true to match similar words) \n" +
+		"GET endpoints of notes accept ?limit=&cursor=&sort=&order= parameters, limited responses\n" +
+		"are {\"notes\": [...], \"next\": cursor}, pass next as cursor to get the following page\n" +
+		"GET /getNotes* endpoints and /getAllNotes also accept a ?q= query to keep only the matching notes, see search\n" +
+		"GET /history/{id} (revisions of the note, oldest first) \n" +
+		"GET /links/{id} (notes that the note links to with [[title]] or [[#id]]) \n" +
+		"GET /backlinks/{id} (notes that link to the note) \n" +
+		"POST /attach/{id} (multipart form with one or more file fields attached to the note) \n" +
+		"GET /attachments/{id} (attachments of the note without their content) \n" +
+		"GET /attachment/{attachmentID} (content of the attachment as a file download) \n" +
+		"GET /diff/{id} (unified diff of the memo, ?from=&to= revisions as in the diff command) \n" +
+		"PUT /restore/{id}/{revision} \n" +
 		"PUT /updateNotebook/{oldTitle}/{newTitle} \n" +
-		"DELETE /deleteNotebooks/{notebookTitles} (comma separated notebook titles)\n",
-	Example: "serve -p 7000",
-	Run:     serve,
+		"PUT /updateNotebook?old=&new= (notebook paths, eg: ?old=work/infra&new=work/ops) \n" +
+		"DELETE /deleteNotebooks/{notebookTitles} (comma separated notebook titles, ?cascade=true or ?reparent=true for notebooks with children)\n" +
+		"DELETE /deleteNotebooks?notebooks= (comma separated notebook paths, eg: work/infra, accepts cascade and reparent too)\n" +
+		"GET /tags (tags with their number of notes) \n" +
+		"PUT /renameTag/{oldTag}/{newTag} \n" +
+		"PUT /mergeTags/{tags}/{into} (comma separated tags replaced by into) \n" +
+		"DELETE /deleteTags/{tags} (comma separated tags)\n",
+	Example:          "serve -p 7000",
+	PersistentPreRun: connectServeRepositories,
+	Run:              serve,
 }
 
 func serve(cmd *cobra.Command, args []string) {
 	port, _ := cmd.Flags().GetString("port")
+	if !cmd.Flags().Changed("port") {
+		port = Config.Port
+	}
 	server := NewServer()
 	server.Initialize()
 	server.Run(port)
 }
 
+//connectServeRepositories uses in memory repositories if --ephemeral is set, the DB otherwise.
+func connectServeRepositories(cmd *cobra.Command, args []string) {
+	ephemeral, _ := cmd.Flags().GetBool("ephemeral")
+	if !ephemeral {
+		connectRepositories(cmd, args)
+		return
+	}
+	initConfig()
+	Store = repository.NewMemoryStore()
+	NoteDB, NotebookDB, AccountDB = Store.Notes(), Store.Notebooks(), Store.Accounts()
+	password, err := createDemoAccount()
+	if err != nil {
+		log.Fatalf("Failed creating demo account, error msg: %v", err)
+	}
+	log.Printf("Ephemeral mode, nothing will be persisted. Login with username: %v password: %v", demoUsername, password)
+}
+
+//createDemoAccount creates the demo account with a random password and returns the password.
+func createDemoAccount() (string, error) {
+	randomBytes := make([]byte, 8)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	password := hex.EncodeToString(randomBytes)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		return "", err
+	}
+	if err := AccountDB.CreateAccount(demoUsername, hashedPassword); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
 func init() {
 	rootCmd.AddCommand(serveCmd)
-	serveCmd.Flags().StringP("port", "p", "8080", "Server port")
+	serveCmd.Flags().StringP("port", "p", "8080", "Server port (overrides config port)")
+	serveCmd.Flags().Bool("ephemeral", false, "Keep notes in memory only, useful for demos")
 }
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/nicolasmanic/tefter/repository"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestCreateDemoAccount(t *testing.T) {
+	originalAccountDB := AccountDB
+	_, _, AccountDB = repository.NewMemoryRepositories()
+	defer func() {
+		AccountDB = originalAccountDB
+	}()
+	password, err := createDemoAccount()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	account, err := AccountDB.GetAccount(demoUsername)
+	if err != nil {
+		t.Fatalf("Demo account not found, error msg: %v", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(password)); err != nil {
+		t.Errorf("Password of demo account does not match, error msg: %v", err)
+	}
+}
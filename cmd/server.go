@@ -4,13 +4,19 @@ import (
 	"crypto/rand"
 	"encoding/json"
 	"errors"
+	"fmt"
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/dgrijalva/jwt-go/request"
 	"github.com/gorilla/mux"
 	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
 	"golang.org/x/crypto/bcrypt"
+	"io/ioutil"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -42,12 +48,28 @@ func (s *Server) Initialize() {
 	s.Router.HandleFunc("/updateNote", s.updateNote).Methods("PUT")
 	s.Router.HandleFunc("/getNotesByID/{ids}", s.getNotes).Methods("GET")
 	s.Router.HandleFunc("/getNotesByNotebookTitle/{notebookTitles}", s.getNotes).Methods("GET")
-	s.Router.HandleFunc("/getNotesByTags/{Tags}", s.getNotes).Methods("GET")
+	//paths of nested notebooks contain slashes, they are given as url parameters
+	s.Router.HandleFunc("/getNotesByNotebookTitle", s.getNotes).Queries("notebooks", "{notebookTitles}").Methods("GET")
+	s.Router.HandleFunc("/getNotesByTags/{tags}", s.getNotes).Methods("GET")
 	s.Router.HandleFunc("/getAllNotes", s.getNotes).Methods("GET")
 	s.Router.HandleFunc("/deleteNotes/{ids}", s.deleteNotes).Methods("DELETE")
 	s.Router.HandleFunc("/searchBy/{keyword}", s.searchKeyword).Methods("GET")
+	s.Router.HandleFunc("/history/{id}", s.history).Methods("GET")
+	s.Router.HandleFunc("/links/{id}", s.links).Methods("GET")
+	s.Router.HandleFunc("/backlinks/{id}", s.backlinks).Methods("GET")
+	s.Router.HandleFunc("/attach/{id}", s.attach).Methods("POST")
+	s.Router.HandleFunc("/attachments/{id}", s.attachments).Methods("GET")
+	s.Router.HandleFunc("/attachment/{attachmentID}", s.attachment).Methods("GET")
+	s.Router.HandleFunc("/diff/{id}", s.diff).Methods("GET")
+	s.Router.HandleFunc("/restore/{id}/{revision}", s.restore).Methods("PUT")
 	s.Router.HandleFunc("/updateNotebook/{oldTitle}/{newTitle}", s.updateNotebook).Methods("PUT")
+	s.Router.HandleFunc("/updateNotebook", s.updateNotebook).Queries("old", "{oldTitle}", "new", "{newTitle}").Methods("PUT")
 	s.Router.HandleFunc("/deleteNotebooks/{notebookTitles}", s.deleteNotebooks).Methods("DELETE")
+	s.Router.HandleFunc("/deleteNotebooks", s.deleteNotebooks).Queries("notebooks", "{notebookTitles}").Methods("DELETE")
+	s.Router.HandleFunc("/tags", s.listTags).Methods("GET")
+	s.Router.HandleFunc("/renameTag/{oldTag}/{newTag}", s.renameTag).Methods("PUT")
+	s.Router.HandleFunc("/mergeTags/{tags}/{into}", s.mergeTags).Methods("PUT")
+	s.Router.HandleFunc("/deleteTags/{tags}", s.deleteTags).Methods("DELETE")
 	s.Router.HandleFunc("/login", s.login).Methods("POST")
 }
 
@@ -78,7 +100,7 @@ func (s *Server) addNote(w http.ResponseWriter, r *http.Request) {
 
 	if err := saveNoteFunc(jNote); err != nil {
 		log.Println(err)
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, httpStatus(err), err.Error())
 		return
 	}
 	respondWithJSON(w, http.StatusCreated, map[string]string{"result": "success"})
@@ -102,9 +124,18 @@ func (s *Server) updateNote(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	if err := updateNoteFunc(jNote); err != nil {
+	rewriteLinks := false
+	if strValue := r.URL.Query().Get("rewriteLinks"); strValue != "" {
+		var err error
+		if rewriteLinks, err = strconv.ParseBool(strValue); err != nil {
+			log.Printf("Error while parsing rewriteLinks, error msg: %v", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid rewriteLinks: %v", strValue))
+			return
+		}
+	}
+	if err := updateNoteFunc(jNote, rewriteLinks); err != nil {
 		log.Println(err)
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, httpStatus(err), err.Error())
 		return
 	}
 	respondWithJSON(w, http.StatusCreated, map[string]string{"result": "success"})
@@ -120,8 +151,6 @@ func (s *Server) getNotes(w http.ResponseWriter, r *http.Request) {
 	}
 
 	vars := mux.Vars(r)
-	var jsonNotes []*jsonNote
-	var err error
 
 	//Comma separated list of ids, tags, notebookTitles
 	strIDs := vars["ids"]
@@ -136,15 +165,23 @@ func (s *Server) getNotes(w http.ResponseWriter, r *http.Request) {
 	}
 	tags := parseStrings(strTags)
 	notebookTitles := parseStrings(strNotebookTitles)
+	//getAllNotes is the only endpoint without path variables
+	getAll := len(vars) == 0
 
-	jsonNotes, err = retrieveNotesFunc(ids, notebookTitles, tags, false)
+	query, paginated, err := parseNoteQuery(r)
+	if err != nil {
+		log.Printf("Error while parsing query, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	jsonNotes, next, err := retrieveNotesFunc(ids, notebookTitles, tags, getAll, r.FormValue("q"), query)
 	if err != nil {
 		log.Println(err)
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, httpStatus(err), err.Error())
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, jsonNotes)
+	respondWithNotes(w, jsonNotes, next, paginated)
 }
 
 var deleteNotesFunc = delete
@@ -169,7 +206,7 @@ func (s *Server) deleteNotes(w http.ResponseWriter, r *http.Request) {
 	err = deleteNotesFunc(int64Slice(ids))
 	if err != nil {
 		log.Print(err)
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, httpStatus(err), err.Error())
 		return
 	}
 	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
@@ -188,11 +225,22 @@ func (s *Server) deleteNotebooks(w http.ResponseWriter, r *http.Request) {
 	//Comma separated  notebookTitles
 	strNotebookTitles := vars["notebookTitles"]
 	notebookTitles := parseStrings(strNotebookTitles)
+	cascade, reparent := false, false
+	for param, value := range map[string]*bool{"cascade": &cascade, "reparent": &reparent} {
+		if strValue := r.URL.Query().Get(param); strValue != "" {
+			var err error
+			if *value, err = strconv.ParseBool(strValue); err != nil {
+				log.Printf("Error while parsing %v, error msg: %v", param, err)
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %v: %v", param, strValue))
+				return
+			}
+		}
+	}
 
-	err := deleteNotebooksFunc(notebookTitles)
+	err := deleteNotebooksFunc(notebookTitles, cascade, reparent)
 	if err != nil {
 		log.Println(err)
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, httpStatus(err), err.Error())
 		return
 	}
 	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
@@ -213,12 +261,89 @@ func (s *Server) updateNotebook(w http.ResponseWriter, r *http.Request) {
 	err := updateNotebookFunc(oldTitle, newTitle)
 	if err != nil {
 		log.Println(err)
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, httpStatus(err), err.Error())
 		return
 	}
 	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
 }
 
+var listTagsFunc = listTags
+
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	jTags, err := listTagsFunc()
+	if err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, jTags)
+}
+
+var renameTagFunc = renameTag
+
+func (s *Server) renameTag(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	renamed, err := renameTagFunc(vars["oldTag"], vars["newTag"])
+	if err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]int{"notes": renamed})
+}
+
+var mergeTagsFunc = mergeTags
+
+func (s *Server) mergeTags(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	//Comma separated tags
+	merged, err := mergeTagsFunc(parseStrings(vars["tags"]), vars["into"])
+	if err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]int{"notes": merged})
+}
+
+var deleteTagsFunc = deleteTags
+
+func (s *Server) deleteTags(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	//Comma separated tags
+	deleted, err := deleteTagsFunc(parseStrings(vars["tags"]))
+	if err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]int{"tags": deleted})
+}
+
 var searchNotesFunc = search
 
 func (s *Server) searchKeyword(w http.ResponseWriter, r *http.Request) {
@@ -229,22 +354,297 @@ func (s *Server) searchKeyword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	vars := mux.Vars(r)
+	//keyword is a query, see search
 	keyword := vars["keyword"]
-	notes, err := searchNotesFunc(keyword)
+	query, paginated, err := parseNoteQuery(r)
+	if err != nil {
+		log.Printf("Error while parsing query, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fuzzy := false
+	if strFuzzy := r.URL.Query().Get("fuzzy"); strFuzzy != "" {
+		if fuzzy, err = strconv.ParseBool(strFuzzy); err != nil {
+			log.Printf("Error while parsing fuzzy, error msg: %v", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid fuzzy: %v", strFuzzy))
+			return
+		}
+	}
+	page, err := searchNotesFunc(keyword, fuzzy, query)
 	if err != nil {
 		log.Println(err)
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, httpStatus(err), err.Error())
 		return
 	}
-	jNotes, err := transformNotes2JSONNotes(notes)
+	jNotes, err := transformSearchHits2JSONNotes(page.Hits)
 	if err != nil {
 		log.Println(err)
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	respondWithNotes(w, jNotes, page.Next, paginated)
+}
+
+var historyFunc = history
+
+func (s *Server) history(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		log.Printf("Error while parsing id, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid id: %v", vars["id"]))
+		return
+	}
+	jRevisions, err := historyFunc(id)
+	if err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, jRevisions)
+}
+
+var linksFunc = links
+
+//links responds with the notes that the note links to, links that match no note are left out.
+func (s *Server) links(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		log.Printf("Error while parsing id, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid id: %v", vars["id"]))
+		return
+	}
+	jNotes, _, err := linksFunc(id)
+	if err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
 		return
 	}
 	respondWithJSON(w, http.StatusOK, jNotes)
 }
 
+var backlinksFunc = backlinks
+
+func (s *Server) backlinks(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		log.Printf("Error while parsing id, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid id: %v", vars["id"]))
+		return
+	}
+	jNotes, err := backlinksFunc(id)
+	if err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, jNotes)
+}
+
+var addAttachmentsFunc = addAttachments
+
+//maxUploadMemory is the part of an upload kept in memory, the rest of the files is kept in temporary files.
+const maxUploadMemory = 32 << 20
+
+//attach stores the files of the multipart form fields named file as attachments of the note.
+func (s *Server) attach(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		log.Printf("Error while parsing id, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid id: %v", vars["id"]))
+		return
+	}
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		log.Printf("Error while parsing multipart form, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, "Failed decoding files")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	attachments := []*model.Attachment{}
+	for _, header := range r.MultipartForm.File["file"] {
+		content, err := readMultipartFile(header)
+		if err != nil {
+			log.Printf("Error while reading %v, error msg: %v", header.Filename, err)
+			respondWithError(w, http.StatusBadRequest, "Failed decoding files")
+			return
+		}
+		attachments = append(attachments, model.NewAttachment(id, header.Filename, content))
+	}
+	if len(attachments) == 0 {
+		respondWithError(w, http.StatusBadRequest, "No file to attach")
+		return
+	}
+	jAttachments, err := addAttachmentsFunc(attachments)
+	if err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, jAttachments)
+}
+
+func readMultipartFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
+
+var listAttachmentsFunc = listAttachments
+
+func (s *Server) attachments(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		log.Printf("Error while parsing id, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid id: %v", vars["id"]))
+		return
+	}
+	jAttachments, err := listAttachmentsFunc(id)
+	if err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, jAttachments)
+}
+
+var retrieveAttachmentFunc = retrieveAttachment
+
+//attachment responds with the content of the attachment as a file download named after the attachment.
+func (s *Server) attachment(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["attachmentID"], 10, 64)
+	if err != nil {
+		log.Printf("Error while parsing id, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid id: %v", vars["attachmentID"]))
+		return
+	}
+	attachment, err := retrieveAttachmentFunc(id)
+	if err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(attachment.Name))
+	if contentType == "" {
+		contentType = http.DetectContentType(attachment.Content)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(attachment.Content)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(attachment.Content)
+}
+
+var diffRevisionsFunc = diffRevisions
+
+//diff responds with the unified diff of the from and to revisions of the url parameters, see diffRevisions
+//for the defaults of missing revisions.
+func (s *Server) diff(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		log.Printf("Error while parsing id, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid id: %v", vars["id"]))
+		return
+	}
+	revisions := [2]int{}
+	for i, param := range []string{"from", "to"} {
+		if strRevision := r.URL.Query().Get(param); strRevision != "" {
+			if revisions[i], err = strconv.Atoi(strRevision); err != nil {
+				log.Printf("Error while parsing %v, error msg: %v", param, err)
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %v: %v", param, strRevision))
+				return
+			}
+		}
+	}
+	diff, err := diffRevisionsFunc(id, revisions[0], revisions[1])
+	if err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"diff": diff})
+}
+
+var restoreFunc = restore
+
+func (s *Server) restore(w http.ResponseWriter, r *http.Request) {
+	if err := checkTokenFunc(r, s.signingKey); err != nil {
+		log.Printf("Invalid token, failed with message: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Authorization failed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		log.Printf("Error while parsing id, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid id: %v", vars["id"]))
+		return
+	}
+	revision, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		log.Printf("Error while parsing revision, error msg: %v", err)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid revision: %v", vars["revision"]))
+		return
+	}
+	if err := restoreFunc(id, revision); err != nil {
+		log.Println(err)
+		respondWithError(w, httpStatus(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
 func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 	var accountRequest *model.Account
 	decoder := json.NewDecoder(r.Body)
@@ -254,6 +654,11 @@ func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	account, err := AccountDB.GetAccount(accountRequest.Username)
+	if errors.Is(err, repository.ErrAccountNotFound) {
+		log.Printf("Unknown username, error msg: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Username and password don't match")
+		return
+	}
 	if err != nil {
 		log.Printf("Error retrieving username, error msg: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "Error retrieving username")
@@ -322,6 +727,40 @@ func parseStrings(str string) []string {
 	return strings.Split(str, ",")
 }
 
+//parseNoteQuery returns the note query of the limit, cursor, sort and order url parameters,
+//paginated is true if limit or cursor is set.
+func parseNoteQuery(r *http.Request) (query repository.NoteQuery, paginated bool, err error) {
+	params := r.URL.Query()
+	if strLimit := params.Get("limit"); strLimit != "" {
+		query.Limit, err = strconv.Atoi(strLimit)
+		if err != nil {
+			return query, false, fmt.Errorf("Invalid limit: %v", strLimit)
+		}
+	}
+	query.Cursor = params.Get("cursor")
+	query.Sort = repository.NoteSort(params.Get("sort"))
+	query.Order = repository.SortOrder(params.Get("order"))
+	return query, query.Limit > 0 || query.Cursor != "", nil
+}
+
+//notesPage is the response of paginated note listings, Next is the cursor parameter of the next page.
+type notesPage struct {
+	Notes []*jsonNote `json:"notes"`
+	Next  string      `json:"next,omitempty"`
+}
+
+//respondWithNotes responds with the notes, paginated responses are wrapped in a notesPage.
+func respondWithNotes(w http.ResponseWriter, jNotes []*jsonNote, next string, paginated bool) {
+	if !paginated {
+		respondWithJSON(w, http.StatusOK, jNotes)
+		return
+	}
+	if jNotes == nil {
+		jNotes = []*jsonNote{}
+	}
+	respondWithJSON(w, http.StatusOK, notesPage{Notes: jNotes, Next: next})
+}
+
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, map[string]string{"error": message})
 }
@@ -2,13 +2,18 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/nicolasmanic/tefter/model"
 	"github.com/nicolasmanic/tefter/repository"
 	"golang.org/x/crypto/bcrypt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -71,7 +76,7 @@ func TestAddNoteAPI(t *testing.T) {
 func TestUpdateNoteAPI(t *testing.T) {
 	cases := []struct {
 		checkTokenFunc   func(r *http.Request, signingKey []byte) error
-		updateNoteFunc   func(*jsonNote) error
+		updateNoteFunc   func(*jsonNote, bool) error
 		payload          []byte
 		expectedHTTPCode int
 	}{
@@ -92,7 +97,7 @@ func TestUpdateNoteAPI(t *testing.T) {
 			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
 				return nil
 			},
-			updateNoteFunc: func(*jsonNote) error {
+			updateNoteFunc: func(*jsonNote, bool) error {
 				return errors.New("Unexpected Error")
 			},
 			payload:          []byte(`{"id":1, "title":"Shopping for weekend","memo":" Things for weekend:\n \u003e Milk\n \u003e Eggs\n \u003e Chicken breast\n","created":"2018-03-20T18:53:35.4123749+02:00","updated":"2018-03-20T18:53:35.4193801+02:00","tags":["weekend","list"],"notebook_title":"Shopping"}`),
@@ -102,7 +107,7 @@ func TestUpdateNoteAPI(t *testing.T) {
 			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
 				return nil
 			},
-			updateNoteFunc: func(*jsonNote) error {
+			updateNoteFunc: func(*jsonNote, bool) error {
 				return nil
 			},
 			payload:          []byte(`{"id":1, "title":"Shopping for weekend","memo":" Things for weekend:\n \u003e Milk\n \u003e Eggs\n \u003e Chicken breast\n","created":"2018-03-20T18:53:35.4123749+02:00","updated":"2018-03-20T18:53:35.4193801+02:00","tags":["weekend","list"],"notebook_title":"Shopping"}`),
@@ -129,7 +134,7 @@ func TestUpdateNoteAPI(t *testing.T) {
 func TestGetNotesAPI(t *testing.T) {
 	cases := []struct {
 		checkTokenFunc    func(r *http.Request, signingKey []byte) error
-		retrieveNotesFunc func(ids []int, notebookTitles, tags []string, getAll bool) ([]*jsonNote, error)
+		retrieveNotesFunc func(ids []int, notebookTitles, tags []string, getAll bool, queryText string, query repository.NoteQuery) ([]*jsonNote, string, error)
 		url               string
 		params            string
 		expectedHTTPCode  int
@@ -154,8 +159,8 @@ func TestGetNotesAPI(t *testing.T) {
 			},
 			url:    "/getNotesByID/",
 			params: "1",
-			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool) ([]*jsonNote, error) {
-				return nil, errors.New("Unexpected Error")
+			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool, queryText string, query repository.NoteQuery) ([]*jsonNote, string, error) {
+				return nil, "", errors.New("Unexpected Error")
 			},
 			expectedHTTPCode: http.StatusInternalServerError,
 		}, {
@@ -164,8 +169,8 @@ func TestGetNotesAPI(t *testing.T) {
 			},
 			url:    "/getNotesByID/",
 			params: "1,2,3",
-			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool) ([]*jsonNote, error) {
-				return nil, errors.New("Unexpected Error")
+			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool, queryText string, query repository.NoteQuery) ([]*jsonNote, string, error) {
+				return nil, "", errors.New("Unexpected Error")
 			},
 			expectedHTTPCode: http.StatusInternalServerError,
 		}, {
@@ -174,8 +179,8 @@ func TestGetNotesAPI(t *testing.T) {
 			},
 			url:    "/getNotesByID/",
 			params: "1,2,3",
-			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool) ([]*jsonNote, error) {
-				return mockJSONNotes(), nil
+			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool, queryText string, query repository.NoteQuery) ([]*jsonNote, string, error) {
+				return mockJSONNotes(), "", nil
 			},
 			expectedHTTPCode: http.StatusOK,
 		}, {
@@ -184,8 +189,8 @@ func TestGetNotesAPI(t *testing.T) {
 			},
 			url:    "/getNotesByNotebookTitle/",
 			params: "title1,title2",
-			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool) ([]*jsonNote, error) {
-				return mockJSONNotes(), nil
+			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool, queryText string, query repository.NoteQuery) ([]*jsonNote, string, error) {
+				return mockJSONNotes(), "", nil
 			},
 			expectedHTTPCode: http.StatusOK,
 		}, {
@@ -194,8 +199,8 @@ func TestGetNotesAPI(t *testing.T) {
 			},
 			url:    "/getNotesByTags/",
 			params: "tag1,tag2",
-			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool) ([]*jsonNote, error) {
-				return mockJSONNotes(), nil
+			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool, queryText string, query repository.NoteQuery) ([]*jsonNote, string, error) {
+				return mockJSONNotes(), "", nil
 			},
 			expectedHTTPCode: http.StatusOK,
 		}, {
@@ -204,8 +209,8 @@ func TestGetNotesAPI(t *testing.T) {
 			},
 			url:    "/getAllNotes",
 			params: "",
-			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool) ([]*jsonNote, error) {
-				return mockJSONNotes(), nil
+			retrieveNotesFunc: func(ids []int, notebookTitles, tags []string, getAll bool, queryText string, query repository.NoteQuery) ([]*jsonNote, string, error) {
+				return mockJSONNotes(), "", nil
 			},
 			expectedHTTPCode: http.StatusOK,
 		},
@@ -228,6 +233,78 @@ func TestGetNotesAPI(t *testing.T) {
 	}
 }
 
+func TestGetNotesAPIPagination(t *testing.T) {
+	cases := []struct {
+		url              string
+		expectedQuery    repository.NoteQuery
+		expectedGetAll   bool
+		expectedText     string
+		expectedHTTPCode int
+		expectedBody     string
+	}{
+		{
+			url:              "/getAllNotes",
+			expectedGetAll:   true,
+			expectedHTTPCode: http.StatusOK,
+			expectedBody:     "[",
+		}, {
+			url:              "/getAllNotes?limit=2&sort=title&order=asc",
+			expectedQuery:    repository.NoteQuery{Limit: 2, Sort: repository.SortByTitle, Order: repository.Ascending},
+			expectedGetAll:   true,
+			expectedHTTPCode: http.StatusOK,
+			expectedBody:     `{"notes":[`,
+		}, {
+			url:              "/getNotesByTags/tag1?cursor=abc",
+			expectedQuery:    repository.NoteQuery{Cursor: "abc"},
+			expectedHTTPCode: http.StatusOK,
+			expectedBody:     `{"notes":[`,
+		}, {
+			url:              "/getNotesByTags/tag1?q=" + url.QueryEscape("-tag:done"),
+			expectedText:     "-tag:done",
+			expectedHTTPCode: http.StatusOK,
+			expectedBody:     "[",
+		}, {
+			url:              "/getAllNotes?limit=two",
+			expectedHTTPCode: http.StatusBadRequest,
+		},
+	}
+
+	originalRetrieveNotes := retrieveNotesFunc
+	originalCheckToken := checkTokenFunc
+	defer func() {
+		retrieveNotesFunc = originalRetrieveNotes
+		checkTokenFunc = originalCheckToken
+	}()
+	checkTokenFunc = func(r *http.Request, signingKey []byte) error {
+		return nil
+	}
+	for _, c := range cases {
+		var query repository.NoteQuery
+		var getAll bool
+		var queryText string
+		retrieveNotesFunc = func(ids []int, notebookTitles, tags []string, all bool, text string, q repository.NoteQuery) ([]*jsonNote, string, error) {
+			query, getAll, queryText = q, all, text
+			return mockJSONNotes(), "next", nil
+		}
+
+		req, _ := http.NewRequest("GET", c.url, nil)
+		response := executeRequest(req)
+		checkResponseCode(t, c.expectedHTTPCode, response.Code)
+		if c.expectedHTTPCode != http.StatusOK {
+			continue
+		}
+		if query != c.expectedQuery || getAll != c.expectedGetAll || queryText != c.expectedText {
+			t.Errorf("Expected query %+v, getAll %v and q %v for url %v, got %+v, %v and %v", c.expectedQuery, c.expectedGetAll, c.expectedText, c.url, query, getAll, queryText)
+		}
+		if !strings.HasPrefix(response.Body.String(), c.expectedBody) {
+			t.Errorf("Expected body of url %v to start with %v, got %v", c.url, c.expectedBody, response.Body.String())
+		}
+		if strings.HasPrefix(c.expectedBody, "{") && !strings.Contains(response.Body.String(), `"next":"next"`) {
+			t.Errorf("Paginated response should contain the next cursor, got %v", response.Body.String())
+		}
+	}
+}
+
 func TestDeleteNotesAPI(t *testing.T) {
 	cases := []struct {
 		checkTokenFunc   func(r *http.Request, signingKey []byte) error
@@ -288,7 +365,7 @@ func TestDeleteNotesAPI(t *testing.T) {
 func TestDeleteNotebooksAPI(t *testing.T) {
 	cases := []struct {
 		checkTokenFunc      func(r *http.Request, signingKey []byte) error
-		deleteNotebooksFunc func(titles []string) error
+		deleteNotebooksFunc func(titles []string, cascade, reparent bool) error
 		params              string
 		expectedHTTPCode    int
 	}{
@@ -302,7 +379,7 @@ func TestDeleteNotebooksAPI(t *testing.T) {
 			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
 				return nil
 			},
-			deleteNotebooksFunc: func(titles []string) error {
+			deleteNotebooksFunc: func(titles []string, cascade, reparent bool) error {
 				return errors.New("Unexpected Error")
 			},
 			params:           "title1",
@@ -311,11 +388,56 @@ func TestDeleteNotebooksAPI(t *testing.T) {
 			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
 				return nil
 			},
-			deleteNotebooksFunc: func(titles []string) error {
+			deleteNotebooksFunc: func(titles []string, cascade, reparent bool) error {
+				return fmt.Errorf("Could not retrieve notebook, error msg: %w", repository.ErrNotebookNotFound)
+			},
+			params:           "title1",
+			expectedHTTPCode: http.StatusNotFound,
+		}, {
+			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
+				return nil
+			},
+			deleteNotebooksFunc: func(titles []string, cascade, reparent bool) error {
+				return fmt.Errorf("Could not delete notebook, error msg: %w", repository.ErrDefaultNotebookProtected)
+			},
+			params:           "title1",
+			expectedHTTPCode: http.StatusConflict,
+		}, {
+			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
+				return nil
+			},
+			deleteNotebooksFunc: func(titles []string, cascade, reparent bool) error {
 				return nil
 			},
 			params:           "title1",
 			expectedHTTPCode: http.StatusOK,
+		}, {
+			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
+				return nil
+			},
+			deleteNotebooksFunc: func(titles []string, cascade, reparent bool) error {
+				if !cascade || reparent {
+					return errors.New("Unexpected flags")
+				}
+				return nil
+			},
+			params:           "title1?cascade=true",
+			expectedHTTPCode: http.StatusOK,
+		}, {
+			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
+				return nil
+			},
+			deleteNotebooksFunc: func(titles []string, cascade, reparent bool) error {
+				return fmt.Errorf("Could not delete notebook, error msg: %w", repository.ErrNotebookHasChildren)
+			},
+			params:           "title1",
+			expectedHTTPCode: http.StatusConflict,
+		}, {
+			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
+				return nil
+			},
+			params:           "title1?reparent=maybe",
+			expectedHTTPCode: http.StatusBadRequest,
 		},
 	}
 
@@ -382,10 +504,55 @@ func TestUpdateNotebooksAPI(t *testing.T) {
 
 }
 
+func TestNestedNotebookAPI(t *testing.T) {
+	oldStore, oldNoteDB, oldNotebookDB, oldCheckToken := Store, NoteDB, NotebookDB, checkTokenFunc
+	Store = repository.NewMemoryStore()
+	NoteDB, NotebookDB = Store.Notes(), Store.Notebooks()
+	checkTokenFunc = func(r *http.Request, signingKey []byte) error {
+		return nil
+	}
+	defer func() {
+		Store, NoteDB, NotebookDB, checkTokenFunc = oldStore, oldNoteDB, oldNotebookDB, oldCheckToken
+	}()
+
+	workID, _ := NotebookDB.SaveNotebook(model.NewNotebook("work"))
+	nested := model.NewNotebook("infra")
+	nested.ParentID = workID
+	nestedID, _ := NotebookDB.SaveNotebook(nested)
+	topID, _ := NotebookDB.SaveNotebook(model.NewNotebook("infra"))
+	NoteDB.SaveNote(&model.Note{Title: "nested", Memo: "memo", NotebookID: nestedID})
+	NoteDB.SaveNote(&model.Note{Title: "top", Memo: "memo", NotebookID: topID})
+
+	req, _ := http.NewRequest("GET", "/getNotesByNotebookTitle?notebooks="+url.QueryEscape("work/infra"), nil)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	var jNotes []*jsonNote
+	if err := json.Unmarshal(response.Body.Bytes(), &jNotes); err != nil || len(jNotes) != 1 || jNotes[0].Title != "nested" {
+		t.Errorf("Expected the note of work/infra, got: %s, error msg: %v", response.Body.String(), err)
+	}
+
+	req, _ = http.NewRequest("PUT", "/updateNotebook?old="+url.QueryEscape("work/infra")+"&new="+url.QueryEscape("work/ops"), nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	if notebook, err := NotebookDB.GetNotebookByTitle("work/ops"); err != nil || notebook == nil || notebook.ID != nestedID {
+		t.Errorf("Expected work/infra to be renamed to work/ops, got: %v, error msg: %v", notebook, err)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/deleteNotebooks?notebooks="+url.QueryEscape("work/ops"), nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	if notebook, err := NotebookDB.GetNotebookByTitle("work/ops"); err != nil || notebook != nil {
+		t.Errorf("Expected work/ops to be deleted, got: %v, error msg: %v", notebook, err)
+	}
+	if notebook, err := NotebookDB.GetNotebookByTitle("infra"); err != nil || notebook == nil || notebook.ID != topID {
+		t.Errorf("Expected top level notebook infra to be kept, got: %v, error msg: %v", notebook, err)
+	}
+}
+
 func TestSearchNotesAPI(t *testing.T) {
 	cases := []struct {
 		checkTokenFunc   func(r *http.Request, signingKey []byte) error
-		searchNotesFunc  func(keyword string) ([]*model.Note, error)
+		searchNotesFunc  func(keyword string, fuzzy bool, query repository.NoteQuery) (*repository.SearchPage, error)
 		notebookDB       mockNotebookDBAPI
 		expectedHTTPCode int
 	}{
@@ -398,7 +565,7 @@ func TestSearchNotesAPI(t *testing.T) {
 			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
 				return nil
 			},
-			searchNotesFunc: func(keyword string) ([]*model.Note, error) {
+			searchNotesFunc: func(keyword string, fuzzy bool, query repository.NoteQuery) (*repository.SearchPage, error) {
 				return nil, errors.New("Unexpected Error")
 			},
 			expectedHTTPCode: http.StatusInternalServerError,
@@ -406,8 +573,8 @@ func TestSearchNotesAPI(t *testing.T) {
 			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
 				return nil
 			},
-			searchNotesFunc: func(keyword string) ([]*model.Note, error) {
-				return nil, nil
+			searchNotesFunc: func(keyword string, fuzzy bool, query repository.NoteQuery) (*repository.SearchPage, error) {
+				return &repository.SearchPage{}, nil
 			},
 			notebookDB: mockNotebookDBAPI{
 				notebookTitles: map[int64]string{1: "testTitle", 2: "testTitle2"},
@@ -418,9 +585,9 @@ func TestSearchNotesAPI(t *testing.T) {
 			checkTokenFunc: func(r *http.Request, signingKey []byte) error {
 				return nil
 			},
-			searchNotesFunc: func(keyword string) ([]*model.Note, error) {
+			searchNotesFunc: func(keyword string, fuzzy bool, query repository.NoteQuery) (*repository.SearchPage, error) {
 				note1 := model.NewNote("testTitle", "testMemo", 1, []string{})
-				return []*model.Note{note1}, nil
+				return &repository.SearchPage{Hits: []*repository.SearchHit{{Note: note1}}}, nil
 			},
 			notebookDB: mockNotebookDBAPI{
 				notebookTitles: map[int64]string{1: "testTitle", 2: "testTitle2"},
@@ -449,6 +616,76 @@ func TestSearchNotesAPI(t *testing.T) {
 	}
 }
 
+func TestSearchNotesAPIFuzzy(t *testing.T) {
+	originalSearchNotes := searchNotesFunc
+	originalCheckToken := checkTokenFunc
+	oldNotebookDB := NotebookDB
+	defer func() {
+		searchNotesFunc = originalSearchNotes
+		checkTokenFunc = originalCheckToken
+		NotebookDB = oldNotebookDB
+	}()
+	NotebookDB = mockNotebookDBAPI{}
+	checkTokenFunc = func(r *http.Request, signingKey []byte) error {
+		return nil
+	}
+	var searchedFuzzy bool
+	searchNotesFunc = func(keyword string, fuzzy bool, query repository.NoteQuery) (*repository.SearchPage, error) {
+		searchedFuzzy = fuzzy
+		return &repository.SearchPage{}, nil
+	}
+
+	req, _ := http.NewRequest("GET", "/searchBy/kube?fuzzy=true", nil)
+	checkResponseCode(t, http.StatusOK, executeRequest(req).Code)
+	if !searchedFuzzy {
+		t.Errorf("Expected fuzzy parameter to be passed to search")
+	}
+	req, _ = http.NewRequest("GET", "/searchBy/kube?fuzzy=maybe", nil)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req).Code)
+}
+
+func TestRevisionsAPI(t *testing.T) {
+	originalCheckToken := checkTokenFunc
+	oldNoteDB := NoteDB
+	defer func() {
+		checkTokenFunc = originalCheckToken
+		NoteDB = oldNoteDB
+	}()
+	checkTokenFunc = func(r *http.Request, signingKey []byte) error {
+		return nil
+	}
+	NoteDB = mockNoteDBRevisions{revisions: []*model.Revision{
+		{NoteID: 1, Number: 1, Title: "title", Memo: "first\n"},
+		{NoteID: 1, Number: 2, Title: "title", Memo: "second\n"},
+	}}
+
+	cases := []struct {
+		method           string
+		url              string
+		expectedHTTPCode int
+		expectedBody     string
+	}{
+		{method: "GET", url: "/history/1", expectedHTTPCode: http.StatusOK, expectedBody: `"memo":"second\n"`},
+		{method: "GET", url: "/history/2", expectedHTTPCode: http.StatusNotFound},
+		{method: "GET", url: "/history/a", expectedHTTPCode: http.StatusBadRequest},
+		{method: "GET", url: "/diff/1", expectedHTTPCode: http.StatusOK, expectedBody: `-first\n+second\n`},
+		{method: "GET", url: "/diff/1?from=2&to=1", expectedHTTPCode: http.StatusOK, expectedBody: `-second\n+first\n`},
+		{method: "GET", url: "/diff/1?from=3", expectedHTTPCode: http.StatusNotFound},
+		{method: "GET", url: "/diff/1?to=b", expectedHTTPCode: http.StatusBadRequest},
+		{method: "PUT", url: "/restore/1/1", expectedHTTPCode: http.StatusOK},
+		{method: "PUT", url: "/restore/1/3", expectedHTTPCode: http.StatusNotFound},
+		{method: "PUT", url: "/restore/1/b", expectedHTTPCode: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, c.url, nil)
+		response := executeRequest(req)
+		checkResponseCode(t, c.expectedHTTPCode, response.Code)
+		if !strings.Contains(response.Body.String(), c.expectedBody) {
+			t.Errorf("Expected response of %v to contain %v, got: %v", c.url, c.expectedBody, response.Body.String())
+		}
+	}
+}
+
 func TestLoginAPI(t *testing.T) {
 	cases := []struct {
 		payload          []byte
@@ -589,3 +826,134 @@ func mockJSONNotes() []*jsonNote {
 
 	return []*jsonNote{jNote1, jNote2}
 }
+
+func TestTagsAPI(t *testing.T) {
+	originalCheckToken := checkTokenFunc
+	defer func() {
+		checkTokenFunc = originalCheckToken
+	}()
+	checkTokenFunc = func(r *http.Request, signingKey []byte) error {
+		return nil
+	}
+	defer useTagsStore(t, []string{"golang", "todo"}, []string{"go-lang"}, []string{"wip"})()
+
+	cases := []struct {
+		method           string
+		url              string
+		expectedHTTPCode int
+		expectedBody     string
+	}{
+		{method: "GET", url: "/tags", expectedHTTPCode: http.StatusOK, expectedBody: `{"tag":"go-lang","count":1}`},
+		{method: "PUT", url: "/renameTag/golang/go", expectedHTTPCode: http.StatusOK, expectedBody: `{"notes":1}`},
+		{method: "PUT", url: "/renameTag/rust/go", expectedHTTPCode: http.StatusNotFound},
+		{method: "PUT", url: "/renameTag/go/todo", expectedHTTPCode: http.StatusConflict},
+		{method: "PUT", url: "/mergeTags/go-lang,GO/go", expectedHTTPCode: http.StatusOK, expectedBody: `{"notes":1}`},
+		{method: "PUT", url: "/mergeTags/go/go", expectedHTTPCode: http.StatusUnprocessableEntity},
+		{method: "DELETE", url: "/deleteTags/todo,wip", expectedHTTPCode: http.StatusOK, expectedBody: `{"tags":2}`},
+		{method: "DELETE", url: "/deleteTags/todo", expectedHTTPCode: http.StatusNotFound},
+		{method: "GET", url: "/tags", expectedHTTPCode: http.StatusOK, expectedBody: `[{"tag":"go","count":2}]`},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, c.url, nil)
+		response := executeRequest(req)
+		checkResponseCode(t, c.expectedHTTPCode, response.Code)
+		if !strings.Contains(response.Body.String(), c.expectedBody) {
+			t.Errorf("Expected response of %v to contain %v, got: %v", c.url, c.expectedBody, response.Body.String())
+		}
+	}
+}
+
+func TestLinksAPI(t *testing.T) {
+	originalCheckToken := checkTokenFunc
+	defer func() {
+		checkTokenFunc = originalCheckToken
+	}()
+	checkTokenFunc = func(r *http.Request, signingKey []byte) error {
+		return nil
+	}
+	defer useMemoryStore(t,
+		model.NewNote("Bali 2018", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+		model.NewNote("trip", "see [[Bali 2018]] and [[missing]]", repository.DEFAULT_NOTEBOOK_ID, []string{}),
+	)()
+
+	cases := []struct {
+		method           string
+		url              string
+		payload          string
+		expectedHTTPCode int
+		expectedBody     string
+	}{
+		{method: "GET", url: "/links/2", expectedHTTPCode: http.StatusOK, expectedBody: `"title":"Bali 2018"`},
+		{method: "GET", url: "/backlinks/1", expectedHTTPCode: http.StatusOK, expectedBody: `"title":"trip"`},
+		{method: "GET", url: "/links/42", expectedHTTPCode: http.StatusNotFound},
+		{method: "GET", url: "/backlinks/a", expectedHTTPCode: http.StatusBadRequest},
+		{method: "PUT", url: "/updateNote?rewriteLinks=yes", payload: `{"id":1,"title":"Bali trip","memo":"memo"}`, expectedHTTPCode: http.StatusBadRequest},
+		{method: "PUT", url: "/updateNote?rewriteLinks=true", payload: `{"id":1,"title":"Bali trip","memo":"memo"}`, expectedHTTPCode: http.StatusCreated},
+		{method: "GET", url: "/backlinks/1", expectedHTTPCode: http.StatusOK, expectedBody: `see [[Bali trip]] and [[missing]]`},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, c.url, strings.NewReader(c.payload))
+		response := executeRequest(req)
+		checkResponseCode(t, c.expectedHTTPCode, response.Code)
+		if !strings.Contains(response.Body.String(), c.expectedBody) {
+			t.Errorf("Expected response of %v to contain %v, got: %v", c.url, c.expectedBody, response.Body.String())
+		}
+	}
+}
+
+func TestAttachmentsAPI(t *testing.T) {
+	originalCheckToken := checkTokenFunc
+	defer func() {
+		checkTokenFunc = originalCheckToken
+	}()
+	checkTokenFunc = func(r *http.Request, signingKey []byte) error {
+		return nil
+	}
+	defer useMemoryStore(t, model.NewNote("title", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{}))()
+
+	upload := func(url string, files map[string]string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for name, content := range files {
+			part, _ := writer.CreateFormFile("file", name)
+			part.Write([]byte(content))
+		}
+		writer.Close()
+		req, _ := http.NewRequest("POST", url, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return executeRequest(req)
+	}
+	response := upload("/attach/1", map[string]string{"../app.log": "line 1\nline 2"})
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	if !strings.Contains(response.Body.String(), `"name":"app.log"`) || strings.Contains(response.Body.String(), `"content"`) {
+		t.Errorf("Expected the attachment without content, got: %v", response.Body.String())
+	}
+	checkResponseCode(t, http.StatusNotFound, upload("/attach/42", map[string]string{"app.log": "log"}).Code)
+	checkResponseCode(t, http.StatusBadRequest, upload("/attach/1", map[string]string{}).Code)
+
+	cases := []struct {
+		url              string
+		expectedHTTPCode int
+		expectedBody     string
+	}{
+		{url: "/attachments/1", expectedHTTPCode: http.StatusOK, expectedBody: `"size":13`},
+		{url: "/attachments/42", expectedHTTPCode: http.StatusNotFound},
+		{url: "/attachment/1", expectedHTTPCode: http.StatusOK, expectedBody: "line 1\nline 2"},
+		{url: "/attachment/42", expectedHTTPCode: http.StatusNotFound},
+		{url: "/attachment/a", expectedHTTPCode: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", c.url, nil)
+		response := executeRequest(req)
+		checkResponseCode(t, c.expectedHTTPCode, response.Code)
+		if !strings.Contains(response.Body.String(), c.expectedBody) {
+			t.Errorf("Expected response of %v to contain %v, got: %v", c.url, c.expectedBody, response.Body.String())
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/attachment/1", nil)
+	response = executeRequest(req)
+	if disposition := response.Header().Get("Content-Disposition"); disposition != `attachment; filename=app.log` {
+		t.Errorf("Unexpected Content-Disposition: %v", disposition)
+	}
+}
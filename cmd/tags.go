@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nicolasmanic/tefter/repository"
+	"github.com/spf13/cobra"
+)
+
+type jsonTag struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+var (
+	tagsCmd = &cobra.Command{
+		Use:   "tags",
+		Short: "List/Rename/Merge/Delete the tags of notes",
+		Long: "Tags are kept in lower case without surrounding spaces, commands accept them in any case.\n" +
+			"Renaming, merging and deleting tags applies to the notes in the trash too.",
+	}
+	listTagsCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the tags with the number of notes tagged with them",
+		Args:  cobra.NoArgs,
+		Run:   listTagsWrapper,
+	}
+	renameTagCmd = &cobra.Command{
+		Use:     "rename",
+		Short:   "Rename a tag on every note",
+		Long:    "Rename requires 2 arguments first the old tag, and the new tag, use merge if the new tag is already in use",
+		Example: "tags rename golnag golang",
+		Args:    cobra.ExactArgs(2),
+		Run:     renameTagWrapper,
+	}
+	mergeTagsCmd = &cobra.Command{
+		Use:     "merge",
+		Short:   "Replace one or more tags with the tag of --into on every note",
+		Example: "tags merge golang go-lang --into go",
+		Args:    cobra.MinimumNArgs(1),
+		Run:     mergeTagsWrapper,
+	}
+	deleteTagsCmd = &cobra.Command{
+		Use:     "delete",
+		Short:   "Remove one or more tags from every note",
+		Example: "tags delete todo wip",
+		Args:    cobra.MinimumNArgs(1),
+		Run:     deleteTagsWrapper,
+	}
+)
+
+func init() {
+	mergeTagsCmd.Flags().StringP("into", "i", "", "Tag that replaces the merged tags")
+	tagsCmd.AddCommand(listTagsCmd)
+	tagsCmd.AddCommand(renameTagCmd)
+	tagsCmd.AddCommand(mergeTagsCmd)
+	tagsCmd.AddCommand(deleteTagsCmd)
+	rootCmd.AddCommand(tagsCmd)
+}
+
+func listTagsWrapper(cmd *cobra.Command, args []string) {
+	tags, err := listTags()
+	if err != nil {
+		exitWithError(err)
+	}
+	printTags(tags)
+}
+
+func renameTagWrapper(cmd *cobra.Command, args []string) {
+	renamed, err := renameTag(args[0], args[1])
+	if err != nil {
+		exitWithError(err)
+	}
+	fmt.Printf("Renamed tag of %d notes\n", renamed)
+}
+
+func mergeTagsWrapper(cmd *cobra.Command, args []string) {
+	into, _ := cmd.Flags().GetString("into")
+	merged, err := mergeTags(args, into)
+	if err != nil {
+		exitWithError(err)
+	}
+	fmt.Printf("Merged tags of %d notes\n", merged)
+}
+
+func deleteTagsWrapper(cmd *cobra.Command, args []string) {
+	deleted, err := deleteTags(args)
+	if err != nil {
+		exitWithError(err)
+	}
+	fmt.Printf("Removed %d tags from notes\n", deleted)
+}
+
+func listTags() ([]*jsonTag, error) {
+	tags, err := NoteDB.ListTags()
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving tags, error msg: %w", err)
+	}
+	jTags := make([]*jsonTag, 0, len(tags))
+	for _, tag := range tags {
+		jTags = append(jTags, &jsonTag{Tag: tag.Tag, Count: tag.Count})
+	}
+	return jTags, nil
+}
+
+func printTags(tags []*jsonTag) {
+	if len(tags) == 0 {
+		fmt.Println("No tags available")
+		return
+	}
+	fmt.Println("> Tags:")
+	for _, tag := range tags {
+		fmt.Printf(" - %s (%d notes)\n", tag.Tag, tag.Count)
+	}
+}
+
+func renameTag(oldTag, newTag string) (int, error) {
+	renamed, err := NoteDB.RenameTag(oldTag, newTag)
+	if err != nil {
+		return 0, fmt.Errorf("Error while renaming tag: %v, error msg: %w", oldTag, err)
+	}
+	return renamed, nil
+}
+
+func mergeTags(tags []string, into string) (int, error) {
+	if into == "" {
+		return 0, errors.New("No tag to merge into, it should be set with --into")
+	}
+	merged, err := NoteDB.MergeTags(tags, into)
+	if err != nil {
+		return 0, fmt.Errorf("Error while merging tags: %v, error msg: %w", tags, err)
+	}
+	return merged, nil
+}
+
+//deleteTags removes the tags from every note in one transaction, if one of them is not found none is removed.
+//It returns the number of removed tags.
+func deleteTags(tags []string) (int, error) {
+	deleted := 0
+	err := withTx(func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+		for _, tag := range tags {
+			count, err := noteDB.DeleteTag(tag)
+			if err != nil {
+				return fmt.Errorf("Error while deleting tag: %v, error msg: %w", tag, err)
+			}
+			deleted += count
+		}
+		return nil
+	})
+	return deleted, err
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"reflect"
+	"testing"
+)
+
+//useTagsStore swaps the DBs with a memory store holding notes tagged with tags.
+func useTagsStore(t *testing.T, tags ...[]string) func() {
+	t.Helper()
+	notes := make([]*model.Note, 0, len(tags))
+	for i, noteTags := range tags {
+		notes = append(notes, model.NewNote(fmt.Sprintf("title%d", i), "memo", repository.DEFAULT_NOTEBOOK_ID, noteTags))
+	}
+	return useMemoryStore(t, notes...)
+}
+
+func TestListTags(t *testing.T) {
+	defer useTagsStore(t, []string{"Go", "todo"}, []string{"go"})()
+
+	tags, err := listTags()
+	if err != nil {
+		t.Fatalf("Could not list tags, error msg: %v", err)
+	}
+	expected := []*jsonTag{{Tag: "go", Count: 2}, {Tag: "todo", Count: 1}}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Expected tags %v, got: %v", expected, tags)
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	cases := []struct {
+		tags           []string
+		into           string
+		expectedMerged int
+		expectedErr    error
+	}{
+		{
+			tags:        []string{"golang"},
+			expectedErr: errors.New("No tag to merge into, it should be set with --into"),
+		}, {
+			tags:        []string{"golang", "rust"},
+			into:        "go",
+			expectedErr: repository.ErrTagNotFound,
+		}, {
+			tags:           []string{"golang", "go-lang"},
+			into:           "go",
+			expectedMerged: 2,
+		},
+	}
+
+	for _, c := range cases {
+		restore := useTagsStore(t, []string{"golang", "go"}, []string{"go-lang"})
+		merged, err := mergeTags(c.tags, c.into)
+		restore()
+		if merged != c.expectedMerged {
+			t.Errorf("Expected %v merged notes, got: %v", c.expectedMerged, merged)
+		}
+		if !sameError(c.expectedErr, err) && !errors.Is(err, c.expectedErr) {
+			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
+		}
+	}
+}
+
+func TestDeleteTags(t *testing.T) {
+	defer useTagsStore(t, []string{"go", "todo"}, []string{"todo"})()
+
+	//a missing tag rolls back the whole delete
+	if _, err := deleteTags([]string{"todo", "wip"}); !errors.Is(err, repository.ErrTagNotFound) {
+		t.Errorf("Expected ErrTagNotFound, got: %v", err)
+	}
+	if tags, _ := listTags(); len(tags) != 2 {
+		t.Errorf("Expected no tag to be deleted, got: %v", tags)
+	}
+
+	deleted, err := deleteTags([]string{"TODO", "go"})
+	if err != nil || deleted != 3 {
+		t.Errorf("Expected 3 deleted tags, got: %v, error msg: %v", deleted, err)
+	}
+	if tags, _ := listTags(); len(tags) != 0 {
+		t.Errorf("Expected no tags, got: %v", tags)
+	}
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nicolasmanic/tefter/config"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"github.com/spf13/cobra"
+	"log"
+	"strconv"
+	"time"
+)
+
+var (
+	trashCmd = &cobra.Command{
+		Use:   "trash",
+		Short: "List/Restore/Empty deleted notes and notebooks",
+		Long: "Deleted notes and notebooks are moved to the trash, they can be restored until the trash is emptied.\n" +
+			"Items older than the trash_retention setting (30d by default, 0 to keep them) are purged automatically.",
+	}
+	listTrashCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the notes and notebooks in the trash",
+		Args:  cobra.NoArgs,
+		Run:   listTrashWrapper,
+	}
+	restoreTrashCmd = &cobra.Command{
+		Use:     "restore",
+		Short:   "Restore notes based on ID(s) and notebooks based on title from the trash",
+		Example: "trash restore 1 2 -n lists",
+		Run:     restoreTrashWrapper,
+	}
+	emptyTrashCmd = &cobra.Command{
+		Use:   "empty",
+		Short: "Delete for good the notes and notebooks in the trash",
+		Args:  cobra.NoArgs,
+		Run:   emptyTrashWrapper,
+	}
+)
+
+func init() {
+	restoreTrashCmd.Flags().StringSliceP("notebook", "n", []string{}, "Titles of the notebooks to restore")
+	trashCmd.AddCommand(listTrashCmd)
+	trashCmd.AddCommand(restoreTrashCmd)
+	trashCmd.AddCommand(emptyTrashCmd)
+	rootCmd.AddCommand(trashCmd)
+}
+
+func listTrashWrapper(cmd *cobra.Command, args []string) {
+	notes, notebooks, err := listTrash()
+	if err != nil {
+		exitWithError(err)
+	}
+	printTrash(notes, notebooks)
+}
+
+func restoreTrashWrapper(cmd *cobra.Command, args []string) {
+	notebookTitles, _ := cmd.Flags().GetStringSlice("notebook")
+	if err := restoreTrashArgs(args, notebookTitles); err != nil {
+		exitWithError(err)
+	}
+}
+
+func emptyTrashWrapper(cmd *cobra.Command, args []string) {
+	notes, notebooks, err := emptyTrash(time.Now())
+	if err != nil {
+		exitWithError(err)
+	}
+	fmt.Printf("Deleted %d notes and %d notebooks\n", notes, notebooks)
+}
+
+func listTrash() ([]*model.Note, []*model.Notebook, error) {
+	page, err := NoteDB.ListNotes(repository.NoteFilter{Trashed: true}, repository.NoteQuery{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error while retrieving notes in the trash, error msg: %w", err)
+	}
+	notebooks, err := NotebookDB.GetTrashedNotebooks()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error while retrieving notebooks in the trash, error msg: %w", err)
+	}
+	return page.Notes, notebooks, nil
+}
+
+func printTrash(notes []*model.Note, notebooks []*model.Notebook) {
+	if len(notes) == 0 && len(notebooks) == 0 {
+		fmt.Println("Trash is empty")
+		return
+	}
+	if len(notebooks) > 0 {
+		fmt.Println("> Notebooks:")
+		for _, notebook := range notebooks {
+			fmt.Printf(" - %s (%d notes, deleted %s)\n", notebook.Title, len(notebook.Notes), formatDeletedAt(notebook.DeletedAt))
+		}
+	}
+	if len(notes) > 0 {
+		fmt.Println("> Notes:")
+		for _, note := range notes {
+			fmt.Printf(" - %d %s (deleted %s)\n", note.ID, note.Title, formatDeletedAt(note.DeletedAt))
+		}
+	}
+}
+
+func formatDeletedAt(deletedAt *time.Time) string {
+	if deletedAt == nil {
+		return ""
+	}
+	return deletedAt.Local().Format("Jan 2 2006 15:04")
+}
+
+func restoreTrashArgs(args []string, notebookTitles []string) error {
+	if len(args) == 0 && len(notebookTitles) == 0 {
+		return errors.New("No argument passed, at least one note id or notebook title should be provided")
+	}
+	ids := make([]int64, 0, len(args))
+	for _, argument := range args {
+		id, err := strconv.ParseInt(argument, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Could note transform input to id for argument: %v", argument)
+		}
+		ids = append(ids, id)
+	}
+	return restoreTrash(ids, notebookTitles)
+}
+
+//restoreTrash restores the notes and the notebooks with the given titles from the trash in one transaction,
+//if one of them is not in the trash none is restored.
+func restoreTrash(ids []int64, notebookTitles []string) error {
+	return withTx(func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+		if len(notebookTitles) > 0 {
+			trashed, err := notebookDB.GetTrashedNotebooks()
+			if err != nil {
+				return fmt.Errorf("Error while retrieving notebooks in the trash, error msg: %w", err)
+			}
+			notebookIDs := make([]int64, 0, len(notebookTitles))
+			for _, title := range notebookTitles {
+				notebook := findNotebookByTitle(trashed, title)
+				if notebook == nil {
+					return fmt.Errorf("Could not find notebook with title: %v in the trash, error msg: %w", title, repository.ErrNotebookNotFound)
+				}
+				notebookIDs = append(notebookIDs, notebook.ID)
+			}
+			if err := notebookDB.RestoreNotebooks(notebookIDs); err != nil {
+				return fmt.Errorf("Error while restoring notebooks, error msg: %w", err)
+			}
+		}
+		if len(ids) > 0 {
+			if err := noteDB.RestoreNotes(ids); err != nil {
+				return fmt.Errorf("Error while restoring notes, error msg: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func findNotebookByTitle(notebooks []*model.Notebook, title string) *model.Notebook {
+	for _, notebook := range notebooks {
+		if notebook.Title == title {
+			return notebook
+		}
+	}
+	return nil
+}
+
+//emptyTrash deletes for good the notes and notebooks moved to the trash before deletedBefore,
+//it returns the number of deleted notes and notebooks.
+func emptyTrash(deletedBefore time.Time) (int, int, error) {
+	notes, notebooks := 0, 0
+	err := withTx(func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+		var err error
+		if notes, err = noteDB.PurgeNotes(deletedBefore); err != nil {
+			return fmt.Errorf("Error while emptying trash, error msg: %w", err)
+		}
+		if notebooks, err = notebookDB.PurgeNotebooks(deletedBefore); err != nil {
+			return fmt.Errorf("Error while emptying trash, error msg: %w", err)
+		}
+		return nil
+	})
+	return notes, notebooks, err
+}
+
+//purgeExpiredTrash empties the trash of the items older than the trash_retention setting.
+//Failing to purge should not stop the command, so errors are only logged.
+func purgeExpiredTrash() {
+	retention, err := config.ParseRetention(Config.TrashRetention)
+	if err != nil {
+		log.Printf("Trash was not purged, error msg: %v", err)
+		return
+	}
+	if retention == 0 {
+		return
+	}
+	if _, _, err := emptyTrash(time.Now().Add(-retention)); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"testing"
+	"time"
+)
+
+func TestRestoreTrashArgs(t *testing.T) {
+	trashed := &model.Notebook{ID: 2, Title: "lists"}
+	cases := []struct {
+		args           []string
+		notebookTitles []string
+		noteDB         *mockNoteDBTrash
+		notebookDB     *mockNotebookDBTrash
+		expectedErr    error
+	}{
+		{
+			args:        []string{"1", "2"},
+			noteDB:      &mockNoteDBTrash{},
+			notebookDB:  &mockNotebookDBTrash{},
+			expectedErr: nil,
+		}, {
+			notebookTitles: []string{"lists"},
+			noteDB:         &mockNoteDBTrash{},
+			notebookDB:     &mockNotebookDBTrash{trashed: []*model.Notebook{trashed}},
+			expectedErr:    nil,
+		}, {
+			noteDB:      &mockNoteDBTrash{},
+			notebookDB:  &mockNotebookDBTrash{},
+			expectedErr: errors.New("No argument passed, at least one note id or notebook title should be provided"),
+		}, {
+			args:        []string{"a"},
+			noteDB:      &mockNoteDBTrash{},
+			notebookDB:  &mockNotebookDBTrash{},
+			expectedErr: errors.New("Could note transform input to id for argument: a"),
+		}, {
+			notebookTitles: []string{"expenses"},
+			noteDB:         &mockNoteDBTrash{},
+			notebookDB:     &mockNotebookDBTrash{trashed: []*model.Notebook{trashed}},
+			expectedErr:    errors.New("Could not find notebook with title: expenses in the trash, error msg: " + repository.ErrNotebookNotFound.Error()),
+		}, {
+			args:        []string{"1"},
+			noteDB:      &mockNoteDBTrash{err: errors.New("Unexpected error")},
+			notebookDB:  &mockNotebookDBTrash{},
+			expectedErr: errors.New("Error while restoring notes, error msg: Unexpected error"),
+		},
+	}
+
+	for _, c := range cases {
+		oldNoteDB, oldNotebookDB := NoteDB, NotebookDB
+		NoteDB, NotebookDB = c.noteDB, c.notebookDB
+		defer func() {
+			NoteDB, NotebookDB = oldNoteDB, oldNotebookDB
+		}()
+
+		err := restoreTrashArgs(c.args, c.notebookTitles)
+		if !sameError(c.expectedErr, err) {
+			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
+		}
+		if err == nil && len(c.notebookTitles) > 0 && (len(c.notebookDB.restored) != 1 || c.notebookDB.restored[0] != trashed.ID) {
+			t.Errorf("Expected notebook %v to be restored, got: %v", trashed.ID, c.notebookDB.restored)
+		}
+	}
+}
+
+func TestEmptyTrash(t *testing.T) {
+	oldNoteDB, oldNotebookDB := NoteDB, NotebookDB
+	noteDB, notebookDB := &mockNoteDBTrash{purged: 3}, &mockNotebookDBTrash{purged: 1}
+	NoteDB, NotebookDB = noteDB, notebookDB
+	defer func() {
+		NoteDB, NotebookDB = oldNoteDB, oldNotebookDB
+	}()
+
+	before := time.Now()
+	notes, notebooks, err := emptyTrash(before)
+	if err != nil || notes != 3 || notebooks != 1 {
+		t.Errorf("Expected 3 notes and 1 notebook to be purged, got: %v, %v, error msg: %v", notes, notebooks, err)
+	}
+	if !noteDB.purgedBefore.Equal(before) || !notebookDB.purgedBefore.Equal(before) {
+		t.Errorf("Expected items deleted before %v to be purged", before)
+	}
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	oldNoteDB, oldNotebookDB, oldRetention := NoteDB, NotebookDB, Config.TrashRetention
+	noteDB, notebookDB := &mockNoteDBTrash{}, &mockNotebookDBTrash{}
+	NoteDB, NotebookDB = noteDB, notebookDB
+	defer func() {
+		NoteDB, NotebookDB, Config.TrashRetention = oldNoteDB, oldNotebookDB, oldRetention
+	}()
+
+	Config.TrashRetention = "0"
+	purgeExpiredTrash()
+	if !noteDB.purgedBefore.IsZero() {
+		t.Error("Trash should not be purged with a retention of 0")
+	}
+
+	Config.TrashRetention = "2d"
+	purgeExpiredTrash()
+	expected := time.Now().Add(-48 * time.Hour)
+	if noteDB.purgedBefore.Sub(expected) > time.Minute || expected.Sub(noteDB.purgedBefore) > time.Minute {
+		t.Errorf("Expected notes deleted before %v to be purged, got: %v", expected, noteDB.purgedBefore)
+	}
+}
+
+type mockNoteDBTrash struct {
+	repository.NoteRepository
+	purged       int
+	purgedBefore time.Time
+	err          error
+}
+
+func (mDB *mockNoteDBTrash) RestoreNotes(noteIDs []int64) error {
+	return mDB.err
+}
+
+func (mDB *mockNoteDBTrash) PurgeNotes(deletedBefore time.Time) (int, error) {
+	mDB.purgedBefore = deletedBefore
+	return mDB.purged, mDB.err
+}
+
+type mockNotebookDBTrash struct {
+	repository.NotebookRepository
+	trashed      []*model.Notebook
+	restored     []int64
+	purged       int
+	purgedBefore time.Time
+}
+
+func (mDB *mockNotebookDBTrash) GetTrashedNotebooks() ([]*model.Notebook, error) {
+	return mDB.trashed, nil
+}
+
+func (mDB *mockNotebookDBTrash) RestoreNotebooks(notebooksIDs []int64) error {
+	mDB.restored = notebooksIDs
+	return nil
+}
+
+func (mDB *mockNotebookDBTrash) PurgeNotebooks(deletedBefore time.Time) (int, error) {
+	mDB.purgedBefore = deletedBefore
+	return mDB.purged, nil
+}
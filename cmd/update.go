@@ -3,8 +3,9 @@ package cmd
 import (
 	"fmt"
 	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
 	"github.com/spf13/cobra"
-	"log"
+	"os"
 	"strconv"
 	"strings"
 )
@@ -14,37 +15,53 @@ var updateCmd = &cobra.Command{
 	Short: "Update existing note",
 	Long: "Select a note to update by providing a valid id (required). \n" +
 		"A tag can be removed by providing a '-' before the tag name, eg: \n" +
-		"--tags tag1,-tag2 will insert tag1 and remove (if exist) tag2 to the note",
-	Example: "update id -t title_1 --tags tag1,-tag2 -n notebook_1",
-	Args:    cobra.ExactArgs(1),
-	Run:     updateWrapper,
+		"--tags tag1,-tag2 will insert tag1 and remove (if exist) tag2 to the note\n" +
+		"Links by title of other notes to a renamed note match no note anymore, unless --rewrite-links is set\n" +
+		"The memo is edited in the editor, or replaced by a memo piped to tefter. Without a terminal or a piped memo,\n" +
+		"eg: in cron jobs, the memo is kept, use --stdin to always read the memo from stdin",
+	Example: "update id -t title_1 --tags tag1,-tag2 -n notebook_1\n " +
+		"echo 'new memo' | tefter update id",
+	Args: cobra.ExactArgs(1),
+	Run:  updateWrapper,
 }
 
 func init() {
 	rootCmd.AddCommand(updateCmd)
 	updateCmd.Flags().StringP("title", "t", "", "Notes title.")
 	updateCmd.Flags().StringSlice("tags", []string{}, "Comma-separated tags of note.")
-	updateCmd.Flags().StringP("notebook", "n", "", "Notebook that this note belongs to")
+	updateCmd.Flags().StringP("notebook", "n", "", "Path of the notebook that this note belongs to, eg: work/infra")
+	updateCmd.Flags().BoolP("rewrite-links", "r", false, "Rewrite the [[title]] links of other notes to the new title")
+	updateCmd.Flags().Bool("stdin", false, "Read the memo from stdin")
 }
 
 func updateWrapper(cmd *cobra.Command, args []string) {
 	title, _ := cmd.Flags().GetString("title")
 	tags, _ := cmd.Flags().GetStringSlice("tags")
 	notebookTitle, _ := cmd.Flags().GetString("notebook")
+	rewriteLinks, _ := cmd.Flags().GetBool("rewrite-links")
+	readStdin, _ := cmd.Flags().GetBool("stdin")
 	id, err := strconv.ParseInt(args[0], 10, 64)
 	if err != nil {
-		log.Panicf("ID could not be converted to integer")
+		exitWithError(fmt.Errorf("ID could not be converted to integer, error msg: %w", err))
+	}
+	editor, err := newUpdateEditor(os.Stdin, readStdin)
+	if err != nil {
+		exitWithError(err)
+	}
+	if err := update(id, title, tags, notebookTitle, rewriteLinks, editor); err != nil {
+		exitWithError(err)
 	}
-	editor := &viEditor{}
-	update(id, title, tags, notebookTitle, editor)
 }
 
-func update(id int64, title string, tags []string, notebookTitle string, editor Editor) error {
+func update(id int64, title string, tags []string, notebookTitle string, rewriteLinks bool, editor Editor) error {
 	note, err := NoteDB.GetNote(id)
 	if err != nil {
-		return fmt.Errorf("Error while retrieving Note from DB, error msg: %v", err)
+		return fmt.Errorf("Error while retrieving Note from DB, error msg: %w", err)
+	}
+	memo, err := editor.edit(note.Memo)
+	if err != nil {
+		return err
 	}
-	memo := editor.edit(note.Memo)
 	jNote := &jsonNote{
 		ID:            id,
 		Title:         title,
@@ -52,21 +69,53 @@ func update(id int64, title string, tags []string, notebookTitle string, editor
 		Tags:          tags,
 		NotebookTitle: notebookTitle,
 	}
-	return updateJSONNote(jNote)
+	return updateJSONNote(jNote, rewriteLinks)
 }
 
-func updateJSONNote(jNote *jsonNote) error {
-	note, err := NoteDB.GetNote(jNote.ID)
-	if err != nil {
-		return fmt.Errorf("Error while retrieving Note from DB, error msg: %v", err)
-	}
-	err = constructUpdatedNote(note, jNote.Title, jNote.NotebookTitle, jNote.Tags, jNote.Memo)
-	if err != nil {
-		return fmt.Errorf("Error while constructing updated note, error msg: %v", err)
-	}
-	err = NoteDB.UpdateNote(note)
-	if err != nil {
-		return fmt.Errorf("Error while updating note, error msg: %v", err)
+//updateJSONNote updates the note, if rewriteLinks is set and the note is renamed the links by title
+//of other notes to the old title are rewritten to the new title.
+func updateJSONNote(jNote *jsonNote, rewriteLinks bool) error {
+	return withTx(func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+		note, err := noteDB.GetNote(jNote.ID)
+		if err != nil {
+			return fmt.Errorf("Error while retrieving Note from DB, error msg: %w", err)
+		}
+		oldTitle := note.Title
+		backlinks := []*model.Note{}
+		//once the note is renamed the links to its old title match no note
+		if rewriteLinks && jNote.Title != "" && jNote.Title != oldTitle {
+			if backlinks, err = noteDB.GetBacklinks(note.ID); err != nil {
+				return fmt.Errorf("Error while retrieving backlinks, error msg: %w", err)
+			}
+		}
+		err = constructUpdatedNote(notebookDB, note, jNote.Title, jNote.NotebookTitle, jNote.Tags, jNote.Memo)
+		if err != nil {
+			return fmt.Errorf("Error while constructing updated note, error msg: %w", err)
+		}
+		for _, backlink := range backlinks {
+			if backlink.ID == note.ID {
+				note.UpdateMemo(model.RewriteLinks(note.Memo, oldTitle, note.Title))
+			}
+		}
+		err = noteDB.UpdateNote(note)
+		if err != nil {
+			return fmt.Errorf("Error while updating note, error msg: %w", err)
+		}
+		return rewriteBacklinks(noteDB, backlinks, oldTitle, note)
+	})
+}
+
+//rewriteBacklinks points the links of backlinks from oldTitle to the title of note, the note itself is skipped.
+func rewriteBacklinks(noteDB repository.NoteRepository, backlinks []*model.Note, oldTitle string, note *model.Note) error {
+	for _, backlink := range backlinks {
+		memo := model.RewriteLinks(backlink.Memo, oldTitle, note.Title)
+		if backlink.ID == note.ID || memo == backlink.Memo {
+			continue
+		}
+		backlink.UpdateMemo(memo)
+		if err := noteDB.UpdateNote(backlink); err != nil {
+			return fmt.Errorf("Error while rewriting links of note: %v, error msg: %w", backlink.ID, err)
+		}
 	}
 	return nil
 }
@@ -75,7 +124,7 @@ func updateJSONNote(jNote *jsonNote) error {
 	If there is no removal of tag, all tags will be replaced by the provided ones,
 	in case we want only to remove specific tags, we need to pass the tags names with a "-" in front.
 */
-func constructUpdatedNote(note *model.Note, title, notebookTitle string, tags []string, memo string) error {
+func constructUpdatedNote(notebookDB repository.NotebookRepository, note *model.Note, title, notebookTitle string, tags []string, memo string) error {
 	if title != "" {
 		note.UpdateTitle(title)
 	}
@@ -97,7 +146,7 @@ func constructUpdatedNote(note *model.Note, title, notebookTitle string, tags []
 	}
 	note.AddTags(toBeAdded)
 	if notebookTitle != "" {
-		err := addNotebookToNote(note, notebookTitle)
+		err := addNotebookToNote(notebookDB, note, notebookTitle)
 		if err != nil {
 			return err
 		}
@@ -3,15 +3,17 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"github.com/nicolasmanic/tefter/repository"
 	"github.com/spf13/cobra"
-	"log"
+	"strings"
 )
 
 var updateNotebookCmd = &cobra.Command{
-	Use:     "updateNotebook",
-	Short:   "Set new title to an existing notebook",
-	Long:    "Update requires 2 arguments first the old notebook title, and the new title",
-	Example: "update 'Old Notebook Title' 'New Notebook Title'",
+	Use:   "updateNotebook",
+	Short: "Set new title to an existing notebook or move it under another notebook",
+	Long: "Update requires 2 arguments first the old notebook path, and the new path,\n" +
+		"notebooks missing from the new path are created and child notebooks move along.",
+	Example: "updateNotebook 'Old Notebook Title' 'New Notebook Title'\nupdateNotebook infra work/infra",
 	Args:    cobra.ExactArgs(2),
 	Run:     updateNotebookWrapper,
 }
@@ -22,25 +24,37 @@ func init() {
 
 func updateNotebookWrapper(cmd *cobra.Command, args []string) {
 	if err := updateNotebook(args[0], args[1]); err != nil {
-		log.Fatalln(err)
+		exitWithError(err)
 	}
 }
 
+//updateNotebook renames the notebook at oldTitle and moves it under the parent of the newTitle path.
 func updateNotebook(oldTitle, newTitle string) error {
-	if newTitle == "" {
+	titles := repository.SplitNotebookPath(newTitle)
+	if len(titles) == 0 {
 		return errors.New("Notebook title should not be empty")
 	}
-	notebook, err := NotebookDB.GetNotebookByTitle(oldTitle)
-	if err != nil {
-		return fmt.Errorf("Error while retrieving notebook by title, error msg: %v", err)
-	} else if notebook != nil {
-		notebook.Title = newTitle
-		err = NotebookDB.UpdateNotebook(notebook)
+	return withTx(func(noteDB repository.NoteRepository, notebookDB repository.NotebookRepository) error {
+		notebook, err := notebookDB.GetNotebookByTitle(oldTitle)
 		if err != nil {
-			return fmt.Errorf("Error while updating notebook, error msg: %v", err)
+			return fmt.Errorf("Error while retrieving notebook by title, error msg: %w", err)
+		} else if notebook != nil {
+			var parentID int64
+			if len(titles) > 1 {
+				parentPath := strings.Join(titles[:len(titles)-1], repository.NotebookPathSeparator)
+				if parentID, err = createNotebookPath(notebookDB, parentPath); err != nil {
+					return fmt.Errorf("Error while creating notebook: %v, error msg: %w", parentPath, err)
+				}
+			}
+			notebook.Title = titles[len(titles)-1]
+			notebook.ParentID = parentID
+			err = notebookDB.UpdateNotebook(notebook)
+			if err != nil {
+				return fmt.Errorf("Error while updating notebook, error msg: %w", err)
+			}
+		} else {
+			return fmt.Errorf("No notebook with title: %v, error msg: %w", oldTitle, repository.ErrNotebookNotFound)
 		}
-	} else {
-		return fmt.Errorf("No notebook with title: %v", oldTitle)
-	}
-	return nil
+		return nil
+	})
 }
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"github.com/nicolasmanic/tefter/model"
 	"github.com/nicolasmanic/tefter/repository"
-	"reflect"
 	"testing"
 )
 
@@ -34,7 +33,7 @@ func TestUpdateNotebook(t *testing.T) {
 				err:      nil,
 			},
 			newTitle:    "newNotebookTitle",
-			expectedErr: errors.New("No notebook with title: oldTitle"),
+			expectedErr: errors.New("No notebook with title: oldTitle, error msg: notebook not found"),
 		}, {
 			mDB: mockNotebookDBUpdateNotebook{
 				notebook: nil,
@@ -53,7 +52,7 @@ func TestUpdateNotebook(t *testing.T) {
 			NotebookDB = oldNotebookDB
 		}()
 		err := updateNotebook("oldTitle", c.newTitle)
-		if !reflect.DeepEqual(c.expectedErr, err) {
+		if !sameError(c.expectedErr, err) {
 			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
 		}
 	}
@@ -72,3 +71,27 @@ func (mDB mockNotebookDBUpdateNotebook) UpdateNotebook(notebook *model.Notebook)
 func (mDB mockNotebookDBUpdateNotebook) GetNotebookByTitle(notebookTitle string) (*model.Notebook, error) {
 	return mDB.notebook, mDB.err
 }
+
+func TestUpdateNotebookMove(t *testing.T) {
+	oldStore, oldNoteDB, oldNotebookDB := Store, NoteDB, NotebookDB
+	Store = repository.NewMemoryStore()
+	NoteDB, NotebookDB = Store.Notes(), Store.Notebooks()
+	defer func() {
+		Store, NoteDB, NotebookDB = oldStore, oldNoteDB, oldNotebookDB
+	}()
+	if _, err := createNotebookPath(NotebookDB, "infra/k8s"); err != nil {
+		t.Fatalf("Could not create notebooks, error msg: %v", err)
+	}
+
+	if err := updateNotebook("infra", "work/servers"); err != nil {
+		t.Fatalf("Could not move notebook, error msg: %v", err)
+	}
+	for _, path := range []string{"work", "work/servers", "work/servers/k8s"} {
+		if notebook, _ := NotebookDB.GetNotebookByTitle(path); notebook == nil {
+			t.Errorf("Expected notebook %v to exist", path)
+		}
+	}
+	if err := updateNotebook("work", "work/servers/work"); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected validation error when moving a notebook under itself, got: %v", err)
+	}
+}
@@ -4,7 +4,8 @@ import (
 	"errors"
 	"github.com/nicolasmanic/tefter/model"
 	"github.com/nicolasmanic/tefter/repository"
-	"reflect"
+	"io/ioutil"
+	"os"
 	"testing"
 )
 
@@ -74,13 +75,43 @@ func TestUpdate(t *testing.T) {
 			NoteDB = oldNoteDB
 		}()
 
-		err := update(c.id, c.noteTitle, c.tags, c.notebookTitle, c.editor)
-		if !reflect.DeepEqual(c.expectedErr, err) {
+		err := update(c.id, c.noteTitle, c.tags, c.notebookTitle, false, c.editor)
+		if !sameError(c.expectedErr, err) {
 			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
 		}
 	}
 }
 
+func TestUpdateEmptyStdin(t *testing.T) {
+	defer useMemoryStore(t, model.NewNote("title", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{}))()
+	stdin, err := ioutil.TempFile("", "stdin")
+	if err != nil {
+		t.Fatalf("Could not create file, error msg: %v", err)
+	}
+	defer os.Remove(stdin.Name())
+	defer stdin.Close()
+	devNull, _ := os.Open(os.DevNull)
+	defer devNull.Close()
+
+	for _, input := range []*os.File{stdin, devNull} {
+		editor, err := newUpdateEditor(input, false)
+		if err != nil {
+			t.Fatalf("Could not create editor, error msg: %v", err)
+		}
+		if err := update(1, "renamed", []string{}, "", false, editor); err != nil {
+			t.Errorf("Could not update note with empty stdin, error msg: %v", err)
+		}
+		if note, _ := NoteDB.GetNote(1); note.Memo != "memo" || note.Title != "renamed" {
+			t.Errorf("Expected memo to be kept with empty stdin, got: %+v", note)
+		}
+	}
+
+	editor, _ := newUpdateEditor(stdin, true)
+	if err := update(1, "", []string{}, "", false, editor); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected empty memo read with --stdin to be invalid, got: %v", err)
+	}
+}
+
 func TestUpdateJSONNote(t *testing.T) {
 	oldNoteDB := NoteDB
 	NoteDB = mockNoteDBUpdate{
@@ -97,9 +128,9 @@ func TestUpdateJSONNote(t *testing.T) {
 		Tags:          []string{"tag1", "tag2"},
 		NotebookTitle: "notebook",
 	}
-	err := updateJSONNote(jNote)
+	err := updateJSONNote(jNote, false)
 	expectedErr := errors.New("Error while retrieving Note from DB, error msg: Unexpected error")
-	if !reflect.DeepEqual(expectedErr, err) {
+	if !sameError(expectedErr, err) {
 		t.Errorf("Expected err to be %q but it was %q", expectedErr, err)
 	}
 }
@@ -112,7 +143,7 @@ func TestConstructUpdatedNote(t *testing.T) {
 		NotebookDB = oldNotebookDB
 	}()
 	note := model.NewNote("testTitle4", "testMemo", repository.DEFAULT_NOTEBOOK_ID, []string{"tag1", "tag2"})
-	constructUpdatedNote(note, "", "", []string{"tag3", "-tag1"}, "NewMemo")
+	constructUpdatedNote(NotebookDB, note, "", "", []string{"tag3", "-tag1"}, "NewMemo")
 
 	if len(note.Tags) != 2 {
 		t.Error("Failed adding/removing tags")
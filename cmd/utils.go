@@ -1,59 +1,15 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"github.com/spf13/cobra"
 	"io"
-	"io/ioutil"
-	"log"
-	"os"
-	"os/exec"
 	"strings"
 )
 
-//Editor interface
-type Editor interface {
-	edit(text string) string
-}
-type viEditor struct{}
-
-func (vie viEditor) edit(text string) string {
-	vi := "vim"
-	fpath := os.TempDir() + "/tmpMemo.txt"
-	f, err := os.Create(fpath)
-	defer os.Remove(fpath)
-	if err != nil {
-		log.Fatalf("Could not create tmp file for memo, error msg %v", err)
-	}
-	_, err = io.Copy(f, strings.NewReader(text))
-	if err != nil {
-		fmt.Printf("Failed copying memo to tmp file, error msg: %v\n", err)
-	}
-	f.Close()
-	path, err := exec.LookPath(vi)
-	if err != nil {
-		log.Fatalf("Could not open VI, error msg %v", err)
-	}
-
-	cmd := exec.Command(path, fpath)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	err = cmd.Start()
-	if err != nil {
-		log.Fatalf("Could not start VI, error msg %v", err)
-	}
-	err = cmd.Wait()
-	if err != nil {
-		log.Fatalf("Error while using editing memo, error msg %v", err)
-	}
-
-	memo, err := ioutil.ReadFile(fpath)
-	if err != nil {
-		log.Fatalf("Could not read tmp file, error msg %v", err)
-	}
-	return string(memo)
-}
-
 func int64Slice(input []int) []int64 {
 	var result = make([]int64, 0, len(input))
 	for _, tmp := range input {
@@ -66,53 +22,99 @@ func int2int64(input int) int64 {
 	return int64(input)
 }
 
-func collectNotesFromDB(ids []int, notebookTitles, tags []string, getAll bool) (map[int64]*model.Note, error) {
-	var notesMap = make(map[int64]*model.Note, 0)
+//collectNotesFromDB returns the page of notes with any of the ids, notebook titles or tags, or of all notes if getAll is set.
+//If queryText is set only the notes matching the query are returned, see repository.ParseQuery.
+//The next cursor of the page is returned along with the notes.
+func collectNotesFromDB(ids []int, notebookTitles, tags []string, getAll bool, queryText string, query repository.NoteQuery) ([]*model.Note, string, error) {
+	expr, err := repository.ParseQuery(queryText)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error while parsing query, error msg: %w", err)
+	}
 	if getAll {
 		//Get all notes in the DB
-		allNotes, err := NoteDB.GetNotes([]int64{})
+		page, err := NoteDB.ListNotes(repository.NoteFilter{Query: expr}, query)
 		if err != nil {
-			return nil, fmt.Errorf("Error while retrieving all notes, error msg: %v", err)
-		}
-		for _, note := range allNotes {
-			notesMap[note.ID] = note
+			return nil, "", fmt.Errorf("Error while retrieving all notes, error msg: %w", err)
 		}
-		return notesMap, nil
+		return page.Notes, page.Next, nil
 	}
-	if len(ids) > 0 {
-		//Add notes based on ids
-		idNotes, err := NoteDB.GetNotes(int64Slice(ids))
+
+	filter := repository.NoteFilter{IDs: int64Slice(ids), Tags: tags, Query: expr}
+	for _, notebookTitle := range notebookTitles {
+		notebook, err := NotebookDB.GetNotebookByTitle(notebookTitle)
 		if err != nil {
-			return nil, fmt.Errorf("Error while retrieving notes by id, error msg: %v", err)
+			return nil, "", fmt.Errorf("Error while retrieving notebook by title, error msg: %w", err)
+		} else if notebook != nil {
+			filter.NotebookIDs = append(filter.NotebookIDs, notebook.ID)
 		}
-		for _, note := range idNotes {
-			notesMap[note.ID] = note
+	}
+	if len(filter.NotebookIDs) > 0 {
+		//notes of the child notebooks belong to the requested notebooks too
+		paths, err := NotebookDB.GetAllNotebooksTitle()
+		if err != nil {
+			return nil, "", fmt.Errorf("Error while retrieving notebook paths, error msg: %w", err)
 		}
+		filter.NotebookIDs = append(filter.NotebookIDs, descendantsByPath(paths, filter.NotebookIDs)...)
+	}
+	//an empty filter matches all notes
+	if len(filter.IDs) == 0 && len(filter.NotebookIDs) == 0 && len(filter.Tags) == 0 && filter.Query == nil {
+		return []*model.Note{}, "", nil
+	}
+	page, err := NoteDB.ListNotes(filter, query)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error while retrieving notes, error msg: %w", err)
 	}
-	if len(notebookTitles) > 0 {
-		//Get notes based on notebook titles
-		for _, notebookTitle := range notebookTitles {
-			notebook, err := NotebookDB.GetNotebookByTitle(notebookTitle)
-			if err != nil {
-				return nil, fmt.Errorf("Error while retrieving notebook by title, error msg: %v", err)
-			} else if notebook != nil {
-				for _, note := range notebook.Notes {
-					notesMap[note.ID] = note
-				}
+	return page.Notes, page.Next, nil
+}
+
+//descendantsByPath returns the ids of the notebooks under the given ones based on their paths.
+func descendantsByPath(paths map[int64]string, notebookIDs []int64) []int64 {
+	ids := []int64{}
+	for id, path := range paths {
+		for _, notebookID := range notebookIDs {
+			if id != notebookID && strings.HasPrefix(path, paths[notebookID]+repository.NotebookPathSeparator) {
+				ids = append(ids, id)
+				break
 			}
 		}
 	}
-	if len(tags) > 0 {
-		//Get notes based on tags
-		tagNotes, err := NoteDB.GetNotesByTag(tags)
-		if err != nil {
-			return nil, fmt.Errorf("Error while retrieving notes by tag, error msg: %v", err)
-		}
-		for _, note := range tagNotes {
-			notesMap[note.ID] = note
-		}
+	return ids
+}
+
+//addNoteQueryFlags adds the paging and sorting flags of note listings to cmd.
+func addNoteQueryFlags(cmd *cobra.Command, defaultSort repository.NoteSort) {
+	cmd.Flags().Int("limit", 0, "Max number of notes, 0 for no limit")
+	cmd.Flags().Int("page", 1, "Page of notes to return, pages have --limit notes")
+	sortUsage := "Sort notes by created, updated or title"
+	if defaultSort == repository.SortByRank {
+		sortUsage = "Sort notes by rank (relevance to the text of the query), created, updated or title"
+	}
+	cmd.Flags().String("sort", string(defaultSort), sortUsage)
+	cmd.Flags().String("order", string(repository.Descending), "Sort order asc or desc")
+}
+
+//noteQueryFromFlags returns the note query of the flags added by addNoteQueryFlags.
+func noteQueryFromFlags(cmd *cobra.Command) (repository.NoteQuery, error) {
+	limit, _ := cmd.Flags().GetInt("limit")
+	page, _ := cmd.Flags().GetInt("page")
+	sort, _ := cmd.Flags().GetString("sort")
+	order, _ := cmd.Flags().GetString("order")
+	return newNoteQuery(limit, page, sort, order)
+}
+
+func newNoteQuery(limit, page int, sort, order string) (repository.NoteQuery, error) {
+	if page < 1 {
+		return repository.NoteQuery{}, &repository.Error{Kind: repository.ErrValidation, Msg: "Page should be greater than 0"}
+	}
+	if page > 1 && limit <= 0 {
+		return repository.NoteQuery{}, &repository.Error{Kind: repository.ErrValidation, Msg: "Page should be used together with limit"}
 	}
-	return notesMap, nil
+	return repository.NoteQuery{
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+		Sort:   repository.NoteSort(sort),
+		Order:  repository.SortOrder(order),
+	}, nil
 }
 
 func noteMap2Slice(m map[int64]*model.Note) []*model.Note {
@@ -131,11 +133,29 @@ func tagMap2Slice(m map[string]bool) []string {
 	return tags
 }
 
+//tagSlice2Map returns the normalized tags as a set, see model.NormalizeTag.
+func tagSlice2Map(tags []string) map[string]bool {
+	m := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if tag = model.NormalizeTag(tag); tag != "" {
+			m[tag] = true
+		}
+	}
+	return m
+}
+
+//confirm prints the prompt and returns true if the line read from input is yes.
+func confirm(input io.Reader, prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := bufio.NewReader(input).ReadString('\n')
+	return strings.EqualFold(strings.TrimSpace(answer), "yes")
+}
+
 func transformNotes2JSONNotes(notes []*model.Note) ([]*jsonNote, error) {
 	var jNotes []*jsonNote
 	notebookTitlesMap, err := NotebookDB.GetAllNotebooksTitle()
 	if err != nil {
-		return nil, fmt.Errorf("Error while retrieving Notebooks titles, error msg: %v", err)
+		return nil, fmt.Errorf("Error while retrieving Notebooks titles, error msg: %w", err)
 	}
 
 	for _, note := range notes {
@@ -152,3 +172,21 @@ func transformNotes2JSONNotes(notes []*model.Note) ([]*jsonNote, error) {
 	}
 	return jNotes, nil
 }
+
+//transformSearchHits2JSONNotes returns the notes of the hits with their rank, highlighted title and snippet.
+func transformSearchHits2JSONNotes(hits []*repository.SearchHit) ([]*jsonNote, error) {
+	notes := make([]*model.Note, 0, len(hits))
+	for _, hit := range hits {
+		notes = append(notes, hit.Note)
+	}
+	jNotes, err := transformNotes2JSONNotes(notes)
+	if err != nil {
+		return nil, err
+	}
+	for i, hit := range hits {
+		jNotes[i].Rank = hit.Rank
+		jNotes[i].HighlightedTitle = hit.Title
+		jNotes[i].Snippet = hit.Snippet
+	}
+	return jNotes, nil
+}
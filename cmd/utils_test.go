@@ -131,7 +131,7 @@ func TestCollectNotesFromDB(t *testing.T) {
 			notebookTitles: []string{},
 			tags:           []string{},
 			getAll:         false,
-			expectedErr:    errors.New("Error while retrieving notes by id, error msg: Unexpected error"),
+			expectedErr:    errors.New("Error while retrieving notes, error msg: Unexpected error"),
 		}, {
 			noteDB: mockNoteDBUtils{},
 			notebookDB: mockNotebookDBUtils{
@@ -153,7 +153,7 @@ func TestCollectNotesFromDB(t *testing.T) {
 			notebookTitles: []string{},
 			tags:           []string{"tags"},
 			getAll:         false,
-			expectedErr:    errors.New("Error while retrieving notes by tag, error msg: Unexpected error"),
+			expectedErr:    errors.New("Error while retrieving notes, error msg: Unexpected error"),
 		},
 	}
 
@@ -168,14 +168,57 @@ func TestCollectNotesFromDB(t *testing.T) {
 			NoteDB = oldNoteDB
 		}()
 
-		_, err := collectNotesFromDB(c.ids, c.notebookTitles, c.tags, c.getAll)
-		if !reflect.DeepEqual(err, c.expectedErr) {
+		_, _, err := collectNotesFromDB(c.ids, c.notebookTitles, c.tags, c.getAll, "", repository.NoteQuery{})
+		if !sameError(c.expectedErr, err) {
 			t.Errorf("Expected err to be %q but it was %q", c.expectedErr, err)
 		}
 	}
 
 }
 
+func TestCollectNotesFromDBQuery(t *testing.T) {
+	oldNotebookDB := NotebookDB
+	oldNoteDB := NoteDB
+	defer func() {
+		NotebookDB = oldNotebookDB
+		NoteDB = oldNoteDB
+	}()
+	NoteDB, NotebookDB, _ = repository.NewMemoryRepositories()
+	goNote := model.NewNote("go", "learn go", repository.DEFAULT_NOTEBOOK_ID, []string{"go", "todo"})
+	doneNote := model.NewNote("done", "learned go", repository.DEFAULT_NOTEBOOK_ID, []string{"go", "done"})
+	NoteDB.SaveNote(goNote)
+	NoteDB.SaveNote(doneNote)
+
+	cases := []struct {
+		tags      []string
+		getAll    bool
+		queryText string
+		expected  []int64
+	}{
+		{nil, false, "-tag:done", []int64{goNote.ID}},
+		{nil, true, "tag:go", []int64{doneNote.ID, goNote.ID}},
+		{[]string{"todo"}, false, "tag:done", []int64{}},
+		{nil, false, "", []int64{}},
+	}
+	for _, c := range cases {
+		notes, _, err := collectNotesFromDB(nil, nil, c.tags, c.getAll, c.queryText, repository.NoteQuery{})
+		if err != nil {
+			t.Fatalf("Could not collect notes of query: %v, error msg: %v", c.queryText, err)
+		}
+		ids := []int64{}
+		for _, note := range notes {
+			ids = append(ids, note.ID)
+		}
+		if !reflect.DeepEqual(ids, c.expected) {
+			t.Errorf("Expected query: %v to collect notes: %v, got: %v", c.queryText, c.expected, ids)
+		}
+	}
+
+	if _, _, err := collectNotesFromDB(nil, nil, nil, true, "tag:go OR", repository.NoteQuery{}); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected validation error for invalid query, got: %v", err)
+	}
+}
+
 type mockNotebookDBUtils struct {
 	repository.NotebookRepository
 	notebook *model.Notebook
@@ -188,14 +231,64 @@ type mockNoteDBUtils struct {
 	err   error
 }
 
-func (mDB mockNoteDBUtils) GetNotesByTag(tags []string) ([]*model.Note, error) {
-	return mDB.notes, mDB.err
-}
-
-func (mDB mockNoteDBUtils) GetNotes(noteIDs []int64) ([]*model.Note, error) {
-	return mDB.notes, mDB.err
+func (mDB mockNoteDBUtils) ListNotes(filter repository.NoteFilter, query repository.NoteQuery) (*repository.NotePage, error) {
+	return &repository.NotePage{Notes: mDB.notes}, mDB.err
 }
 
 func (mDB mockNotebookDBUtils) GetNotebookByTitle(notebooksTitle string) (*model.Notebook, error) {
 	return mDB.notebook, mDB.err
 }
+
+func (mDB mockNotebookDBUtils) GetAllNotebooksTitle() (map[int64]string, error) {
+	return map[int64]string{}, mDB.err
+}
+
+func TestDescendantsByPath(t *testing.T) {
+	paths := map[int64]string{1: "work", 2: "work/infra", 3: "work/infra/k8s", 4: "workshop", 5: "home"}
+	ids := descendantsByPath(paths, []int64{2, 5})
+	if len(ids) != 1 || ids[0] != 3 {
+		t.Errorf("Expected only notebook 3 under work/infra and home, got: %v", ids)
+	}
+	ids = descendantsByPath(paths, []int64{1})
+	if len(ids) != 2 || ids[0]+ids[1] != 5 {
+		t.Errorf("Expected notebooks 2 and 3 under work, got: %v", ids)
+	}
+}
+
+//sameError compares errors by message since errors wrapped with %w are not DeepEqual to errors.New
+//useMemoryStore swaps the DBs with a memory store holding the notes and returns a function restoring them.
+func useMemoryStore(t *testing.T, notes ...*model.Note) func() {
+	t.Helper()
+	oldStore, oldNoteDB, oldNotebookDB := Store, NoteDB, NotebookDB
+	Store = repository.NewMemoryStore()
+	NoteDB, NotebookDB = Store.Notes(), Store.Notebooks()
+	for _, note := range notes {
+		if _, err := NoteDB.SaveNote(note); err != nil {
+			t.Fatalf("Could not save note, error msg: %v", err)
+		}
+	}
+	return func() {
+		Store, NoteDB, NotebookDB = oldStore, oldNoteDB, oldNotebookDB
+	}
+}
+
+func sameError(expected, actual error) bool {
+	if expected == nil || actual == nil {
+		return expected == actual
+	}
+	return expected.Error() == actual.Error()
+}
+
+func TestNewNoteQuery(t *testing.T) {
+	query, err := newNoteQuery(10, 3, "title", "asc")
+	expected := repository.NoteQuery{Limit: 10, Offset: 20, Sort: repository.SortByTitle, Order: repository.Ascending}
+	if err != nil || query != expected {
+		t.Errorf("Expected query %+v, got %+v with error %v", expected, query, err)
+	}
+	if _, err := newNoteQuery(10, 0, "", ""); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Page 0 should be a validation error, got %v", err)
+	}
+	if _, err := newNoteQuery(0, 2, "", ""); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Page without limit should be a validation error, got %v", err)
+	}
+}
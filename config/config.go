@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//DBEnv is the environment variable that overrides the DB location of the config file.
+const DBEnv = "TEFTER_DB"
+
+//LegacyDB is the DB location of tefter versions before the XDG data dir, relative to the working directory.
+const LegacyDB = "tefter.db"
+
+//Config holds the user defined settings of tefter, it is persisted as yaml under the XDG config dir.
+//If DSN is set notes are stored to that postgres DB instead of the sqlite DB file.
+//TrashRetention is how long deleted notes and notebooks are kept in the trash, see ParseRetention.
+type Config struct {
+	DB              string `yaml:"db"`
+	DSN             string `yaml:"dsn"`
+	Editor          string `yaml:"editor"`
+	EditorExtension string `yaml:"editor_extension"`
+	Port            string `yaml:"port"`
+	DefaultNotebook string `yaml:"default_notebook"`
+	TrashRetention  string `yaml:"trash_retention"`
+}
+
+//Default returns a Config with the values used when no config file exists.
+func Default() *Config {
+	return &Config{
+		DB:              filepath.Join(dataDir(), "tefter.db"),
+		EditorExtension: ".txt",
+		Port:            "8080",
+		TrashRetention:  "30d",
+	}
+}
+
+//Path returns the location of the config file, $XDG_CONFIG_HOME/tefter/config.yaml
+//or ~/.config/tefter/config.yaml if XDG_CONFIG_HOME is not set.
+func Path() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(homeDir(), ".config")
+	}
+	return filepath.Join(dir, "tefter", "config.yaml")
+}
+
+//Load reads the config file at path, missing values are set to their default.
+//If the file does not exist the default config is returned.
+func Load(path string) (*Config, error) {
+	conf := Default()
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return conf, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Could not read config file at path: %v, error msg: %v", path, err)
+	}
+	if err := yaml.Unmarshal(raw, conf); err != nil {
+		return nil, fmt.Errorf("Could not unmarshal config file at path: %v, error msg: %v", path, err)
+	}
+	return conf, nil
+}
+
+//Save writes the config to path, creating any missing directory.
+func (conf *Config) Save(path string) error {
+	raw, err := yaml.Marshal(conf)
+	if err != nil {
+		return fmt.Errorf("Error while marshalling config, error msg: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("Could not create config dir, error msg: %v", err)
+	}
+	return ioutil.WriteFile(path, raw, 0644)
+}
+
+//ResolveDB returns the DB location, in order of precedence: the given flag value,
+//the TEFTER_DB environment variable and finally the config value, see UsesLegacyDB.
+func (conf *Config) ResolveDB(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if env := os.Getenv(DBEnv); env != "" {
+		return env
+	}
+	if conf.UsesLegacyDB(flagValue) {
+		return LegacyDB
+	}
+	return conf.DB
+}
+
+//UsesLegacyDB returns true if the DB resolves to LegacyDB of older tefter versions. It does when neither the flag,
+//the environment variable nor the config file set the DB, the default DB does not exist yet and LegacyDB does,
+//so that upgrading tefter keeps the notes of the working directory.
+func (conf *Config) UsesLegacyDB(flagValue string) bool {
+	if flagValue != "" || os.Getenv(DBEnv) != "" || conf.DB != Default().DB {
+		return false
+	}
+	if _, err := os.Stat(conf.DB); !os.IsNotExist(err) {
+		return false
+	}
+	info, err := os.Stat(LegacyDB)
+	return err == nil && info.Mode().IsRegular()
+}
+
+//ParseRetention parses a retention period given in days, eg: 30d, or as a go duration, eg: 36h.
+//A period of 0 or an empty one means that nothing expires.
+func ParseRetention(retention string) (time.Duration, error) {
+	switch retention {
+	case "", "0":
+		return 0, nil
+	}
+	if strings.HasSuffix(retention, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(retention, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("Invalid retention: %v, expected days (eg: 30d) or a duration (eg: 36h)", retention)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	duration, err := time.ParseDuration(retention)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("Invalid retention: %v, expected days (eg: 30d) or a duration (eg: 36h)", retention)
+	}
+	return duration, nil
+}
+
+//Keys returns the available config keys sorted alphabetically.
+func Keys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		keys = append(keys, t.Field(i).Tag.Get("yaml"))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//Get returns the value of a config key.
+func (conf *Config) Get(key string) (string, error) {
+	field, err := conf.field(key)
+	if err != nil {
+		return "", err
+	}
+	return field.String(), nil
+}
+
+//Set updates the value of a config key.
+func (conf *Config) Set(key, value string) error {
+	field, err := conf.field(key)
+	if err != nil {
+		return err
+	}
+	field.SetString(value)
+	return nil
+}
+
+func (conf *Config) field(key string) (reflect.Value, error) {
+	v := reflect.ValueOf(conf).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("yaml") == key {
+			return v.Field(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("Unknown config key: %v", key)
+}
+
+//dataDir returns $XDG_DATA_HOME/tefter or ~/.local/share/tefter if XDG_DATA_HOME is not set.
+func dataDir() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		dir = filepath.Join(homeDir(), ".local", "share")
+	}
+	return filepath.Join(dir, "tefter")
+}
+
+func homeDir() string {
+	if home := os.Getenv("HOME"); home != "" {
+		return home
+	}
+	//USERPROFILE is the equivalent of HOME on windows
+	return os.Getenv("USERPROFILE")
+}
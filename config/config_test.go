@@ -0,0 +1,162 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	conf, err := Load("not_existing.yaml")
+	if err != nil {
+		t.Errorf("Loading missing config file should not fail, error msg: %v", err)
+	}
+	if !reflect.DeepEqual(conf, Default()) {
+		t.Error("Loading missing config file should return default config")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tefter")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tefter", "config.yaml")
+
+	conf := Default()
+	conf.Editor = "nano"
+	conf.DefaultNotebook = "inbox"
+	if err := conf.Save(path); err != nil {
+		t.Errorf("Could not save config, error msg: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Errorf("Could not load config, error msg: %v", err)
+	}
+	if !reflect.DeepEqual(conf, loaded) {
+		t.Errorf("Expected loaded config to be %v, got: %v", conf, loaded)
+	}
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	f, _ := ioutil.TempFile("", "config")
+	f.WriteString("db: [not a string")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if _, err := Load(f.Name()); err == nil {
+		t.Error("Loading invalid config file should fail")
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	conf := Default()
+	for _, key := range Keys() {
+		if err := conf.Set(key, "value"); err != nil {
+			t.Errorf("Could not set key: %v, error msg: %v", key, err)
+		}
+		value, err := conf.Get(key)
+		if err != nil || value != "value" {
+			t.Errorf("Could not get key: %v", key)
+		}
+	}
+	if err := conf.Set("unknown", "value"); err == nil || err.Error() != "Unknown config key: unknown" {
+		t.Error("Expected error with message: 'Unknown config key: unknown'")
+	}
+	if _, err := conf.Get("unknown"); err == nil {
+		t.Error("Getting unknown key should fail")
+	}
+}
+
+func TestResolveDB(t *testing.T) {
+	conf := Default()
+	conf.DB = "config.db"
+	originalEnv := os.Getenv(DBEnv)
+	defer os.Setenv(DBEnv, originalEnv)
+
+	os.Setenv(DBEnv, "")
+	if conf.ResolveDB("") != "config.db" {
+		t.Error("Expected DB of config file")
+	}
+	os.Setenv(DBEnv, "env.db")
+	if conf.ResolveDB("") != "env.db" {
+		t.Error("Expected DB of environment variable")
+	}
+	if conf.ResolveDB("flag.db") != "flag.db" {
+		t.Error("Expected DB of flag")
+	}
+}
+
+func TestResolveLegacyDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tefter")
+	if err != nil {
+		t.Fatalf("Could not create directory, error msg: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	originalEnv, originalData := os.Getenv(DBEnv), os.Getenv("XDG_DATA_HOME")
+	defer func() {
+		os.Setenv(DBEnv, originalEnv)
+		os.Setenv("XDG_DATA_HOME", originalData)
+	}()
+	os.Setenv(DBEnv, "")
+	os.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+
+	conf := Default()
+	if conf.UsesLegacyDB("") || conf.ResolveDB("") != conf.DB {
+		t.Error("Expected default DB without a legacy DB")
+	}
+	ioutil.WriteFile(LegacyDB, []byte{}, 0644)
+	if !conf.UsesLegacyDB("") || conf.ResolveDB("") != LegacyDB {
+		t.Error("Expected legacy DB of the working directory when the default DB does not exist")
+	}
+	if conf.UsesLegacyDB("flag.db") || conf.ResolveDB("flag.db") != "flag.db" {
+		t.Error("Expected DB of flag over the legacy DB")
+	}
+	os.MkdirAll(filepath.Dir(conf.DB), 0755)
+	ioutil.WriteFile(conf.DB, []byte{}, 0644)
+	if conf.UsesLegacyDB("") || conf.ResolveDB("") != conf.DB {
+		t.Error("Expected default DB once it exists")
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	cases := []struct {
+		retention string
+		expected  time.Duration
+		valid     bool
+	}{
+		{"30d", 30 * 24 * time.Hour, true},
+		{"36h", 36 * time.Hour, true},
+		{"0", 0, true},
+		{"", 0, true},
+		{"0d", 0, true},
+		{"-1d", 0, false},
+		{"-1h", 0, false},
+		{"month", 0, false},
+		{"xd", 0, false},
+	}
+	for _, c := range cases {
+		retention, err := ParseRetention(c.retention)
+		if (err == nil) != c.valid {
+			t.Errorf("Unexpected error for retention: %q, error msg: %v", c.retention, err)
+		}
+		if retention != c.expected {
+			t.Errorf("Expected retention: %q to be %v, got: %v", c.retention, c.expected, retention)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	originalEnv := os.Getenv("XDG_CONFIG_HOME")
+	defer os.Setenv("XDG_CONFIG_HOME", originalEnv)
+
+	os.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+	if Path() != filepath.Join("/tmp/xdg", "tefter", "config.yaml") {
+		t.Errorf("Unexpected config path: %v", Path())
+	}
+}
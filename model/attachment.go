@@ -0,0 +1,38 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+//Attachment is a file attached to a note. Contents are stored once per Hash, so attaching the same file
+//to many notes takes the space of one file.
+type Attachment struct {
+	ID      int64     `db:"id"`
+	NoteID  int64     `db:"note_id"`
+	Name    string    `db:"name"`
+	Hash    string    `db:"hash"`
+	Size    int64     `db:"size"`
+	Created time.Time `db:"created"`
+	//Content is only set when a single attachment is retrieved
+	Content []byte `db:"content"`
+}
+
+//NewAttachment returns a new attachment pointer of the note with the hash and size of content.
+func NewAttachment(noteID int64, name string, content []byte) *Attachment {
+	return &Attachment{
+		NoteID:  noteID,
+		Name:    name,
+		Hash:    ContentHash(content),
+		Size:    int64(len(content)),
+		Created: time.Now(),
+		Content: content,
+	}
+}
+
+//ContentHash returns the hex encoded sha256 of content, it is the key of the stored content of attachments.
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
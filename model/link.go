@@ -0,0 +1,67 @@
+package model
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Link is a wiki link of a memo to another note, either by id: [[#42]] or by title: [[Bali 2018]].
+//Exactly one of NoteID and Title is set.
+type Link struct {
+	NoteID int64
+	Title  string
+}
+
+var linkPattern = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+//ParseLinks returns the links of memo in order of appearance without duplicates. Titles are trimmed
+//and matched exactly, links with an empty title are skipped.
+func ParseLinks(memo string) []Link {
+	links := []Link{}
+	seen := make(map[Link]bool)
+	for _, match := range linkPattern.FindAllStringSubmatch(memo, -1) {
+		link, ok := parseLink(match[1])
+		if ok && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+func parseLink(text string) (Link, bool) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "#") {
+		if id, err := strconv.ParseInt(text[1:], 10, 64); err == nil && id > 0 {
+			return Link{NoteID: id}, true
+		}
+	}
+	return Link{Title: text}, text != ""
+}
+
+//LinksTo returns true if the link points to the note.
+func (link Link) LinksTo(note *Note) bool {
+	if link.NoteID != 0 {
+		return link.NoteID == note.ID
+	}
+	return link.Title == note.Title
+}
+
+//String returns the link as written in a memo.
+func (link Link) String() string {
+	if link.NoteID != 0 {
+		return "[[#" + strconv.FormatInt(link.NoteID, 10) + "]]"
+	}
+	return "[[" + link.Title + "]]"
+}
+
+//RewriteLinks replaces the links of memo to oldTitle with links to newTitle, links by id are left as is.
+func RewriteLinks(memo, oldTitle, newTitle string) string {
+	return linkPattern.ReplaceAllStringFunc(memo, func(match string) string {
+		if link, ok := parseLink(match[2 : len(match)-2]); ok && link.NoteID == 0 && link.Title == oldTitle {
+			return Link{Title: newTitle}.String()
+		}
+		return match
+	})
+}
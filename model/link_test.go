@@ -0,0 +1,34 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLinks(t *testing.T) {
+	cases := []struct {
+		memo     string
+		expected []Link
+	}{
+		{"no links [here] [[ ]]", []Link{}},
+		{"see [[Bali 2018]] and [[#42]]", []Link{{Title: "Bali 2018"}, {NoteID: 42}}},
+		{"[[ Bali 2018 ]], [[Bali 2018]] and [[#42]] twice [[#42]]", []Link{{Title: "Bali 2018"}, {NoteID: 42}}},
+		{"[[#]] [[#0]] [[#abc]]", []Link{{Title: "#"}, {Title: "#0"}, {Title: "#abc"}}},
+		{"[[[nested]]] [[multi\nline]]", []Link{{Title: "nested"}}},
+	}
+
+	for _, c := range cases {
+		if links := ParseLinks(c.memo); !reflect.DeepEqual(links, c.expected) {
+			t.Errorf("Expected links of %q to be %v, got: %v", c.memo, c.expected, links)
+		}
+	}
+}
+
+func TestRewriteLinks(t *testing.T) {
+	memo := "see [[Bali 2018]], [[ Bali 2018 ]], [[Bali 2019]], [[#42]] and [Bali 2018]"
+	expected := "see [[Bali 2020]], [[Bali 2020]], [[Bali 2019]], [[#42]] and [Bali 2018]"
+
+	if rewritten := RewriteLinks(memo, "Bali 2018", "Bali 2020"); rewritten != expected {
+		t.Errorf("Expected memo %q, got: %q", expected, rewritten)
+	}
+}
@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 //Note is the note we want to keep.
 type Note struct {
@@ -11,6 +14,8 @@ type Note struct {
 	LastUpdated time.Time `db:"lastUpdated"`
 	Tags        map[string]bool
 	NotebookID  int64 `db:"notebook_id"`
+	//DeletedAt is set while the note is in the trash
+	DeletedAt *time.Time `db:"deleted_at"`
 }
 
 //NewNote returns a new note pointer.
@@ -40,9 +45,12 @@ func (note *Note) UpdateMemo(newMemo string) {
 }
 
 //AddTags add one or more tags to note (also updates the LastUpdate value)
+//Tags are normalized, tags that are empty once normalized are skipped.
 func (note *Note) AddTags(tags []string) {
 	for _, tag := range tags {
-		note.Tags[tag] = true
+		if tag = NormalizeTag(tag); tag != "" {
+			note.Tags[tag] = true
+		}
 	}
 	noteUpdated(note)
 }
@@ -50,11 +58,39 @@ func (note *Note) AddTags(tags []string) {
 //RemoveTags removes tags (also updates the LastUpdate value)
 func (note *Note) RemoveTags(tags []string) {
 	for _, tag := range tags {
-		delete(note.Tags, tag)
+		delete(note.Tags, NormalizeTag(tag))
 	}
 	noteUpdated(note)
 }
 
+//NormalizeTag returns tag in lower case without surrounding spaces and with inner spaces collapsed,
+//so that "Go", " go" and "GO " are the same tag.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+//TagSeparator separates the levels of hierarchical tags, eg: lang/go is a child of lang.
+const TagSeparator = "/"
+
+//IsSubTag returns true if tag is parent or one of its descendants, eg: lang/go and lang/go/generics are
+//sub tags of lang but lang-go is not. Both tags should be normalized.
+func IsSubTag(tag, parent string) bool {
+	return tag == parent || strings.HasPrefix(tag, parent+TagSeparator)
+}
+
+//HasSubTag returns true if the note is tagged with parent or one of its descendants, parent should be normalized.
+func (note *Note) HasSubTag(parent string) bool {
+	if note.Tags[parent] {
+		return true
+	}
+	for tag := range note.Tags {
+		if IsSubTag(tag, parent) {
+			return true
+		}
+	}
+	return false
+}
+
 //UpdateTags updates tags (also updates the LastUpdate value)
 func (note *Note) UpdateTags(tags []string) {
 	note.Tags = make(map[string]bool)
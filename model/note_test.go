@@ -73,3 +73,30 @@ func TestNoteUpdated(t *testing.T) {
 func createMockedNote() *Note {
 	return NewNote("testTitle", "testMemo", 111, []string{"tag1", "tag2"})
 }
+
+func TestAddTagsNormalized(t *testing.T) {
+	note := NewNote("title", "memo", 1, []string{" Go ", "go", "Machine   Learning", "  "})
+
+	if len(note.Tags) != 2 || !note.Tags["go"] || !note.Tags["machine learning"] {
+		t.Errorf("Expected tags to be normalized, got: %v", note.Tags)
+	}
+	note.RemoveTags([]string{"GO"})
+	if note.Tags["go"] {
+		t.Error("Failed removing tag regardless of case")
+	}
+}
+
+func TestHasSubTag(t *testing.T) {
+	note := NewNote("title", "memo", 1, []string{"lang/go/generics", "todo"})
+
+	for _, parent := range []string{"lang", "lang/go", "lang/go/generics", "todo"} {
+		if !note.HasSubTag(parent) {
+			t.Errorf("Expected note to have a sub tag of %v", parent)
+		}
+	}
+	for _, parent := range []string{"lan", "lang/rust", "lang/go/generic", "todo/today"} {
+		if note.HasSubTag(parent) {
+			t.Errorf("Expected note to have no sub tag of %v", parent)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package model
 
+import "time"
+
 //Notebook holds a list of "similar" notes
 type Notebook struct {
 	ID    int64  `db:"id"`
 	Title string `db:"title"`
-	Notes map[int64]*Note
+	//ParentID is the id of the parent notebook, 0 for top level notebooks
+	ParentID int64 `db:"parent_id"`
+	Notes    map[int64]*Note
+	//DeletedAt is set while the notebook is in the trash
+	DeletedAt *time.Time `db:"deleted_at"`
 }
 
 //NewNotebook returns a Notebook pointer
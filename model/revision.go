@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+//Revision is a saved version of the title and memo of a note. Revisions of a note are numbered from 1,
+//in the order they were saved.
+type Revision struct {
+	NoteID  int64     `db:"note_id"`
+	Number  int       `db:"revision"`
+	Title   string    `db:"title"`
+	Memo    string    `db:"memo"`
+	Created time.Time `db:"created"`
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicolasmanic/tefter/model"
+	"sort"
+	"time"
+)
+
+const attachmentColumns = "a.id, a.note_id, a.name, a.hash, a.size, a.created"
+
+//addAttachment implements AddAttachment, insert stores the content and the attachment and returns the id of
+//the attachment since sqlite and postgres return generated ids differently.
+func addAttachment(handle dbHandle, attachment *model.Attachment, insert func(tx *sqlx.Tx, attachment *model.Attachment) (int64, error)) (int64, error) {
+	if attachment.Name == "" {
+		return -1, newError(ErrValidation, "Attachment should have a name")
+	}
+	//a nil content would be stored as NULL
+	if attachment.Content == nil {
+		attachment.Content = []byte{}
+	}
+	attachment.Hash = model.ContentHash(attachment.Content)
+	attachment.Size = int64(len(attachment.Content))
+	if attachment.Created.IsZero() {
+		attachment.Created = time.Now().UTC()
+	}
+
+	var attachmentID int64
+	err := transaction(handle, func(tx *sqlx.Tx) error {
+		if err := checkNoteExists(tx, attachment.NoteID); err != nil {
+			return err
+		}
+		var err error
+		attachmentID, err = insert(tx, attachment)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrNoteNotFound) {
+		return -1, fmt.Errorf("Could not save attachment, error msg: %v", err)
+	} else if err != nil {
+		return -1, err
+	}
+	attachment.ID = attachmentID
+	return attachmentID, nil
+}
+
+//checkNoteExists returns ErrNoteNotFound if the note doesn't exist or is in the trash.
+func checkNoteExists(handle dbHandle, noteID int64) error {
+	noteIDs := []int64{}
+	if err := handle.Select(&noteIDs, handle.Rebind("SELECT id FROM note WHERE id = ? AND deleted_at IS NULL"), noteID); err != nil {
+		return err
+	}
+	if len(noteIDs) == 0 {
+		return newError(ErrNoteNotFound, "Could find note with id: %v", noteID)
+	}
+	return nil
+}
+
+//getAttachments implements GetAttachments.
+func getAttachments(handle dbHandle, noteID int64) ([]*model.Attachment, error) {
+	if err := checkNoteExists(handle, noteID); err != nil && !errors.Is(err, ErrNoteNotFound) {
+		return nil, fmt.Errorf("Could not retrieve attachments, error msg: %v", err)
+	} else if err != nil {
+		return nil, err
+	}
+	attachments := []*model.Attachment{}
+	err := handle.Select(&attachments, handle.Rebind("SELECT "+attachmentColumns+" FROM attachment a WHERE a.note_id = ? ORDER BY a.id"), noteID)
+	if err != nil {
+		return nil, fmt.Errorf("Could not retrieve attachments, error msg: %v", err)
+	}
+	return attachments, nil
+}
+
+//getAttachment implements GetAttachment, attachments of notes in the trash are not found.
+func getAttachment(handle dbHandle, attachmentID int64) (*model.Attachment, error) {
+	attachment := &model.Attachment{}
+	err := handle.Get(attachment, handle.Rebind(`SELECT `+attachmentColumns+`, b.content FROM attachment a
+		JOIN attachment_blob b ON b.hash = a.hash
+		JOIN note n ON n.id = a.note_id
+		WHERE a.id = ? AND n.deleted_at IS NULL`), attachmentID)
+	if err == sql.ErrNoRows {
+		return nil, newError(ErrAttachmentNotFound, "Could not find attachment with id: %v", attachmentID)
+	} else if err != nil {
+		return nil, fmt.Errorf("Could not retrieve attachment, error msg: %v", err)
+	}
+	return attachment, nil
+}
+
+//deleteOrphanBlobs deletes the contents that are no longer attached to any note, it runs after the attachments
+//of deleted notes are deleted.
+func deleteOrphanBlobs(tx *sqlx.Tx) error {
+	_, err := tx.Exec("DELETE FROM attachment_blob WHERE hash NOT IN (SELECT hash FROM attachment)")
+	return err
+}
+
+//copyAttachment returns a copy of attachment, the content is shared since it is never modified.
+func copyAttachment(attachment *model.Attachment) *model.Attachment {
+	attachmentCopy := *attachment
+	return &attachmentCopy
+}
+
+//sortAttachments sorts attachments oldest first, same as the sql implementations.
+func sortAttachments(attachments []*model.Attachment) {
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID < attachments[j].ID })
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/nicolasmanic/tefter/model"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+//The benchmarks run against a sqlite DB of benchmarkNotes notes spread over benchmarkNotebooks notebooks,
+//the fixture is built once, only when benchmarks run:
+//go test ./repository -run XXX -bench .
+const (
+	benchmarkNotes     = 100000
+	benchmarkNotebooks = 100
+	benchmarkTags      = 50
+)
+
+var (
+	benchmarkOnce  sync.Once
+	benchmarkDir   string
+	benchmarkStore Store
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if benchmarkStore != nil {
+		benchmarkStore.Close()
+		os.RemoveAll(benchmarkDir)
+	}
+	os.Exit(code)
+}
+
+//newBenchmarkStore returns the store of the fixture DB, building it on first call.
+func newBenchmarkStore(b *testing.B) Store {
+	benchmarkOnce.Do(func() {
+		dir, err := ioutil.TempDir("", "tefter-bench")
+		if err != nil {
+			b.Fatalf("Could not create temp dir, error msg: %v", err)
+		}
+		benchmarkDir = dir
+		benchmarkStore = NewStore(filepath.Join(dir, "bench.db"))
+		if err := seedBenchmarkStore(benchmarkStore); err != nil {
+			b.Fatalf("Could not create benchmark fixture, error msg: %v", err)
+		}
+	})
+	if benchmarkStore == nil {
+		b.Skip("Benchmark fixture is not available")
+	}
+	return benchmarkStore
+}
+
+func seedBenchmarkStore(store Store) error {
+	return store.WithTx(func(tx Store) error {
+		notebookIDs := []int64{DEFAULT_NOTEBOOK_ID}
+		for i := 1; i < benchmarkNotebooks; i++ {
+			id, err := tx.Notebooks().SaveNotebook(model.NewNotebook(fmt.Sprintf("notebook%d", i)))
+			if err != nil {
+				return err
+			}
+			notebookIDs = append(notebookIDs, id)
+		}
+		for i := 0; i < benchmarkNotes; i++ {
+			tags := []string{
+				fmt.Sprintf("tag%d", i%benchmarkTags),
+				fmt.Sprintf("tag%d", (i+1)%benchmarkTags),
+				fmt.Sprintf("tag%d", (i+7)%benchmarkTags),
+			}
+			memo := fmt.Sprintf("memo %d of the benchmark fixture, word%d", i, i%1000)
+			note := model.NewNote(fmt.Sprintf("note%d", i), memo, notebookIDs[i%benchmarkNotebooks], tags)
+			if _, err := tx.Notes().SaveNote(note); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func BenchmarkGetAllNotes(b *testing.B) {
+	noteRepo := newBenchmarkStore(b).Notes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := noteRepo.GetNotes([]int64{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetNotesByTag(b *testing.B) {
+	noteRepo := newBenchmarkStore(b).Notes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := noteRepo.GetNotesByTag([]string{"tag1", "tag2"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSearchNotesByKeyword(b *testing.B) {
+	noteRepo := newBenchmarkStore(b).Notes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := noteRepo.SearchNotesByKeyword("word42"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetAllNotebooks(b *testing.B) {
+	notebookRepo := newBenchmarkStore(b).Notebooks()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := notebookRepo.GetNotebooks([]int64{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package repository_test
+
+import (
+	"github.com/nicolasmanic/tefter/repository"
+	"github.com/nicolasmanic/tefter/repository/repotest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSqliteConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) (*repotest.Repositories, func()) {
+		dir, err := ioutil.TempDir("", "tefter")
+		if err != nil {
+			t.Fatalf("Could not create temp dir, error msg: %v", err)
+		}
+		dbPath := filepath.Join(dir, "test.db")
+		repos := &repotest.Repositories{
+			Notes:     repository.NewNoteRepository(dbPath),
+			Notebooks: repository.NewNotebookRepository(dbPath),
+			Accounts:  repository.NewAccountRepository(dbPath),
+		}
+		return repos, func() { os.RemoveAll(dir) }
+	})
+}
+
+func TestSqliteStoreConformance(t *testing.T) {
+	repotest.RunStore(t, func(t *testing.T) (repository.Store, func()) {
+		dir, err := ioutil.TempDir("", "tefter")
+		if err != nil {
+			t.Fatalf("Could not create temp dir, error msg: %v", err)
+		}
+		return repository.NewStore(filepath.Join(dir, "test.db")), func() { os.RemoveAll(dir) }
+	})
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	repotest.RunStore(t, func(t *testing.T) (repository.Store, func()) {
+		return repository.NewMemoryStore(), func() {}
+	})
+}
+
+func TestMemoryConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) (*repotest.Repositories, func()) {
+		notes, notebooks, accounts := repository.NewMemoryRepositories()
+		return &repotest.Repositories{Notes: notes, Notebooks: notebooks, Accounts: accounts}, func() {}
+	})
+}
+
+func TestPostgresConformance(t *testing.T) {
+	dsn := os.Getenv("TEFTER_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEFTER_TEST_POSTGRES_DSN is not set")
+	}
+	repotest.RunStore(t, func(t *testing.T) (repository.Store, func()) {
+		return repository.NewPostgresStore(dsn), func() {
+			//rolling back every migration drops all tables, the next test starts from an empty DB.
+			migrator := repository.NewPostgresMigrator(dsn)
+			defer migrator.CloseDB()
+			for {
+				version, err := migrator.Rollback(true)
+				if err != nil || version == 0 {
+					return
+				}
+			}
+		}
+	})
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/nicolasmanic/tefter/model"
+	"time"
 )
 
 //NoteRepository is an interface for handling DB related tasks for Note
@@ -11,10 +12,51 @@ type NoteRepository interface {
 	GetNotes(noteIDs []int64) ([]*model.Note, error)
 	GetNote(noteID int64) (*model.Note, error)
 	GetNotesByTag(tags []string) ([]*model.Note, error)
+	//ListTags returns the tags of the notes out of the trash with the number of notes tagged with them, sorted by tag.
+	ListTags() ([]*TagCount, error)
+	//RenameTag renames the tag on every note, the notes in the trash included, and returns the number of renamed
+	//notes. It fails with ErrTagExists if newTag is already in use, see MergeTags.
+	RenameTag(oldTag, newTag string) (int, error)
+	//MergeTags replaces every one of tags with into, that may already be in use, and returns the number of notes
+	//tagged with any of tags.
+	MergeTags(tags []string, into string) (int, error)
+	//DeleteTag removes the tag from every note and returns the number of notes it was removed from.
+	DeleteTag(tag string) (int, error)
 	UpdateNote(note *model.Note) error
+	//DeleteNotes moves the notes to the trash. Notes in the trash are hidden from every other method unless
+	//NoteFilter.Trashed is set, until they are restored or purged.
 	DeleteNotes(noteIDs []int64) error
 	DeleteNote(noteIDs int64) error
+	//RestoreNotes takes the notes out of the trash, along with their notebooks if those are in the trash too.
+	RestoreNotes(noteIDs []int64) error
+	//PurgeNotes deletes for good the notes moved to the trash before deletedBefore and returns their number.
+	PurgeNotes(deletedBefore time.Time) (int, error)
 	SearchNotesByKeyword(keyword string) ([]*model.Note, error)
+	ListNotes(filter NoteFilter, query NoteQuery) (*NotePage, error)
+	//SearchNotes returns a page of the notes matching filter, ranked by relevance to the text terms of filter.Query
+	//unless query sets another sort. Matches of the text terms are highlighted in the title and snippet of every hit.
+	//Text terms match similar words if filter.Fuzzy is set.
+	SearchNotes(filter NoteFilter, query NoteQuery) (*SearchPage, error)
+	//GetRevisions returns the revisions of a note oldest first, every save of a changed title or memo is a revision
+	//and the latest revision is the current content of the note.
+	GetRevisions(noteID int64) ([]*model.Revision, error)
+	GetRevision(noteID int64, revision int) (*model.Revision, error)
+	//RestoreRevision sets the title and memo of the note to those of the revision, the restored content is saved
+	//as a new revision so that the restore can be undone.
+	RestoreRevision(noteID int64, revision int) (*model.Note, error)
+	//GetLinks returns the notes out of the trash that the memo of the note links to with [[title]] or [[#id]],
+	//see model.ParseLinks. Links by title match every note with that title.
+	GetLinks(noteID int64) ([]*model.Note, error)
+	//GetBacklinks returns the notes out of the trash whose memo links to the note.
+	GetBacklinks(noteID int64) ([]*model.Note, error)
+	//AddAttachment attaches a file to a note out of the trash and returns the id of the attachment. The hash and
+	//size are those of the content, the content is stored once no matter how many notes it is attached to.
+	AddAttachment(attachment *model.Attachment) (int64, error)
+	//GetAttachments returns the attachments of the note without their content, oldest first.
+	GetAttachments(noteID int64) ([]*model.Attachment, error)
+	//GetAttachment returns an attachment with its content. Attachments of notes in the trash are hidden
+	//until the note is restored and deleted for good when it is purged.
+	GetAttachment(attachmentID int64) (*model.Attachment, error)
 	CloseDB() error
 }
 
@@ -26,8 +68,17 @@ type NotebookRepository interface {
 	GetNotebookByTitle(notebookTitle string) (*model.Notebook, error)
 	GetAllNotebooksTitle() (map[int64]string, error)
 	UpdateNotebook(notebook *model.Notebook) error
+	//DeleteNotebooks moves the notebooks and their notes to the trash. Notebooks in the trash are hidden from
+	//every other method except GetTrashedNotebooks, until they are restored or purged.
 	DeleteNotebooks(notebooksIDs []int64) error
 	DeleteNotebook(notebooksID int64) error
+	//GetTrashedNotebooks returns the notebooks in the trash with their notes, most recently deleted first.
+	GetTrashedNotebooks() ([]*model.Notebook, error)
+	//RestoreNotebooks takes the notebooks out of the trash, along with the notes moved to the trash with them.
+	RestoreNotebooks(notebooksIDs []int64) error
+	//PurgeNotebooks deletes for good the notebooks moved to the trash before deletedBefore and all of their notes,
+	//it returns the number of deleted notebooks.
+	PurgeNotebooks(deletedBefore time.Time) (int, error)
 	CloseDB() error
 }
 
@@ -35,7 +86,26 @@ type NotebookRepository interface {
 type AccountRepository interface {
 	CreateAccount(username string, password []byte) error
 	GetAccount(username string) (*model.Account, error)
-	GetUsernames() []string
+	GetUsernames() ([]string, error)
 	DeleteAccount(username string) error
 	CloseDB() error
 }
+
+//Migrator is an interface for handling the versioned schema of the DB
+type Migrator interface {
+	Migrate() (int, error)
+	Rollback(force bool) (int, error)
+	Status() ([]MigrationStatus, error)
+	CloseDB() error
+}
+
+//Store owns a single DB connection and hands out repositories sharing it.
+type Store interface {
+	Notes() NoteRepository
+	Notebooks() NotebookRepository
+	Accounts() AccountRepository
+	//WithTx runs fn with a Store whose repositories share a single transaction. The transaction is
+	//committed if fn returns nil and rolled back otherwise. Nested calls join the outer transaction.
+	WithTx(fn func(tx Store) error) error
+	Close() error
+}
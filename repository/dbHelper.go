@@ -1,89 +1,140 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/nicolasmanic/tefter/model"
 	"log"
+	"strings"
+	"unicode"
 )
 
 const databaseDriver = "sqlite3"
 
-func connect2DB(dbPath string) *sqlx.DB {
-	var err error
-	db := sqlx.MustConnect(databaseDriver, dbPath)
-	tx := db.MustBegin()
-
-	defer func() {
-		if r := recover(); r != nil {
-			err = r.(error)
-			tx.Rollback()
-			db = nil
-			log.Fatalf("Could note  connect to DB error msg: %v", err)
-		}
-	}()
-
-	tx.MustExec(`CREATE TABLE IF NOT EXISTS account (
-		username TEXT NOT NULL,
-		password TEXT NOT NULL,
-		CONSTRAINT account_PK PRIMARY KEY(username))`)
+const postgresDriver = "postgres"
 
-	tx.MustExec(`CREATE TABLE IF NOT EXISTS notebook (
-		id INTEGER NOT NULL,
-		title TEXT NOT NULL,
-		CONSTRAINT title_UN UNIQUE(title),
-		CONSTRAINT notebook_PK PRIMARY KEY(id))`)
+//maxBatchSize is the max number of ids bound to a single IN clause, sqlite allows at most 999 variables per query.
+const maxBatchSize = 900
 
-	//Default Notebook has id = 1
-	tx.MustExec(`INSERT OR IGNORE INTO notebook (id, title)
-				 VALUES (1, 'Default Notebook')`)
+//connect2DB connects to the DB at dbPath and applies every pending migration,
+//see sqliteMigrations for the schema.
+func connect2DB(dbPath string) *sqlx.DB {
+	db := sqlx.MustConnect(databaseDriver, dbPath)
+	if _, err := migrateUp(db, sqliteMigrations); err != nil {
+		db.Close()
+		log.Fatalf("Could not connect to DB, error msg: %v", err)
+	}
+	return db
+}
 
-	tx.MustExec(`CREATE TABLE IF NOT EXISTS note (
-		id INTEGER NOT NULL,
-		title TEXT NOT NULL,
-		memo TEXT NOT NULL,
-		created DATETIME NOT NULL,
-		lastUpdated DATETIME NOT NULL,
-		notebook_id INTEGER NOT NULL,
-		CONSTRAINT note_PK PRIMARY KEY(id),
-		CONSTRAINT notebook_id_FK FOREIGN KEY(notebook_id) REFERENCES notebook(id))`)
+//connect2Postgres connects to the postgres DB described by dsn and applies every pending migration,
+//see postgresMigrations for the schema.
+func connect2Postgres(dsn string) *sqlx.DB {
+	db := sqlx.MustConnect(postgresDriver, dsn)
+	if _, err := migrateUp(db, postgresMigrations); err != nil {
+		db.Close()
+		log.Fatalf("Could not connect to DB, error msg: %v", err)
+	}
+	return db
+}
 
-	tx.MustExec(`CREATE TABLE IF NOT EXISTS notebook_note (
-		note_id INTEGER NOT NULL,
-		notebook_id		INTEGER NOT NULL,
-		CONSTRAINT notebook_note_PK PRIMARY KEY(note_id, notebook_id),
-		CONSTRAINT note_id_FK FOREIGN KEY(note_id) REFERENCES note(id),
-		CONSTRAINT notebook_id_FK FOREIGN KEY(notebook_id) REFERENCES notebook(id))`)
+//dbHandle is implemented by both *sqlx.DB and *sqlx.Tx, repositories use it so that the same code
+//runs either on the DB or inside a transaction of Store.WithTx.
+type dbHandle interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	Preparex(query string) (*sqlx.Stmt, error)
+	Rebind(query string) string
+}
 
-	tx.MustExec(`CREATE TABLE IF NOT EXISTS note_tag (
-		note_id INTEGER NOT NULL,
-		tag		TEXT NOT NULL,
-		CONSTRAINT note_tag_PK PRIMARY KEY(tag, note_id),
-		CONSTRAINT note_id_FK FOREIGN KEY(note_id) REFERENCES note(id))`)
+//transaction runs fn inside a transaction, the transaction is committed only if fn succeeds.
+//If handle is already a transaction fn joins it, the owner of the transaction commits or rolls back.
+func transaction(handle dbHandle, fn func(tx *sqlx.Tx) error) error {
+	if tx, ok := handle.(*sqlx.Tx); ok {
+		return fn(tx)
+	}
+	db, ok := handle.(*sqlx.DB)
+	if !ok {
+		return fmt.Errorf("Unsupported DB handle: %T", handle)
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
 
-	tx.MustExec(`CREATE VIRTUAL TABLE IF NOT EXISTS note_fts USING fts4(content='note', title, memo)`)
+//closeHandle closes the DB connection, repositories of a transaction do not own a connection.
+func closeHandle(handle dbHandle) error {
+	if db, ok := handle.(*sqlx.DB); ok {
+		return db.Close()
+	}
+	return nil
+}
 
-	tx.MustExec(`CREATE TRIGGER IF NOT EXISTS note_bu BEFORE UPDATE ON note BEGIN
-				 DELETE FROM note_fts WHERE docid = old.rowid;
-				 END;`)
-	tx.MustExec(`CREATE TRIGGER IF NOT EXISTS note_bd BEFORE DELETE ON note BEGIN
-				DELETE FROM note_fts WHERE docid = old.rowid;
-				END;`)
+//isUniqueViolation returns true if err is a unique or primary key constraint violation of sqlite or postgres.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return false
+}
 
-	tx.MustExec(`CREATE TRIGGER IF NOT EXISTS note_au AFTER UPDATE ON note BEGIN
-				 INSERT INTO note_fts(docid, title, memo) VALUES(new.rowid, new.title, new.memo);
-				 END;`)
-	tx.MustExec(`CREATE TRIGGER IF NOT EXISTS note_ai AFTER INSERT ON note BEGIN
-				INSERT INTO note_fts(docid, title, memo) VALUES(new.rowid, new.title, new.memo);
-				END;`)
+//loadTags sets the tags of every note, tags are retrieved with one query per maxBatchSize notes.
+func loadTags(handle dbHandle, notes []*model.Note) error {
+	notesByID := make(map[int64]*model.Note, len(notes))
+	noteIDs := make([]int64, 0, len(notes))
+	for _, note := range notes {
+		note.Tags = make(map[string]bool)
+		notesByID[note.ID] = note
+		noteIDs = append(noteIDs, note.ID)
+	}
 
-	err = tx.Commit()
-	checkError(err)
-	return db
+	for _, batch := range batchIDs(noteIDs) {
+		query, args, err := sqlx.In("SELECT note_id, tag FROM note_tag WHERE note_id IN (?)", batch)
+		if err != nil {
+			return err
+		}
+		noteTags := []struct {
+			NoteID int64  `db:"note_id"`
+			Tag    string `db:"tag"`
+		}{}
+		if err := handle.Select(&noteTags, handle.Rebind(query), args...); err != nil {
+			return err
+		}
+		for _, noteTag := range noteTags {
+			notesByID[noteTag.NoteID].Tags[noteTag.Tag] = true
+		}
+	}
+	return nil
 }
 
-func checkError(err error) {
-	if err != nil {
-		log.Panicln(err)
+//batchIDs splits ids to consecutive batches of at most maxBatchSize ids.
+func batchIDs(ids []int64) [][]int64 {
+	batches := [][]int64{}
+	for len(ids) > maxBatchSize {
+		batches = append(batches, ids[:maxBatchSize])
+		ids = ids[maxBatchSize:]
+	}
+	if len(ids) > 0 {
+		batches = append(batches, ids)
 	}
+	return batches
 }
 
 func removeDups(integers []int64) []int64 {
@@ -99,3 +150,10 @@ func removeDups(integers []int64) []int64 {
 	}
 	return integers[:j]
 }
+
+//tokenize splits text to lower case alphanumeric tokens, similar to the simple tokenizer of sqlite fts.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
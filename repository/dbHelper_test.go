@@ -26,3 +26,18 @@ func TestRemoveDups(t *testing.T) {
 		t.Error("Could not remove duplicates")
 	}
 }
+
+func TestBatchIDs(t *testing.T) {
+	ids := make([]int64, 2*maxBatchSize+1)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	batches := batchIDs(ids)
+
+	if len(batches) != 3 || len(batches[0]) != maxBatchSize || len(batches[2]) != 1 || batches[2][0] != ids[2*maxBatchSize] {
+		t.Errorf("Could not split ids to batches, got %d batches", len(batches))
+	}
+	if len(batchIDs([]int64{})) != 0 {
+		t.Error("Empty ids should not produce any batch")
+	}
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+)
+
+//Sentinel errors returned, possibly wrapped, by every repository implementation. Use errors.Is to check for them.
+var (
+	//ErrNoteNotFound is returned when a note with the requested id does not exist.
+	ErrNoteNotFound = errors.New("note not found")
+	//ErrRevisionNotFound is returned when a note exists but has no revision with the requested number.
+	ErrRevisionNotFound = errors.New("revision not found")
+	//ErrNotebookNotFound is returned when a notebook with the requested id or title does not exist.
+	ErrNotebookNotFound = errors.New("notebook not found")
+	//ErrNotebookExists is returned when a notebook title is already used by another notebook of the same parent.
+	ErrNotebookExists = errors.New("notebook already exists")
+	//ErrDefaultNotebookProtected is returned when trying to delete the default notebook.
+	ErrDefaultNotebookProtected = errors.New("default notebook can not be deleted")
+	//ErrNotebookHasChildren is returned when deleting a notebook without its child notebooks.
+	ErrNotebookHasChildren = errors.New("notebook has child notebooks")
+	//ErrTagNotFound is returned when no note is tagged with the requested tag.
+	ErrTagNotFound = errors.New("tag not found")
+	//ErrTagExists is returned when renaming a tag to a tag that is already in use, see MergeTags.
+	ErrTagExists = errors.New("tag already exists")
+	//ErrAttachmentNotFound is returned when an attachment with the requested id does not exist.
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	//ErrAccountNotFound is returned when no account exists for a username.
+	ErrAccountNotFound = errors.New("account not found")
+	//ErrAccountExists is returned when an account already exists for a username.
+	ErrAccountExists = errors.New("account already exists")
+	//ErrValidation is returned when the input is invalid, eg: a note without memo.
+	ErrValidation = errors.New("validation failed")
+	//ErrDestructiveRollback is returned when rolling back a migration would delete data of the DB without force.
+	ErrDestructiveRollback = errors.New("rollback deletes data")
+	//ErrIrreversibleMigration is returned when rolling back a migration whose changes can not be undone without force.
+	ErrIrreversibleMigration = errors.New("irreversible migration")
+)
+
+//Error describes a failure of a repository, Kind is one of the sentinel errors.
+type Error struct {
+	Kind error
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+//Unwrap returns the sentinel error so that errors.Is(err, ErrNoteNotFound) works.
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func newError(kind error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Msg: fmt.Sprintf(format, args...)}
+}
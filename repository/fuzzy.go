@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/nicolasmanic/tefter/model"
+	"strings"
+	"sync"
+)
+
+//fuzzyMinSubstring is the min length of a word of a fuzzy search that matches as part of a longer word
+const fuzzyMinSubstring = 3
+
+//fuzzyIndex is an in memory index of the words of notes for fuzzy searches, see NoteFilter.Fuzzy. Words similar
+//to a searched word are found by their common trigrams, every word is mapped to the notes containing it.
+type fuzzyIndex struct {
+	words []string
+	ids   map[string]int
+	//postings maps every word to the notes containing it and the weight of the best field it is found in
+	postings []map[int64]float64
+	trigrams map[string][]int
+}
+
+//fuzzyTextExpr replaces a text term in the query of a fuzzy search, it matches the notes of scores.
+type fuzzyTextExpr struct {
+	*textExpr
+	//scores is the relevance of the term to every matching note
+	scores map[int64]float64
+	//words are the words of every matching note that matched the term
+	words map[int64][]string
+}
+
+//fuzzyCorpus is the fuzzy index of every note of a DB, in and out of the trash, along with the notes and the
+//notebook paths it indexes.
+type fuzzyCorpus struct {
+	//generation is the change generation of the DB the corpus was built at, see the change_generation table
+	generation int64
+	notes      []*model.Note
+	paths      map[int64]string
+	index      *fuzzyIndex
+}
+
+//fuzzyCache holds the corpus of the latest change generation of a sql DB, it is shared by the repositories of a
+//store so that fuzzy searches only read and index the notes again after they changed.
+type fuzzyCache struct {
+	sync.Mutex
+	corpus *fuzzyCorpus
+}
+
+//newFuzzyIndex indexes the words of the title, memo, tags and notebook path of notes.
+func newFuzzyIndex(notes []*model.Note, paths map[int64]string) *fuzzyIndex {
+	index := &fuzzyIndex{ids: map[string]int{}, trigrams: map[string][]int{}}
+	for _, note := range notes {
+		index.add(note.ID, note.Title, titleWeight)
+		index.add(note.ID, note.Memo, memoWeight)
+		//tags and notebooks label notes, they weigh as much as the title
+		for tag := range note.Tags {
+			index.add(note.ID, tag, titleWeight)
+		}
+		if path, ok := paths[note.NotebookID]; ok {
+			index.add(note.ID, path, titleWeight)
+		}
+	}
+	return index
+}
+
+func (index *fuzzyIndex) add(noteID int64, text string, weight float64) {
+	for _, word := range tokenize(text) {
+		id, ok := index.ids[word]
+		if !ok {
+			id = len(index.words)
+			index.ids[word] = id
+			index.words = append(index.words, word)
+			index.postings = append(index.postings, map[int64]float64{})
+			for _, trigram := range trigrams(word) {
+				index.trigrams[trigram] = append(index.trigrams[trigram], id)
+			}
+		}
+		if weight > index.postings[id][noteID] {
+			index.postings[id][noteID] = weight
+		}
+	}
+}
+
+//similarWords returns the ids of the indexed words similar to token and their similarity. Words within the
+//edit distance allowed share at least one trigram with token, so only words sharing trigrams are compared.
+func (index *fuzzyIndex) similarWords(token string) map[int]float64 {
+	compared := map[int]bool{}
+	similar := map[int]float64{}
+	for _, trigram := range trigrams(token) {
+		for _, id := range index.trigrams[trigram] {
+			if compared[id] {
+				continue
+			}
+			compared[id] = true
+			if score := similarity(token, index.words[id]); score > 0 {
+				similar[id] = score
+			}
+		}
+	}
+	return similar
+}
+
+//match returns the fuzzy term of expr, notes match it if every token of expr matches a similar word of the note.
+//Fuzzy terms ignore the order of the tokens of phrases.
+func (index *fuzzyIndex) match(expr *textExpr) *fuzzyTextExpr {
+	term := &fuzzyTextExpr{textExpr: expr, scores: map[int64]float64{}, words: map[int64][]string{}}
+	for i, token := range expr.tokens {
+		//best score of the token for every note containing a similar word
+		scores := map[int64]float64{}
+		words := map[int64][]string{}
+		for id, score := range index.similarWords(token) {
+			for noteID, weight := range index.postings[id] {
+				if i > 0 && term.scores[noteID] == 0 {
+					continue
+				}
+				if score*weight > scores[noteID] {
+					scores[noteID] = score * weight
+				}
+				words[noteID] = append(words[noteID], index.words[id])
+			}
+		}
+		for noteID := range term.scores {
+			if scores[noteID] == 0 {
+				delete(term.scores, noteID)
+				delete(term.words, noteID)
+			}
+		}
+		for noteID, score := range scores {
+			term.scores[noteID] += score
+			term.words[noteID] = append(term.words[noteID], words[noteID]...)
+		}
+	}
+	return term
+}
+
+func (expr *fuzzyTextExpr) matches(note *model.Note, paths map[int64]string) bool {
+	return expr.scores[note.ID] > 0
+}
+
+//fuzzyQuery returns expr with its text terms replaced by fuzzy terms, the terms that notes are ranked by are
+//appended to terms.
+func fuzzyQuery(expr QueryExpr, index *fuzzyIndex, negated bool, terms *[]*fuzzyTextExpr) QueryExpr {
+	switch e := expr.(type) {
+	case *textExpr:
+		term := index.match(e)
+		if !negated {
+			*terms = append(*terms, term)
+		}
+		return term
+	case *andExpr:
+		return &andExpr{fuzzyQueries(e.exprs, index, negated, terms)}
+	case *orExpr:
+		return &orExpr{fuzzyQueries(e.exprs, index, negated, terms)}
+	case *notExpr:
+		return &notExpr{fuzzyQuery(e.expr, index, true, terms)}
+	}
+	return expr
+}
+
+func fuzzyQueries(exprs []QueryExpr, index *fuzzyIndex, negated bool, terms *[]*fuzzyTextExpr) []QueryExpr {
+	fuzzy := make([]QueryExpr, 0, len(exprs))
+	for _, expr := range exprs {
+		fuzzy = append(fuzzy, fuzzyQuery(expr, index, negated, terms))
+	}
+	return fuzzy
+}
+
+//searchFuzzy returns the hits of the notes matching the fuzzy query of filter, notes should already match
+//the other fields of filter and index should contain them. Matched words are highlighted.
+func searchFuzzy(index *fuzzyIndex, notes []*model.Note, paths map[int64]string, filter NoteFilter) []*SearchHit {
+	terms := []*fuzzyTextExpr{}
+	expr := fuzzyQuery(filter.Query, index, false, &terms)
+	hits := []*SearchHit{}
+	for _, note := range notes {
+		if !expr.matches(note, paths) {
+			continue
+		}
+		rank := 0.0
+		matched := []*textExpr{}
+		for _, term := range terms {
+			rank += term.scores[note.ID]
+			for _, word := range term.words[note.ID] {
+				matched = append(matched, &textExpr{tokens: []string{word}})
+			}
+		}
+		hits = append(hits, &SearchHit{Note: note, Rank: rank, Title: highlight(note.Title, matched), Snippet: snippet(note.Memo, matched)})
+	}
+	return hits
+}
+
+//searchFuzzySQL implements fuzzy searches of the sql backends. The notes of the corpus of cache matching the ids,
+//notebooks and tags of filter are matched in memory.
+func searchFuzzySQL(noteRepo NoteRepository, handle dbHandle, cache *fuzzyCache, filter NoteFilter, query NoteQuery) (*SearchPage, error) {
+	corpus, err := cache.get(noteRepo, handle)
+	if err != nil {
+		return nil, err
+	}
+	notes := []*model.Note{}
+	for _, note := range corpus.notes {
+		if matchesFilter(note, NoteFilter{IDs: filter.IDs, NotebookIDs: filter.NotebookIDs, Tags: filter.Tags, Trashed: filter.Trashed}, corpus.paths) {
+			notes = append(notes, copyNote(note))
+		}
+	}
+	return pageHits(searchFuzzy(corpus.index, notes, corpus.paths, filter), query), nil
+}
+
+//get returns the corpus of the current change generation of the DB, it is rebuilt if the DB changed since the
+//cached corpus was built. The changes of a transaction may be rolled back, so transactions and nil caches
+//always build a new corpus.
+func (cache *fuzzyCache) get(noteRepo NoteRepository, handle dbHandle) (*fuzzyCorpus, error) {
+	var generation int64
+	if err := handle.Get(&generation, "SELECT value FROM change_generation"); err != nil {
+		return nil, err
+	}
+	if _, ok := handle.(*sqlx.Tx); ok || cache == nil {
+		return newFuzzyCorpus(noteRepo, handle, generation)
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.corpus == nil || cache.corpus.generation != generation {
+		corpus, err := newFuzzyCorpus(noteRepo, handle, generation)
+		if err != nil {
+			return nil, err
+		}
+		cache.corpus = corpus
+	}
+	return cache.corpus, nil
+}
+
+//newFuzzyCorpus reads every note and notebook of the DB and indexes them.
+func newFuzzyCorpus(noteRepo NoteRepository, handle dbHandle, generation int64) (*fuzzyCorpus, error) {
+	notes := []*model.Note{}
+	for _, trashed := range []bool{false, true} {
+		page, err := noteRepo.ListNotes(NoteFilter{Trashed: trashed}, NoteQuery{})
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, page.Notes...)
+	}
+	notebooks := []*model.Notebook{}
+	if err := handle.Select(&notebooks, "SELECT id, title, parent_id, deleted_at FROM notebook"); err != nil {
+		return nil, err
+	}
+	paths := activeNotebookPaths(notebooks)
+	return &fuzzyCorpus{generation: generation, notes: notes, paths: paths, index: newFuzzyIndex(notes, paths)}, nil
+}
+
+//trigrams returns the trigrams of word padded with spaces, so that short words have trigrams too.
+func trigrams(word string) []string {
+	runes := []rune("  " + word + " ")
+	trigrams := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		trigrams = append(trigrams, string(runes[i:i+3]))
+	}
+	return trigrams
+}
+
+//similarity returns how similar word is to the searched token from 0, not similar, to 1, the same word.
+//Words containing the token and misspellings of the token are similar, the longer the token the more
+//misspelled it can be.
+func similarity(token, word string) float64 {
+	if token == word {
+		return 1
+	}
+	t, w := []rune(token), []rune(word)
+	if len(t) >= fuzzyMinSubstring && strings.Contains(word, token) {
+		return 0.5 + 0.5*float64(len(t))/float64(len(w))
+	}
+	maxDistance := maxEditDistance(len(t))
+	if len(w)-len(t) > maxDistance || len(t)-len(w) > maxDistance {
+		return 0
+	}
+	distance := editDistance(t, w)
+	if distance > maxDistance {
+		return 0
+	}
+	longest := len(t)
+	if len(w) > longest {
+		longest = len(w)
+	}
+	return 1 - float64(distance)/float64(longest)
+}
+
+//maxEditDistance returns the number of typos allowed in a token of length runes.
+func maxEditDistance(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	}
+	return 2
+}
+
+//editDistance returns the number of insertions, deletions, substitutions and transpositions of adjacent
+//runes that turn a into b.
+func editDistance(a, b []rune) int {
+	//rows i-2, i-1 and i of the distances between the prefixes of a and b
+	previous2 := make([]int, len(b)+1)
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && previous2[j-2]+1 < current[j] {
+				current[j] = previous2[j-2] + 1
+			}
+		}
+		previous2, previous, current = previous, current, previous2
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"kubernetes", "kubernetes", 0},
+		{"kubernets", "kubernetes", 1},
+		{"kuberentes", "kubernetes", 1},
+		{"kubrenetse", "kubernetes", 2},
+		{"", "go", 2},
+		{"café", "cafe", 1},
+	}
+	for _, c := range cases {
+		if distance := editDistance([]rune(c.a), []rune(c.b)); distance != c.expected {
+			t.Errorf("Expected edit distance of %q and %q to be %v, got: %v", c.a, c.b, c.expected, distance)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	cases := []struct {
+		token, word string
+		similar     bool
+	}{
+		{"go", "go", true},
+		{"go", "golang", false},
+		{"kube", "kubernetes", true},
+		{"bern", "kubernetes", true},
+		{"gp", "go", false},
+		{"tesd", "test", true},
+		{"tsst", "tent", false},
+		{"kubrenetse", "kubernetes", true},
+		{"kubernetes", "kube", false},
+	}
+	for _, c := range cases {
+		if score := similarity(c.token, c.word); (score > 0) != c.similar {
+			t.Errorf("Expected similarity of %q to %q to be positive: %v, got: %v", c.token, c.word, c.similar, score)
+		}
+	}
+	if similarity("kubernets", "kubernetes") <= similarity("kube", "kubernetes") {
+		t.Errorf("Expected misspelled word to be more similar than part of the word")
+	}
+}
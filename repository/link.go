@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicolasmanic/tefter/model"
+)
+
+//insertLinks stores the wiki links of the memo of the note, replacing the stored ones. Links by id are stored
+//in target_id and links by title in target_title, so that links to notes that don't exist yet are kept.
+func insertLinks(tx *sqlx.Tx, noteID int64, memo string) error {
+	if _, err := tx.Exec(tx.Rebind("DELETE FROM note_link WHERE note_id = ?"), noteID); err != nil {
+		return err
+	}
+	for _, link := range model.ParseLinks(memo) {
+		if _, err := tx.Exec(tx.Rebind("INSERT INTO note_link (note_id, target_id, target_title) VALUES (?, ?, ?)"),
+			noteID, link.NoteID, link.Title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//fillLinks stores the links of every note, migrations that add the link table run it since memos can
+//not be parsed in SQL.
+func fillLinks(tx *sqlx.Tx) error {
+	notes := []*model.Note{}
+	if err := tx.Select(&notes, "SELECT id, memo FROM note"); err != nil {
+		return err
+	}
+	for _, note := range notes {
+		if err := insertLinks(tx, note.ID, note.Memo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//getLinks implements GetLinks with the GetNote and GetNotes of noteRepo, notes in the trash are skipped by GetNotes.
+func getLinks(noteRepo NoteRepository, handle dbHandle, noteID int64) ([]*model.Note, error) {
+	if _, err := noteRepo.GetNote(noteID); err != nil {
+		return nil, err
+	}
+	noteIDs := []int64{}
+	err := handle.Select(&noteIDs, handle.Rebind(`SELECT DISTINCT n.id FROM note_link l
+		JOIN note n ON n.id = l.target_id OR (l.target_title <> '' AND n.title = l.target_title)
+		WHERE l.note_id = ?`), noteID)
+	if err != nil {
+		return nil, fmt.Errorf("Could not retrieve links, error msg: %v", err)
+	}
+	if len(noteIDs) == 0 {
+		return []*model.Note{}, nil
+	}
+	return noteRepo.GetNotes(noteIDs)
+}
+
+//getBacklinks implements GetBacklinks with the GetNote and GetNotes of noteRepo.
+func getBacklinks(noteRepo NoteRepository, handle dbHandle, noteID int64) ([]*model.Note, error) {
+	note, err := noteRepo.GetNote(noteID)
+	if err != nil {
+		return nil, err
+	}
+	noteIDs := []int64{}
+	err = handle.Select(&noteIDs, handle.Rebind(`SELECT DISTINCT note_id FROM note_link
+		WHERE target_id = ? OR (target_title <> '' AND target_title = ?)`), note.ID, note.Title)
+	if err != nil {
+		return nil, fmt.Errorf("Could not retrieve backlinks, error msg: %v", err)
+	}
+	if len(noteIDs) == 0 {
+		return []*model.Note{}, nil
+	}
+	return noteRepo.GetNotes(noteIDs)
+}
+
+//linkedNotes returns the notes of candidates that the memo of note links to, used by the memory DB.
+func linkedNotes(note *model.Note, candidates []*model.Note) []*model.Note {
+	links := model.ParseLinks(note.Memo)
+	linked := []*model.Note{}
+	for _, candidate := range candidates {
+		if linksToNote(links, candidate) {
+			linked = append(linked, candidate)
+		}
+	}
+	return linked
+}
+
+//backlinkingNotes returns the notes of candidates whose memo links to note, used by the memory DB.
+func backlinkingNotes(note *model.Note, candidates []*model.Note) []*model.Note {
+	backlinks := []*model.Note{}
+	for _, candidate := range candidates {
+		if linksToNote(model.ParseLinks(candidate.Memo), note) {
+			backlinks = append(backlinks, candidate)
+		}
+	}
+	return backlinks
+}
+
+func linksToNote(links []model.Link, note *model.Note) bool {
+	for _, link := range links {
+		if link.LinksTo(note) {
+			return true
+		}
+	}
+	return false
+}
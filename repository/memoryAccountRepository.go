@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"github.com/nicolasmanic/tefter/model"
+	"sort"
+)
+
+type memoryAccountRepository struct {
+	*memoryDB
+}
+
+func (accountRepo *memoryAccountRepository) CreateAccount(username string, password []byte) error {
+	if username == "" || len(password) == 0 {
+		return newError(ErrValidation, "Username or/and password are empty")
+	}
+	accountRepo.Lock()
+	defer accountRepo.Unlock()
+	if _, ok := accountRepo.accounts[username]; ok {
+		return newError(ErrAccountExists, "Account for username: %v already exists", username)
+	}
+	accountRepo.accounts[username] = string(password)
+	return nil
+}
+
+func (accountRepo *memoryAccountRepository) GetAccount(username string) (*model.Account, error) {
+	accountRepo.RLock()
+	defer accountRepo.RUnlock()
+	password, ok := accountRepo.accounts[username]
+	if !ok {
+		return nil, newError(ErrAccountNotFound, "No account found for username: %v", username)
+	}
+	return &model.Account{Username: username, Password: password}, nil
+}
+
+func (accountRepo *memoryAccountRepository) DeleteAccount(username string) error {
+	accountRepo.Lock()
+	defer accountRepo.Unlock()
+	delete(accountRepo.accounts, username)
+	return nil
+}
+
+func (accountRepo *memoryAccountRepository) GetUsernames() ([]string, error) {
+	accountRepo.RLock()
+	defer accountRepo.RUnlock()
+	usernames := make([]string, 0, len(accountRepo.accounts))
+	for username := range accountRepo.accounts {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	return usernames, nil
+}
+
+func (accountRepo *memoryAccountRepository) CloseDB() error {
+	return nil
+}
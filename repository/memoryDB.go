@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"github.com/nicolasmanic/tefter/model"
+	"sync"
+)
+
+//memoryDB keeps notes, notebooks, attachments and accounts in memory, it is shared between the memory repositories
+//the same way a DB file is shared between the sqlite repositories. Nothing is persisted.
+type memoryDB struct {
+	sync.RWMutex
+	notes            map[int64]*model.Note
+	notebooks        map[int64]*model.Notebook
+	accounts         map[string]string
+	revisions        map[int64][]*model.Revision
+	attachments      map[int64]*model.Attachment
+	lastNoteID       int64
+	lastNotebookID   int64
+	lastAttachmentID int64
+}
+
+//memoryStore hands out repositories sharing the same memoryDB.
+type memoryStore struct {
+	db *memoryDB
+}
+
+//NewMemoryStore returns a Store keeping everything in memory. Useful for tests and for servers
+//that should not persist anything.
+func NewMemoryStore() Store {
+	return &memoryStore{newMemoryDB()}
+}
+
+//NewMemoryRepositories returns a NoteRepository, a NotebookRepository and a AccountRepository
+//sharing the same in memory DB.
+func NewMemoryRepositories() (NoteRepository, NotebookRepository, AccountRepository) {
+	store := NewMemoryStore()
+	return store.Notes(), store.Notebooks(), store.Accounts()
+}
+
+func (store *memoryStore) Notes() NoteRepository {
+	return &memoryNoteRepository{store.db}
+}
+
+func (store *memoryStore) Notebooks() NotebookRepository {
+	return &memoryNotebookRepository{store.db}
+}
+
+func (store *memoryStore) Accounts() AccountRepository {
+	return &memoryAccountRepository{store.db}
+}
+
+//WithTx runs fn on a copy of the DB and keeps the copy only if fn succeeds. The DB is locked
+//meanwhile, so fn must only use the repositories of tx.
+func (store *memoryStore) WithTx(fn func(tx Store) error) error {
+	store.db.Lock()
+	defer store.db.Unlock()
+	snapshot := store.db.clone()
+	if err := fn(&memoryStore{snapshot}); err != nil {
+		return err
+	}
+	store.db.notes = snapshot.notes
+	store.db.notebooks = snapshot.notebooks
+	store.db.accounts = snapshot.accounts
+	store.db.revisions = snapshot.revisions
+	store.db.attachments = snapshot.attachments
+	store.db.lastNoteID = snapshot.lastNoteID
+	store.db.lastNotebookID = snapshot.lastNotebookID
+	store.db.lastAttachmentID = snapshot.lastAttachmentID
+	return nil
+}
+
+func (store *memoryStore) Close() error {
+	return nil
+}
+
+func newMemoryDB() *memoryDB {
+	db := &memoryDB{
+		notes:       make(map[int64]*model.Note),
+		notebooks:   make(map[int64]*model.Notebook),
+		accounts:    make(map[string]string),
+		revisions:   make(map[int64][]*model.Revision),
+		attachments: make(map[int64]*model.Attachment),
+	}
+	db.notebooks[DEFAULT_NOTEBOOK_ID] = &model.Notebook{ID: DEFAULT_NOTEBOOK_ID, Title: "Default Notebook"}
+	db.lastNotebookID = DEFAULT_NOTEBOOK_ID
+	return db
+}
+
+//clone returns a deep copy of db, must be called while holding the lock.
+func (db *memoryDB) clone() *memoryDB {
+	dbCopy := &memoryDB{
+		notes:            make(map[int64]*model.Note, len(db.notes)),
+		notebooks:        make(map[int64]*model.Notebook, len(db.notebooks)),
+		accounts:         make(map[string]string, len(db.accounts)),
+		revisions:        make(map[int64][]*model.Revision, len(db.revisions)),
+		attachments:      make(map[int64]*model.Attachment, len(db.attachments)),
+		lastNoteID:       db.lastNoteID,
+		lastNotebookID:   db.lastNotebookID,
+		lastAttachmentID: db.lastAttachmentID,
+	}
+	for id, note := range db.notes {
+		dbCopy.notes[id] = copyNote(note)
+	}
+	for id, notebook := range db.notebooks {
+		dbCopy.notebooks[id] = &model.Notebook{ID: notebook.ID, Title: notebook.Title, ParentID: notebook.ParentID, DeletedAt: notebook.DeletedAt}
+	}
+	for username, password := range db.accounts {
+		dbCopy.accounts[username] = password
+	}
+	//revisions are never modified once added, only the slices need copying
+	for id, revisions := range db.revisions {
+		dbCopy.revisions[id] = append([]*model.Revision{}, revisions...)
+	}
+	for id, attachment := range db.attachments {
+		dbCopy.attachments[id] = copyAttachment(attachment)
+	}
+	return dbCopy
+}
+
+//purgeNote deletes the note along with its revisions and attachments, must be called while holding the lock.
+func (db *memoryDB) purgeNote(noteID int64) {
+	delete(db.notes, noteID)
+	delete(db.revisions, noteID)
+	for id, attachment := range db.attachments {
+		if attachment.NoteID == noteID {
+			delete(db.attachments, id)
+		}
+	}
+}
+
+//restoreAncestors takes the ancestors of the notebook out of the trash, must be called while holding the lock.
+func (db *memoryDB) restoreAncestors(notebookID int64) {
+	for notebook, ok := db.notebooks[notebookID]; ok && notebook.ParentID != 0; notebook, ok = db.notebooks[notebook.ParentID] {
+		if parent, ok := db.notebooks[notebook.ParentID]; ok {
+			parent.DeletedAt = nil
+		}
+	}
+}
+
+//activeNotebookPaths returns the path of every notebook out of the trash, must be called while holding the lock.
+func (db *memoryDB) activeNotebookPaths() map[int64]string {
+	notebooks := make([]*model.Notebook, 0, len(db.notebooks))
+	for _, notebook := range db.notebooks {
+		notebooks = append(notebooks, notebook)
+	}
+	return activeNotebookPaths(notebooks)
+}
+
+//copyNote returns a deep copy of note so that callers can not modify the stored notes.
+func copyNote(note *model.Note) *model.Note {
+	noteCopy := *note
+	noteCopy.Tags = make(map[string]bool, len(note.Tags))
+	for tag := range note.Tags {
+		noteCopy.Tags[tag] = true
+	}
+	return &noteCopy
+}
+
+//matchesKeyword returns true if every term of keyword is a token of the title or memo of the note.
+//A term ending with * matches every token starting with it.
+func matchesKeyword(note *model.Note, keyword string) bool {
+	expr := keywordQuery(keyword)
+	return expr != nil && expr.matches(note, nil)
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"github.com/nicolasmanic/tefter/model"
+	"testing"
+)
+
+func TestMemoryRepositoriesShareDB(t *testing.T) {
+	noteRepo, notebookRepo, _ := NewMemoryRepositories()
+	notebook := model.NewNotebook("notebook")
+	notebookRepo.SaveNotebook(notebook)
+	noteRepo.SaveNote(model.NewNote("title", "memo", notebook.ID, []string{}))
+
+	notebook, _ = notebookRepo.GetNotebookByTitle("notebook")
+	if len(notebook.Notes) != 1 {
+		t.Error("Note and notebook repositories should share the same DB")
+	}
+}
+
+func TestMemoryNoteIsCopied(t *testing.T) {
+	noteRepo, _, _ := NewMemoryRepositories()
+	note := model.NewNote("title", "memo", DEFAULT_NOTEBOOK_ID, []string{"tag1"})
+	id, _ := noteRepo.SaveNote(note)
+
+	note.UpdateMemo("changed without UpdateNote")
+	note.AddTags([]string{"tag2"})
+	stored, _ := noteRepo.GetNote(id)
+	if stored.Memo != "memo" || len(stored.Tags) != 1 {
+		t.Error("Modifying a saved note should not modify the stored one")
+	}
+}
+
+func TestMatchesKeyword(t *testing.T) {
+	note := model.NewNote("Kubernetes notes", "Deploying pods, services & ingress", DEFAULT_NOTEBOOK_ID, []string{})
+	tests := []struct {
+		keyword  string
+		expected bool
+	}{
+		{"pods", true},
+		{"PODS", true},
+		{"kubernetes ingress", true},
+		{"kube", false},
+		{"kube*", true},
+		{"pods helm", false},
+		{"   ", false},
+	}
+
+	for _, test := range tests {
+		if matchesKeyword(note, test.keyword) != test.expected {
+			t.Errorf("Expected matching %q to be %v", test.keyword, test.expected)
+		}
+	}
+}
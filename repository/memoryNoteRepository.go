@@ -0,0 +1,476 @@
+package repository
+
+import (
+	"github.com/nicolasmanic/tefter/model"
+	"time"
+)
+
+type memoryNoteRepository struct {
+	*memoryDB
+}
+
+//SaveNote keeps a copy of the note in memory. For a note to be valid the memo field must not be empty.
+//Default values are the same as in the sqlite implementation.
+func (noteRepo *memoryNoteRepository) SaveNote(note *model.Note) (int64, error) {
+	if note.Memo == "" {
+		return -1, newError(ErrValidation, "Note should contain memo")
+	}
+	if note.Created.IsZero() {
+		note.Created = time.Now().UTC()
+	}
+	if note.LastUpdated.IsZero() {
+		note.LastUpdated = time.Now().UTC()
+	}
+	//If notebook id is 0 set it to the default notebook.
+	if note.NotebookID == 0 {
+		note.NotebookID = DEFAULT_NOTEBOOK_ID
+	}
+
+	noteRepo.Lock()
+	defer noteRepo.Unlock()
+	noteRepo.lastNoteID++
+	note.ID = noteRepo.lastNoteID
+	noteRepo.notes[note.ID] = copyNote(note)
+	noteRepo.addRevision(note)
+	return note.ID, nil
+}
+
+//GetNotes return a slice of notes based on the given slice of ids,
+//if ids slice is empty all notes are returned
+func (noteRepo *memoryNoteRepository) GetNotes(noteIDs []int64) ([]*model.Note, error) {
+	page, err := noteRepo.ListNotes(NoteFilter{IDs: noteIDs}, NoteQuery{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Notes, nil
+}
+
+//ListNotes returns a page of the notes matching filter, see NoteQuery for paging and sorting.
+func (noteRepo *memoryNoteRepository) ListNotes(filter NoteFilter, query NoteQuery) (*NotePage, error) {
+	return noteRepo.listNotes(filter, query)
+}
+
+//GetNote returns a single note based on an id, returns error if note with id doesn't exist
+func (noteRepo *memoryNoteRepository) GetNote(noteID int64) (*model.Note, error) {
+	notes, err := noteRepo.GetNotes([]int64{noteID})
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) != 1 {
+		return nil, newError(ErrNoteNotFound, "Could find note with id: %v", noteID)
+	}
+	return notes[0], nil
+}
+
+//UpdateNote updates an existing note. For a note to be valid the memo field must not be empty.
+func (noteRepo *memoryNoteRepository) UpdateNote(note *model.Note) error {
+	if note.Memo == "" {
+		return newError(ErrValidation, "Note should contain memo")
+	}
+	if note.Created.IsZero() {
+		note.Created = time.Now().UTC()
+	}
+	if note.LastUpdated.IsZero() {
+		note.LastUpdated = time.Now().UTC()
+	}
+
+	noteRepo.Lock()
+	defer noteRepo.Unlock()
+	//Same as an UPDATE statement, updating a not existing note is a no-op.
+	if stored, ok := noteRepo.notes[note.ID]; ok {
+		if stored.Title != note.Title || stored.Memo != note.Memo {
+			noteRepo.addRevision(note)
+		}
+		noteRepo.notes[note.ID] = copyNote(note)
+	}
+	return nil
+}
+
+//addRevision appends the title and memo of note to its revisions, must be called while holding the lock.
+func (noteRepo *memoryNoteRepository) addRevision(note *model.Note) {
+	revisions := noteRepo.revisions[note.ID]
+	noteRepo.revisions[note.ID] = append(revisions, &model.Revision{
+		NoteID:  note.ID,
+		Number:  len(revisions) + 1,
+		Title:   note.Title,
+		Memo:    note.Memo,
+		Created: note.LastUpdated,
+	})
+}
+
+//GetRevisions returns the revisions of a note oldest first, see NoteRepository.
+func (noteRepo *memoryNoteRepository) GetRevisions(noteID int64) ([]*model.Revision, error) {
+	noteRepo.RLock()
+	defer noteRepo.RUnlock()
+	revisions, ok := noteRepo.revisions[noteID]
+	if !ok {
+		return nil, newError(ErrNoteNotFound, "Could find note with id: %v", noteID)
+	}
+	revisionsCopy := make([]*model.Revision, 0, len(revisions))
+	for _, revision := range revisions {
+		revisionCopy := *revision
+		revisionsCopy = append(revisionsCopy, &revisionCopy)
+	}
+	return revisionsCopy, nil
+}
+
+//GetRevision returns a revision of a note, returns error if the note or the revision doesn't exist
+func (noteRepo *memoryNoteRepository) GetRevision(noteID int64, revision int) (*model.Revision, error) {
+	revisions, err := noteRepo.GetRevisions(noteID)
+	if err != nil {
+		return nil, err
+	}
+	if revision < 1 || revision > len(revisions) {
+		return nil, newError(ErrRevisionNotFound, "Could not find revision %v of note with id: %v", revision, noteID)
+	}
+	return revisions[revision-1], nil
+}
+
+//RestoreRevision sets the title and memo of a note to those of the revision, see NoteRepository.
+func (noteRepo *memoryNoteRepository) RestoreRevision(noteID int64, revision int) (*model.Note, error) {
+	return restoreRevision(noteRepo, noteID, revision)
+}
+
+//DeleteNotes moves the notes to the trash, see PurgeNotes for deleting them for good.
+func (noteRepo *memoryNoteRepository) DeleteNotes(noteIDs []int64) error {
+	deletedAt := time.Now().UTC()
+	noteRepo.Lock()
+	defer noteRepo.Unlock()
+	for _, id := range noteIDs {
+		//notes already in the trash keep their deletion time
+		if note, ok := noteRepo.notes[id]; ok && note.DeletedAt == nil {
+			note.DeletedAt = &deletedAt
+		}
+	}
+	return nil
+}
+
+func (noteRepo *memoryNoteRepository) DeleteNote(noteID int64) error {
+	return noteRepo.DeleteNotes([]int64{noteID})
+}
+
+//RestoreNotes takes the notes out of the trash, returns error if any of the notes is not in the trash.
+func (noteRepo *memoryNoteRepository) RestoreNotes(noteIDs []int64) error {
+	noteIDs = removeDups(noteIDs)
+	noteRepo.Lock()
+	defer noteRepo.Unlock()
+	missing := []int64{}
+	for _, id := range noteIDs {
+		if note, ok := noteRepo.notes[id]; !ok || note.DeletedAt == nil {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return newError(ErrNoteNotFound, "Could not find notes with ids: %v in the trash", missing)
+	}
+	for _, id := range noteIDs {
+		note := noteRepo.notes[id]
+		note.DeletedAt = nil
+		if notebook, ok := noteRepo.notebooks[note.NotebookID]; ok {
+			notebook.DeletedAt = nil
+			noteRepo.restoreAncestors(notebook.ID)
+		}
+	}
+	return nil
+}
+
+//PurgeNotes deletes for good the notes moved to the trash before deletedBefore, along with their revisions
+//and attachments.
+func (noteRepo *memoryNoteRepository) PurgeNotes(deletedBefore time.Time) (int, error) {
+	noteRepo.Lock()
+	defer noteRepo.Unlock()
+	purged := 0
+	for id, note := range noteRepo.notes {
+		if note.DeletedAt != nil && note.DeletedAt.Before(deletedBefore) {
+			noteRepo.purgeNote(id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+//SearchNotesByKeyword searches title and memo of notes for every word of keyword. Keyword cannot be empty,
+//words must be complete unless they end with *. Notes are sorted by relevance.
+func (noteRepo *memoryNoteRepository) SearchNotesByKeyword(keyword string) ([]*model.Note, error) {
+	return searchNotesByKeyword(noteRepo, keyword)
+}
+
+//SearchNotes returns a page of the notes matching filter ranked by the text terms of filter.Query,
+//see NoteRepository.
+func (noteRepo *memoryNoteRepository) SearchNotes(filter NoteFilter, query NoteQuery) (*SearchPage, error) {
+	terms := searchTerms(filter.Query)
+	if err := query.normalizeSearch(len(terms) > 0); err != nil {
+		return nil, err
+	}
+	noteRepo.RLock()
+	defer noteRepo.RUnlock()
+
+	paths := noteRepo.activeNotebookPaths()
+	if filter.Fuzzy && len(terms) > 0 {
+		notes := []*model.Note{}
+		for _, note := range noteRepo.notes {
+			if matchesFilter(note, NoteFilter{IDs: filter.IDs, NotebookIDs: filter.NotebookIDs, Tags: filter.Tags, Trashed: filter.Trashed}, paths) {
+				notes = append(notes, copyNote(note))
+			}
+		}
+		return pageHits(searchFuzzy(newFuzzyIndex(notes, paths), notes, paths, filter), query), nil
+	}
+
+	//every note counts for the statistics of the ranking, not only the notes matching filter
+	type candidate struct {
+		note   *model.Note
+		freqs  []termFrequency
+		length int
+	}
+	candidates := []candidate{}
+	stats := rankStats{docFreq: make([]int, len(terms))}
+	totalLength := 0
+	for _, note := range noteRepo.notes {
+		titleTokens, memoTokens := tokenize(note.Title), tokenize(note.Memo)
+		freqs := make([]termFrequency, len(terms))
+		for i, term := range terms {
+			freqs[i] = termFrequency{title: term.count(titleTokens), memo: term.count(memoTokens)}
+			if freqs[i].title+freqs[i].memo > 0 {
+				stats.docFreq[i]++
+			}
+		}
+		stats.notes++
+		totalLength += len(titleTokens) + len(memoTokens)
+		if matchesFilter(note, filter, paths) {
+			candidates = append(candidates, candidate{note, freqs, len(titleTokens) + len(memoTokens)})
+		}
+	}
+	if stats.notes > 0 {
+		stats.avgLength = float64(totalLength) / float64(stats.notes)
+	}
+
+	hits := make([]*SearchHit, 0, len(candidates))
+	for _, c := range candidates {
+		note := copyNote(c.note)
+		hits = append(hits, &SearchHit{
+			Note:    note,
+			Rank:    bm25(c.freqs, c.length, stats),
+			Title:   highlight(note.Title, terms),
+			Snippet: snippet(note.Memo, terms),
+		})
+	}
+	return pageHits(hits, query), nil
+}
+
+//GetLinks returns the notes out of the trash that the memo of the note links to, see NoteRepository.
+func (noteRepo *memoryNoteRepository) GetLinks(noteID int64) ([]*model.Note, error) {
+	note, err := noteRepo.GetNote(noteID)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := noteRepo.GetNotes([]int64{})
+	if err != nil {
+		return nil, err
+	}
+	return linkedNotes(note, notes), nil
+}
+
+//GetBacklinks returns the notes out of the trash whose memo links to the note.
+func (noteRepo *memoryNoteRepository) GetBacklinks(noteID int64) ([]*model.Note, error) {
+	note, err := noteRepo.GetNote(noteID)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := noteRepo.GetNotes([]int64{})
+	if err != nil {
+		return nil, err
+	}
+	return backlinkingNotes(note, notes), nil
+}
+
+//AddAttachment attaches a file to a note out of the trash, see NoteRepository.
+func (noteRepo *memoryNoteRepository) AddAttachment(attachment *model.Attachment) (int64, error) {
+	if attachment.Name == "" {
+		return -1, newError(ErrValidation, "Attachment should have a name")
+	}
+	if _, err := noteRepo.GetNote(attachment.NoteID); err != nil {
+		return -1, err
+	}
+	attachment.Hash = model.ContentHash(attachment.Content)
+	attachment.Size = int64(len(attachment.Content))
+	if attachment.Created.IsZero() {
+		attachment.Created = time.Now().UTC()
+	}
+
+	noteRepo.Lock()
+	defer noteRepo.Unlock()
+	noteRepo.lastAttachmentID++
+	attachment.ID = noteRepo.lastAttachmentID
+	noteRepo.attachments[attachment.ID] = copyAttachment(attachment)
+	return attachment.ID, nil
+}
+
+//GetAttachments returns the attachments of the note without their content, oldest first.
+func (noteRepo *memoryNoteRepository) GetAttachments(noteID int64) ([]*model.Attachment, error) {
+	if _, err := noteRepo.GetNote(noteID); err != nil {
+		return nil, err
+	}
+	noteRepo.RLock()
+	defer noteRepo.RUnlock()
+	attachments := []*model.Attachment{}
+	for _, attachment := range noteRepo.attachments {
+		if attachment.NoteID == noteID {
+			attachmentCopy := copyAttachment(attachment)
+			attachmentCopy.Content = nil
+			attachments = append(attachments, attachmentCopy)
+		}
+	}
+	sortAttachments(attachments)
+	return attachments, nil
+}
+
+//GetAttachment returns an attachment with its content, returns error if it doesn't exist or its note is in the trash.
+func (noteRepo *memoryNoteRepository) GetAttachment(attachmentID int64) (*model.Attachment, error) {
+	noteRepo.RLock()
+	defer noteRepo.RUnlock()
+	attachment, ok := noteRepo.attachments[attachmentID]
+	if ok {
+		note, ok := noteRepo.notes[attachment.NoteID]
+		if ok && note.DeletedAt == nil {
+			return copyAttachment(attachment), nil
+		}
+	}
+	return nil, newError(ErrAttachmentNotFound, "Could not find attachment with id: %v", attachmentID)
+}
+
+//GetNotesByTag returns all notes tagged with one or more of tags given as inputs or their descendants, eg: lang/go for lang
+func (noteRepo *memoryNoteRepository) GetNotesByTag(tags []string) ([]*model.Note, error) {
+	if len(tags) == 0 {
+		return []*model.Note{}, nil
+	}
+	page, err := noteRepo.ListNotes(NoteFilter{Tags: tags}, NoteQuery{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Notes, nil
+}
+
+//ListTags returns the tags of the notes out of the trash with the number of notes tagged with them, sorted by tag.
+func (noteRepo *memoryNoteRepository) ListTags() ([]*TagCount, error) {
+	noteRepo.RLock()
+	defer noteRepo.RUnlock()
+	counts := make(map[string]*TagCount)
+	tags := []*TagCount{}
+	for _, note := range noteRepo.notes {
+		if note.DeletedAt != nil {
+			continue
+		}
+		for tag := range note.Tags {
+			if _, ok := counts[tag]; !ok {
+				counts[tag] = &TagCount{Tag: tag}
+				tags = append(tags, counts[tag])
+			}
+			counts[tag].Count++
+		}
+	}
+	sortTagCounts(tags)
+	return tags, nil
+}
+
+//RenameTag renames the tag on every note and returns the number of renamed notes, see NoteRepository.
+func (noteRepo *memoryNoteRepository) RenameTag(oldTag, newTag string) (int, error) {
+	oldTag, newTag, err := renameTagArgs(oldTag, newTag)
+	if err != nil {
+		return 0, err
+	}
+	noteRepo.Lock()
+	defer noteRepo.Unlock()
+	used := noteRepo.usedTags()
+	if err := missingTags([]string{oldTag}, used); err != nil {
+		return 0, err
+	}
+	if used[newTag] {
+		return 0, newError(ErrTagExists, "Tag: %v is already in use, merge the tags instead", newTag)
+	}
+	return noteRepo.replaceTags([]string{oldTag}, newTag), nil
+}
+
+//MergeTags replaces every one of tags with into and returns the number of merged notes, see NoteRepository.
+func (noteRepo *memoryNoteRepository) MergeTags(tags []string, into string) (int, error) {
+	tags, into, err := mergeTagsArgs(tags, into)
+	if err != nil {
+		return 0, err
+	}
+	noteRepo.Lock()
+	defer noteRepo.Unlock()
+	if err := missingTags(tags, noteRepo.usedTags()); err != nil {
+		return 0, err
+	}
+	return noteRepo.replaceTags(tags, into), nil
+}
+
+//DeleteTag removes the tag from every note and returns the number of notes it was removed from.
+func (noteRepo *memoryNoteRepository) DeleteTag(tag string) (int, error) {
+	tag = model.NormalizeTag(tag)
+	noteRepo.Lock()
+	defer noteRepo.Unlock()
+	deleted := 0
+	for _, note := range noteRepo.notes {
+		if note.Tags[tag] {
+			delete(note.Tags, tag)
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return 0, newError(ErrTagNotFound, "Could not find notes tagged with: %v", tag)
+	}
+	return deleted, nil
+}
+
+//usedTags returns the tags of every note, in the trash or not. Must be called while holding the lock.
+func (noteRepo *memoryNoteRepository) usedTags() map[string]bool {
+	used := make(map[string]bool)
+	for _, note := range noteRepo.notes {
+		for tag := range note.Tags {
+			used[tag] = true
+		}
+	}
+	return used
+}
+
+//replaceTags replaces the tags with into on every note and returns the number of notes tagged with any of tags.
+//Must be called while holding the lock.
+func (noteRepo *memoryNoteRepository) replaceTags(tags []string, into string) int {
+	replaced := 0
+	for _, note := range noteRepo.notes {
+		found := false
+		for _, tag := range tags {
+			if note.Tags[tag] {
+				delete(note.Tags, tag)
+				found = true
+			}
+		}
+		if found {
+			note.Tags[into] = true
+			replaced++
+		}
+	}
+	return replaced
+}
+
+//listNotes returns a page of copies of the notes matching filter.
+func (noteRepo *memoryNoteRepository) listNotes(filter NoteFilter, query NoteQuery) (*NotePage, error) {
+	if err := query.normalizeList(); err != nil {
+		return nil, err
+	}
+	noteRepo.RLock()
+	defer noteRepo.RUnlock()
+
+	paths := noteRepo.activeNotebookPaths()
+	notes := []*model.Note{}
+	for _, note := range noteRepo.notes {
+		if matchesFilter(note, filter, paths) {
+			notes = append(notes, copyNote(note))
+		}
+	}
+	return pageNotes(notes, query), nil
+}
+
+func (noteRepo *memoryNoteRepository) CloseDB() error {
+	return nil
+}
@@ -0,0 +1,307 @@
+package repository
+
+import (
+	"github.com/nicolasmanic/tefter/model"
+	"sort"
+	"time"
+)
+
+type memoryNotebookRepository struct {
+	*memoryDB
+}
+
+//SaveNotebook saves a notebook under the notebook with id notebook.ParentID, or at the top level if it is 0.
+func (notebookRepo *memoryNotebookRepository) SaveNotebook(notebook *model.Notebook) (int64, error) {
+	if err := validateNotebookTitle(notebook.Title); err != nil {
+		return -1, err
+	}
+	notebookRepo.Lock()
+	defer notebookRepo.Unlock()
+	if err := notebookRepo.checkParent(notebook); err != nil {
+		return -1, err
+	}
+	if existing := notebookRepo.findChild(notebook.ParentID, notebook.Title); existing != nil {
+		return -1, notebookTitleError(existing.Title, existing.DeletedAt != nil)
+	}
+	notebookRepo.lastNotebookID++
+	notebook.ID = notebookRepo.lastNotebookID
+	notebookRepo.notebooks[notebook.ID] = &model.Notebook{ID: notebook.ID, Title: notebook.Title, ParentID: notebook.ParentID}
+	return notebook.ID, nil
+}
+
+func (notebookRepo *memoryNotebookRepository) GetNotebooks(notebooksIDs []int64) ([]*model.Notebook, error) {
+	notebookRepo.RLock()
+	defer notebookRepo.RUnlock()
+
+	notebooks := []*model.Notebook{}
+	if len(notebooksIDs) == 0 {
+		for _, notebook := range notebookRepo.notebooks {
+			if notebook.DeletedAt == nil {
+				notebooks = append(notebooks, notebookRepo.withNotes(notebook))
+			}
+		}
+	} else {
+		for _, id := range removeDups(notebooksIDs) {
+			if notebook, ok := notebookRepo.notebooks[id]; ok && notebook.DeletedAt == nil {
+				notebooks = append(notebooks, notebookRepo.withNotes(notebook))
+			}
+		}
+	}
+	return notebooks, nil
+}
+
+func (notebookRepo *memoryNotebookRepository) GetNotebook(notebookID int64) (*model.Notebook, error) {
+	notebooks, err := notebookRepo.GetNotebooks([]int64{notebookID})
+	if err != nil {
+		return nil, err
+	}
+	if len(notebooks) != 1 {
+		return nil, newError(ErrNotebookNotFound, "Could find notebook with id: %v", notebookID)
+	}
+	return notebooks[0], nil
+}
+
+//GetNotebookByTitle returns the notebook at path, eg: work/infra, or nil if no such notebook exists.
+func (notebookRepo *memoryNotebookRepository) GetNotebookByTitle(path string) (*model.Notebook, error) {
+	notebookRepo.RLock()
+	defer notebookRepo.RUnlock()
+	notebook := notebookRepo.resolvePath(path)
+	if notebook == nil {
+		return nil, nil
+	}
+	return notebookRepo.withNotes(notebook), nil
+}
+
+//UpdateNotebook sets the title and the parent of an existing notebook.
+func (notebookRepo *memoryNotebookRepository) UpdateNotebook(notebook *model.Notebook) error {
+	if err := validateNotebookTitle(notebook.Title); err != nil {
+		return err
+	}
+	notebookRepo.Lock()
+	defer notebookRepo.Unlock()
+	if err := notebookRepo.checkParent(notebook); err != nil {
+		return err
+	}
+	if existing := notebookRepo.findChild(notebook.ParentID, notebook.Title); existing != nil && existing.ID != notebook.ID {
+		return notebookTitleError(existing.Title, existing.DeletedAt != nil)
+	}
+	if existing, ok := notebookRepo.notebooks[notebook.ID]; ok && existing.DeletedAt == nil {
+		existing.Title = notebook.Title
+		existing.ParentID = notebook.ParentID
+	}
+	return nil
+}
+
+//DeleteNotebooks moves the notebooks and all of their notes to the trash, the default notebook and notebooks
+//with children that are not deleted along with them can not be deleted.
+func (notebookRepo *memoryNotebookRepository) DeleteNotebooks(notebooksIDs []int64) error {
+	for _, id := range notebooksIDs {
+		if id == DEFAULT_NOTEBOOK_ID {
+			return newError(ErrDefaultNotebookProtected, "Default notebook can not be deleted")
+		}
+	}
+	deletedAt := time.Now().UTC()
+	notebookRepo.Lock()
+	defer notebookRepo.Unlock()
+	deleted := make(map[int64]bool, len(notebooksIDs))
+	for _, id := range notebooksIDs {
+		deleted[id] = true
+	}
+	children := []int64{}
+	for id, notebook := range notebookRepo.notebooks {
+		if deleted[notebook.ParentID] && !deleted[id] && notebook.DeletedAt == nil {
+			children = append(children, id)
+		}
+	}
+	if len(children) > 0 {
+		return newError(ErrNotebookHasChildren, "Notebooks with ids: %v have child notebooks with ids: %v, "+
+			"delete or move the children first", notebooksIDs, children)
+	}
+	for _, notebookID := range notebooksIDs {
+		notebook, ok := notebookRepo.notebooks[notebookID]
+		if !ok || notebook.DeletedAt != nil {
+			continue
+		}
+		notebook.DeletedAt = &deletedAt
+		//notes share the deletion time of their notebook, so that restoring the notebook restores only them
+		for _, note := range notebookRepo.notes {
+			if note.NotebookID == notebookID && note.DeletedAt == nil {
+				note.DeletedAt = &deletedAt
+			}
+		}
+	}
+	return nil
+}
+
+func (notebookRepo *memoryNotebookRepository) DeleteNotebook(notebookID int64) error {
+	return notebookRepo.DeleteNotebooks([]int64{notebookID})
+}
+
+//GetAllNotebooksTitle returns the path of every notebook out of the trash, eg: work/infra.
+func (notebookRepo *memoryNotebookRepository) GetAllNotebooksTitle() (map[int64]string, error) {
+	notebookRepo.RLock()
+	defer notebookRepo.RUnlock()
+	notebooks := make([]*model.Notebook, 0, len(notebookRepo.notebooks))
+	for _, notebook := range notebookRepo.notebooks {
+		if notebook.DeletedAt == nil {
+			notebooks = append(notebooks, notebook)
+		}
+	}
+	return notebookPaths(notebooks), nil
+}
+
+//GetTrashedNotebooks returns the notebooks in the trash with their notes, most recently deleted first.
+func (notebookRepo *memoryNotebookRepository) GetTrashedNotebooks() ([]*model.Notebook, error) {
+	notebookRepo.RLock()
+	defer notebookRepo.RUnlock()
+	notebooks := []*model.Notebook{}
+	for _, notebook := range notebookRepo.notebooks {
+		if notebook.DeletedAt != nil {
+			notebooks = append(notebooks, notebookRepo.withNotes(notebook))
+		}
+	}
+	sort.Slice(notebooks, func(i, j int) bool {
+		if !notebooks[i].DeletedAt.Equal(*notebooks[j].DeletedAt) {
+			return notebooks[i].DeletedAt.After(*notebooks[j].DeletedAt)
+		}
+		return notebooks[i].ID < notebooks[j].ID
+	})
+	return notebooks, nil
+}
+
+//RestoreNotebooks takes the notebooks out of the trash along with their ancestors, returns error if any of the
+//notebooks is not in the trash.
+func (notebookRepo *memoryNotebookRepository) RestoreNotebooks(notebooksIDs []int64) error {
+	notebooksIDs = removeDups(notebooksIDs)
+	notebookRepo.Lock()
+	defer notebookRepo.Unlock()
+	missing := []int64{}
+	for _, id := range notebooksIDs {
+		if notebook, ok := notebookRepo.notebooks[id]; !ok || notebook.DeletedAt == nil {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return newError(ErrNotebookNotFound, "Could not find notebooks with ids: %v in the trash", missing)
+	}
+	//child notebooks deleted along with a notebook are restored with it
+	restored := append([]int64{}, notebooksIDs...)
+	for i := 0; i < len(restored); i++ {
+		parent := notebookRepo.notebooks[restored[i]]
+		for id, notebook := range notebookRepo.notebooks {
+			if notebook.ParentID == parent.ID && notebook.DeletedAt != nil && notebook.DeletedAt.Equal(*parent.DeletedAt) {
+				restored = append(restored, id)
+			}
+		}
+	}
+	for _, id := range restored {
+		notebook := notebookRepo.notebooks[id]
+		for _, note := range notebookRepo.notes {
+			if note.NotebookID == id && note.DeletedAt != nil && note.DeletedAt.Equal(*notebook.DeletedAt) {
+				note.DeletedAt = nil
+			}
+		}
+	}
+	for _, id := range restored {
+		notebookRepo.notebooks[id].DeletedAt = nil
+	}
+	for _, id := range notebooksIDs {
+		notebookRepo.restoreAncestors(id)
+	}
+	return nil
+}
+
+//PurgeNotebooks deletes for good the notebooks moved to the trash before deletedBefore and all of their notes.
+func (notebookRepo *memoryNotebookRepository) PurgeNotebooks(deletedBefore time.Time) (int, error) {
+	notebookRepo.Lock()
+	defer notebookRepo.Unlock()
+	purged := 0
+	for notebookID, notebook := range notebookRepo.notebooks {
+		if notebook.DeletedAt == nil || !notebook.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		for id, note := range notebookRepo.notes {
+			if note.NotebookID == notebookID {
+				notebookRepo.purgeNote(id)
+			}
+		}
+		delete(notebookRepo.notebooks, notebookID)
+		purged++
+	}
+	return purged, nil
+}
+
+func (notebookRepo *memoryNotebookRepository) CloseDB() error {
+	return nil
+}
+
+//findChild returns the child of the notebook with id parentID with title, in the trash or not.
+//Must be called while holding the lock.
+func (notebookRepo *memoryNotebookRepository) findChild(parentID int64, title string) *model.Notebook {
+	for _, notebook := range notebookRepo.notebooks {
+		if notebook.ParentID == parentID && notebook.Title == title {
+			return notebook
+		}
+	}
+	return nil
+}
+
+//resolvePath returns the notebook out of the trash at path or nil, see resolveNotebookPath.
+//Must be called while holding the lock.
+func (notebookRepo *memoryNotebookRepository) resolvePath(path string) *model.Notebook {
+	titles := SplitNotebookPath(path)
+	var notebook *model.Notebook
+	parentID := int64(0)
+	for _, title := range titles {
+		notebook = notebookRepo.findChild(parentID, title)
+		if notebook == nil || notebook.DeletedAt != nil {
+			notebook = nil
+			break
+		}
+		parentID = notebook.ID
+	}
+	if notebook == nil && len(titles) > 1 {
+		notebook = notebookRepo.findChild(0, path)
+	}
+	if notebook == nil || notebook.DeletedAt != nil {
+		return nil
+	}
+	return notebook
+}
+
+//checkParent is the checkNotebookParent of the memory DB, must be called while holding the lock.
+func (notebookRepo *memoryNotebookRepository) checkParent(notebook *model.Notebook) error {
+	if notebook.ParentID == 0 {
+		return nil
+	}
+	if notebook.ID == DEFAULT_NOTEBOOK_ID {
+		return newError(ErrDefaultNotebookProtected, "Default notebook can not be moved under another notebook")
+	}
+	if parent, ok := notebookRepo.notebooks[notebook.ParentID]; !ok || parent.DeletedAt != nil {
+		return newError(ErrNotebookNotFound, "Could find parent notebook with id: %v", notebook.ParentID)
+	}
+	for ancestorID := notebook.ParentID; ancestorID != 0; ancestorID = notebookRepo.notebooks[ancestorID].ParentID {
+		if ancestorID == notebook.ID {
+			return newError(ErrValidation, "Notebook with id: %v can not be moved under itself", notebook.ID)
+		}
+	}
+	return nil
+}
+
+//withNotes returns a copy of notebook containing copies of its notes, must be called while holding the lock.
+func (notebookRepo *memoryNotebookRepository) withNotes(notebook *model.Notebook) *model.Notebook {
+	notebookCopy := &model.Notebook{
+		ID:        notebook.ID,
+		Title:     notebook.Title,
+		ParentID:  notebook.ParentID,
+		Notes:     make(map[int64]*model.Note),
+		DeletedAt: notebook.DeletedAt,
+	}
+	for _, note := range notebookRepo.notes {
+		//a notebook in the trash holds the notes in the trash, any other notebook the notes out of it
+		if note.NotebookID == notebook.ID && (note.DeletedAt != nil) == (notebook.DeletedAt != nil) {
+			notebookCopy.Notes[note.ID] = copyNote(note)
+		}
+	}
+	return notebookCopy
+}
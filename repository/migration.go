@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+//migration is a single versioned step of the DB schema. Every migration must be reversible, down statements
+//should undo everything the up statements did, unless the migration is flagged as irreversible.
+type migration struct {
+	version     int
+	description string
+	up          []string
+	//fill runs after up in the same transaction, for data that can not be migrated in SQL, eg: parsing memos.
+	fill func(tx *sqlx.Tx) error
+	down []string
+	//destructive migrations drop data of the DB when rolled back, eg: notes or attachments, they are only rolled back when forced
+	destructive bool
+	//irreversible migrations have no down statements, rolling back past them keeps their changes and needs force
+	irreversible bool
+}
+
+//MigrationStatus describes a known migration and whether it has been applied to the DB
+type MigrationStatus struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+}
+
+//SchemaVersion is the latest schema version known to this build, DBs are migrated to it while connecting.
+var SchemaVersion = sqliteMigrations[len(sqliteMigrations)-1].version
+
+type sqlMigrator struct {
+	*sqlx.DB
+	migrations []migration
+}
+
+//NewMigrator returns a Migrator interface for the DB at dbPath. Unlike the repositories
+//the migrator does not apply any pending migration while connecting.
+func NewMigrator(dbPath string) Migrator {
+	db := sqlx.MustConnect(databaseDriver, dbPath)
+	return &sqlMigrator{db, sqliteMigrations}
+}
+
+//NewPostgresMigrator returns a Migrator interface for the postgres DB described by dsn.
+func NewPostgresMigrator(dsn string) Migrator {
+	db := sqlx.MustConnect(postgresDriver, dsn)
+	return &sqlMigrator{db, postgresMigrations}
+}
+
+//Migrate applies all pending migrations in order and returns the resulting schema version
+func (migrator *sqlMigrator) Migrate() (int, error) {
+	return migrateUp(migrator.DB, migrator.migrations)
+}
+
+//Rollback reverts the latest applied migration and returns the resulting schema version, destructive and
+//irreversible migrations are only reverted with force
+func (migrator *sqlMigrator) Rollback(force bool) (int, error) {
+	return migrateDown(migrator.DB, migrator.migrations, force)
+}
+
+//Status returns every known migration, flagged as applied or pending
+func (migrator *sqlMigrator) Status() ([]MigrationStatus, error) {
+	if err := createSchemaVersionTable(migrator.DB); err != nil {
+		return nil, err
+	}
+	applied := []struct {
+		Version int       `db:"version"`
+		Applied time.Time `db:"applied"`
+	}{}
+	err := migrator.Select(&applied, "SELECT version, applied FROM schema_version")
+	if err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int]time.Time, len(applied))
+	for _, a := range applied {
+		appliedAt[a.Version] = a.Applied
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrator.migrations))
+	for _, m := range migrator.migrations {
+		at, ok := appliedAt[m.version]
+		statuses = append(statuses, MigrationStatus{
+			Version:     m.version,
+			Description: m.description,
+			Applied:     ok,
+			AppliedAt:   at,
+		})
+	}
+	return statuses, nil
+}
+
+func (migrator *sqlMigrator) CloseDB() error {
+	return migrator.Close()
+}
+
+func createSchemaVersionTable(db *sqlx.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER NOT NULL,
+		description TEXT NOT NULL,
+		applied TIMESTAMP NOT NULL,
+		CONSTRAINT schema_version_PK PRIMARY KEY(version))`)
+	return err
+}
+
+func schemaVersion(db *sqlx.DB) (int, error) {
+	var version int
+	err := db.Get(&version, "SELECT COALESCE(MAX(version), 0) FROM schema_version")
+	return version, err
+}
+
+//migrateUp applies, one transaction per step, every migration newer than the current schema version.
+func migrateUp(db *sqlx.DB, migrations []migration) (int, error) {
+	if err := createSchemaVersionTable(db); err != nil {
+		return 0, fmt.Errorf("Could not create schema_version table, error msg: %v", err)
+	}
+	current, err := schemaVersion(db)
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) > 0 && current > migrations[len(migrations)-1].version {
+		return current, fmt.Errorf("Unknown schema version: %d, DB was migrated by a newer version of tefter", current)
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		err := applyMigration(db, m.up, m.fill,
+			db.Rebind("INSERT INTO schema_version (version, description, applied) VALUES (?, ?, ?)"),
+			m.version, m.description, time.Now().UTC())
+		if err != nil {
+			return current, fmt.Errorf("Migration %d (%v) failed, error msg: %v", m.version, m.description, err)
+		}
+		current = m.version
+	}
+	return current, nil
+}
+
+//migrateDown reverts the latest applied migration, a DB at version 0 can not be rolled back. Destructive migrations,
+//eg: the initial schema whose rollback drops every table, are only reverted with force.
+func migrateDown(db *sqlx.DB, migrations []migration, force bool) (int, error) {
+	if err := createSchemaVersionTable(db); err != nil {
+		return 0, fmt.Errorf("Could not create schema_version table, error msg: %v", err)
+	}
+	current, err := schemaVersion(db)
+	if err != nil {
+		return 0, err
+	}
+	if current == 0 {
+		return 0, fmt.Errorf("Nothing to rollback, DB is at version 0")
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version != current {
+			continue
+		}
+		if m.destructive && !force {
+			return current, newError(ErrDestructiveRollback, "Rolling back migration %d (%v) deletes its data from the DB", m.version, m.description)
+		}
+		if m.irreversible && !force {
+			return current, newError(ErrIrreversibleMigration, "Migration %d (%v) can not be undone, rolling back past it keeps its changes", m.version, m.description)
+		}
+		err := applyMigration(db, m.down, nil, db.Rebind("DELETE FROM schema_version WHERE version = ?"), m.version)
+		if err != nil {
+			return current, fmt.Errorf("Rollback of migration %d (%v) failed, error msg: %v", m.version, m.description, err)
+		}
+		return schemaVersion(db)
+	}
+	return current, fmt.Errorf("Unknown schema version: %d, DB was migrated by a newer version of tefter", current)
+}
+
+//applyMigration executes statements, fill if set and the bookkeeping query in a single transaction
+func applyMigration(db *sqlx.DB, statements []string, fill func(tx *sqlx.Tx) error, bookkeeping string, args ...interface{}) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if fill != nil {
+		if err := fill(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec(bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"testing"
+)
+
+func TestMigrateFreshDB(t *testing.T) {
+	migrator := NewMigrator("test.db")
+	//tear down test
+	defer func() {
+		migrator.CloseDB()
+		os.Remove("test.db")
+	}()
+
+	version, err := migrator.Migrate()
+	if err != nil {
+		t.Errorf("Could not migrate DB, error msg: %v", err)
+	}
+	if version != SchemaVersion {
+		t.Errorf("Expected DB to be at latest version, got: %d", version)
+	}
+	if postgresVersion := postgresMigrations[len(postgresMigrations)-1].version; postgresVersion != SchemaVersion {
+		t.Errorf("Expected postgres migrations to end at version %d, got: %d", SchemaVersion, postgresVersion)
+	}
+
+	statuses, _ := migrator.Status()
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt.IsZero() {
+			t.Errorf("Migration %d should have been applied", status.Version)
+		}
+	}
+}
+
+func TestMigrateLegacyDB(t *testing.T) {
+	//DB created before migrations were introduced, without schema_version table.
+	db := sqlx.MustConnect(databaseDriver, "test.db")
+	defer func() {
+		db.Close()
+		os.Remove("test.db")
+	}()
+	for _, statement := range sqliteMigrations[0].up {
+		db.MustExec(statement)
+	}
+	db.MustExec(`INSERT INTO note (title, memo, created, lastUpdated, notebook_id)
+		VALUES ('title', 'memo', '2018-03-19 18:58:29', '2018-03-19 18:58:29', 1)`)
+
+	version, err := migrateUp(db, sqliteMigrations)
+	if err != nil {
+		t.Errorf("Could not migrate legacy DB, error msg: %v", err)
+	}
+	if version != sqliteMigrations[len(sqliteMigrations)-1].version {
+		t.Errorf("Expected DB to be at latest version, got: %d", version)
+	}
+	var count int
+	db.Get(&count, "SELECT COUNT(*) FROM note")
+	if count != 1 {
+		t.Error("Migrating legacy DB should keep existing notes")
+	}
+}
+
+func TestMigrateNormalizesTags(t *testing.T) {
+	db := sqlx.MustConnect(databaseDriver, "test.db")
+	defer func() {
+		db.Close()
+		os.Remove("test.db")
+	}()
+	if _, err := migrateUp(db, sqliteMigrations[:5]); err != nil {
+		t.Fatalf("Could not migrate DB, error msg: %v", err)
+	}
+	for _, tag := range []string{"go", " Go", "Machine  Learning", "  "} {
+		db.MustExec("INSERT INTO note_tag (note_id, tag) VALUES (1, ?)", tag)
+	}
+
+	if _, err := migrateUp(db, sqliteMigrations); err != nil {
+		t.Fatalf("Could not migrate DB, error msg: %v", err)
+	}
+	tags := []string{}
+	db.Select(&tags, "SELECT tag FROM note_tag ORDER BY tag")
+	if len(tags) != 2 || tags[0] != "go" || tags[1] != "machine learning" {
+		t.Errorf("Expected tags to be normalized, got: %q", tags)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	db := sqlx.MustConnect(databaseDriver, "test.db")
+	defer func() {
+		db.Close()
+		os.Remove("test.db")
+	}()
+	migrations := append(sqliteMigrations, migration{
+		version:     sqliteMigrations[len(sqliteMigrations)-1].version + 1,
+		description: "test migration",
+		up:          []string{"CREATE TABLE test_rollback (id INTEGER)"},
+		down:        []string{"DROP TABLE test_rollback"},
+	})
+	latest, _ := migrateUp(db, migrations)
+
+	version, err := migrateDown(db, migrations, false)
+	if err != nil {
+		t.Errorf("Could not rollback DB, error msg: %v", err)
+	}
+	if version != latest-1 {
+		t.Errorf("Expected DB to be at version %d, got: %d", latest-1, version)
+	}
+	if _, err := db.Exec("SELECT * FROM test_rollback"); err == nil {
+		t.Error("Rollback should have reverted the migration")
+	}
+
+	version, _ = migrateUp(db, migrations)
+	if version != latest {
+		t.Errorf("Expected DB to be migrated again to version %d, got: %d", latest, version)
+	}
+}
+
+func TestRollbackInitialSchema(t *testing.T) {
+	db := sqlx.MustConnect(databaseDriver, "test.db")
+	defer func() {
+		db.Close()
+		os.Remove("test.db")
+	}()
+	initial := sqliteMigrations[:1]
+	migrateUp(db, initial)
+
+	version, err := migrateDown(db, initial, false)
+	if !errors.Is(err, ErrDestructiveRollback) || version != 1 {
+		t.Errorf("Expected rolling back the initial schema to be refused, got version: %d, error msg: %v", version, err)
+	}
+	if _, err := db.Exec("SELECT * FROM note"); err != nil {
+		t.Errorf("Refused rollback should keep the notes, error msg: %v", err)
+	}
+
+	version, err = migrateDown(db, initial, true)
+	if err != nil || version != 0 {
+		t.Errorf("Expected forced rollback to version 0, got: %d, error msg: %v", version, err)
+	}
+}
+
+func TestRollbackDestructiveMigrations(t *testing.T) {
+	cases := []struct {
+		version int
+		table   string
+	}{
+		{3, "note_revision"},
+		{8, "attachment"},
+	}
+	for _, c := range cases {
+		db := sqlx.MustConnect(databaseDriver, "test.db")
+		migrations := sqliteMigrations[:c.version]
+		if _, err := migrateUp(db, migrations); err != nil {
+			t.Fatalf("Could not migrate DB, error msg: %v", err)
+		}
+
+		version, err := migrateDown(db, migrations, false)
+		if !errors.Is(err, ErrDestructiveRollback) || version != c.version {
+			t.Errorf("Expected rolling back migration %d to be refused, got version: %d, error msg: %v", c.version, version, err)
+		}
+		if _, err := db.Exec("SELECT * FROM " + c.table); err != nil {
+			t.Errorf("Refused rollback of migration %d should keep table %v, error msg: %v", c.version, c.table, err)
+		}
+		version, err = migrateDown(db, migrations, true)
+		if err != nil || version != c.version-1 {
+			t.Errorf("Expected forced rollback to version %d, got: %d, error msg: %v", c.version-1, version, err)
+		}
+		db.Close()
+		os.Remove("test.db")
+	}
+}
+
+func TestRollbackIrreversibleMigration(t *testing.T) {
+	db := sqlx.MustConnect(databaseDriver, "test.db")
+	defer func() {
+		db.Close()
+		os.Remove("test.db")
+	}()
+	migrations := sqliteMigrations[:6]
+	if _, err := migrateUp(db, migrations[:5]); err != nil {
+		t.Fatalf("Could not migrate DB, error msg: %v", err)
+	}
+	db.MustExec(`INSERT INTO note (id, title, memo, created, lastUpdated, notebook_id)
+		VALUES (1, 'trip', 'Bali', datetime('now'), datetime('now'), 1)`)
+	db.MustExec(`INSERT INTO note_tag (note_id, tag) VALUES (1, ' Travel  Plans ')`)
+	if _, err := migrateUp(db, migrations); err != nil {
+		t.Fatalf("Could not migrate DB, error msg: %v", err)
+	}
+
+	version, err := migrateDown(db, migrations, false)
+	if !errors.Is(err, ErrIrreversibleMigration) || version != 6 {
+		t.Errorf("Expected rolling back normalize tags to be refused, got version: %d, error msg: %v", version, err)
+	}
+	version, err = migrateDown(db, migrations, true)
+	if err != nil || version != 5 {
+		t.Fatalf("Expected forced rollback to version 5, got: %d, error msg: %v", version, err)
+	}
+	var tags []string
+	if err := db.Select(&tags, "SELECT tag FROM note_tag"); err != nil || len(tags) != 1 || tags[0] != "travel plans" {
+		t.Errorf("Expected the normalized tag to be kept, got: %v, error msg: %v", tags, err)
+	}
+}
+
+func TestRollbackEmptyDB(t *testing.T) {
+	migrator := NewMigrator("test.db")
+	//tear down test
+	defer func() {
+		migrator.CloseDB()
+		os.Remove("test.db")
+	}()
+
+	_, err := migrator.Rollback(false)
+	if err == nil || err.Error() != "Nothing to rollback, DB is at version 0" {
+		t.Error("Expected error with message: 'Nothing to rollback, DB is at version 0'")
+	}
+}
+
+func TestMigrateUnknownVersion(t *testing.T) {
+	db := sqlx.MustConnect(databaseDriver, "test.db")
+	defer func() {
+		db.Close()
+		os.Remove("test.db")
+	}()
+	migrateUp(db, sqliteMigrations)
+	db.MustExec("INSERT INTO schema_version (version, description, applied) VALUES (1000, 'future', '2030-01-01 00:00:00')")
+
+	_, err := migrateUp(db, sqliteMigrations)
+	if err == nil {
+		t.Error("Migrating a DB with unknown schema version should fail")
+	}
+}
+
+func TestMigrateFillsLinks(t *testing.T) {
+	db := sqlx.MustConnect(databaseDriver, "test.db")
+	defer func() {
+		db.Close()
+		os.Remove("test.db")
+	}()
+	if _, err := migrateUp(db, sqliteMigrations[:6]); err != nil {
+		t.Fatalf("Could not migrate DB, error msg: %v", err)
+	}
+	db.MustExec(`INSERT INTO note (id, title, memo, created, lastUpdated, notebook_id)
+		VALUES (1, 'trip', 'see [[Bali 2018]] and [[#2]]', datetime('now'), datetime('now'), 1)`)
+
+	if _, err := migrateUp(db, sqliteMigrations); err != nil {
+		t.Fatalf("Could not migrate DB, error msg: %v", err)
+	}
+	links := []struct {
+		NoteID      int64  `db:"note_id"`
+		TargetID    int64  `db:"target_id"`
+		TargetTitle string `db:"target_title"`
+	}{}
+	db.Select(&links, "SELECT note_id, target_id, target_title FROM note_link ORDER BY target_id")
+	if len(links) != 2 || links[0].TargetTitle != "Bali 2018" || links[1].TargetID != 2 || links[1].NoteID != 1 {
+		t.Errorf("Expected links of existing notes to be stored, got: %+v", links)
+	}
+}
+
+func TestMigrateFTS5Index(t *testing.T) {
+	db := sqlx.MustConnect(databaseDriver, "test.db")
+	defer func() {
+		db.Close()
+		os.Remove("test.db")
+	}()
+	if _, err := migrateUp(db, sqliteMigrations[:8]); err != nil {
+		t.Fatalf("Could not migrate DB, error msg: %v", err)
+	}
+	db.MustExec(`INSERT INTO note (id, title, memo, created, lastUpdated, notebook_id)
+		VALUES (1, 'trip', 'Bali beaches', datetime('now'), datetime('now'), 1)`)
+
+	var supported bool
+	db.Get(&supported, "SELECT sqlite_compileoption_used('ENABLE_FTS5')")
+	if _, err := migrateUp(db, sqliteMigrations[:9]); err != nil {
+		t.Fatalf("Could not migrate DB, error msg: %v", err)
+	}
+	if fts5, err := isSearchIndexFTS5(db); err != nil || fts5 != supported {
+		t.Errorf("Expected fts5 index: %v, got: %v, error msg: %v", supported, fts5, err)
+	}
+	var ids []int64
+	if err := db.Select(&ids, "SELECT rowid FROM note_fts WHERE note_fts MATCH 'beaches'"); err != nil || len(ids) != 1 {
+		t.Errorf("Expected the note to be indexed, got: %v, error msg: %v", ids, err)
+	}
+
+	if version, err := migrateDown(db, sqliteMigrations[:9], false); err != nil || version != 8 {
+		t.Fatalf("Could not rollback DB, version: %d, error msg: %v", version, err)
+	}
+	if fts5, err := isSearchIndexFTS5(db); err != nil || fts5 {
+		t.Errorf("Expected fts4 index after rollback, error msg: %v", err)
+	}
+	ids = nil
+	if err := db.Select(&ids, "SELECT docid FROM note_fts WHERE note_fts MATCH 'beaches'"); err != nil || len(ids) != 1 {
+		t.Errorf("Expected the note to be indexed after rollback, got: %v, error msg: %v", ids, err)
+	}
+}
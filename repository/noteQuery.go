@@ -0,0 +1,328 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicolasmanic/tefter/model"
+	"sort"
+	"strings"
+	"time"
+)
+
+//NoteSort is a field that notes can be sorted by
+type NoteSort string
+
+//Available note sort fields, notes are sorted by created if no field is set.
+//SortByRank sorts by relevance and is only available to searches, where it is the default.
+const (
+	SortByCreated     NoteSort = "created"
+	SortByLastUpdated NoteSort = "updated"
+	SortByTitle       NoteSort = "title"
+	SortByRank        NoteSort = "rank"
+)
+
+//SortOrder is the direction of a sort
+type SortOrder string
+
+//Available sort orders, notes are sorted descending if no order is set.
+const (
+	Descending SortOrder = "desc"
+	Ascending  SortOrder = "asc"
+)
+
+//NoteFilter selects the notes of a listing. A note matches if it matches any of the non empty IDs, NotebookIDs
+//and Tags fields and it matches Query if set, an empty filter matches every note that is not in the trash.
+//Tags match their descendant tags too, eg: lang matches notes tagged with lang/go.
+type NoteFilter struct {
+	IDs         []int64
+	NotebookIDs []int64
+	Tags        []string
+	Query       QueryExpr
+	//Fuzzy makes the text terms of Query match similar words of the title, memo, tags and notebook path of notes:
+	//words containing them, eg: kube matches kubernetes, and misspellings of them. Fuzzy searches are matched in
+	//memory, only SearchNotes supports them and ListNotes ignores Fuzzy.
+	Fuzzy bool
+	//Trashed selects the notes in the trash instead of the other notes.
+	Trashed bool
+}
+
+//NoteQuery holds the paging and sorting options of a note listing. The zero value returns every note
+//sorted by created desc. Cursor is the Next cursor of a previous page, if set Offset is ignored and
+//the sort must be the same as the sort of the previous page.
+type NoteQuery struct {
+	Limit  int
+	Offset int
+	Cursor string
+	Sort   NoteSort
+	Order  SortOrder
+	//position is the decoded Cursor, set by normalize
+	position *noteCursor
+}
+
+//NotePage is a page of notes, Next is the cursor of the following page and is empty for the last page.
+type NotePage struct {
+	Notes []*model.Note
+	Next  string
+}
+
+//noteCursor is the position of the last note of a page, it is encoded in NotePage.Next.
+type noteCursor struct {
+	Sort  NoteSort  `json:"s"`
+	Order SortOrder `json:"o"`
+	ID    int64     `json:"id"`
+	Time  time.Time `json:"t,omitempty"`
+	Title string    `json:"ti,omitempty"`
+	//Offset is the position of the next page of searches sorted by rank, ranks change as notes change
+	//so rank pages are not keyed by the last note.
+	Offset int `json:"off,omitempty"`
+}
+
+//normalize sets the default sort and order, validates the query and decodes its cursor.
+func (query *NoteQuery) normalize() error {
+	if query.Sort == "" {
+		query.Sort = SortByCreated
+	}
+	if query.Order == "" {
+		query.Order = Descending
+	}
+	switch query.Sort {
+	case SortByCreated, SortByLastUpdated, SortByTitle, SortByRank:
+	default:
+		return newError(ErrValidation, "Unknown sort field: %v, should be one of created, updated, title, rank", query.Sort)
+	}
+	if query.Order != Ascending && query.Order != Descending {
+		return newError(ErrValidation, "Unknown sort order: %v, should be asc or desc", query.Order)
+	}
+	if query.Limit < 0 || query.Offset < 0 {
+		return newError(ErrValidation, "Limit and offset should not be negative")
+	}
+	if query.Offset > 0 && query.Limit == 0 {
+		return newError(ErrValidation, "Offset should be used together with limit")
+	}
+	cursor, err := query.cursor()
+	if err != nil {
+		return err
+	}
+	if cursor != nil && query.Sort == SortByRank {
+		if query.Limit == 0 {
+			return newError(ErrValidation, "Cursor of rank sort should be used together with limit")
+		}
+		query.Offset = cursor.Offset
+	} else if cursor != nil {
+		query.position = cursor
+		query.Offset = 0
+	}
+	return nil
+}
+
+//normalizeList normalizes the query of a note listing, only searches can be sorted by rank.
+func (query *NoteQuery) normalizeList() error {
+	if query.Sort == SortByRank {
+		return newError(ErrValidation, "Only search results can be sorted by rank")
+	}
+	return query.normalize()
+}
+
+//normalizeSearch normalizes the query of a search. Searches are sorted by rank by default,
+//unless ranked is false because the search has no text terms to rank notes by.
+func (query *NoteQuery) normalizeSearch(ranked bool) error {
+	if query.Sort == "" && ranked {
+		query.Sort = SortByRank
+	} else if query.Sort == SortByRank && !ranked {
+		query.Sort = SortByCreated
+	}
+	return query.normalize()
+}
+
+//cursor decodes the cursor of the query, it returns nil if the query has no cursor.
+func (query NoteQuery) cursor() (*noteCursor, error) {
+	if query.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, newError(ErrValidation, "Invalid cursor: %v", query.Cursor)
+	}
+	cursor := &noteCursor{}
+	if err := json.Unmarshal(raw, cursor); err != nil {
+		return nil, newError(ErrValidation, "Invalid cursor: %v", query.Cursor)
+	}
+	if cursor.Sort != query.Sort || cursor.Order != query.Order {
+		return nil, newError(ErrValidation, "Cursor was created for sort: %v %v", cursor.Sort, cursor.Order)
+	}
+	return cursor, nil
+}
+
+func newNoteCursor(note *model.Note, query NoteQuery) string {
+	cursor := noteCursor{Sort: query.Sort, Order: query.Order, ID: note.ID}
+	switch query.Sort {
+	case SortByCreated:
+		cursor.Time = note.Created
+	case SortByLastUpdated:
+		cursor.Time = note.LastUpdated
+	case SortByTitle:
+		cursor.Title = note.Title
+	case SortByRank:
+		cursor.Offset = query.Offset + query.Limit
+	}
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+//newNotePage returns a page of the notes retrieved with the limit of the query increased by one,
+//the extra note only denotes that a next page exists.
+func newNotePage(notes []*model.Note, query NoteQuery) *NotePage {
+	page := &NotePage{Notes: notes}
+	if query.Limit > 0 && len(notes) > query.Limit {
+		page.Notes = notes[:query.Limit]
+		page.Next = newNoteCursor(page.Notes[query.Limit-1], query)
+	}
+	return page
+}
+
+//sortTime returns the time the note is sorted by, for the created and updated sort fields.
+func sortTime(note *model.Note, sort NoteSort) time.Time {
+	if sort == SortByLastUpdated {
+		return note.LastUpdated
+	}
+	return note.Created
+}
+
+//noteSortColumns maps every sort field to the column of the note table.
+var noteSortColumns = map[NoteSort]string{
+	SortByCreated:     "n.created",
+	SortByLastUpdated: "n.lastUpdated",
+	SortByTitle:       "n.title",
+	SortByRank:        "relevance",
+}
+
+//listNotesSQL builds the query of a note listing. selectFrom is the select clause of the backend and
+//conditions, args are backend specific conditions such as full text search. The query uses ? placeholders
+//and is run through sqlx.In, it must be rebound for postgres. query must be normalized, searches sorted by rank
+//must select the relevance of notes.
+func listNotesSQL(dialect queryDialect, selectFrom string, conditions []string, args []interface{}, filter NoteFilter, query NoteQuery) (string, []interface{}, error) {
+	cursor := query.position
+	conditions = append(conditions, trashedCondition(filter.Trashed))
+	matches := []string{}
+	if len(filter.IDs) > 0 {
+		matches = append(matches, "n.id IN (?)")
+		args = append(args, filter.IDs)
+	}
+	if len(filter.NotebookIDs) > 0 {
+		matches = append(matches, "n.notebook_id IN (?)")
+		args = append(args, filter.NotebookIDs)
+	}
+	if len(filter.Tags) > 0 {
+		//sub-query instead of join so that notes with more than one of the tags are returned once.
+		//descendants of the tags are matched too, eg: lang matches lang/go
+		condition, tagArgs := subTagsCondition(normalizeTags(filter.Tags))
+		matches = append(matches, "n.id IN (SELECT note_id FROM note_tag WHERE "+condition+")")
+		args = append(args, tagArgs...)
+	}
+	if len(matches) > 0 {
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	if filter.Query != nil {
+		condition, queryArgs := filter.Query.sql(dialect)
+		conditions = append(conditions, "("+condition+")")
+		args = append(args, queryArgs...)
+	}
+
+	column := noteSortColumns[query.Sort]
+	operator := "<"
+	if query.Order == Ascending {
+		operator = ">"
+	}
+	if cursor != nil {
+		var value interface{} = cursor.Time
+		if query.Sort == SortByTitle {
+			value = cursor.Title
+		}
+		conditions = append(conditions, "("+column+" "+operator+" ? OR ("+column+" = ? AND n.id "+operator+" ?))")
+		args = append(args, value, value, cursor.ID)
+	}
+
+	sqlQuery := selectFrom
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += " ORDER BY " + column + " " + string(query.Order) + ", n.id " + string(query.Order)
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit+1)
+	}
+	if query.Offset > 0 {
+		sqlQuery += " OFFSET ?"
+		args = append(args, query.Offset)
+	}
+	return sqlx.In(sqlQuery, args...)
+}
+
+//matchesFilter returns true if the note matches the filter, see NoteFilter. paths holds the path of every
+//notebook out of the trash.
+func matchesFilter(note *model.Note, filter NoteFilter, paths map[int64]string) bool {
+	if (note.DeletedAt != nil) != filter.Trashed {
+		return false
+	}
+	if filter.Query != nil && !filter.Query.matches(note, paths) {
+		return false
+	}
+	if len(filter.IDs) == 0 && len(filter.NotebookIDs) == 0 && len(filter.Tags) == 0 {
+		return true
+	}
+	for _, id := range filter.IDs {
+		if note.ID == id {
+			return true
+		}
+	}
+	for _, notebookID := range filter.NotebookIDs {
+		if note.NotebookID == notebookID {
+			return true
+		}
+	}
+	for _, tag := range filter.Tags {
+		if note.HasSubTag(model.NormalizeTag(tag)) {
+			return true
+		}
+	}
+	return false
+}
+
+//pageNotes sorts, skips and limits in memory notes the same way listNotesSQL does in the DB, query must be normalized.
+func pageNotes(notes []*model.Note, query NoteQuery) *NotePage {
+	cursor := query.position
+	//less reports whether a is before b in ascending order
+	less := func(a, b *model.Note) bool {
+		if query.Sort == SortByTitle {
+			if a.Title != b.Title {
+				return a.Title < b.Title
+			}
+		} else {
+			aTime, bTime := sortTime(a, query.Sort), sortTime(b, query.Sort)
+			if !aTime.Equal(bTime) {
+				return aTime.Before(bTime)
+			}
+		}
+		return a.ID < b.ID
+	}
+	before := less
+	if query.Order == Descending {
+		before = func(a, b *model.Note) bool { return less(b, a) }
+	}
+	sort.Slice(notes, func(i, j int) bool { return before(notes[i], notes[j]) })
+
+	start := query.Offset
+	if cursor != nil {
+		last := &model.Note{ID: cursor.ID, Created: cursor.Time, LastUpdated: cursor.Time, Title: cursor.Title}
+		start = sort.Search(len(notes), func(i int) bool { return before(last, notes[i]) })
+	}
+	if start > len(notes) {
+		start = len(notes)
+	}
+	notes = notes[start:]
+	if query.Limit > 0 && len(notes) > query.Limit+1 {
+		notes = notes[:query.Limit+1]
+	}
+	return newNotePage(notes, query)
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicolasmanic/tefter/model"
+	"strings"
+)
+
+//NotebookPathSeparator separates the titles of a notebook path, eg: work/infra/k8s is notebook k8s,
+//child of infra, child of the top level notebook work.
+const NotebookPathSeparator = "/"
+
+//SplitNotebookPath returns the titles of a notebook path from the top level notebook down,
+//surrounding spaces and empty titles are dropped.
+func SplitNotebookPath(path string) []string {
+	titles := []string{}
+	for _, title := range strings.Split(path, NotebookPathSeparator) {
+		if title = strings.TrimSpace(title); title != "" {
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
+
+//validateNotebookTitle checks the title of a notebook to be saved, titles are the parts of notebook paths
+//so they can not contain the path separator.
+func validateNotebookTitle(title string) error {
+	if title == "" {
+		return newError(ErrValidation, "Notebook should contain title")
+	}
+	if strings.Contains(title, NotebookPathSeparator) {
+		return newError(ErrValidation, "Notebook title: %v should not contain %v", title, NotebookPathSeparator)
+	}
+	return nil
+}
+
+//notebookPaths returns the path of every notebook, notebooks must contain the ancestors of every notebook.
+func notebookPaths(notebooks []*model.Notebook) map[int64]string {
+	byID := make(map[int64]*model.Notebook, len(notebooks))
+	for _, notebook := range notebooks {
+		byID[notebook.ID] = notebook
+	}
+	paths := make(map[int64]string, len(notebooks))
+	var pathOf func(notebook *model.Notebook, depth int) string
+	pathOf = func(notebook *model.Notebook, depth int) string {
+		if path, ok := paths[notebook.ID]; ok {
+			return path
+		}
+		path := notebook.Title
+		//depth guards against cycles of a corrupted DB
+		if parent, ok := byID[notebook.ParentID]; ok && depth < len(notebooks) {
+			path = pathOf(parent, depth+1) + NotebookPathSeparator + notebook.Title
+		}
+		paths[notebook.ID] = path
+		return path
+	}
+	for _, notebook := range notebooks {
+		pathOf(notebook, 0)
+	}
+	return paths
+}
+
+//activeNotebookPaths returns the path of every notebook that is out of the trash along with its ancestors,
+//notebooks must contain the ancestors of every notebook.
+func activeNotebookPaths(notebooks []*model.Notebook) map[int64]string {
+	byID := make(map[int64]*model.Notebook, len(notebooks))
+	for _, notebook := range notebooks {
+		byID[notebook.ID] = notebook
+	}
+	paths := notebookPaths(notebooks)
+	for _, notebook := range notebooks {
+		//depth guards against cycles of a corrupted DB
+		for ancestor, depth := notebook, 0; ancestor != nil && depth <= len(notebooks); ancestor, depth = byID[ancestor.ParentID], depth+1 {
+			if ancestor.DeletedAt != nil {
+				delete(paths, notebook.ID)
+				break
+			}
+		}
+	}
+	return paths
+}
+
+//notebookPathsCTE is the common table expression of the path of every notebook out of the trash, the
+//notebook_path table has the columns id and path.
+const notebookPathsCTE = `WITH RECURSIVE notebook_path(id, path) AS (
+	SELECT id, title FROM notebook WHERE parent_id = 0 AND deleted_at IS NULL
+	UNION ALL
+	SELECT c.id, p.path || '` + NotebookPathSeparator + `' || c.title FROM notebook c
+	INNER JOIN notebook_path p ON c.parent_id = p.id WHERE c.deleted_at IS NULL)`
+
+//resolveNotebookPath returns the id of the notebook out of the trash at path, or 0 if there is none.
+//Top level notebooks of DBs created before notebooks could be nested may contain the separator in their
+//title, they are found by their title if no notebook matches the path.
+func resolveNotebookPath(handle dbHandle, path string) (int64, error) {
+	titles := SplitNotebookPath(path)
+	if len(titles) == 0 {
+		return 0, nil
+	}
+	var notebookID int64
+	for _, title := range titles {
+		err := handle.Get(&notebookID, handle.Rebind(`SELECT id FROM notebook
+			WHERE parent_id = ? AND title = ? AND deleted_at IS NULL`), notebookID, title)
+		if err == sql.ErrNoRows {
+			notebookID = 0
+			break
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	if notebookID != 0 || len(titles) == 1 {
+		return notebookID, nil
+	}
+	err := handle.Get(&notebookID, handle.Rebind(`SELECT id FROM notebook
+		WHERE parent_id = 0 AND title = ? AND deleted_at IS NULL`), path)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return notebookID, err
+}
+
+//checkNotebookParent checks that the parent of notebook exists out of the trash and that the notebook is not
+//moved under itself or one of its children. The default notebook always stays at the top level.
+func checkNotebookParent(handle dbHandle, notebook *model.Notebook) error {
+	if notebook.ParentID == 0 {
+		return nil
+	}
+	if notebook.ID == DEFAULT_NOTEBOOK_ID {
+		return newError(ErrDefaultNotebookProtected, "Default notebook can not be moved under another notebook")
+	}
+	parents := []int64{}
+	err := handle.Select(&parents, handle.Rebind("SELECT id FROM notebook WHERE id = ? AND deleted_at IS NULL"), notebook.ParentID)
+	if err != nil {
+		return err
+	}
+	if len(parents) == 0 {
+		return newError(ErrNotebookNotFound, "Could find parent notebook with id: %v", notebook.ParentID)
+	}
+	for ancestorID := notebook.ParentID; ancestorID != 0; {
+		if ancestorID == notebook.ID {
+			return newError(ErrValidation, "Notebook with id: %v can not be moved under itself", notebook.ID)
+		}
+		if err := handle.Get(&ancestorID, handle.Rebind("SELECT parent_id FROM notebook WHERE id = ?"), ancestorID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//checkNoChildren fails with ErrNotebookHasChildren if any of the notebooks has children out of the trash
+//that are not deleted along with it.
+func checkNoChildren(handle dbHandle, notebookIDs []int64) error {
+	children, err := selectIDsIn(handle, `SELECT id FROM notebook
+		WHERE parent_id IN (?) AND id NOT IN (?) AND deleted_at IS NULL`, notebookIDs, notebookIDs)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return newError(ErrNotebookHasChildren, "Notebooks with ids: %v have child notebooks with ids: %v, "+
+			"delete or move the children first", notebookIDs, children)
+	}
+	return nil
+}
+
+//restoreNotebookAncestors takes the ancestors of the notebooks out of the trash,
+//so that restored notebooks and notes are reachable by their path.
+func restoreNotebookAncestors(tx *sqlx.Tx, notebookIDs []int64) error {
+	for len(notebookIDs) > 0 {
+		parents, err := selectIDsIn(tx, "SELECT parent_id FROM notebook WHERE id IN (?) AND parent_id <> 0", notebookIDs)
+		if err != nil {
+			return err
+		}
+		if len(parents) == 0 {
+			return nil
+		}
+		if err := execIn(tx, "UPDATE notebook SET deleted_at = NULL WHERE id IN (?)", parents); err != nil {
+			return err
+		}
+		notebookIDs = parents
+	}
+	return nil
+}
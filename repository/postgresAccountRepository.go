@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/nicolasmanic/tefter/model"
+)
+
+type postgresAccountRepository struct {
+	dbHandle
+}
+
+//NewPostgresAccountRepository returns a AccountRepository interface backed by the postgres DB described by dsn,
+//use NewPostgresStore to share a connection between repositories.
+func NewPostgresAccountRepository(dsn string) AccountRepository {
+	return NewPostgresStore(dsn).Accounts()
+}
+
+func (accountRepo *postgresAccountRepository) CreateAccount(username string, password []byte) error {
+	if username == "" || len(password) == 0 {
+		return newError(ErrValidation, "Username or/and password are empty")
+	}
+	//password column is TEXT, passing []byte would store it in bytea escape format.
+	_, err := accountRepo.Exec(`INSERT INTO account (username, password) VALUES($1, $2)`, username, string(password))
+	if isUniqueViolation(err) {
+		return newError(ErrAccountExists, "Account for username: %v already exists", username)
+	}
+	return err
+}
+
+func (accountRepo *postgresAccountRepository) GetAccount(username string) (*model.Account, error) {
+	accounts := []*model.Account{}
+	err := accountRepo.Select(&accounts, "SELECT username, password FROM account WHERE username = $1", username)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, newError(ErrAccountNotFound, "No account found for username: %v", username)
+	}
+	return accounts[0], nil
+}
+
+func (accountRepo *postgresAccountRepository) DeleteAccount(username string) error {
+	_, err := accountRepo.Exec("DELETE FROM account WHERE username = $1", username)
+	return err
+}
+
+func (accountRepo *postgresAccountRepository) GetUsernames() ([]string, error) {
+	usernames := []string{}
+	if err := accountRepo.Select(&usernames, "SELECT username FROM account"); err != nil {
+		return nil, fmt.Errorf("Could not retrieve usernames, error msg: %v", err)
+	}
+	return usernames, nil
+}
+
+func (accountRepo *postgresAccountRepository) CloseDB() error {
+	return closeHandle(accountRepo.dbHandle)
+}
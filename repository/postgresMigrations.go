@@ -0,0 +1,217 @@
+package repository
+
+//postgresMigrations holds every schema change of the postgres DB in ascending version order.
+//Versions are kept in sync with sqliteMigrations so that both backends report the same schema version.
+//Foreign keys are not declared since sqlite does not enforce them either, both backends should behave the same.
+var postgresMigrations = []migration{
+	{
+		version:     1,
+		description: "initial schema",
+		destructive: true,
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS account (
+				username TEXT NOT NULL,
+				password TEXT NOT NULL,
+				CONSTRAINT account_PK PRIMARY KEY(username))`,
+			`CREATE TABLE IF NOT EXISTS notebook (
+				id BIGSERIAL NOT NULL,
+				title TEXT NOT NULL,
+				CONSTRAINT title_UN UNIQUE(title),
+				CONSTRAINT notebook_PK PRIMARY KEY(id))`,
+			//Default Notebook has id = 1, the sequence is moved forward so that the next notebook gets id = 2
+			`INSERT INTO notebook (id, title) VALUES (1, 'Default Notebook') ON CONFLICT DO NOTHING`,
+			`SELECT setval(pg_get_serial_sequence('notebook', 'id'), GREATEST(MAX(id), 1)) FROM notebook`,
+			`CREATE TABLE IF NOT EXISTS note (
+				id BIGSERIAL NOT NULL,
+				title TEXT NOT NULL,
+				memo TEXT NOT NULL,
+				created TIMESTAMP WITH TIME ZONE NOT NULL,
+				lastUpdated TIMESTAMP WITH TIME ZONE NOT NULL,
+				notebook_id BIGINT NOT NULL,
+				search TSVECTOR GENERATED ALWAYS AS (
+					setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', memo), 'B')) STORED,
+				CONSTRAINT note_PK PRIMARY KEY(id))`,
+			`CREATE INDEX IF NOT EXISTS note_search_IX ON note USING GIN(search)`,
+			`CREATE TABLE IF NOT EXISTS notebook_note (
+				note_id BIGINT NOT NULL,
+				notebook_id BIGINT NOT NULL,
+				CONSTRAINT notebook_note_PK PRIMARY KEY(note_id, notebook_id))`,
+			`CREATE TABLE IF NOT EXISTS note_tag (
+				note_id BIGINT NOT NULL,
+				tag TEXT NOT NULL,
+				CONSTRAINT note_tag_PK PRIMARY KEY(tag, note_id))`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS note_tag`,
+			`DROP TABLE IF EXISTS notebook_note`,
+			`DROP TABLE IF EXISTS note`,
+			`DROP TABLE IF EXISTS notebook`,
+			`DROP TABLE IF EXISTS account`,
+		},
+	},
+	{
+		version:     2,
+		description: "index note relations",
+		up: []string{
+			`CREATE INDEX IF NOT EXISTS note_tag_note_id_IX ON note_tag(note_id)`,
+			`CREATE INDEX IF NOT EXISTS notebook_note_notebook_id_IX ON notebook_note(notebook_id)`,
+			`CREATE INDEX IF NOT EXISTS note_notebook_id_IX ON note(notebook_id)`,
+		},
+		down: []string{
+			`DROP INDEX IF EXISTS note_notebook_id_IX`,
+			`DROP INDEX IF EXISTS notebook_note_notebook_id_IX`,
+			`DROP INDEX IF EXISTS note_tag_note_id_IX`,
+		},
+	},
+	{
+		version:     3,
+		description: "note revisions",
+		destructive: true,
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS note_revision (
+				note_id BIGINT NOT NULL,
+				revision INTEGER NOT NULL,
+				title TEXT NOT NULL,
+				memo TEXT NOT NULL,
+				created TIMESTAMP WITH TIME ZONE NOT NULL,
+				CONSTRAINT note_revision_PK PRIMARY KEY(note_id, revision))`,
+			//the current content of existing notes is their first revision
+			`INSERT INTO note_revision (note_id, revision, title, memo, created)
+				SELECT id, 1, title, memo, lastUpdated FROM note`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS note_revision`,
+		},
+	},
+	{
+		version:     4,
+		description: "trash",
+		up: []string{
+			`ALTER TABLE note ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE`,
+			`ALTER TABLE notebook ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE`,
+			`CREATE INDEX IF NOT EXISTS note_deleted_at_IX ON note(deleted_at)`,
+		},
+		//rolling back restores the notes and notebooks of the trash
+		down: []string{
+			`DROP INDEX IF EXISTS note_deleted_at_IX`,
+			`ALTER TABLE notebook DROP COLUMN IF EXISTS deleted_at`,
+			`ALTER TABLE note DROP COLUMN IF EXISTS deleted_at`,
+		},
+	},
+	{
+		version:     5,
+		description: "nested notebooks",
+		//titles are unique among the children of a notebook
+		up: []string{
+			`ALTER TABLE notebook ADD COLUMN IF NOT EXISTS parent_id BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE notebook DROP CONSTRAINT IF EXISTS title_UN`,
+			`ALTER TABLE notebook ADD CONSTRAINT parent_title_UN UNIQUE(parent_id, title)`,
+		},
+		//rolling back moves every notebook to the top level, it fails if two notebooks have the same title
+		down: []string{
+			`ALTER TABLE notebook DROP CONSTRAINT IF EXISTS parent_title_UN`,
+			`ALTER TABLE notebook ADD CONSTRAINT title_UN UNIQUE(title)`,
+			`ALTER TABLE notebook DROP COLUMN IF EXISTS parent_id`,
+		},
+	},
+	{
+		version:     6,
+		description: "normalize tags",
+		//tags are lower case with single inner spaces since Note.AddTags normalizes them, see model.NormalizeTag
+		up: []string{
+			`INSERT INTO note_tag (note_id, tag)
+				SELECT note_id, ` + postgresNormalizedTag + ` FROM note_tag
+				WHERE tag <> ` + postgresNormalizedTag + ` AND ` + postgresNormalizedTag + ` <> ''
+				ON CONFLICT DO NOTHING`,
+			`DELETE FROM note_tag WHERE tag <> ` + postgresNormalizedTag + ` OR tag = ''`,
+		},
+		//normalized tags can not be told apart from the tags they replaced, rolling back keeps them
+		irreversible: true,
+	},
+	{
+		version:     7,
+		description: "note links",
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS note_link (
+				note_id BIGINT NOT NULL,
+				target_id BIGINT NOT NULL DEFAULT 0,
+				target_title TEXT NOT NULL DEFAULT '',
+				CONSTRAINT note_link_PK PRIMARY KEY(note_id, target_id, target_title))`,
+			`CREATE INDEX IF NOT EXISTS note_link_target_id_IX ON note_link(target_id)`,
+			`CREATE INDEX IF NOT EXISTS note_link_target_title_IX ON note_link(target_title)`,
+		},
+		fill: fillLinks,
+		down: []string{
+			`DROP TABLE IF EXISTS note_link`,
+		},
+	},
+	{
+		version:     8,
+		description: "attachments",
+		destructive: true,
+		//contents are stored once per sha256 hash, see model.ContentHash
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS attachment_blob (
+				hash TEXT NOT NULL,
+				content BYTEA NOT NULL,
+				CONSTRAINT attachment_blob_PK PRIMARY KEY(hash))`,
+			`CREATE TABLE IF NOT EXISTS attachment (
+				id BIGSERIAL NOT NULL,
+				note_id BIGINT NOT NULL,
+				name TEXT NOT NULL,
+				hash TEXT NOT NULL,
+				size BIGINT NOT NULL,
+				created TIMESTAMP WITH TIME ZONE NOT NULL,
+				CONSTRAINT attachment_PK PRIMARY KEY(id))`,
+			`CREATE INDEX IF NOT EXISTS attachment_note_id_IX ON attachment(note_id)`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS attachment`,
+			`DROP TABLE IF EXISTS attachment_blob`,
+		},
+	},
+	{
+		version:     9,
+		description: "fts5 full text index",
+		//the fts5 index is sqlite only, the search column of version 1 is the full text index of postgres. The version
+		//keeps both schemas in step so that exports of either DB have the same schema version
+		up:   []string{},
+		down: []string{},
+	},
+	{
+		version:     10,
+		description: "change generation",
+		//the generation counts the changes of notes, tags and notebooks, fuzzy searches index the notes again
+		//only after it changed, see fuzzyCache. It is a sequence since nextval does not lock, concurrent writers
+		//would otherwise wait on each other to update a shared row until they commit. nextval is not rolled back,
+		//so the generation may change before a transaction commits and a search in between keeps the notes
+		//without its changes until the next change. The change_generation view reads it like the sqlite table.
+		up: []string{
+			`CREATE SEQUENCE IF NOT EXISTS change_generation_seq`,
+			`CREATE OR REPLACE VIEW change_generation AS SELECT last_value AS value FROM change_generation_seq`,
+			`CREATE OR REPLACE FUNCTION next_change_generation() RETURNS trigger AS $$
+				BEGIN
+					PERFORM nextval('change_generation_seq');
+					RETURN NULL;
+				END;
+				$$ LANGUAGE plpgsql`,
+			`CREATE TRIGGER note_generation AFTER INSERT OR UPDATE OR DELETE ON note
+				FOR EACH STATEMENT EXECUTE PROCEDURE next_change_generation()`,
+			`CREATE TRIGGER note_tag_generation AFTER INSERT OR UPDATE OR DELETE ON note_tag
+				FOR EACH STATEMENT EXECUTE PROCEDURE next_change_generation()`,
+			`CREATE TRIGGER notebook_generation AFTER INSERT OR UPDATE OR DELETE ON notebook
+				FOR EACH STATEMENT EXECUTE PROCEDURE next_change_generation()`,
+		},
+		down: []string{
+			`DROP TRIGGER IF EXISTS notebook_generation ON notebook`,
+			`DROP TRIGGER IF EXISTS note_tag_generation ON note_tag`,
+			`DROP TRIGGER IF EXISTS note_generation ON note`,
+			`DROP FUNCTION IF EXISTS next_change_generation()`,
+			`DROP VIEW IF EXISTS change_generation`,
+			`DROP SEQUENCE IF EXISTS change_generation_seq`,
+		},
+	},
+}
+
+//postgresNormalizedTag is model.NormalizeTag in sql.
+const postgresNormalizedTag = `lower(btrim(regexp_replace(tag, '\s+', ' ', 'g')))`
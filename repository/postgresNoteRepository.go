@@ -0,0 +1,424 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicolasmanic/tefter/model"
+	"strings"
+	"time"
+)
+
+//postgres folds unquoted identifiers to lower case, lastUpdated must be aliased to match the db tag of model.Note
+const postgresNoteColumns = `n.id, n.title, n.memo, n.created, n.lastUpdated AS "lastUpdated", n.notebook_id, n.deleted_at`
+
+//postgresDialect matches text terms of queries with the search column.
+var postgresDialect = queryDialect{
+	fullText: func(tokens []string, prefix bool) (string, []interface{}) {
+		return "n.search @@ to_tsquery('simple', ?)", []interface{}{postgresPhrase(tokens, prefix)}
+	},
+	//times are stored WITH TIME ZONE and compare in time order
+	time: func(value string) string { return value },
+}
+
+//postgresRankWeights are the weights of the D, C, B and A labels of the search column, see titleWeight and memoWeight.
+var postgresRankWeights = fmt.Sprintf("{0, 0, %v, %v}", memoWeight/titleWeight, 1.0)
+
+//postgresHeadline are the ts_headline options of the highlighted title and of the snippet.
+var postgresHeadline = struct {
+	title   string
+	snippet string
+}{
+	title: fmt.Sprintf(`HighlightAll=true, StartSel="%s", StopSel="%s"`, HighlightStart, HighlightEnd),
+	snippet: fmt.Sprintf(`MaxWords=%d, MinWords=%d, MaxFragments=1, FragmentDelimiter="%s", StartSel="%s", StopSel="%s"`,
+		snippetTokens, snippetTokens/2, snippetEllipsis, HighlightStart, HighlightEnd),
+}
+
+type postgresNoteRepository struct {
+	dbHandle
+	//fuzzy caches the corpus of fuzzy searches, nil builds it on every search
+	fuzzy *fuzzyCache
+}
+
+//NewPostgresNoteRepository returns a NoteRepository interface backed by the postgres DB described by dsn,
+//use NewPostgresStore to share a connection between repositories.
+func NewPostgresNoteRepository(dsn string) NoteRepository {
+	return NewPostgresStore(dsn).Notes()
+}
+
+//SaveNote persist a note to DB. For a note to be valid the memo field must not be empty.
+//Default values are the same as in the sqlite implementation.
+func (noteRepo *postgresNoteRepository) SaveNote(note *model.Note) (int64, error) {
+	if note.Memo == "" {
+		return -1, newError(ErrValidation, "Note should contain memo")
+	}
+	if note.Created.IsZero() {
+		note.Created = time.Now().UTC()
+	}
+	if note.LastUpdated.IsZero() {
+		note.LastUpdated = time.Now().UTC()
+	}
+	//If notebook id is 0 set it to the default notebook.
+	if note.NotebookID == 0 {
+		note.NotebookID = DEFAULT_NOTEBOOK_ID
+	}
+
+	var noteID int64
+	err := transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		err := tx.Get(&noteID, `INSERT INTO note (title, memo, created, lastUpdated, notebook_id)
+			VALUES($1, $2, $3, $4, $5) RETURNING id`,
+			note.Title,
+			note.Memo,
+			note.Created,
+			note.LastUpdated,
+			note.NotebookID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO notebook_note (note_id, notebook_id) VALUES ($1, $2)`, noteID, note.NotebookID); err != nil {
+			return err
+		}
+		if err := insertFirstRevision(tx, noteID, note); err != nil {
+			return err
+		}
+		if err := insertLinks(tx, noteID, note.Memo); err != nil {
+			return err
+		}
+		return insertPostgresTags(tx, noteID, note.Tags)
+	})
+	if err != nil {
+		return -1, err
+	}
+	note.ID = noteID
+	return noteID, nil
+}
+
+//GetNotes return a slice of notes based on the given slice of ids,
+//if ids slice is empty all notes are returned
+func (noteRepo *postgresNoteRepository) GetNotes(noteIDs []int64) ([]*model.Note, error) {
+	page, err := noteRepo.ListNotes(NoteFilter{IDs: removeDups(noteIDs)}, NoteQuery{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Notes, nil
+}
+
+//ListNotes returns a page of the notes matching filter, see NoteQuery for paging and sorting.
+func (noteRepo *postgresNoteRepository) ListNotes(filter NoteFilter, query NoteQuery) (*NotePage, error) {
+	if err := query.normalizeList(); err != nil {
+		return nil, err
+	}
+	return noteRepo.listNotes("SELECT "+postgresNoteColumns+" FROM note n", nil, nil, filter, query)
+}
+
+//GetNote returns a single note based on an id, returns error if note with id doesn't exist
+func (noteRepo *postgresNoteRepository) GetNote(noteID int64) (*model.Note, error) {
+	notes, err := noteRepo.GetNotes([]int64{noteID})
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) != 1 {
+		return nil, newError(ErrNoteNotFound, "Could find note with id: %v", noteID)
+	}
+	return notes[0], nil
+}
+
+//UpdateNote updates an existing note. For a note to be valid the memo field must not be empty.
+func (noteRepo *postgresNoteRepository) UpdateNote(note *model.Note) error {
+	if note.Memo == "" {
+		return newError(ErrValidation, "Note should contain memo")
+	}
+	if note.Created.IsZero() {
+		note.Created = time.Now().UTC()
+	}
+	if note.LastUpdated.IsZero() {
+		note.LastUpdated = time.Now().UTC()
+	}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE note SET title = $1, memo = $2, created = $3, lastUpdated = $4, notebook_id = $5 WHERE id = $6`,
+			[]interface{}{note.Title, note.Memo, note.Created, note.LastUpdated, note.NotebookID, note.ID}},
+		{`DELETE FROM notebook_note WHERE note_id = $1`, []interface{}{note.ID}},
+		{`INSERT INTO notebook_note (note_id, notebook_id) VALUES ($1, $2)`, []interface{}{note.ID, note.NotebookID}},
+		{`DELETE FROM note_tag WHERE note_id = $1`, []interface{}{note.ID}},
+	}
+	return transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		if err := insertRevision(tx, note); err != nil {
+			return err
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement.query, statement.args...); err != nil {
+				return err
+			}
+		}
+		if err := insertLinks(tx, note.ID, note.Memo); err != nil {
+			return err
+		}
+		return insertPostgresTags(tx, note.ID, note.Tags)
+	})
+}
+
+//DeleteNotes moves the notes to the trash, see PurgeNotes for deleting them for good.
+func (noteRepo *postgresNoteRepository) DeleteNotes(noteIDs []int64) error {
+	noteIDs = removeDups(noteIDs)
+	if len(noteIDs) == 0 {
+		return nil
+	}
+	return transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		return trashNotes(tx, noteIDs, time.Now().UTC())
+	})
+}
+
+func (noteRepo *postgresNoteRepository) DeleteNote(noteID int64) error {
+	return noteRepo.DeleteNotes([]int64{noteID})
+}
+
+//RestoreNotes takes the notes out of the trash, returns error if any of the notes is not in the trash.
+func (noteRepo *postgresNoteRepository) RestoreNotes(noteIDs []int64) error {
+	noteIDs = removeDups(noteIDs)
+	if len(noteIDs) == 0 {
+		return nil
+	}
+	return transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		return restoreNotes(tx, noteIDs)
+	})
+}
+
+//PurgeNotes deletes for good the notes moved to the trash before deletedBefore, along with their tags, revisions
+//and attachments.
+func (noteRepo *postgresNoteRepository) PurgeNotes(deletedBefore time.Time) (int, error) {
+	return purgeNotes(noteRepo.dbHandle, deletedBefore, deletePostgresNotes)
+}
+
+//SearchNotesByKeyword searches the title and memo of notes for every word of keyword using the tsvector index.
+//Keyword cannot be empty, like the sqlite implementation only complete words are matched unless they end with *.
+//Notes are sorted by relevance.
+func (noteRepo *postgresNoteRepository) SearchNotesByKeyword(keyword string) ([]*model.Note, error) {
+	return searchNotesByKeyword(noteRepo, keyword)
+}
+
+//SearchNotes returns a page of the notes matching filter ranked by the text terms of filter.Query,
+//see NoteRepository. Notes are ranked by ts_rank and highlighted by ts_headline.
+func (noteRepo *postgresNoteRepository) SearchNotes(filter NoteFilter, query NoteQuery) (*SearchPage, error) {
+	terms := searchTerms(filter.Query)
+	if err := query.normalizeSearch(len(terms) > 0); err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		page, err := noteRepo.listNotes("SELECT "+postgresNoteColumns+" FROM note n", nil, nil, filter, query)
+		if err != nil {
+			return nil, err
+		}
+		return &SearchPage{Hits: plainHits(page.Notes), Next: page.Next}, nil
+	}
+	if filter.Fuzzy {
+		return searchFuzzySQL(noteRepo, noteRepo.dbHandle, noteRepo.fuzzy, filter, query)
+	}
+
+	phrases := make([]string, 0, len(terms))
+	for _, term := range terms {
+		phrases = append(phrases, "("+postgresPhrase(term.tokens, term.prefix)+")")
+	}
+	selectFrom := `SELECT ` + postgresNoteColumns + `, ts_rank(?::float4[], n.search, q) AS relevance,
+		ts_headline('simple', n.title, q, ?) AS highlighted_title,
+		ts_headline('simple', n.memo, q, ?) AS snippet
+		FROM note n, to_tsquery('simple', ?) q`
+	sqlQuery, args, err := listNotesSQL(postgresDialect, selectFrom, []string{"n.search @@ q"},
+		[]interface{}{postgresRankWeights, postgresHeadline.title, postgresHeadline.snippet, strings.Join(phrases, " | ")},
+		filter, query)
+	if err != nil {
+		return nil, err
+	}
+	rows := []*searchRow{}
+	if err := noteRepo.Select(&rows, noteRepo.Rebind(sqlQuery), args...); err != nil {
+		return nil, err
+	}
+	notes := make([]*model.Note, 0, len(rows))
+	hits := make([]*SearchHit, 0, len(rows))
+	for _, row := range rows {
+		notes = append(notes, &row.Note)
+		hits = append(hits, &SearchHit{Note: &row.Note, Rank: row.Relevance, Title: row.HighlightedTitle, Snippet: row.Snippet})
+	}
+	if err := loadTags(noteRepo.dbHandle, notes); err != nil {
+		return nil, err
+	}
+	return newSearchPage(hits, query), nil
+}
+
+//GetRevisions returns the revisions of a note oldest first, see NoteRepository.
+func (noteRepo *postgresNoteRepository) GetRevisions(noteID int64) ([]*model.Revision, error) {
+	return selectRevisions(noteRepo.dbHandle, noteID)
+}
+
+//GetRevision returns a revision of a note, returns error if the note or the revision doesn't exist
+func (noteRepo *postgresNoteRepository) GetRevision(noteID int64, revision int) (*model.Revision, error) {
+	return selectRevision(noteRepo.dbHandle, noteID, revision)
+}
+
+//RestoreRevision sets the title and memo of a note to those of the revision, see NoteRepository.
+func (noteRepo *postgresNoteRepository) RestoreRevision(noteID int64, revision int) (*model.Note, error) {
+	return restoreRevision(noteRepo, noteID, revision)
+}
+
+//GetLinks returns the notes out of the trash that the memo of the note links to, see NoteRepository.
+func (noteRepo *postgresNoteRepository) GetLinks(noteID int64) ([]*model.Note, error) {
+	return getLinks(noteRepo, noteRepo.dbHandle, noteID)
+}
+
+//GetBacklinks returns the notes out of the trash whose memo links to the note.
+func (noteRepo *postgresNoteRepository) GetBacklinks(noteID int64) ([]*model.Note, error) {
+	return getBacklinks(noteRepo, noteRepo.dbHandle, noteID)
+}
+
+//AddAttachment attaches a file to a note out of the trash, see NoteRepository.
+func (noteRepo *postgresNoteRepository) AddAttachment(attachment *model.Attachment) (int64, error) {
+	return addAttachment(noteRepo.dbHandle, attachment, insertPostgresAttachment)
+}
+
+//GetAttachments returns the attachments of the note without their content, oldest first.
+func (noteRepo *postgresNoteRepository) GetAttachments(noteID int64) ([]*model.Attachment, error) {
+	return getAttachments(noteRepo.dbHandle, noteID)
+}
+
+//GetAttachment returns an attachment with its content, returns error if it doesn't exist or its note is in the trash.
+func (noteRepo *postgresNoteRepository) GetAttachment(attachmentID int64) (*model.Attachment, error) {
+	return getAttachment(noteRepo.dbHandle, attachmentID)
+}
+
+//GetNotesByTag returns all notes tagged with one or more of tags given as inputs or their descendants, eg: lang/go for lang
+func (noteRepo *postgresNoteRepository) GetNotesByTag(tags []string) ([]*model.Note, error) {
+	if len(tags) == 0 {
+		return []*model.Note{}, nil
+	}
+	page, err := noteRepo.ListNotes(NoteFilter{Tags: tags}, NoteQuery{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Notes, nil
+}
+
+//ListTags returns the tags of the notes out of the trash with the number of notes tagged with them, sorted by tag.
+func (noteRepo *postgresNoteRepository) ListTags() ([]*TagCount, error) {
+	return listTags(noteRepo.dbHandle)
+}
+
+//RenameTag renames the tag on every note and returns the number of renamed notes, see NoteRepository.
+func (noteRepo *postgresNoteRepository) RenameTag(oldTag, newTag string) (int, error) {
+	oldTag, newTag, err := renameTagArgs(oldTag, newTag)
+	if err != nil {
+		return 0, err
+	}
+	renamed := 0
+	err = transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		renamed, err = renameTag(tx, oldTag, newTag)
+		return err
+	})
+	return renamed, err
+}
+
+//MergeTags replaces every one of tags with into and returns the number of merged notes, see NoteRepository.
+func (noteRepo *postgresNoteRepository) MergeTags(tags []string, into string) (int, error) {
+	tags, into, err := mergeTagsArgs(tags, into)
+	if err != nil {
+		return 0, err
+	}
+	merged := 0
+	err = transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		merged, err = mergeTags(tx, tags, into)
+		return err
+	})
+	return merged, err
+}
+
+//DeleteTag removes the tag from every note and returns the number of notes it was removed from.
+func (noteRepo *postgresNoteRepository) DeleteTag(tag string) (int, error) {
+	deleted := 0
+	err := transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) (err error) {
+		deleted, err = deleteTag(tx, model.NormalizeTag(tag))
+		return err
+	})
+	return deleted, err
+}
+
+func (noteRepo *postgresNoteRepository) CloseDB() error {
+	return closeHandle(noteRepo.dbHandle)
+}
+
+//listNotes runs the query built by listNotesSQL and returns the page of notes, query must be normalized.
+func (noteRepo *postgresNoteRepository) listNotes(selectFrom string, conditions []string, args []interface{},
+	filter NoteFilter, query NoteQuery) (*NotePage, error) {
+	sqlQuery, args, err := listNotesSQL(postgresDialect, selectFrom, conditions, args, filter, query)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := noteRepo.selectNotes(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return newNotePage(notes, query), nil
+}
+
+//selectNotes runs a query with ? placeholders and loads the tags of every returned note.
+func (noteRepo *postgresNoteRepository) selectNotes(query string, args ...interface{}) ([]*model.Note, error) {
+	notes := []*model.Note{}
+	if err := noteRepo.Select(&notes, noteRepo.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	if err := loadTags(noteRepo.dbHandle, notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func insertPostgresTags(tx *sqlx.Tx, noteID int64, tags map[string]bool) error {
+	for tag := range tags {
+		if _, err := tx.Exec(`INSERT INTO note_tag (note_id, tag) VALUES($1, $2)`, noteID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deletePostgresNotes(tx *sqlx.Tx, noteIDs []int64) error {
+	for _, query := range []string{
+		"DELETE FROM note WHERE id IN (?)",
+		"DELETE FROM note_tag WHERE note_id IN (?)",
+		"DELETE FROM notebook_note WHERE note_id IN (?)",
+		"DELETE FROM note_revision WHERE note_id IN (?)",
+		"DELETE FROM note_link WHERE note_id IN (?)",
+		"DELETE FROM attachment WHERE note_id IN (?)",
+	} {
+		query, args, err := sqlx.In(query, noteIDs)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(tx.Rebind(query), args...); err != nil {
+			return err
+		}
+	}
+	return deleteOrphanBlobs(tx)
+}
+
+//insertPostgresAttachment stores the content unless it is already stored and the attachment referring to it.
+func insertPostgresAttachment(tx *sqlx.Tx, attachment *model.Attachment) (int64, error) {
+	if _, err := tx.Exec(`INSERT INTO attachment_blob (hash, content) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		attachment.Hash, attachment.Content); err != nil {
+		return -1, err
+	}
+	var attachmentID int64
+	err := tx.Get(&attachmentID, `INSERT INTO attachment (note_id, name, hash, size, created)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		attachment.NoteID, attachment.Name, attachment.Hash, attachment.Size, attachment.Created)
+	return attachmentID, err
+}
+
+//postgresPhrase returns the tsquery matching tokens in sequence, the last token matches as prefix if prefix is set.
+func postgresPhrase(tokens []string, prefix bool) string {
+	tsQuery := strings.Join(tokens, " <-> ")
+	if prefix {
+		tsQuery += ":*"
+	}
+	return tsQuery
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/nicolasmanic/tefter/model"
+	"time"
+)
+
+type postgresNotebookRepository struct {
+	dbHandle
+}
+
+//NewPostgresNotebookRepository returns a NotebookRepository interface backed by the postgres DB described by dsn,
+//use NewPostgresStore to share a connection between repositories.
+func NewPostgresNotebookRepository(dsn string) NotebookRepository {
+	return NewPostgresStore(dsn).Notebooks()
+}
+
+//SaveNotebook saves a notebook under the notebook with id notebook.ParentID, or at the top level if it is 0.
+func (notebookRepo *postgresNotebookRepository) SaveNotebook(notebook *model.Notebook) (int64, error) {
+	if err := validateNotebookTitle(notebook.Title); err != nil {
+		return -1, err
+	}
+	if err := checkNotebookParent(notebookRepo.dbHandle, notebook); err != nil {
+		return -1, err
+	}
+	var notebookID int64
+	err := notebookRepo.Get(&notebookID, `INSERT INTO notebook (title, parent_id) VALUES($1, $2) RETURNING id`, notebook.Title, notebook.ParentID)
+	if isUniqueViolation(err) {
+		return -1, notebookExistsError(notebookRepo.dbHandle, notebook)
+	}
+	if err != nil {
+		return -1, err
+	}
+	notebook.ID = notebookID
+	return notebookID, nil
+}
+
+func (notebookRepo *postgresNotebookRepository) GetNotebooks(notebooksIDs []int64) ([]*model.Notebook, error) {
+	notebooksIDs = removeDups(notebooksIDs)
+	query := "SELECT id, title, parent_id FROM notebook WHERE deleted_at IS NULL"
+	args := []interface{}{}
+	if len(notebooksIDs) != 0 {
+		var err error
+		query, args, err = sqlx.In("SELECT id, title, parent_id FROM notebook WHERE deleted_at IS NULL AND id IN (?)", notebooksIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return notebookRepo.selectNotebooks(false, query, args...)
+}
+
+func (notebookRepo *postgresNotebookRepository) GetNotebook(notebookID int64) (*model.Notebook, error) {
+	notebooks, err := notebookRepo.GetNotebooks([]int64{notebookID})
+	if err != nil {
+		return nil, err
+	}
+	if len(notebooks) != 1 {
+		return nil, newError(ErrNotebookNotFound, "Could find notebook with id: %v", notebookID)
+	}
+	return notebooks[0], nil
+}
+
+//GetNotebookByTitle returns the notebook at path, eg: work/infra, or nil if no such notebook exists.
+func (notebookRepo *postgresNotebookRepository) GetNotebookByTitle(path string) (*model.Notebook, error) {
+	notebookID, err := resolveNotebookPath(notebookRepo.dbHandle, path)
+	if err != nil {
+		return nil, err
+	}
+	if notebookID == 0 {
+		return nil, nil
+	}
+	return notebookRepo.GetNotebook(notebookID)
+}
+
+//UpdateNotebook sets the title and the parent of an existing notebook.
+func (notebookRepo *postgresNotebookRepository) UpdateNotebook(notebook *model.Notebook) error {
+	if err := validateNotebookTitle(notebook.Title); err != nil {
+		return err
+	}
+	if err := checkNotebookParent(notebookRepo.dbHandle, notebook); err != nil {
+		return err
+	}
+	_, err := notebookRepo.Exec(`UPDATE notebook SET title = $1, parent_id = $2 WHERE id = $3`, notebook.Title, notebook.ParentID, notebook.ID)
+	if isUniqueViolation(err) {
+		return notebookExistsError(notebookRepo.dbHandle, notebook)
+	}
+	return err
+}
+
+//DeleteNotebooks moves the notebooks and all of their notes to the trash in a single transaction, the default
+//notebook and notebooks with children that are not deleted along with them can not be deleted.
+func (notebookRepo *postgresNotebookRepository) DeleteNotebooks(notebooksIDs []int64) error {
+	notebooksIDs = removeDups(notebooksIDs)
+	if len(notebooksIDs) == 0 {
+		return nil
+	}
+	for _, id := range notebooksIDs {
+		if id == DEFAULT_NOTEBOOK_ID {
+			return newError(ErrDefaultNotebookProtected, "Default notebook can not be deleted")
+		}
+	}
+
+	return transaction(notebookRepo.dbHandle, func(tx *sqlx.Tx) error {
+		if err := checkNoChildren(tx, notebooksIDs); err != nil {
+			return err
+		}
+		return trashNotebooks(tx, notebooksIDs, time.Now().UTC())
+	})
+}
+
+func (notebookRepo *postgresNotebookRepository) DeleteNotebook(notebookID int64) error {
+	return notebookRepo.DeleteNotebooks([]int64{notebookID})
+}
+
+//GetAllNotebooksTitle returns the path of every notebook out of the trash, eg: work/infra.
+func (notebookRepo *postgresNotebookRepository) GetAllNotebooksTitle() (map[int64]string, error) {
+	notebooks := []*model.Notebook{}
+	if err := notebookRepo.Select(&notebooks, "SELECT id, title, parent_id FROM notebook WHERE deleted_at IS NULL"); err != nil {
+		return nil, err
+	}
+	return notebookPaths(notebooks), nil
+}
+
+//GetTrashedNotebooks returns the notebooks in the trash with their notes, most recently deleted first.
+func (notebookRepo *postgresNotebookRepository) GetTrashedNotebooks() ([]*model.Notebook, error) {
+	return notebookRepo.selectNotebooks(true, `SELECT id, title, parent_id, deleted_at FROM notebook
+		WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id`)
+}
+
+//RestoreNotebooks takes the notebooks out of the trash, returns error if any of the notebooks is not in the trash.
+func (notebookRepo *postgresNotebookRepository) RestoreNotebooks(notebooksIDs []int64) error {
+	notebooksIDs = removeDups(notebooksIDs)
+	if len(notebooksIDs) == 0 {
+		return nil
+	}
+	return transaction(notebookRepo.dbHandle, func(tx *sqlx.Tx) error {
+		return restoreNotebooks(tx, notebooksIDs)
+	})
+}
+
+//PurgeNotebooks deletes for good the notebooks moved to the trash before deletedBefore and all of their notes.
+func (notebookRepo *postgresNotebookRepository) PurgeNotebooks(deletedBefore time.Time) (int, error) {
+	return purgeNotebooks(notebookRepo.dbHandle, deletedBefore, deletePostgresNotes)
+}
+
+func (notebookRepo *postgresNotebookRepository) CloseDB() error {
+	return closeHandle(notebookRepo.dbHandle)
+}
+
+//selectNotebooks runs a query with ? placeholders and loads the notes of every returned notebook,
+//notes are loaded in batches through a note repository sharing the same connection. Only the notes
+//in the trash are loaded if trashed is set, the notes out of it otherwise.
+func (notebookRepo *postgresNotebookRepository) selectNotebooks(trashed bool, query string, args ...interface{}) ([]*model.Notebook, error) {
+	notebooks := []*model.Notebook{}
+	if err := notebookRepo.Select(&notebooks, notebookRepo.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	noteRepo := &postgresNoteRepository{dbHandle: notebookRepo.dbHandle}
+	notebooksByID := make(map[int64]*model.Notebook, len(notebooks))
+	notebookIDs := make([]int64, 0, len(notebooks))
+	for _, notebook := range notebooks {
+		notebook.Notes = make(map[int64]*model.Note)
+		notebooksByID[notebook.ID] = notebook
+		notebookIDs = append(notebookIDs, notebook.ID)
+	}
+	for _, batch := range batchIDs(notebookIDs) {
+		query, args, err := sqlx.In("SELECT "+postgresNoteColumns+" FROM note n WHERE n.notebook_id IN (?) AND "+
+			trashedCondition(trashed)+" ORDER BY n.created desc", batch)
+		if err != nil {
+			return nil, err
+		}
+		notes, err := noteRepo.selectNotes(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, note := range notes {
+			notebooksByID[note.NotebookID].Notes[note.ID] = note
+		}
+	}
+	return notebooks, nil
+}
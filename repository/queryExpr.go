@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"github.com/nicolasmanic/tefter/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//QueryExpr is a parsed note query, see ParseQuery. Set it to NoteFilter.Query to list the matching notes.
+type QueryExpr interface {
+	//String returns the query in canonical form, eg: (tag:go AND -tag:done)
+	String() string
+	//sql returns the condition of the expression with ? placeholders and its args
+	sql(dialect queryDialect) (string, []interface{})
+	//matches reports whether a note matches the expression in memory, paths holds the path of every notebook
+	//out of the trash, see activeNotebookPaths
+	matches(note *model.Note, paths map[int64]string) bool
+}
+
+//queryDialect holds the SQL that differs between the backends.
+type queryDialect struct {
+	//fullText returns the condition matching the notes containing tokens in sequence,
+	//the last token matches as prefix if prefix is set.
+	fullText func(tokens []string, prefix bool) (string, []interface{})
+	//time returns the SQL expression of the time value that compares in time order whatever its zone is, value
+	//is a column or a placeholder.
+	time func(value string) string
+}
+
+type andExpr struct {
+	exprs []QueryExpr
+}
+
+type orExpr struct {
+	exprs []QueryExpr
+}
+
+type notExpr struct {
+	expr QueryExpr
+}
+
+//likeEscaper escapes the wildcards of LIKE patterns that use ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+//textExpr matches the notes containing the tokens in title or memo
+type textExpr struct {
+	tokens []string
+	prefix bool
+	phrase bool
+}
+
+//tagExpr matches the notes tagged with tag or one of its descendants, eg: tag:lang matches lang/go
+type tagExpr struct {
+	tag string
+}
+
+//notebookExpr matches the notes of the notebook out of the trash at path, eg: notebook:work/infra
+type notebookExpr struct {
+	path string
+}
+
+//titleExpr matches the notes whose title contains text, case insensitive
+type titleExpr struct {
+	text string
+}
+
+type idExpr struct {
+	id int64
+}
+
+//dateExpr matches the notes whose created or updated time is in [from, to), a zero time is unbounded.
+type dateExpr struct {
+	field    string
+	operator string
+	day      time.Time
+	from     time.Time
+	to       time.Time
+}
+
+func (expr *andExpr) String() string {
+	return joinExprs(expr.exprs, " AND ")
+}
+
+func (expr *andExpr) sql(dialect queryDialect) (string, []interface{}) {
+	return joinSQL(expr.exprs, " AND ", dialect)
+}
+
+func (expr *andExpr) matches(note *model.Note, paths map[int64]string) bool {
+	for _, e := range expr.exprs {
+		if !e.matches(note, paths) {
+			return false
+		}
+	}
+	return true
+}
+
+func (expr *orExpr) String() string {
+	return joinExprs(expr.exprs, " OR ")
+}
+
+func (expr *orExpr) sql(dialect queryDialect) (string, []interface{}) {
+	return joinSQL(expr.exprs, " OR ", dialect)
+}
+
+func (expr *orExpr) matches(note *model.Note, paths map[int64]string) bool {
+	for _, e := range expr.exprs {
+		if e.matches(note, paths) {
+			return true
+		}
+	}
+	return false
+}
+
+func (expr *notExpr) String() string {
+	return "-" + expr.expr.String()
+}
+
+func (expr *notExpr) sql(dialect queryDialect) (string, []interface{}) {
+	condition, args := expr.expr.sql(dialect)
+	return "NOT (" + condition + ")", args
+}
+
+func (expr *notExpr) matches(note *model.Note, paths map[int64]string) bool {
+	return !expr.expr.matches(note, paths)
+}
+
+func (expr *textExpr) String() string {
+	text := strings.Join(expr.tokens, " ")
+	if expr.prefix {
+		text += "*"
+	}
+	if expr.phrase || len(expr.tokens) > 1 {
+		return strconv.Quote(text)
+	}
+	return text
+}
+
+func (expr *textExpr) sql(dialect queryDialect) (string, []interface{}) {
+	return dialect.fullText(expr.tokens, expr.prefix)
+}
+
+func (expr *textExpr) matches(note *model.Note, paths map[int64]string) bool {
+	return expr.count(tokenize(note.Title+" "+note.Memo)) > 0
+}
+
+//count returns the number of matches of expr in tokens
+func (expr *textExpr) count(tokens []string) int {
+	count := 0
+	for start := range tokens {
+		if expr.matchesAt(tokens, start) {
+			count++
+		}
+	}
+	return count
+}
+
+//matchesAt reports whether the tokens of expr follow each other in tokens starting at start.
+func (expr *textExpr) matchesAt(tokens []string, start int) bool {
+	if start+len(expr.tokens) > len(tokens) {
+		return false
+	}
+	last := len(expr.tokens) - 1
+	for i, token := range expr.tokens {
+		if i == last && expr.prefix {
+			if !strings.HasPrefix(tokens[start+i], token) {
+				return false
+			}
+		} else if tokens[start+i] != token {
+			return false
+		}
+	}
+	return true
+}
+
+func (expr *tagExpr) String() string {
+	return "tag:" + quoteValue(expr.tag)
+}
+
+func (expr *tagExpr) sql(dialect queryDialect) (string, []interface{}) {
+	condition, args := subTagsCondition([]string{expr.tag})
+	return "n.id IN (SELECT note_id FROM note_tag WHERE " + condition + ")", args
+}
+
+func (expr *tagExpr) matches(note *model.Note, paths map[int64]string) bool {
+	return note.HasSubTag(expr.tag)
+}
+
+func (expr *notebookExpr) String() string {
+	return "notebook:" + quoteValue(expr.path)
+}
+
+func (expr *notebookExpr) sql(dialect queryDialect) (string, []interface{}) {
+	return "n.notebook_id IN (" + notebookPathsCTE + " SELECT id FROM notebook_path WHERE path = ?)", []interface{}{expr.path}
+}
+
+func (expr *notebookExpr) matches(note *model.Note, paths map[int64]string) bool {
+	path, ok := paths[note.NotebookID]
+	return ok && path == expr.path
+}
+
+func (expr *titleExpr) String() string {
+	return "title:" + quoteValue(expr.text)
+}
+
+func (expr *titleExpr) sql(dialect queryDialect) (string, []interface{}) {
+	escaped := likeEscaper.Replace(strings.ToLower(expr.text))
+	return `LOWER(n.title) LIKE ? ESCAPE '\'`, []interface{}{"%" + escaped + "%"}
+}
+
+func (expr *titleExpr) matches(note *model.Note, paths map[int64]string) bool {
+	return strings.Contains(strings.ToLower(note.Title), strings.ToLower(expr.text))
+}
+
+func (expr *idExpr) String() string {
+	return "id:" + strconv.FormatInt(expr.id, 10)
+}
+
+func (expr *idExpr) sql(dialect queryDialect) (string, []interface{}) {
+	return "n.id = ?", []interface{}{expr.id}
+}
+
+func (expr *idExpr) matches(note *model.Note, paths map[int64]string) bool {
+	return note.ID == expr.id
+}
+
+func (expr *dateExpr) String() string {
+	return expr.field + ":" + expr.operator + expr.day.Format(queryDateLayout)
+}
+
+func (expr *dateExpr) sql(dialect queryDialect) (string, []interface{}) {
+	column := noteSortColumns[SortByCreated]
+	if expr.field == "updated" {
+		column = noteSortColumns[SortByLastUpdated]
+	}
+	conditions := []string{}
+	args := []interface{}{}
+	if !expr.from.IsZero() {
+		conditions = append(conditions, dialect.time(column)+" >= "+dialect.time("?"))
+		args = append(args, expr.from)
+	}
+	if !expr.to.IsZero() {
+		conditions = append(conditions, dialect.time(column)+" < "+dialect.time("?"))
+		args = append(args, expr.to)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func (expr *dateExpr) matches(note *model.Note, paths map[int64]string) bool {
+	t := note.Created
+	if expr.field == "updated" {
+		t = note.LastUpdated
+	}
+	return (expr.from.IsZero() || !t.Before(expr.from)) && (expr.to.IsZero() || t.Before(expr.to))
+}
+
+func joinExprs(exprs []QueryExpr, separator string) string {
+	parts := make([]string, 0, len(exprs))
+	for _, expr := range exprs {
+		parts = append(parts, expr.String())
+	}
+	return "(" + strings.Join(parts, separator) + ")"
+}
+
+func joinSQL(exprs []QueryExpr, separator string, dialect queryDialect) (string, []interface{}) {
+	conditions := make([]string, 0, len(exprs))
+	args := []interface{}{}
+	for _, expr := range exprs {
+		condition, exprArgs := expr.sql(dialect)
+		conditions = append(conditions, "("+condition+")")
+		args = append(args, exprArgs...)
+	}
+	return strings.Join(conditions, separator), args
+}
+
+//quoteValue quotes the value of a field term if it contains characters the lexer would split on.
+func quoteValue(value string) string {
+	if strings.ContainsAny(value, " \t()\"") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"github.com/nicolasmanic/tefter/model"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+//queryDateLayout is the layout of the dates of created: and updated: terms
+const queryDateLayout = "2006-01-02"
+
+//ParseQuery parses a note query such as: tag:go tag:todo notebook:work updated:>2026-10-01 "exact phrase" -tag:done
+//
+//Terms are separated by spaces and all of them must match, OR matches either side and parentheses group terms,
+//a term starting with - matches the notes the term doesn't match. Available terms:
+//  word, "exact phrase"       title or memo contains the words, a word ending with * matches as prefix
+//  tag:go                     note is tagged with go
+//  notebook:work              note belongs to notebook with title work
+//  title:plan                 title contains plan, case insensitive
+//  id:42                      note has id 42
+//  created:2026-10-01         note was created that day, the date can be prefixed with >, >=, < or <=
+//  updated:>=2026-10-01       same as created for the last update of the note
+//Values containing spaces can be quoted, eg: notebook:"my work". Words with a colon that are not one of the fields,
+//eg: todo: or http://example.com, are plain words. ParseQuery returns nil for an empty query
+//and an ErrValidation error for an invalid query.
+func ParseQuery(query string) (QueryExpr, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	parser := &queryParser{tokens: tokens}
+	expr, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !parser.done() {
+		return nil, newError(ErrValidation, "Unexpected %q in query: %v", parser.peek().text, query)
+	}
+	return expr, nil
+}
+
+type queryTokenKind int
+
+const (
+	tokenTerm queryTokenKind = iota
+	tokenPhrase
+	tokenNot
+	tokenOr
+	tokenAnd
+	tokenLParen
+	tokenRParen
+)
+
+//queryToken is a token of a query, field is only set for terms such as tag:go
+type queryToken struct {
+	kind  queryTokenKind
+	field string
+	text  string
+}
+
+//lexQuery splits a query to tokens.
+func lexQuery(query string) ([]queryToken, error) {
+	tokens := []queryToken{}
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokenLParen, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokenRParen, text: ")"})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, queryToken{kind: tokenNot, text: "-"})
+			i++
+		case r == '"':
+			text, next, err := lexQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{kind: tokenPhrase, text: text})
+			i = next
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()\"", runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			if colon := strings.Index(word, ":"); colon > 0 && isQueryField(word[:colon]) {
+				token := queryToken{kind: tokenTerm, field: word[:colon], text: word[colon+1:]}
+				if token.text == "" && i < len(runes) && runes[i] == '"' {
+					text, next, err := lexQuoted(runes, i)
+					if err != nil {
+						return nil, err
+					}
+					token.text, i = text, next
+				}
+				if token.text == "" {
+					return nil, newError(ErrValidation, "Missing value of query term: %v", word)
+				}
+				tokens = append(tokens, token)
+				continue
+			}
+			//words with a colon that are not fields, eg: todo: or http://example.com, are plain terms
+			switch word {
+			case "OR":
+				tokens = append(tokens, queryToken{kind: tokenOr, text: word})
+			case "AND":
+				tokens = append(tokens, queryToken{kind: tokenAnd, text: word})
+			default:
+				tokens = append(tokens, queryToken{kind: tokenTerm, text: word})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+//lexQuoted returns the text between the quote at start and the closing quote, and the position after the closing quote.
+func lexQuoted(runes []rune, start int) (string, int, error) {
+	for end := start + 1; end < len(runes); end++ {
+		if runes[end] == '"' {
+			return string(runes[start+1 : end]), end + 1, nil
+		}
+	}
+	return "", 0, newError(ErrValidation, "Missing closing quote in query: %v", string(runes))
+}
+
+func isQueryField(field string) bool {
+	switch field {
+	case "tag", "notebook", "title", "id", "created", "updated":
+		return true
+	}
+	return false
+}
+
+//queryParser is a recursive descent parser of the grammar:
+//  or      = and { "OR" and }
+//  and     = unary { ["AND"] unary }
+//  unary   = "-" unary | primary
+//  primary = "(" or ")" | term
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (parser *queryParser) done() bool {
+	return parser.pos >= len(parser.tokens)
+}
+
+func (parser *queryParser) peek() queryToken {
+	return parser.tokens[parser.pos]
+}
+
+func (parser *queryParser) parseOr() (QueryExpr, error) {
+	exprs := []QueryExpr{}
+	for {
+		expr, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		if parser.done() || parser.peek().kind != tokenOr {
+			break
+		}
+		parser.pos++
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return &orExpr{exprs}, nil
+}
+
+func (parser *queryParser) parseAnd() (QueryExpr, error) {
+	exprs := []QueryExpr{}
+	for !parser.done() && parser.peek().kind != tokenOr && parser.peek().kind != tokenRParen {
+		if parser.peek().kind == tokenAnd && len(exprs) > 0 {
+			parser.pos++
+		}
+		expr, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	switch len(exprs) {
+	case 0:
+		return nil, parser.unexpected()
+	case 1:
+		return exprs[0], nil
+	}
+	return &andExpr{exprs}, nil
+}
+
+func (parser *queryParser) parseUnary() (QueryExpr, error) {
+	if parser.done() {
+		return nil, parser.unexpected()
+	}
+	if parser.peek().kind == tokenNot {
+		parser.pos++
+		expr, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{expr}, nil
+	}
+	return parser.parsePrimary()
+}
+
+func (parser *queryParser) parsePrimary() (QueryExpr, error) {
+	token := parser.peek()
+	switch token.kind {
+	case tokenLParen:
+		parser.pos++
+		expr, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if parser.done() || parser.peek().kind != tokenRParen {
+			return nil, newError(ErrValidation, "Missing closing parenthesis in query")
+		}
+		parser.pos++
+		return expr, nil
+	case tokenTerm, tokenPhrase:
+		parser.pos++
+		return newTermExpr(token)
+	}
+	return nil, parser.unexpected()
+}
+
+func (parser *queryParser) unexpected() error {
+	if parser.done() {
+		return newError(ErrValidation, "Unexpected end of query")
+	}
+	return newError(ErrValidation, "Unexpected %q in query", parser.peek().text)
+}
+
+//newTermExpr returns the expression of a single term token.
+func newTermExpr(token queryToken) (QueryExpr, error) {
+	switch token.field {
+	case "tag":
+		return &tagExpr{model.NormalizeTag(token.text)}, nil
+	case "notebook":
+		return &notebookExpr{token.text}, nil
+	case "title":
+		return &titleExpr{token.text}, nil
+	case "id":
+		id, err := strconv.ParseInt(token.text, 10, 64)
+		if err != nil {
+			return nil, newError(ErrValidation, "Invalid note id in query: %v", token.text)
+		}
+		return &idExpr{id}, nil
+	case "created", "updated":
+		return newDateExpr(token.field, token.text)
+	}
+	text := token.text
+	prefix := token.kind == tokenTerm && strings.HasSuffix(text, "*")
+	tokens := tokenize(text)
+	if len(tokens) == 0 {
+		return nil, newError(ErrValidation, "Query term %q contains no words", text)
+	}
+	return &textExpr{tokens: tokens, prefix: prefix, phrase: token.kind == tokenPhrase}, nil
+}
+
+//newDateExpr parses a date such as >=2026-10-01 to the range of times it matches.
+func newDateExpr(field, value string) (QueryExpr, error) {
+	operator := ""
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, op) {
+			operator = op
+			break
+		}
+	}
+	day, err := time.ParseInLocation(queryDateLayout, value[len(operator):], time.Local)
+	if err != nil {
+		return nil, newError(ErrValidation, "Invalid date of %v: %v, dates should be formatted as %v", field, value, queryDateLayout)
+	}
+	nextDay := day.AddDate(0, 0, 1)
+	expr := &dateExpr{field: field, operator: operator, day: day}
+	switch operator {
+	case ">":
+		expr.from = nextDay
+	case ">=":
+		expr.from = day
+	case "<":
+		expr.to = day
+	case "<=":
+		expr.to = nextDay
+	default:
+		expr.from, expr.to = day, nextDay
+	}
+	return expr, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{"tag:go", "tag:go"},
+		{"tag:go tag:todo notebook:work updated:>2026-10-01", "(tag:go AND tag:todo AND notebook:work AND updated:>2026-10-01)"},
+		{`"Exact Phrase" -tag:done`, `("exact phrase" AND -tag:done)`},
+		{"tag:go OR tag:rust AND title:plan", "(tag:go OR (tag:rust AND title:plan))"},
+		{"(tag:go OR tag:rust) -(notebook:\"my work\" id:42)", `((tag:go OR tag:rust) AND -(notebook:"my work" AND id:42))`},
+		{"holi* created:<=2018-06-01", "(holi* AND created:<=2018-06-01)"},
+		{"foo-bar 12:30", `("foo bar" AND "12 30")`},
+		{"todo: buy milk", "(todo AND buy AND milk)"},
+		{"http://example.com", `"http example com"`},
+		{"tga:go", `"tga go"`},
+		{"  ", "<nil>"},
+	}
+	for _, c := range cases {
+		expr, err := ParseQuery(c.query)
+		if err != nil {
+			t.Errorf("Could not parse query: %v, error msg: %v", c.query, err)
+			continue
+		}
+		result := "<nil>"
+		if expr != nil {
+			result = expr.String()
+		}
+		if result != c.expected {
+			t.Errorf("Expected query: %v to be parsed to: %v, got: %v", c.query, c.expected, result)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	queries := []string{
+		"tag:",
+		`"open phrase`,
+		"(tag:go",
+		"tag:go)",
+		"tag:go OR",
+		"-",
+		"id:abc",
+		"created:yesterday",
+		`"..."`,
+	}
+	for _, query := range queries {
+		if _, err := ParseQuery(query); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected validation error for query: %v, got: %v", query, err)
+		}
+	}
+}
+
+func TestDateExprRange(t *testing.T) {
+	expr, _ := ParseQuery("updated:>2026-10-01")
+	date := expr.(*dateExpr)
+	if !date.to.IsZero() || date.from.Format(queryDateLayout) != "2026-10-02" {
+		t.Errorf("Expected > to match from the next day, got from: %v to: %v", date.from, date.to)
+	}
+	expr, _ = ParseQuery("created:2026-10-01")
+	date = expr.(*dateExpr)
+	if date.from.Format(queryDateLayout) != "2026-10-01" || date.to.Format(queryDateLayout) != "2026-10-02" {
+		t.Errorf("Expected a date to match the whole day, got from: %v to: %v", date.from, date.to)
+	}
+}
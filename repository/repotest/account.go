@@ -0,0 +1,88 @@
+package repotest
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/repository"
+	"reflect"
+	"testing"
+)
+
+//RunAccountRepository checks the AccountRepository contract.
+func RunAccountRepository(t *testing.T, factory Factory) {
+	runCases(t, factory, []testCase{
+		{"CreateAndGetAccount", testCreateAndGetAccount},
+		{"CreateAccountWithoutCredentials", testCreateAccountWithoutCredentials},
+		{"CreateDuplicateAccount", testCreateDuplicateAccount},
+		{"GetMissingAccount", testGetMissingAccount},
+		{"DeleteAccount", testDeleteAccount},
+		{"GetUsernames", testGetUsernames},
+	})
+}
+
+func testCreateAndGetAccount(t *testing.T, repos *Repositories) {
+	if err := repos.Accounts.CreateAccount("user", []byte("hashed")); err != nil {
+		t.Fatalf("Could not create account, error msg: %v", err)
+	}
+	account, err := repos.Accounts.GetAccount("user")
+	if err != nil {
+		t.Fatalf("Could not retrieve account, error msg: %v", err)
+	}
+	if account.Username != "user" || account.Password != "hashed" {
+		t.Errorf("Unexpected account: %+v", account)
+	}
+}
+
+func testCreateAccountWithoutCredentials(t *testing.T, repos *Repositories) {
+	if err := repos.Accounts.CreateAccount("", []byte("hashed")); !errors.Is(err, repository.ErrValidation) {
+		t.Error("Account without username should not be created")
+	}
+	if err := repos.Accounts.CreateAccount("user", []byte{}); !errors.Is(err, repository.ErrValidation) {
+		t.Error("Account without password should not be created")
+	}
+}
+
+func testCreateDuplicateAccount(t *testing.T, repos *Repositories) {
+	repos.Accounts.CreateAccount("user", []byte("hashed"))
+	if err := repos.Accounts.CreateAccount("user", []byte("other")); !errors.Is(err, repository.ErrAccountExists) {
+		t.Errorf("Usernames should be unique, got: %v", err)
+	}
+	account, _ := repos.Accounts.GetAccount("user")
+	if account == nil || account.Password != "hashed" {
+		t.Error("Failed create should not modify the existing account")
+	}
+}
+
+func testGetMissingAccount(t *testing.T, repos *Repositories) {
+	if _, err := repos.Accounts.GetAccount("missing"); !errors.Is(err, repository.ErrAccountNotFound) {
+		t.Errorf("Retrieving a missing account should fail with ErrAccountNotFound, got: %v", err)
+	}
+}
+
+func testDeleteAccount(t *testing.T, repos *Repositories) {
+	repos.Accounts.CreateAccount("user", []byte("hashed"))
+	if err := repos.Accounts.DeleteAccount("user"); err != nil {
+		t.Fatalf("Could not delete account, error msg: %v", err)
+	}
+	if _, err := repos.Accounts.GetAccount("user"); err == nil {
+		t.Error("Deleted account should not be retrieved")
+	}
+}
+
+func testGetUsernames(t *testing.T, repos *Repositories) {
+	if usernames, err := repos.Accounts.GetUsernames(); err != nil || len(usernames) != 0 {
+		t.Errorf("Expected no usernames, got: %v, error msg: %v", usernames, err)
+	}
+	repos.Accounts.CreateAccount("user1", []byte("hashed"))
+	repos.Accounts.CreateAccount("user2", []byte("hashed"))
+
+	usernames, err := repos.Accounts.GetUsernames()
+	if err != nil {
+		t.Fatalf("Could not retrieve usernames, error msg: %v", err)
+	}
+	if len(usernames) == 2 && usernames[0] > usernames[1] {
+		usernames[0], usernames[1] = usernames[1], usernames[0]
+	}
+	if !reflect.DeepEqual(usernames, []string{"user1", "user2"}) {
+		t.Errorf("Expected usernames user1, user2, got: %v", usernames)
+	}
+}
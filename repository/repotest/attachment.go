@@ -0,0 +1,150 @@
+package repotest
+
+import (
+	"bytes"
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"testing"
+	"time"
+)
+
+//RunAttachments checks that files attached to notes are stored, retrieved and deleted along with their note.
+func RunAttachments(t *testing.T, factory Factory) {
+	runCases(t, factory, []testCase{
+		{"AddAttachment", testAddAttachment},
+		{"SharedContent", testSharedContent},
+		{"AttachmentsOfTrashedNotes", testAttachmentsOfTrashedNotes},
+		{"AttachmentsOfPurgedNotebooks", testAttachmentsOfPurgedNotebooks},
+		{"InvalidAttachments", testInvalidAttachments},
+	})
+}
+
+func addAttachment(t *testing.T, repo repository.NoteRepository, noteID int64, name, content string) int64 {
+	t.Helper()
+	attachment := model.NewAttachment(noteID, name, []byte(content))
+	id, err := repo.AddAttachment(attachment)
+	if err != nil {
+		t.Fatalf("Could not add attachment, error msg: %v", err)
+	}
+	if id != attachment.ID {
+		t.Fatalf("Returned id: %v should be set to the attachment, got: %v", id, attachment.ID)
+	}
+	return id
+}
+
+func checkAttachment(t *testing.T, repo repository.NoteRepository, id int64, name, content string) {
+	t.Helper()
+	attachment, err := repo.GetAttachment(id)
+	if err != nil {
+		t.Fatalf("Could not retrieve attachment, error msg: %v", err)
+	}
+	if attachment.Name != name || !bytes.Equal(attachment.Content, []byte(content)) {
+		t.Errorf("Expected attachment %v with content %q, got: %v with content %q", name, content, attachment.Name, attachment.Content)
+	}
+	if attachment.Hash != model.ContentHash([]byte(content)) || attachment.Size != int64(len(content)) {
+		t.Errorf("Unexpected hash: %v or size: %v of attachment %v", attachment.Hash, attachment.Size, name)
+	}
+}
+
+func testAddAttachment(t *testing.T, repos *Repositories) {
+	noteID := saveNote(t, repos.Notes, newNote("title", "memo", 0, []string{}, 0))
+	otherID := saveNote(t, repos.Notes, newNote("other", "memo", 0, []string{}, 1))
+	logID := addAttachment(t, repos.Notes, noteID, "app.log", "line 1\nline 2")
+	emptyID := addAttachment(t, repos.Notes, noteID, "empty.txt", "")
+	addAttachment(t, repos.Notes, otherID, "other.txt", "other")
+
+	checkAttachment(t, repos.Notes, logID, "app.log", "line 1\nline 2")
+	checkAttachment(t, repos.Notes, emptyID, "empty.txt", "")
+	attachments, err := repos.Notes.GetAttachments(noteID)
+	if err != nil {
+		t.Fatalf("Could not retrieve attachments, error msg: %v", err)
+	}
+	if len(attachments) != 2 || attachments[0].ID != logID || attachments[1].ID != emptyID {
+		t.Fatalf("Expected attachments %v and %v, got: %v", logID, emptyID, attachments)
+	}
+	if attachments[0].NoteID != noteID || attachments[0].Size != 13 || attachments[0].Created.IsZero() || attachments[0].Content != nil {
+		t.Errorf("Unexpected attachment without content: %+v", attachments[0])
+	}
+}
+
+func testSharedContent(t *testing.T, repos *Repositories) {
+	deletedID := saveNote(t, repos.Notes, newNote("deleted", "memo", 0, []string{}, 0))
+	keptID := saveNote(t, repos.Notes, newNote("kept", "memo", 0, []string{}, 1))
+	addAttachment(t, repos.Notes, deletedID, "screenshot.png", "png")
+	addAttachment(t, repos.Notes, deletedID, "copy.png", "png")
+	keptAttachmentID := addAttachment(t, repos.Notes, keptID, "kept.png", "png")
+	if err := repos.Notes.DeleteNote(deletedID); err != nil {
+		t.Fatalf("Could not delete note, error msg: %v", err)
+	}
+	if _, err := repos.Notes.PurgeNotes(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Could not purge notes, error msg: %v", err)
+	}
+
+	checkAttachment(t, repos.Notes, keptAttachmentID, "kept.png", "png")
+}
+
+func testAttachmentsOfTrashedNotes(t *testing.T, repos *Repositories) {
+	noteID := saveNote(t, repos.Notes, newNote("title", "memo", 0, []string{}, 0))
+	id := addAttachment(t, repos.Notes, noteID, "report.pdf", "pdf")
+	if err := repos.Notes.DeleteNote(noteID); err != nil {
+		t.Fatalf("Could not delete note, error msg: %v", err)
+	}
+
+	if _, err := repos.Notes.GetAttachment(id); !errors.Is(err, repository.ErrAttachmentNotFound) {
+		t.Errorf("Expected ErrAttachmentNotFound for an attachment of a note in the trash, got: %v", err)
+	}
+	if _, err := repos.Notes.GetAttachments(noteID); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound for a note in the trash, got: %v", err)
+	}
+	if _, err := repos.Notes.AddAttachment(model.NewAttachment(noteID, "other.pdf", []byte("pdf"))); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound for a note in the trash, got: %v", err)
+	}
+	if err := repos.Notes.RestoreNotes([]int64{noteID}); err != nil {
+		t.Fatalf("Could not restore note, error msg: %v", err)
+	}
+	checkAttachment(t, repos.Notes, id, "report.pdf", "pdf")
+
+	if err := repos.Notes.DeleteNote(noteID); err != nil {
+		t.Fatalf("Could not delete note, error msg: %v", err)
+	}
+	if _, err := repos.Notes.PurgeNotes(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Could not purge notes, error msg: %v", err)
+	}
+	if _, err := repos.Notes.GetAttachment(id); !errors.Is(err, repository.ErrAttachmentNotFound) {
+		t.Errorf("Expected ErrAttachmentNotFound for an attachment of a purged note, got: %v", err)
+	}
+}
+
+func testAttachmentsOfPurgedNotebooks(t *testing.T, repos *Repositories) {
+	notebookID := saveNotebook(t, repos.Notebooks, "lists")
+	noteID := saveNote(t, repos.Notes, newNote("title", "memo", notebookID, []string{}, 0))
+	id := addAttachment(t, repos.Notes, noteID, "list.txt", "milk")
+	if err := repos.Notebooks.DeleteNotebook(notebookID); err != nil {
+		t.Fatalf("Could not delete notebook, error msg: %v", err)
+	}
+	if _, err := repos.Notebooks.PurgeNotebooks(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Could not purge notebooks, error msg: %v", err)
+	}
+
+	if _, err := repos.Notes.GetAttachment(id); !errors.Is(err, repository.ErrAttachmentNotFound) {
+		t.Errorf("Expected ErrAttachmentNotFound for an attachment of a purged notebook, got: %v", err)
+	}
+}
+
+func testInvalidAttachments(t *testing.T, repos *Repositories) {
+	noteID := saveNote(t, repos.Notes, newNote("title", "memo", 0, []string{}, 0))
+
+	if _, err := repos.Notes.AddAttachment(model.NewAttachment(noteID, "", []byte("content"))); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected ErrValidation for an attachment without name, got: %v", err)
+	}
+	if _, err := repos.Notes.AddAttachment(model.NewAttachment(42, "name", []byte("content"))); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got: %v", err)
+	}
+	if _, err := repos.Notes.GetAttachments(42); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got: %v", err)
+	}
+	if _, err := repos.Notes.GetAttachment(42); !errors.Is(err, repository.ErrAttachmentNotFound) {
+		t.Errorf("Expected ErrAttachmentNotFound, got: %v", err)
+	}
+}
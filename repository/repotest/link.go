@@ -0,0 +1,119 @@
+package repotest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"testing"
+)
+
+//RunLinks checks that the wiki links of memos are kept up to date with the notes.
+func RunLinks(t *testing.T, factory Factory) {
+	runCases(t, factory, []testCase{
+		{"GetLinks", testGetLinks},
+		{"GetBacklinks", testGetBacklinks},
+		{"UpdateLinks", testUpdateLinks},
+		{"LinksToLaterNotes", testLinksToLaterNotes},
+		{"LinksOfTrashedNotes", testLinksOfTrashedNotes},
+		{"LinksOfMissingNote", testLinksOfMissingNote},
+	})
+}
+
+func getLinks(t *testing.T, repo repository.NoteRepository, noteID int64) []*model.Note {
+	t.Helper()
+	links, err := repo.GetLinks(noteID)
+	if err != nil {
+		t.Fatalf("Could not retrieve links, error msg: %v", err)
+	}
+	return links
+}
+
+func getBacklinks(t *testing.T, repo repository.NoteRepository, noteID int64) []*model.Note {
+	t.Helper()
+	backlinks, err := repo.GetBacklinks(noteID)
+	if err != nil {
+		t.Fatalf("Could not retrieve backlinks, error msg: %v", err)
+	}
+	return backlinks
+}
+
+func testGetLinks(t *testing.T, repos *Repositories) {
+	byTitle := saveNote(t, repos.Notes, newNote("Bali 2018", "memo", 0, []string{}, 0))
+	sameTitle := saveNote(t, repos.Notes, newNote("Bali 2018", "memo", 0, []string{}, 1))
+	byID := saveNote(t, repos.Notes, newNote("packing list", "memo", 0, []string{}, 2))
+	saveNote(t, repos.Notes, newNote("bali 2018", "memo", 0, []string{}, 3))
+	memo := fmt.Sprintf("see [[ Bali 2018 ]], [[#%d]] and [[missing]]", byID)
+	id := saveNote(t, repos.Notes, newNote("trip", memo, 0, []string{}, 4))
+
+	checkNoteIDs(t, getLinks(t, repos.Notes, id), byID, sameTitle, byTitle)
+	checkNoteIDs(t, getLinks(t, repos.Notes, byID))
+}
+
+func testGetBacklinks(t *testing.T, repos *Repositories) {
+	target := saveNote(t, repos.Notes, newNote("Bali 2018", "memo", 0, []string{}, 0))
+	byTitle := saveNote(t, repos.Notes, newNote("trip", "see [[Bali 2018]]", 0, []string{}, 1))
+	byID := saveNote(t, repos.Notes, newNote("budget", fmt.Sprintf("see [[#%d]]", target), 0, []string{}, 2))
+	both := saveNote(t, repos.Notes, newNote("photos", fmt.Sprintf("[[#%d]] [[Bali 2018]]", target), 0, []string{}, 3))
+	saveNote(t, repos.Notes, newNote("other", "see [[bali 2018]] and [[Bali 2019]]", 0, []string{}, 4))
+
+	checkNoteIDs(t, getBacklinks(t, repos.Notes, target), both, byID, byTitle)
+	checkNoteIDs(t, getBacklinks(t, repos.Notes, byTitle))
+}
+
+func testUpdateLinks(t *testing.T, repos *Repositories) {
+	first := saveNote(t, repos.Notes, newNote("first", "memo", 0, []string{}, 0))
+	second := saveNote(t, repos.Notes, newNote("second", "memo", 0, []string{}, 1))
+	id := saveNote(t, repos.Notes, newNote("title", "see [[first]]", 0, []string{}, 2))
+
+	note, _ := repos.Notes.GetNote(id)
+	note.UpdateMemo("see [[second]]")
+	if err := repos.Notes.UpdateNote(note); err != nil {
+		t.Fatalf("Could not update note, error msg: %v", err)
+	}
+	checkNoteIDs(t, getLinks(t, repos.Notes, id), second)
+	checkNoteIDs(t, getBacklinks(t, repos.Notes, first))
+	//links by title follow the title of the target
+	target, _ := repos.Notes.GetNote(second)
+	target.UpdateTitle("renamed")
+	if err := repos.Notes.UpdateNote(target); err != nil {
+		t.Fatalf("Could not update note, error msg: %v", err)
+	}
+	checkNoteIDs(t, getBacklinks(t, repos.Notes, second))
+	checkNoteIDs(t, getLinks(t, repos.Notes, id))
+}
+
+func testLinksToLaterNotes(t *testing.T, repos *Repositories) {
+	id := saveNote(t, repos.Notes, newNote("title", "see [[later]]", 0, []string{}, 0))
+	checkNoteIDs(t, getLinks(t, repos.Notes, id))
+
+	later := saveNote(t, repos.Notes, newNote("later", "memo", 0, []string{}, 1))
+	checkNoteIDs(t, getLinks(t, repos.Notes, id), later)
+	checkNoteIDs(t, getBacklinks(t, repos.Notes, later), id)
+}
+
+func testLinksOfTrashedNotes(t *testing.T, repos *Repositories) {
+	target := saveNote(t, repos.Notes, newNote("target", "memo", 0, []string{}, 0))
+	trashedTarget := saveNote(t, repos.Notes, newNote("trashed target", "memo", 0, []string{}, 1))
+	id := saveNote(t, repos.Notes, newNote("title", "[[target]] [[trashed target]]", 0, []string{}, 2))
+	trashed := saveNote(t, repos.Notes, newNote("trashed", "[[target]]", 0, []string{}, 3))
+	if err := repos.Notes.DeleteNotes([]int64{trashedTarget, trashed}); err != nil {
+		t.Fatalf("Could not delete notes, error msg: %v", err)
+	}
+
+	checkNoteIDs(t, getLinks(t, repos.Notes, id), target)
+	checkNoteIDs(t, getBacklinks(t, repos.Notes, target), id)
+	if err := repos.Notes.RestoreNotes([]int64{trashed}); err != nil {
+		t.Fatalf("Could not restore note, error msg: %v", err)
+	}
+	checkNoteIDs(t, getBacklinks(t, repos.Notes, target), trashed, id)
+}
+
+func testLinksOfMissingNote(t *testing.T, repos *Repositories) {
+	if _, err := repos.Notes.GetLinks(42); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got: %v", err)
+	}
+	if _, err := repos.Notes.GetBacklinks(42); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got: %v", err)
+	}
+}
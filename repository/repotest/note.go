@@ -0,0 +1,248 @@
+package repotest
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//RunNoteRepository checks the NoteRepository contract.
+func RunNoteRepository(t *testing.T, factory Factory) {
+	runCases(t, factory, []testCase{
+		{"SaveAndGetNote", testSaveAndGetNote},
+		{"SaveNoteDefaults", testSaveNoteDefaults},
+		{"SaveNoteWithoutMemo", testSaveNoteWithoutMemo},
+		{"GetMissingNote", testGetMissingNote},
+		{"GetNotesOrderedByCreated", testGetNotesOrderedByCreated},
+		{"GetNotesByIDs", testGetNotesByIDs},
+		{"UpdateNoteReplacesTags", testUpdateNoteReplacesTags},
+		{"UpdateNoteWithoutMemo", testUpdateNoteWithoutMemo},
+		{"DeleteNotes", testDeleteNotes},
+		{"GetNotesByTag", testGetNotesByTag},
+		{"SearchNotesByKeyword", testSearchNotesByKeyword},
+		{"SearchNotesByEmptyKeyword", testSearchNotesByEmptyKeyword},
+	})
+}
+
+//baseTime is the creation time of test notes, stores may drop the sub-second part.
+var baseTime = time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func saveNote(t *testing.T, repo repository.NoteRepository, note *model.Note) int64 {
+	id, err := repo.SaveNote(note)
+	if err != nil {
+		t.Fatalf("Could not save note, error msg: %v", err)
+	}
+	if id != note.ID {
+		t.Fatalf("Returned id: %v should be set to the note, got: %v", id, note.ID)
+	}
+	return id
+}
+
+//newNote returns a note created hoursAfter hours after baseTime.
+func newNote(title, memo string, notebookID int64, tags []string, hoursAfter int) *model.Note {
+	note := model.NewNote(title, memo, notebookID, tags)
+	note.Created = baseTime.Add(time.Duration(hoursAfter) * time.Hour)
+	note.LastUpdated = note.Created
+	return note
+}
+
+func noteIDs(notes []*model.Note) []int64 {
+	ids := []int64{}
+	for _, note := range notes {
+		ids = append(ids, note.ID)
+	}
+	return ids
+}
+
+func checkNoteIDs(t *testing.T, notes []*model.Note, expected ...int64) {
+	t.Helper()
+	if expected == nil {
+		expected = []int64{}
+	}
+	if ids := noteIDs(notes); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Expected notes: %v, got: %v", expected, ids)
+	}
+}
+
+func testSaveAndGetNote(t *testing.T, repos *Repositories) {
+	note := newNote("title", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"tag1", "tag2"}, 0)
+	id := saveNote(t, repos.Notes, note)
+
+	stored, err := repos.Notes.GetNote(id)
+	if err != nil {
+		t.Fatalf("Could not retrieve note, error msg: %v", err)
+	}
+	if stored.Title != "title" || stored.Memo != "memo" || stored.NotebookID != repository.DEFAULT_NOTEBOOK_ID {
+		t.Errorf("Stored note: %+v does not match saved note: %+v", stored, note)
+	}
+	if !stored.Created.Equal(note.Created) {
+		t.Errorf("Expected created: %v, got: %v", note.Created, stored.Created)
+	}
+	if !reflect.DeepEqual(stored.Tags, map[string]bool{"tag1": true, "tag2": true}) {
+		t.Errorf("Expected tags tag1, tag2, got: %v", stored.Tags)
+	}
+}
+
+func testSaveNoteDefaults(t *testing.T, repos *Repositories) {
+	note := model.NewNote("", "memo", 0, []string{})
+	note.Created = time.Time{}
+	note.LastUpdated = time.Time{}
+	id := saveNote(t, repos.Notes, note)
+
+	stored, err := repos.Notes.GetNote(id)
+	if err != nil {
+		t.Fatalf("Could not retrieve note, error msg: %v", err)
+	}
+	if stored.NotebookID != repository.DEFAULT_NOTEBOOK_ID {
+		t.Errorf("Note without notebook should be saved to the default notebook, got: %v", stored.NotebookID)
+	}
+	if stored.Created.IsZero() || stored.LastUpdated.IsZero() {
+		t.Error("Created and last updated should be set when missing")
+	}
+}
+
+func testSaveNoteWithoutMemo(t *testing.T, repos *Repositories) {
+	_, err := repos.Notes.SaveNote(model.NewNote("title", "", 0, []string{}))
+	if !errors.Is(err, repository.ErrValidation) {
+		t.Error("Note without memo should not be saved")
+	}
+	notes, _ := repos.Notes.GetNotes([]int64{})
+	checkNoteIDs(t, notes)
+}
+
+func testGetMissingNote(t *testing.T, repos *Repositories) {
+	if _, err := repos.Notes.GetNote(42); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Retrieving a missing note should fail with ErrNoteNotFound, got: %v", err)
+	}
+	notes, err := repos.Notes.GetNotes([]int64{42})
+	if err != nil {
+		t.Errorf("Retrieving missing notes should not fail, error msg: %v", err)
+	}
+	checkNoteIDs(t, notes)
+}
+
+func testGetNotesOrderedByCreated(t *testing.T, repos *Repositories) {
+	id1 := saveNote(t, repos.Notes, newNote("first", "memo", 0, []string{}, 1))
+	id2 := saveNote(t, repos.Notes, newNote("third", "memo", 0, []string{}, 3))
+	id3 := saveNote(t, repos.Notes, newNote("second", "memo", 0, []string{}, 2))
+
+	notes, err := repos.Notes.GetNotes([]int64{})
+	if err != nil {
+		t.Fatalf("Could not retrieve notes, error msg: %v", err)
+	}
+	checkNoteIDs(t, notes, id2, id3, id1)
+}
+
+func testGetNotesByIDs(t *testing.T, repos *Repositories) {
+	id1 := saveNote(t, repos.Notes, newNote("first", "memo", 0, []string{}, 1))
+	id2 := saveNote(t, repos.Notes, newNote("second", "memo", 0, []string{}, 2))
+	saveNote(t, repos.Notes, newNote("third", "memo", 0, []string{}, 3))
+
+	notes, err := repos.Notes.GetNotes([]int64{id1, id2, id1})
+	if err != nil {
+		t.Fatalf("Could not retrieve notes, error msg: %v", err)
+	}
+	checkNoteIDs(t, notes, id2, id1)
+}
+
+func testUpdateNoteReplacesTags(t *testing.T, repos *Repositories) {
+	note := newNote("title", "memo", 0, []string{"keep", "remove"}, 0)
+	id := saveNote(t, repos.Notes, note)
+
+	note.UpdateTitle("new title")
+	note.UpdateMemo("new memo")
+	note.RemoveTags([]string{"remove"})
+	note.AddTags([]string{"add"})
+	if err := repos.Notes.UpdateNote(note); err != nil {
+		t.Fatalf("Could not update note, error msg: %v", err)
+	}
+
+	stored, err := repos.Notes.GetNote(id)
+	if err != nil {
+		t.Fatalf("Could not retrieve note, error msg: %v", err)
+	}
+	if stored.Title != "new title" || stored.Memo != "new memo" {
+		t.Errorf("Expected updated title and memo, got: %v, %v", stored.Title, stored.Memo)
+	}
+	if !reflect.DeepEqual(stored.Tags, map[string]bool{"keep": true, "add": true}) {
+		t.Errorf("Expected tags keep, add, got: %v", stored.Tags)
+	}
+	removed, _ := repos.Notes.GetNotesByTag([]string{"remove"})
+	checkNoteIDs(t, removed)
+}
+
+func testUpdateNoteWithoutMemo(t *testing.T, repos *Repositories) {
+	note := newNote("title", "memo", 0, []string{}, 0)
+	id := saveNote(t, repos.Notes, note)
+
+	note.Memo = ""
+	if err := repos.Notes.UpdateNote(note); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Note without memo should not be updated, got: %v", err)
+	}
+	stored, _ := repos.Notes.GetNote(id)
+	if stored == nil || stored.Memo != "memo" {
+		t.Error("Failed update should not modify the note")
+	}
+}
+
+func testDeleteNotes(t *testing.T, repos *Repositories) {
+	id1 := saveNote(t, repos.Notes, newNote("first", "memo", 0, []string{"tag"}, 1))
+	id2 := saveNote(t, repos.Notes, newNote("second", "memo", 0, []string{"tag"}, 2))
+	id3 := saveNote(t, repos.Notes, newNote("third", "memo", 0, []string{"tag"}, 3))
+
+	if err := repos.Notes.DeleteNotes([]int64{id1, id3}); err != nil {
+		t.Fatalf("Could not delete notes, error msg: %v", err)
+	}
+	if err := repos.Notes.DeleteNote(42); err != nil {
+		t.Errorf("Deleting a missing note should not fail, error msg: %v", err)
+	}
+
+	notes, _ := repos.Notes.GetNotes([]int64{})
+	checkNoteIDs(t, notes, id2)
+	tagged, _ := repos.Notes.GetNotesByTag([]string{"tag"})
+	checkNoteIDs(t, tagged, id2)
+}
+
+func testGetNotesByTag(t *testing.T, repos *Repositories) {
+	id1 := saveNote(t, repos.Notes, newNote("first", "memo", 0, []string{"tag1"}, 1))
+	id2 := saveNote(t, repos.Notes, newNote("second", "memo", 0, []string{"tag1", "tag2"}, 2))
+	id3 := saveNote(t, repos.Notes, newNote("third", "memo", 0, []string{"tag2"}, 3))
+	saveNote(t, repos.Notes, newNote("fourth", "memo", 0, []string{"tag3"}, 4))
+
+	notes, err := repos.Notes.GetNotesByTag([]string{"tag1", "tag2"})
+	if err != nil {
+		t.Fatalf("Could not retrieve notes, error msg: %v", err)
+	}
+	checkNoteIDs(t, notes, id3, id2, id1)
+
+	notes, _ = repos.Notes.GetNotesByTag([]string{"missing"})
+	checkNoteIDs(t, notes)
+}
+
+func testSearchNotesByKeyword(t *testing.T, repos *Repositories) {
+	id1 := saveNote(t, repos.Notes, newNote("Bali trip", "beaches", 0, []string{}, 1))
+	id2 := saveNote(t, repos.Notes, newNote("groceries", "milk for the trip", 0, []string{}, 2))
+	saveNote(t, repos.Notes, newNote("work", "meeting", 0, []string{}, 3))
+
+	notes, err := repos.Notes.SearchNotesByKeyword("trip")
+	if err != nil {
+		t.Fatalf("Could not search notes, error msg: %v", err)
+	}
+	//matches in the title rank higher
+	checkNoteIDs(t, notes, id1, id2)
+
+	notes, _ = repos.Notes.SearchNotesByKeyword("beach*")
+	checkNoteIDs(t, notes, id1)
+
+	notes, _ = repos.Notes.SearchNotesByKeyword("missing")
+	checkNoteIDs(t, notes)
+}
+
+func testSearchNotesByEmptyKeyword(t *testing.T, repos *Repositories) {
+	if _, err := repos.Notes.SearchNotesByKeyword(""); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Searching for an empty keyword should fail with ErrValidation, got: %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+package repotest
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//RunNoteQuery checks the paging, sorting and filtering of note listings.
+func RunNoteQuery(t *testing.T, factory Factory) {
+	runCases(t, factory, []testCase{
+		{"ListNotesSorted", testListNotesSorted},
+		{"ListNotesCursorPages", testListNotesCursorPages},
+		{"ListNotesCursorWithTies", testListNotesCursorWithTies},
+		{"ListNotesOffset", testListNotesOffset},
+		{"ListNotesFilter", testListNotesFilter},
+		{"ListNotesInvalidQuery", testListNotesInvalidQuery},
+		{"SearchNotesPages", testSearchNotesPages},
+		{"ListNotesQuery", testListNotesQuery},
+		{"ListNotesQueryWithFilter", testListNotesQueryWithFilter},
+		{"ListNotesQueryLocalTime", testListNotesQueryLocalTime},
+	})
+}
+
+//saveSortableNotes saves notes whose created, updated and title orders all differ, it returns their ids.
+func saveSortableNotes(t *testing.T, repo repository.NoteRepository) []int64 {
+	ids := []int64{}
+	updatedHours := []int{10, 8, 2, 6, 6}
+	for i, title := range []string{"echo", "charlie", "alpha", "delta", "bravo"} {
+		note := newNote(title, "memo", 0, []string{}, i)
+		note.LastUpdated = baseTime.Add(time.Duration(updatedHours[i]) * time.Hour)
+		ids = append(ids, saveNote(t, repo, note))
+	}
+	return ids
+}
+
+func listNotes(t *testing.T, repo repository.NoteRepository, filter repository.NoteFilter, query repository.NoteQuery) *repository.NotePage {
+	t.Helper()
+	page, err := repo.ListNotes(filter, query)
+	if err != nil {
+		t.Fatalf("Could not list notes with query: %+v, error msg: %v", query, err)
+	}
+	return page
+}
+
+//collectPages follows the next cursors of query and returns the ids of every page.
+func collectPages(t *testing.T, list func(query repository.NoteQuery) (*repository.NotePage, error), query repository.NoteQuery) [][]int64 {
+	t.Helper()
+	pages := [][]int64{}
+	for {
+		page, err := list(query)
+		if err != nil {
+			t.Fatalf("Could not list notes with query: %+v, error msg: %v", query, err)
+		}
+		pages = append(pages, noteIDs(page.Notes))
+		if page.Next == "" || len(pages) > 10 {
+			return pages
+		}
+		query.Cursor = page.Next
+	}
+}
+
+func testListNotesSorted(t *testing.T, repos *Repositories) {
+	ids := saveSortableNotes(t, repos.Notes)
+	echo, charlie, alpha, delta, bravo := ids[0], ids[1], ids[2], ids[3], ids[4]
+
+	cases := []struct {
+		query    repository.NoteQuery
+		expected []int64
+	}{
+		{repository.NoteQuery{}, []int64{bravo, delta, alpha, charlie, echo}},
+		{repository.NoteQuery{Order: repository.Ascending}, []int64{echo, charlie, alpha, delta, bravo}},
+		{repository.NoteQuery{Sort: repository.SortByTitle, Order: repository.Ascending}, []int64{alpha, bravo, charlie, delta, echo}},
+		{repository.NoteQuery{Sort: repository.SortByTitle}, []int64{echo, delta, charlie, bravo, alpha}},
+		{repository.NoteQuery{Sort: repository.SortByLastUpdated}, []int64{echo, charlie, bravo, delta, alpha}},
+		{repository.NoteQuery{Sort: repository.SortByLastUpdated, Order: repository.Ascending}, []int64{alpha, delta, bravo, charlie, echo}},
+	}
+	for _, c := range cases {
+		page := listNotes(t, repos.Notes, repository.NoteFilter{}, c.query)
+		checkNoteIDs(t, page.Notes, c.expected...)
+		if page.Next != "" {
+			t.Errorf("Listing without limit should not have a next page, query: %+v", c.query)
+		}
+	}
+}
+
+func testListNotesCursorPages(t *testing.T, repos *Repositories) {
+	saveSortableNotes(t, repos.Notes)
+	for _, sort := range []repository.NoteSort{repository.SortByCreated, repository.SortByLastUpdated, repository.SortByTitle} {
+		for _, order := range []repository.SortOrder{repository.Ascending, repository.Descending} {
+			query := repository.NoteQuery{Sort: sort, Order: order}
+			all := noteIDs(listNotes(t, repos.Notes, repository.NoteFilter{}, query).Notes)
+
+			query.Limit = 2
+			pages := collectPages(t, func(query repository.NoteQuery) (*repository.NotePage, error) {
+				return repos.Notes.ListNotes(repository.NoteFilter{}, query)
+			}, query)
+			if len(pages) != 3 || len(pages[2]) != 1 {
+				t.Errorf("Expected pages of 2, 2 and 1 notes for sort: %v %v, got: %v", sort, order, pages)
+				continue
+			}
+			paged := append(append(pages[0], pages[1]...), pages[2]...)
+			if !sameIDs(all, paged) {
+				t.Errorf("Pages: %v of sort: %v %v should match the listing: %v", pages, sort, order, all)
+			}
+		}
+	}
+}
+
+func testListNotesCursorWithTies(t *testing.T, repos *Repositories) {
+	expected := []int64{}
+	for i := 0; i < 5; i++ {
+		//same created time and title, only the id separates the notes
+		expected = append([]int64{saveNote(t, repos.Notes, newNote("same", "memo", 0, []string{}, 0))}, expected...)
+	}
+	for _, sort := range []repository.NoteSort{repository.SortByCreated, repository.SortByTitle} {
+		pages := collectPages(t, func(query repository.NoteQuery) (*repository.NotePage, error) {
+			return repos.Notes.ListNotes(repository.NoteFilter{}, query)
+		}, repository.NoteQuery{Limit: 2, Sort: sort})
+		paged := []int64{}
+		for _, page := range pages {
+			paged = append(paged, page...)
+		}
+		if !sameIDs(expected, paged) {
+			t.Errorf("Expected notes with same %v to be paged by id: %v, got: %v", sort, expected, pages)
+		}
+	}
+}
+
+func testListNotesOffset(t *testing.T, repos *Repositories) {
+	ids := saveSortableNotes(t, repos.Notes)
+
+	page := listNotes(t, repos.Notes, repository.NoteFilter{}, repository.NoteQuery{Limit: 2, Offset: 2})
+	checkNoteIDs(t, page.Notes, ids[2], ids[1])
+	if page.Next == "" {
+		t.Error("Page before the last one should have a next cursor")
+	}
+	page = listNotes(t, repos.Notes, repository.NoteFilter{}, repository.NoteQuery{Limit: 2, Offset: 4})
+	checkNoteIDs(t, page.Notes, ids[0])
+	if page.Next != "" {
+		t.Error("Last page should not have a next cursor")
+	}
+	page = listNotes(t, repos.Notes, repository.NoteFilter{}, repository.NoteQuery{Limit: 2, Offset: 10})
+	checkNoteIDs(t, page.Notes)
+}
+
+func testListNotesFilter(t *testing.T, repos *Repositories) {
+	notebookID := saveNotebook(t, repos.Notebooks, "work")
+	byID := saveNote(t, repos.Notes, newNote("by id", "memo", 0, []string{}, 1))
+	byNotebook := saveNote(t, repos.Notes, newNote("by notebook", "memo", notebookID, []string{}, 2))
+	byTag := saveNote(t, repos.Notes, newNote("by tag", "memo", 0, []string{"go"}, 3))
+	byAll := saveNote(t, repos.Notes, newNote("by all", "memo", notebookID, []string{"go", "todo"}, 4))
+	saveNote(t, repos.Notes, newNote("none", "memo", 0, []string{"other"}, 5))
+
+	filter := repository.NoteFilter{IDs: []int64{byID, byAll}, NotebookIDs: []int64{notebookID}, Tags: []string{"go", "todo"}}
+	page := listNotes(t, repos.Notes, filter, repository.NoteQuery{})
+	checkNoteIDs(t, page.Notes, byAll, byTag, byNotebook, byID)
+
+	page = listNotes(t, repos.Notes, repository.NoteFilter{NotebookIDs: []int64{notebookID}}, repository.NoteQuery{Limit: 1})
+	checkNoteIDs(t, page.Notes, byAll)
+	if len(page.Notes) == 1 && !reflect.DeepEqual(page.Notes[0].Tags, map[string]bool{"go": true, "todo": true}) {
+		t.Errorf("Tags of listed notes should be loaded, got: %v", page.Notes[0].Tags)
+	}
+	page = listNotes(t, repos.Notes, repository.NoteFilter{NotebookIDs: []int64{notebookID}}, repository.NoteQuery{Limit: 1, Cursor: page.Next})
+	checkNoteIDs(t, page.Notes, byNotebook)
+	if page.Next != "" {
+		t.Error("Last page of filtered listing should not have a next cursor")
+	}
+}
+
+func testListNotesInvalidQuery(t *testing.T, repos *Repositories) {
+	saveSortableNotes(t, repos.Notes)
+	page := listNotes(t, repos.Notes, repository.NoteFilter{}, repository.NoteQuery{Limit: 1})
+
+	queries := []repository.NoteQuery{
+		{Sort: "unknown"},
+		{Order: "sideways"},
+		{Limit: -1},
+		{Offset: 1},
+		{Limit: 1, Cursor: "not a cursor"},
+		{Limit: 1, Cursor: page.Next, Sort: repository.SortByTitle},
+		{Limit: 1, Cursor: page.Next, Order: repository.Ascending},
+	}
+	for _, query := range queries {
+		if _, err := repos.Notes.ListNotes(repository.NoteFilter{}, query); !errors.Is(err, repository.ErrValidation) {
+			t.Errorf("Expected validation error for query: %+v, got: %v", query, err)
+		}
+	}
+}
+
+func testSearchNotesPages(t *testing.T, repos *Repositories) {
+	expected := []int64{}
+	for i := 0; i < 3; i++ {
+		expected = append([]int64{saveNote(t, repos.Notes, newNote("title", "holiday plans", 0, []string{}, i))}, expected...)
+	}
+	saveNote(t, repos.Notes, newNote("title", "work plans", 0, []string{}, 4))
+
+	pages := collectPages(t, func(query repository.NoteQuery) (*repository.NotePage, error) {
+		return searchNotePage(repos.Notes, "holiday", repository.NoteFilter{}, query)
+	}, repository.NoteQuery{Limit: 2, Sort: repository.SortByCreated})
+	if len(pages) != 2 || !sameIDs(append(pages[0], pages[1]...), expected) {
+		t.Errorf("Expected search pages of notes: %v, got: %v", expected, pages)
+	}
+}
+
+func testListNotesQuery(t *testing.T, repos *Repositories) {
+	work := saveNotebook(t, repos.Notebooks, "my work")
+	goTodo := saveNote(t, repos.Notes, newNote("Go plans", "learn generics", work, []string{"go", "todo"}, 0))
+	goDone := saveNote(t, repos.Notes, newNote("go done", "holiday plans finished", work, []string{"go", "done"}, 24))
+	rust := saveNote(t, repos.Notes, newNote("rust", "plans for the holiday", repository.DEFAULT_NOTEBOOK_ID, []string{"rust", "todo"}, 48))
+	holiday := saveNote(t, repos.Notes, newNote("50% off", "Holiday plans", repository.DEFAULT_NOTEBOOK_ID, []string{}, 72))
+	day := baseTime.Add(24 * time.Hour).Format("2006-01-02")
+
+	cases := []struct {
+		query    string
+		expected []int64
+	}{
+		{"tag:go tag:todo", []int64{goTodo}},
+		{`tag:go notebook:"my work" -tag:done`, []int64{goTodo}},
+		{"tag:go OR tag:rust", []int64{rust, goDone, goTodo}},
+		{"(tag:go OR tag:rust) tag:todo", []int64{rust, goTodo}},
+		{"holiday", []int64{holiday, rust, goDone}},
+		{`"holiday plans"`, []int64{holiday, goDone}},
+		{"holi* -tag:done", []int64{holiday, rust}},
+		{"title:PLAN", []int64{goTodo}},
+		{"title:50%", []int64{holiday}},
+		{"title:_", []int64{}},
+		{"id:" + strconv.FormatInt(rust, 10), []int64{rust}},
+		{"created:" + day, []int64{goDone}},
+		{"created:>" + day, []int64{holiday, rust}},
+		{"created:<=" + day + " updated:>=" + day, []int64{goDone}},
+		{"notebook:missing", []int64{}},
+	}
+	for _, c := range cases {
+		expr, err := repository.ParseQuery(c.query)
+		if err != nil {
+			t.Fatalf("Could not parse query: %v, error msg: %v", c.query, err)
+		}
+		page := listNotes(t, repos.Notes, repository.NoteFilter{Query: expr}, repository.NoteQuery{})
+		if ids := noteIDs(page.Notes); !sameIDs(ids, c.expected) {
+			t.Errorf("Expected query: %v to match notes: %v, got: %v", c.query, c.expected, ids)
+		}
+	}
+}
+
+func testListNotesQueryWithFilter(t *testing.T, repos *Repositories) {
+	first := saveNote(t, repos.Notes, newNote("first", "memo", 0, []string{"go"}, 0))
+	second := saveNote(t, repos.Notes, newNote("second", "memo", 0, []string{"go", "done"}, 1))
+	saveNote(t, repos.Notes, newNote("third", "memo", 0, []string{"rust"}, 2))
+
+	expr, _ := repository.ParseQuery("-tag:done")
+	page := listNotes(t, repos.Notes, repository.NoteFilter{Tags: []string{"go"}, Query: expr}, repository.NoteQuery{})
+	checkNoteIDs(t, page.Notes, first)
+
+	expr, _ = repository.ParseQuery("tag:go")
+	pages := collectPages(t, func(query repository.NoteQuery) (*repository.NotePage, error) {
+		return repos.Notes.ListNotes(repository.NoteFilter{Query: expr}, query)
+	}, repository.NoteQuery{Limit: 1})
+	if len(pages) != 2 || !sameIDs(append(pages[0], pages[1]...), []int64{second, first}) {
+		t.Errorf("Expected query pages of notes: %v, got: %v", []int64{second, first}, pages)
+	}
+}
+
+func sameIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//testListNotesQueryLocalTime checks that dates of queries match the local day of notes saved with local times,
+//the day of a note created at 23:30 in Athens is not the day of the same time in UTC.
+func testListNotesQueryLocalTime(t *testing.T, repos *Repositories) {
+	local := time.Local
+	time.Local = time.FixedZone("EEST", 3*60*60)
+	defer func() { time.Local = local }()
+
+	late := model.NewNote("late", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{})
+	late.Created = time.Date(2026, 10, 1, 23, 30, 0, 0, time.Local)
+	late.LastUpdated = late.Created
+	lateID := saveNote(t, repos.Notes, late)
+	early := model.NewNote("early", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{})
+	early.Created = time.Date(2026, 10, 2, 1, 0, 0, 0, time.Local)
+	early.LastUpdated = early.Created
+	earlyID := saveNote(t, repos.Notes, early)
+
+	cases := []struct {
+		query    string
+		expected []int64
+	}{
+		{"created:2026-10-01", []int64{lateID}},
+		{"created:2026-10-02", []int64{earlyID}},
+		{"updated:>2026-10-01", []int64{earlyID}},
+		{"updated:<=2026-10-01", []int64{lateID}},
+	}
+	for _, c := range cases {
+		expr, err := repository.ParseQuery(c.query)
+		if err != nil {
+			t.Fatalf("Could not parse query: %v, error msg: %v", c.query, err)
+		}
+		page := listNotes(t, repos.Notes, repository.NoteFilter{Query: expr}, repository.NoteQuery{})
+		if ids := noteIDs(page.Notes); !sameIDs(ids, c.expected) {
+			t.Errorf("Expected query: %v to match notes: %v, got: %v", c.query, c.expected, ids)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package repotest
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"testing"
+)
+
+//RunNotebookRepository checks the NotebookRepository contract.
+func RunNotebookRepository(t *testing.T, factory Factory) {
+	runCases(t, factory, []testCase{
+		{"DefaultNotebook", testDefaultNotebook},
+		{"SaveAndGetNotebook", testSaveAndGetNotebook},
+		{"SaveNotebookWithoutTitle", testSaveNotebookWithoutTitle},
+		{"SaveNotebookWithDuplicateTitle", testSaveNotebookWithDuplicateTitle},
+		{"GetMissingNotebook", testGetMissingNotebook},
+		{"GetNotebookContainsNotes", testGetNotebookContainsNotes},
+		{"UpdateNotebook", testUpdateNotebook},
+		{"UpdateNotebookWithoutTitle", testUpdateNotebookWithoutTitle},
+		{"DeleteNotebooksCascadesToNotes", testDeleteNotebooksCascadesToNotes},
+		{"DeleteDefaultNotebook", testDeleteDefaultNotebook},
+		{"GetAllNotebooksTitle", testGetAllNotebooksTitle},
+	})
+}
+
+func saveNotebook(t *testing.T, repo repository.NotebookRepository, title string) int64 {
+	notebook := model.NewNotebook(title)
+	id, err := repo.SaveNotebook(notebook)
+	if err != nil {
+		t.Fatalf("Could not save notebook, error msg: %v", err)
+	}
+	if id != notebook.ID {
+		t.Fatalf("Returned id: %v should be set to the notebook, got: %v", id, notebook.ID)
+	}
+	return id
+}
+
+func testDefaultNotebook(t *testing.T, repos *Repositories) {
+	notebook, err := repos.Notebooks.GetNotebook(repository.DEFAULT_NOTEBOOK_ID)
+	if err != nil {
+		t.Fatalf("Default notebook should always exist, error msg: %v", err)
+	}
+	if notebook.Title != "Default Notebook" {
+		t.Errorf("Expected title: Default Notebook, got: %v", notebook.Title)
+	}
+	notebooks, _ := repos.Notebooks.GetNotebooks([]int64{})
+	if len(notebooks) != 1 {
+		t.Errorf("Empty storage should contain only the default notebook, got: %v notebooks", len(notebooks))
+	}
+
+	id := saveNote(t, repos.Notes, newNote("title", "memo", 0, []string{}, 0))
+	notebook, _ = repos.Notebooks.GetNotebook(repository.DEFAULT_NOTEBOOK_ID)
+	if notebook == nil || notebook.Notes[id] == nil {
+		t.Error("Notes without notebook should belong to the default notebook")
+	}
+}
+
+func testSaveAndGetNotebook(t *testing.T, repos *Repositories) {
+	id := saveNotebook(t, repos.Notebooks, "lists")
+	if id == repository.DEFAULT_NOTEBOOK_ID {
+		t.Error("New notebook should not get the id of the default notebook")
+	}
+
+	notebook, err := repos.Notebooks.GetNotebook(id)
+	if err != nil {
+		t.Fatalf("Could not retrieve notebook, error msg: %v", err)
+	}
+	if notebook.Title != "lists" || len(notebook.Notes) != 0 {
+		t.Errorf("Expected empty notebook with title lists, got: %+v", notebook)
+	}
+
+	notebook, err = repos.Notebooks.GetNotebookByTitle("lists")
+	if err != nil || notebook == nil || notebook.ID != id {
+		t.Errorf("Could not retrieve notebook by title, error msg: %v", err)
+	}
+}
+
+func testSaveNotebookWithoutTitle(t *testing.T, repos *Repositories) {
+	if _, err := repos.Notebooks.SaveNotebook(model.NewNotebook("")); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Notebook without title should not be saved, got: %v", err)
+	}
+}
+
+func testSaveNotebookWithDuplicateTitle(t *testing.T, repos *Repositories) {
+	saveNotebook(t, repos.Notebooks, "lists")
+	if _, err := repos.Notebooks.SaveNotebook(model.NewNotebook("lists")); !errors.Is(err, repository.ErrNotebookExists) {
+		t.Errorf("Notebook titles should be unique, got: %v", err)
+	}
+	titles, _ := repos.Notebooks.GetAllNotebooksTitle()
+	if len(titles) != 2 {
+		t.Errorf("Expected default notebook and lists, got: %v", titles)
+	}
+}
+
+func testGetMissingNotebook(t *testing.T, repos *Repositories) {
+	if _, err := repos.Notebooks.GetNotebook(42); !errors.Is(err, repository.ErrNotebookNotFound) {
+		t.Errorf("Retrieving a missing notebook should fail with ErrNotebookNotFound, got: %v", err)
+	}
+	notebook, err := repos.Notebooks.GetNotebookByTitle("missing")
+	if err != nil || notebook != nil {
+		t.Errorf("Retrieving a missing notebook by title should return nil, got: %v, %v", notebook, err)
+	}
+}
+
+func testGetNotebookContainsNotes(t *testing.T, repos *Repositories) {
+	notebookID := saveNotebook(t, repos.Notebooks, "lists")
+	id1 := saveNote(t, repos.Notes, newNote("first", "memo", notebookID, []string{"tag"}, 1))
+	id2 := saveNote(t, repos.Notes, newNote("second", "memo", notebookID, []string{}, 2))
+	saveNote(t, repos.Notes, newNote("other", "memo", 0, []string{}, 3))
+
+	notebook, err := repos.Notebooks.GetNotebook(notebookID)
+	if err != nil {
+		t.Fatalf("Could not retrieve notebook, error msg: %v", err)
+	}
+	if len(notebook.Notes) != 2 || notebook.Notes[id1] == nil || notebook.Notes[id2] == nil {
+		t.Fatalf("Expected notes %v and %v in notebook, got: %v", id1, id2, notebook.Notes)
+	}
+	if !notebook.Notes[id1].Tags["tag"] {
+		t.Error("Notes of notebook should contain their tags")
+	}
+}
+
+func testUpdateNotebook(t *testing.T, repos *Repositories) {
+	id := saveNotebook(t, repos.Notebooks, "lists")
+	saveNotebook(t, repos.Notebooks, "expenses")
+
+	notebook, _ := repos.Notebooks.GetNotebook(id)
+	notebook.Title = "2018 lists"
+	if err := repos.Notebooks.UpdateNotebook(notebook); err != nil {
+		t.Fatalf("Could not update notebook, error msg: %v", err)
+	}
+	stored, _ := repos.Notebooks.GetNotebook(id)
+	if stored == nil || stored.Title != "2018 lists" {
+		t.Errorf("Expected title: 2018 lists, got: %+v", stored)
+	}
+
+	notebook.Title = "expenses"
+	if err := repos.Notebooks.UpdateNotebook(notebook); !errors.Is(err, repository.ErrNotebookExists) {
+		t.Errorf("Notebook should not be renamed to an existing title, got: %v", err)
+	}
+}
+
+func testUpdateNotebookWithoutTitle(t *testing.T, repos *Repositories) {
+	id := saveNotebook(t, repos.Notebooks, "lists")
+	if err := repos.Notebooks.UpdateNotebook(&model.Notebook{ID: id}); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Notebook without title should not be updated, got: %v", err)
+	}
+}
+
+func testDeleteNotebooksCascadesToNotes(t *testing.T, repos *Repositories) {
+	deletedID := saveNotebook(t, repos.Notebooks, "lists")
+	keptID := saveNotebook(t, repos.Notebooks, "expenses")
+	saveNote(t, repos.Notes, newNote("deleted", "memo", deletedID, []string{"tag"}, 1))
+	keptNoteID := saveNote(t, repos.Notes, newNote("kept", "memo", keptID, []string{"tag"}, 2))
+
+	if err := repos.Notebooks.DeleteNotebooks([]int64{deletedID}); err != nil {
+		t.Fatalf("Could not delete notebook, error msg: %v", err)
+	}
+	if _, err := repos.Notebooks.GetNotebook(deletedID); err == nil {
+		t.Error("Deleted notebook should not be retrieved")
+	}
+	notes, _ := repos.Notes.GetNotes([]int64{})
+	checkNoteIDs(t, notes, keptNoteID)
+	tagged, _ := repos.Notes.GetNotesByTag([]string{"tag"})
+	checkNoteIDs(t, tagged, keptNoteID)
+
+	if err := repos.Notebooks.DeleteNotebook(42); err != nil {
+		t.Errorf("Deleting a missing notebook should not fail, error msg: %v", err)
+	}
+}
+
+func testDeleteDefaultNotebook(t *testing.T, repos *Repositories) {
+	otherID := saveNotebook(t, repos.Notebooks, "lists")
+	id := saveNote(t, repos.Notes, newNote("title", "memo", 0, []string{}, 0))
+
+	err := repos.Notebooks.DeleteNotebooks([]int64{otherID, repository.DEFAULT_NOTEBOOK_ID})
+	if !errors.Is(err, repository.ErrDefaultNotebookProtected) {
+		t.Errorf("Deleting the default notebook should fail with ErrDefaultNotebookProtected, got: %v", err)
+	}
+	if _, err := repos.Notebooks.GetNotebook(otherID); err != nil {
+		t.Error("Failed delete should not delete any notebook")
+	}
+	if _, err := repos.Notes.GetNote(id); err != nil {
+		t.Error("Failed delete should not delete notes of the default notebook")
+	}
+}
+
+func testGetAllNotebooksTitle(t *testing.T, repos *Repositories) {
+	id := saveNotebook(t, repos.Notebooks, "lists")
+	titles, err := repos.Notebooks.GetAllNotebooksTitle()
+	if err != nil {
+		t.Fatalf("Could not retrieve titles, error msg: %v", err)
+	}
+	if len(titles) != 2 || titles[repository.DEFAULT_NOTEBOOK_ID] != "Default Notebook" || titles[id] != "lists" {
+		t.Errorf("Unexpected notebook titles: %v", titles)
+	}
+}
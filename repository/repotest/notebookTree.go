@@ -0,0 +1,228 @@
+package repotest
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"testing"
+)
+
+//RunNotebookTree checks that notebooks can be nested and addressed by their path.
+func RunNotebookTree(t *testing.T, factory Factory) {
+	runCases(t, factory, []testCase{
+		{"SaveChildNotebook", testSaveChildNotebook},
+		{"ChildTitlesUniquePerParent", testChildTitlesUniquePerParent},
+		{"SaveNotebookInvalidTitle", testSaveNotebookInvalidTitle},
+		{"SaveNotebookMissingParent", testSaveNotebookMissingParent},
+		{"GetNotebookByMissingPath", testGetNotebookByMissingPath},
+		{"GetAllNotebooksPaths", testGetAllNotebooksPaths},
+		{"MoveNotebook", testMoveNotebook},
+		{"MoveNotebookUnderItself", testMoveNotebookUnderItself},
+		{"MoveDefaultNotebook", testMoveDefaultNotebook},
+		{"DeleteNotebookWithChildren", testDeleteNotebookWithChildren},
+		{"RestoreNotebookTree", testRestoreNotebookTree},
+		{"RestoreChildNotebook", testRestoreChildNotebook},
+	})
+}
+
+func saveChildNotebook(t *testing.T, repo repository.NotebookRepository, title string, parentID int64) int64 {
+	t.Helper()
+	notebook := model.NewNotebook(title)
+	notebook.ParentID = parentID
+	id, err := repo.SaveNotebook(notebook)
+	if err != nil {
+		t.Fatalf("Could not save notebook, error msg: %v", err)
+	}
+	return id
+}
+
+func getNotebookByPath(t *testing.T, repo repository.NotebookRepository, path string) *model.Notebook {
+	t.Helper()
+	notebook, err := repo.GetNotebookByTitle(path)
+	if err != nil {
+		t.Fatalf("Could not retrieve notebook with path: %v, error msg: %v", path, err)
+	}
+	return notebook
+}
+
+func testSaveChildNotebook(t *testing.T, repos *Repositories) {
+	workID := saveNotebook(t, repos.Notebooks, "work")
+	infraID := saveChildNotebook(t, repos.Notebooks, "infra", workID)
+	k8sID := saveChildNotebook(t, repos.Notebooks, "k8s", infraID)
+	noteID := saveNote(t, repos.Notes, newNote("title", "memo", k8sID, []string{}, 0))
+
+	notebook := getNotebookByPath(t, repos.Notebooks, "work/infra/k8s")
+	if notebook == nil || notebook.ID != k8sID || notebook.ParentID != infraID {
+		t.Fatalf("Expected notebook %v under %v, got: %+v", k8sID, infraID, notebook)
+	}
+	if _, ok := notebook.Notes[noteID]; !ok {
+		t.Errorf("Expected notebook to contain note %v, got: %v", noteID, notebook.Notes)
+	}
+	//surrounding slashes and spaces are ignored
+	if notebook := getNotebookByPath(t, repos.Notebooks, "/work/ infra/"); notebook == nil || notebook.ID != infraID {
+		t.Errorf("Expected notebook %v, got: %+v", infraID, notebook)
+	}
+	stored, err := repos.Notebooks.GetNotebook(infraID)
+	if err != nil || stored.ParentID != workID {
+		t.Errorf("Expected notebook %v to have parent %v, got: %+v, error msg: %v", infraID, workID, stored, err)
+	}
+}
+
+func testChildTitlesUniquePerParent(t *testing.T, repos *Repositories) {
+	workID := saveNotebook(t, repos.Notebooks, "work")
+	homeID := saveNotebook(t, repos.Notebooks, "home")
+	workInfraID := saveChildNotebook(t, repos.Notebooks, "infra", workID)
+	homeInfraID := saveChildNotebook(t, repos.Notebooks, "infra", homeID)
+
+	if notebook := getNotebookByPath(t, repos.Notebooks, "home/infra"); notebook == nil || notebook.ID != homeInfraID {
+		t.Errorf("Expected notebook %v, got: %+v", homeInfraID, notebook)
+	}
+	if notebook := getNotebookByPath(t, repos.Notebooks, "work/infra"); notebook == nil || notebook.ID != workInfraID {
+		t.Errorf("Expected notebook %v, got: %+v", workInfraID, notebook)
+	}
+	duplicate := model.NewNotebook("infra")
+	duplicate.ParentID = workID
+	if _, err := repos.Notebooks.SaveNotebook(duplicate); !errors.Is(err, repository.ErrNotebookExists) {
+		t.Errorf("Expected ErrNotebookExists for a duplicate title of the same parent, got: %v", err)
+	}
+}
+
+func testSaveNotebookInvalidTitle(t *testing.T, repos *Repositories) {
+	if _, err := repos.Notebooks.SaveNotebook(model.NewNotebook("work/infra")); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected ErrValidation for a title containing the path separator, got: %v", err)
+	}
+}
+
+func testSaveNotebookMissingParent(t *testing.T, repos *Repositories) {
+	notebook := model.NewNotebook("infra")
+	notebook.ParentID = 42
+	if _, err := repos.Notebooks.SaveNotebook(notebook); !errors.Is(err, repository.ErrNotebookNotFound) {
+		t.Errorf("Expected ErrNotebookNotFound for a missing parent, got: %v", err)
+	}
+}
+
+func testGetNotebookByMissingPath(t *testing.T, repos *Repositories) {
+	workID := saveNotebook(t, repos.Notebooks, "work")
+	saveChildNotebook(t, repos.Notebooks, "infra", workID)
+
+	for _, path := range []string{"infra", "work/k8s", "home/infra", ""} {
+		if notebook := getNotebookByPath(t, repos.Notebooks, path); notebook != nil {
+			t.Errorf("Expected no notebook at path: %q, got: %+v", path, notebook)
+		}
+	}
+}
+
+func testGetAllNotebooksPaths(t *testing.T, repos *Repositories) {
+	workID := saveNotebook(t, repos.Notebooks, "work")
+	infraID := saveChildNotebook(t, repos.Notebooks, "infra", workID)
+	k8sID := saveChildNotebook(t, repos.Notebooks, "k8s", infraID)
+
+	paths, err := repos.Notebooks.GetAllNotebooksTitle()
+	if err != nil {
+		t.Fatalf("Could not retrieve notebook paths, error msg: %v", err)
+	}
+	if len(paths) != 4 || paths[workID] != "work" || paths[infraID] != "work/infra" || paths[k8sID] != "work/infra/k8s" {
+		t.Errorf("Unexpected notebook paths: %v", paths)
+	}
+}
+
+func testMoveNotebook(t *testing.T, repos *Repositories) {
+	workID := saveNotebook(t, repos.Notebooks, "work")
+	homeID := saveNotebook(t, repos.Notebooks, "home")
+	infraID := saveChildNotebook(t, repos.Notebooks, "infra", workID)
+	saveChildNotebook(t, repos.Notebooks, "k8s", infraID)
+
+	if err := repos.Notebooks.UpdateNotebook(&model.Notebook{ID: infraID, Title: "servers", ParentID: homeID}); err != nil {
+		t.Fatalf("Could not move notebook, error msg: %v", err)
+	}
+	if notebook := getNotebookByPath(t, repos.Notebooks, "home/servers/k8s"); notebook == nil {
+		t.Error("Children should move along with their parent")
+	}
+	if notebook := getNotebookByPath(t, repos.Notebooks, "work/infra"); notebook != nil {
+		t.Errorf("Expected no notebook at the old path, got: %+v", notebook)
+	}
+	if err := repos.Notebooks.UpdateNotebook(&model.Notebook{ID: infraID, Title: "servers"}); err != nil {
+		t.Fatalf("Could not move notebook to the top level, error msg: %v", err)
+	}
+	if notebook := getNotebookByPath(t, repos.Notebooks, "servers"); notebook == nil || notebook.ID != infraID {
+		t.Errorf("Expected notebook %v at the top level, got: %+v", infraID, notebook)
+	}
+}
+
+func testMoveNotebookUnderItself(t *testing.T, repos *Repositories) {
+	workID := saveNotebook(t, repos.Notebooks, "work")
+	infraID := saveChildNotebook(t, repos.Notebooks, "infra", workID)
+	k8sID := saveChildNotebook(t, repos.Notebooks, "k8s", infraID)
+
+	for _, parentID := range []int64{workID, k8sID} {
+		err := repos.Notebooks.UpdateNotebook(&model.Notebook{ID: workID, Title: "work", ParentID: parentID})
+		if !errors.Is(err, repository.ErrValidation) {
+			t.Errorf("Expected ErrValidation when moving a notebook under %v, got: %v", parentID, err)
+		}
+	}
+	if notebook := getNotebookByPath(t, repos.Notebooks, "work/infra/k8s"); notebook == nil {
+		t.Error("Failed move should not modify the notebooks")
+	}
+}
+
+func testMoveDefaultNotebook(t *testing.T, repos *Repositories) {
+	workID := saveNotebook(t, repos.Notebooks, "work")
+	err := repos.Notebooks.UpdateNotebook(&model.Notebook{ID: repository.DEFAULT_NOTEBOOK_ID, Title: "Default Notebook", ParentID: workID})
+	if !errors.Is(err, repository.ErrDefaultNotebookProtected) {
+		t.Errorf("Expected ErrDefaultNotebookProtected, got: %v", err)
+	}
+}
+
+func testDeleteNotebookWithChildren(t *testing.T, repos *Repositories) {
+	workID := saveNotebook(t, repos.Notebooks, "work")
+	infraID := saveChildNotebook(t, repos.Notebooks, "infra", workID)
+
+	if err := repos.Notebooks.DeleteNotebook(workID); !errors.Is(err, repository.ErrNotebookHasChildren) {
+		t.Errorf("Expected ErrNotebookHasChildren, got: %v", err)
+	}
+	if notebook := getNotebookByPath(t, repos.Notebooks, "work/infra"); notebook == nil {
+		t.Error("Failed delete should not delete any notebook")
+	}
+	if err := repos.Notebooks.DeleteNotebooks([]int64{workID, infraID}); err != nil {
+		t.Fatalf("Could not delete notebook along with its children, error msg: %v", err)
+	}
+	if len(getTrashedNotebooks(t, repos.Notebooks)) != 2 {
+		t.Error("Expected both notebooks in the trash")
+	}
+}
+
+func testRestoreNotebookTree(t *testing.T, repos *Repositories) {
+	workID := saveNotebook(t, repos.Notebooks, "work")
+	infraID := saveChildNotebook(t, repos.Notebooks, "infra", workID)
+	noteID := saveNote(t, repos.Notes, newNote("title", "memo", infraID, []string{}, 0))
+	if err := repos.Notebooks.DeleteNotebooks([]int64{workID, infraID}); err != nil {
+		t.Fatalf("Could not delete notebooks, error msg: %v", err)
+	}
+	if err := repos.Notebooks.RestoreNotebooks([]int64{workID}); err != nil {
+		t.Fatalf("Could not restore notebook, error msg: %v", err)
+	}
+
+	notebook := getNotebookByPath(t, repos.Notebooks, "work/infra")
+	if notebook == nil {
+		t.Fatal("Children deleted along with a notebook should be restored with it")
+	}
+	if _, ok := notebook.Notes[noteID]; !ok {
+		t.Errorf("Expected restored child to contain note %v, got: %v", noteID, notebook.Notes)
+	}
+}
+
+func testRestoreChildNotebook(t *testing.T, repos *Repositories) {
+	workID := saveNotebook(t, repos.Notebooks, "work")
+	infraID := saveChildNotebook(t, repos.Notebooks, "infra", workID)
+	noteID := saveNote(t, repos.Notes, newNote("title", "memo", infraID, []string{}, 0))
+	if err := repos.Notebooks.DeleteNotebooks([]int64{workID, infraID}); err != nil {
+		t.Fatalf("Could not delete notebooks, error msg: %v", err)
+	}
+	if err := repos.Notes.RestoreNotes([]int64{noteID}); err != nil {
+		t.Fatalf("Could not restore note, error msg: %v", err)
+	}
+
+	if notebook := getNotebookByPath(t, repos.Notebooks, "work/infra"); notebook == nil {
+		t.Error("Restoring a note should restore its notebook along with the ancestors of the notebook")
+	}
+}
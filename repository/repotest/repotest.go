@@ -0,0 +1,73 @@
+//Package repotest is a conformance suite for the repository interfaces. Every backend, or wrapper of a
+//backend, should pass it so that commands and the rest API behave the same regardless of the storage.
+//
+//Usage from a test of the backend:
+//
+//	func TestConformance(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) (*repotest.Repositories, func()) {
+//			...
+//		})
+//	}
+package repotest
+
+import (
+	"github.com/nicolasmanic/tefter/repository"
+	"testing"
+)
+
+//Repositories groups the repositories under test, they must share the same storage.
+type Repositories struct {
+	Notes     repository.NoteRepository
+	Notebooks repository.NotebookRepository
+	Accounts  repository.AccountRepository
+}
+
+//Factory returns repositories backed by an empty storage and a function tearing the storage down.
+//It is called once per test case.
+type Factory func(t *testing.T) (*Repositories, func())
+
+//Run executes the whole suite against the repositories returned by factory.
+func Run(t *testing.T, factory Factory) {
+	t.Run("NoteRepository", func(t *testing.T) { RunNoteRepository(t, factory) })
+	t.Run("NoteQuery", func(t *testing.T) { RunNoteQuery(t, factory) })
+	t.Run("Search", func(t *testing.T) { RunSearch(t, factory) })
+	t.Run("Revisions", func(t *testing.T) { RunRevisions(t, factory) })
+	t.Run("Trash", func(t *testing.T) { RunTrash(t, factory) })
+	t.Run("Tags", func(t *testing.T) { RunTags(t, factory) })
+	t.Run("Links", func(t *testing.T) { RunLinks(t, factory) })
+	t.Run("Attachments", func(t *testing.T) { RunAttachments(t, factory) })
+	t.Run("NotebookRepository", func(t *testing.T) { RunNotebookRepository(t, factory) })
+	t.Run("NotebookTree", func(t *testing.T) { RunNotebookTree(t, factory) })
+	t.Run("AccountRepository", func(t *testing.T) { RunAccountRepository(t, factory) })
+}
+
+type testCase struct {
+	name string
+	test func(t *testing.T, repos *Repositories)
+}
+
+func runCases(t *testing.T, factory Factory, cases []testCase) {
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			repos, tearDown := factory(t)
+			defer func() {
+				closeRepositories(repos)
+				tearDown()
+			}()
+			c.test(t, repos)
+		})
+	}
+}
+
+func closeRepositories(repos *Repositories) {
+	if repos.Notes != nil {
+		repos.Notes.CloseDB()
+	}
+	if repos.Notebooks != nil {
+		repos.Notebooks.CloseDB()
+	}
+	if repos.Accounts != nil {
+		repos.Accounts.CloseDB()
+	}
+}
@@ -0,0 +1,168 @@
+package repotest
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"testing"
+	"time"
+)
+
+//RunRevisions checks that updates of notes are kept as revisions and that revisions can be restored.
+func RunRevisions(t *testing.T, factory Factory) {
+	runCases(t, factory, []testCase{
+		{"SaveNoteFirstRevision", testSaveNoteFirstRevision},
+		{"UpdateNoteAddsRevision", testUpdateNoteAddsRevision},
+		{"UpdateNoteTagsOnly", testUpdateNoteTagsOnly},
+		{"GetRevision", testGetRevision},
+		{"GetRevisionsMissingNote", testGetRevisionsMissingNote},
+		{"RestoreRevision", testRestoreRevision},
+		{"DeleteNoteRevisions", testDeleteNoteRevisions},
+	})
+}
+
+func getRevisions(t *testing.T, repo repository.NoteRepository, noteID int64) []*model.Revision {
+	t.Helper()
+	revisions, err := repo.GetRevisions(noteID)
+	if err != nil {
+		t.Fatalf("Could not retrieve revisions, error msg: %v", err)
+	}
+	return revisions
+}
+
+func updateNote(t *testing.T, repo repository.NoteRepository, note *model.Note, title, memo string) {
+	t.Helper()
+	note.UpdateTitle(title)
+	note.UpdateMemo(memo)
+	if err := repo.UpdateNote(note); err != nil {
+		t.Fatalf("Could not update note, error msg: %v", err)
+	}
+}
+
+func checkRevisionMemos(t *testing.T, revisions []*model.Revision, expected ...string) {
+	t.Helper()
+	memos := []string{}
+	for i, revision := range revisions {
+		if revision.Number != i+1 {
+			t.Errorf("Expected revision %v to be numbered %v, got: %v", i, i+1, revision.Number)
+		}
+		memos = append(memos, revision.Memo)
+	}
+	if len(memos) != len(expected) {
+		t.Fatalf("Expected revisions with memos: %v, got: %v", expected, memos)
+	}
+	for i := range memos {
+		if memos[i] != expected[i] {
+			t.Fatalf("Expected revisions with memos: %v, got: %v", expected, memos)
+		}
+	}
+}
+
+func testSaveNoteFirstRevision(t *testing.T, repos *Repositories) {
+	note := newNote("title", "memo", 0, []string{}, 0)
+	id := saveNote(t, repos.Notes, note)
+
+	revisions := getRevisions(t, repos.Notes, id)
+	checkRevisionMemos(t, revisions, "memo")
+	if revisions[0].NoteID != id || revisions[0].Title != "title" {
+		t.Errorf("Unexpected first revision: %+v", revisions[0])
+	}
+	if !revisions[0].Created.Equal(note.LastUpdated) {
+		t.Errorf("Expected revision created at: %v, got: %v", note.LastUpdated, revisions[0].Created)
+	}
+}
+
+func testUpdateNoteAddsRevision(t *testing.T, repos *Repositories) {
+	note := newNote("title", "first", 0, []string{}, 0)
+	id := saveNote(t, repos.Notes, note)
+	updateNote(t, repos.Notes, note, "title", "second")
+	updateNote(t, repos.Notes, note, "new title", "second")
+
+	revisions := getRevisions(t, repos.Notes, id)
+	checkRevisionMemos(t, revisions, "first", "second", "second")
+	if revisions[2].Title != "new title" {
+		t.Errorf("Expected latest revision to have the new title, got: %v", revisions[2].Title)
+	}
+}
+
+func testUpdateNoteTagsOnly(t *testing.T, repos *Repositories) {
+	note := newNote("title", "memo", 0, []string{}, 0)
+	id := saveNote(t, repos.Notes, note)
+	note.AddTags([]string{"tag"})
+	if err := repos.Notes.UpdateNote(note); err != nil {
+		t.Fatalf("Could not update note, error msg: %v", err)
+	}
+
+	checkRevisionMemos(t, getRevisions(t, repos.Notes, id), "memo")
+}
+
+func testGetRevision(t *testing.T, repos *Repositories) {
+	note := newNote("title", "first", 0, []string{}, 0)
+	id := saveNote(t, repos.Notes, note)
+	updateNote(t, repos.Notes, note, "title", "second")
+
+	revision, err := repos.Notes.GetRevision(id, 2)
+	if err != nil {
+		t.Fatalf("Could not retrieve revision, error msg: %v", err)
+	}
+	if revision.Number != 2 || revision.Memo != "second" {
+		t.Errorf("Unexpected revision: %+v", revision)
+	}
+	if _, err := repos.Notes.GetRevision(id, 3); !errors.Is(err, repository.ErrRevisionNotFound) {
+		t.Errorf("Expected ErrRevisionNotFound, got: %v", err)
+	}
+	if _, err := repos.Notes.GetRevision(id+1, 1); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got: %v", err)
+	}
+}
+
+func testGetRevisionsMissingNote(t *testing.T, repos *Repositories) {
+	if _, err := repos.Notes.GetRevisions(42); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got: %v", err)
+	}
+}
+
+func testRestoreRevision(t *testing.T, repos *Repositories) {
+	note := newNote("first title", "first", 0, []string{"tag"}, 0)
+	id := saveNote(t, repos.Notes, note)
+	updateNote(t, repos.Notes, note, "second title", "second")
+
+	restored, err := repos.Notes.RestoreRevision(id, 1)
+	if err != nil {
+		t.Fatalf("Could not restore revision, error msg: %v", err)
+	}
+	if restored.Title != "first title" || restored.Memo != "first" {
+		t.Errorf("Expected restored title and memo, got: %v, %v", restored.Title, restored.Memo)
+	}
+	stored, err := repos.Notes.GetNote(id)
+	if err != nil {
+		t.Fatalf("Could not retrieve note, error msg: %v", err)
+	}
+	if stored.Title != "first title" || stored.Memo != "first" || !stored.Tags["tag"] {
+		t.Errorf("Expected stored note to be restored keeping its tags, got: %+v", stored)
+	}
+	//restoring is an update too, so it can be undone
+	checkRevisionMemos(t, getRevisions(t, repos.Notes, id), "first", "second", "first")
+
+	if _, err := repos.Notes.RestoreRevision(id, 5); !errors.Is(err, repository.ErrRevisionNotFound) {
+		t.Errorf("Expected ErrRevisionNotFound, got: %v", err)
+	}
+}
+
+func testDeleteNoteRevisions(t *testing.T, repos *Repositories) {
+	note := newNote("title", "memo", 0, []string{}, 0)
+	id := saveNote(t, repos.Notes, note)
+	updateNote(t, repos.Notes, note, "title", "new memo")
+	if err := repos.Notes.DeleteNote(id); err != nil {
+		t.Fatalf("Could not delete note, error msg: %v", err)
+	}
+	//revisions stay while the note is in the trash
+	checkRevisionMemos(t, getRevisions(t, repos.Notes, id), "memo", "new memo")
+	if _, err := repos.Notes.PurgeNotes(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Could not purge notes, error msg: %v", err)
+	}
+
+	if _, err := repos.Notes.GetRevisions(id); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got: %v", err)
+	}
+}
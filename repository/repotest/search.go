@@ -0,0 +1,314 @@
+package repotest
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/repository"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//RunSearch checks the ranking, highlighting and paging of searches.
+func RunSearch(t *testing.T, factory Factory) {
+	runCases(t, factory, []testCase{
+		{"SearchRanksTitleHigher", testSearchRanksTitleHigher},
+		{"SearchRanksMoreMatchesHigher", testSearchRanksMoreMatchesHigher},
+		{"SearchHighlights", testSearchHighlights},
+		{"SearchPrefix", testSearchPrefix},
+		{"SearchWordsWithColon", testSearchWordsWithColon},
+		{"SearchRankPages", testSearchRankPages},
+		{"SearchWithFilter", testSearchWithFilter},
+		{"SearchWithoutText", testSearchWithoutText},
+		{"SearchInvalidQuery", testSearchInvalidQuery},
+		{"SearchFuzzy", testSearchFuzzy},
+		{"SearchFuzzyRanks", testSearchFuzzyRanks},
+		{"SearchFuzzyWithFilter", testSearchFuzzyWithFilter},
+		{"SearchNotebookPath", testSearchNotebookPath},
+		{"SearchFuzzyAfterChanges", testSearchFuzzyAfterChanges},
+	})
+}
+
+func searchNotes(t *testing.T, repo repository.NoteRepository, queryText string, query repository.NoteQuery) *repository.SearchPage {
+	t.Helper()
+	return searchFilter(t, repo, queryText, repository.NoteFilter{}, query)
+}
+
+//searchFilter searches the notes matching filter and queryText.
+func searchFilter(t *testing.T, repo repository.NoteRepository, queryText string, filter repository.NoteFilter, query repository.NoteQuery) *repository.SearchPage {
+	t.Helper()
+	expr, err := repository.ParseQuery(queryText)
+	if err != nil {
+		t.Fatalf("Could not parse query: %v, error msg: %v", queryText, err)
+	}
+	filter.Query = expr
+	page, err := repo.SearchNotes(filter, query)
+	if err != nil {
+		t.Fatalf("Could not search notes with query: %v, error msg: %v", queryText, err)
+	}
+	return page
+}
+
+//searchNotePage returns the notes of a search page as a NotePage, to page searches with collectPages.
+func searchNotePage(repo repository.NoteRepository, queryText string, filter repository.NoteFilter, query repository.NoteQuery) (*repository.NotePage, error) {
+	expr, err := repository.ParseQuery(queryText)
+	if err != nil {
+		return nil, err
+	}
+	filter.Query = expr
+	page, err := repo.SearchNotes(filter, query)
+	if err != nil {
+		return nil, err
+	}
+	notePage := &repository.NotePage{Next: page.Next}
+	for _, hit := range page.Hits {
+		notePage.Notes = append(notePage.Notes, hit.Note)
+	}
+	return notePage, nil
+}
+
+func hitIDs(page *repository.SearchPage) []int64 {
+	ids := []int64{}
+	for _, hit := range page.Hits {
+		ids = append(ids, hit.Note.ID)
+	}
+	return ids
+}
+
+func checkHitIDs(t *testing.T, page *repository.SearchPage, expected ...int64) {
+	t.Helper()
+	if expected == nil {
+		expected = []int64{}
+	}
+	if ids := hitIDs(page); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Expected hits: %v, got: %v", expected, ids)
+	}
+}
+
+func testSearchRanksTitleHigher(t *testing.T, repos *Repositories) {
+	memo := saveNote(t, repos.Notes, newNote("shopping", "learn go before the trip", 0, []string{}, 1))
+	title := saveNote(t, repos.Notes, newNote("go", "learn before the trip", 0, []string{}, 0))
+	saveNote(t, repos.Notes, newNote("rust", "learn rust before the trip", 0, []string{}, 2))
+
+	page := searchNotes(t, repos.Notes, "go", repository.NoteQuery{})
+	checkHitIDs(t, page, title, memo)
+	if len(page.Hits) == 2 && page.Hits[0].Rank <= page.Hits[1].Rank {
+		t.Errorf("Expected rank of title match to be higher, got: %v and %v", page.Hits[0].Rank, page.Hits[1].Rank)
+	}
+}
+
+func testSearchRanksMoreMatchesHigher(t *testing.T, repos *Repositories) {
+	once := saveNote(t, repos.Notes, newNote("notes", "go rust java lisp", 0, []string{}, 1))
+	thrice := saveNote(t, repos.Notes, newNote("notes", "go go go rust", 0, []string{}, 0))
+	saveNote(t, repos.Notes, newNote("notes", "python ruby perl lua", 0, []string{}, 2))
+
+	checkHitIDs(t, searchNotes(t, repos.Notes, "go", repository.NoteQuery{}), thrice, once)
+	//the order of the sort field is kept by searches
+	checkHitIDs(t, searchNotes(t, repos.Notes, "go", repository.NoteQuery{Sort: repository.SortByCreated}), once, thrice)
+}
+
+func testSearchHighlights(t *testing.T, repos *Repositories) {
+	memo := "A long memo with many words before the interesting part, here we learn go generics " +
+		"and afterwards a lot of other words that should not be part of the snippet at all"
+	saveNote(t, repos.Notes, newNote("Learn Go", memo, 0, []string{}, 0))
+
+	page := searchNotes(t, repos.Notes, "go", repository.NoteQuery{})
+	if len(page.Hits) != 1 {
+		t.Fatalf("Expected one hit, got: %v", hitIDs(page))
+	}
+	hit := page.Hits[0]
+	if hit.Title != "Learn <mark>Go</mark>" {
+		t.Errorf("Expected highlighted title, got: %q", hit.Title)
+	}
+	if !strings.Contains(hit.Snippet, "<mark>go</mark>") || strings.Contains(hit.Snippet, "at all") {
+		t.Errorf("Expected snippet around the match, got: %q", hit.Snippet)
+	}
+	if hit.Note.Memo != memo || hit.Note.Title != "Learn Go" {
+		t.Errorf("Expected note to be returned unchanged, got: %+v", hit.Note)
+	}
+}
+
+func testSearchPrefix(t *testing.T, repos *Repositories) {
+	id := saveNote(t, repos.Notes, newNote("holiday", "planning the trip", 0, []string{}, 0))
+	saveNote(t, repos.Notes, newNote("work", "the plan", 0, []string{}, 1))
+
+	page := searchNotes(t, repos.Notes, "plann*", repository.NoteQuery{})
+	checkHitIDs(t, page, id)
+	if len(page.Hits) == 1 && !strings.Contains(page.Hits[0].Snippet, "<mark>planning</mark>") {
+		t.Errorf("Expected prefix match to be highlighted, got: %q", page.Hits[0].Snippet)
+	}
+}
+
+func testSearchWordsWithColon(t *testing.T, repos *Repositories) {
+	id := saveNote(t, repos.Notes, newNote("shopping", "todo: buy milk, see http://example.com", 0, []string{}, 0))
+	saveNote(t, repos.Notes, newNote("work", "todo review", 0, []string{}, 1))
+
+	checkHitIDs(t, searchNotes(t, repos.Notes, "todo: buy milk", repository.NoteQuery{}), id)
+	checkHitIDs(t, searchNotes(t, repos.Notes, "http://example.com", repository.NoteQuery{}), id)
+}
+
+func testSearchRankPages(t *testing.T, repos *Repositories) {
+	memos := []string{"go", "go go go go", "go go", "go go go", "go go go go go"}
+	for i, memo := range memos {
+		saveNote(t, repos.Notes, newNote("notes", memo+" and some words", 0, []string{}, i))
+	}
+	all := hitIDs(searchNotes(t, repos.Notes, "go", repository.NoteQuery{}))
+	pages := collectPages(t, func(query repository.NoteQuery) (*repository.NotePage, error) {
+		return searchNotePage(repos.Notes, "go", repository.NoteFilter{}, query)
+	}, repository.NoteQuery{Limit: 2})
+	paged := []int64{}
+	for _, page := range pages {
+		paged = append(paged, page...)
+	}
+	if len(pages) != 3 || !sameIDs(paged, all) {
+		t.Errorf("Expected rank pages of notes: %v, got: %v", all, pages)
+	}
+}
+
+func testSearchWithFilter(t *testing.T, repos *Repositories) {
+	tagged := saveNote(t, repos.Notes, newNote("trip", "holiday plans", 0, []string{"go"}, 0))
+	untagged := saveNote(t, repos.Notes, newNote("holiday", "holiday plans", 0, []string{}, 1))
+	saveNote(t, repos.Notes, newNote("go", "learn generics", 0, []string{"go"}, 2))
+
+	checkHitIDs(t, searchNotes(t, repos.Notes, "tag:go holiday", repository.NoteQuery{}), tagged)
+	checkHitIDs(t, searchNotes(t, repos.Notes, "holiday -tag:go", repository.NoteQuery{}), untagged)
+}
+
+func testSearchWithoutText(t *testing.T, repos *Repositories) {
+	first := saveNote(t, repos.Notes, newNote("trip", "holiday plans", 0, []string{"go"}, 0))
+	second := saveNote(t, repos.Notes, newNote("go", "learn generics", 0, []string{"go"}, 1))
+
+	for _, query := range []repository.NoteQuery{{}, {Sort: repository.SortByRank}} {
+		page := searchNotes(t, repos.Notes, "tag:go", query)
+		checkHitIDs(t, page, second, first)
+		if len(page.Hits) == 2 && (page.Hits[0].Title != "go" || page.Hits[0].Snippet != "learn generics") {
+			t.Errorf("Expected hits without highlights, got: %+v", page.Hits[0])
+		}
+	}
+}
+
+func testSearchInvalidQuery(t *testing.T, repos *Repositories) {
+	expr, _ := repository.ParseQuery("go")
+	queries := []repository.NoteQuery{
+		{Sort: "bogus"},
+		{Offset: 2},
+		{Sort: repository.SortByRank, Cursor: "bogus", Limit: 2},
+	}
+	for _, query := range queries {
+		if _, err := repos.Notes.SearchNotes(repository.NoteFilter{Query: expr}, query); !errors.Is(err, repository.ErrValidation) {
+			t.Errorf("Expected validation error for query: %+v, got: %v", query, err)
+		}
+	}
+	if _, err := repos.Notes.ListNotes(repository.NoteFilter{}, repository.NoteQuery{Sort: repository.SortByRank}); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected validation error for listing sorted by rank, got: %v", err)
+	}
+}
+
+func testSearchFuzzy(t *testing.T, repos *Repositories) {
+	ops := saveNotebook(t, repos.Notebooks, "operations")
+	kubernetes := saveNote(t, repos.Notes, newNote("cluster", "deploy to kubernetes", 0, []string{}, 0))
+	tagged := saveNote(t, repos.Notes, newNote("groceries", "milk", 0, []string{"golang"}, 1))
+	notebook := saveNote(t, repos.Notes, newNote("pager", "on call", ops, []string{}, 2))
+	fuzzy := repository.NoteFilter{Fuzzy: true}
+
+	cases := []struct {
+		queryText string
+		expected  []int64
+	}{
+		{"kube", []int64{kubernetes}},
+		{"kubernets", []int64{kubernetes}},
+		{"kuberentes", []int64{kubernetes}},
+		{"golan", []int64{tagged}},
+		{"operatons", []int64{notebook}},
+		{"deploy -kubernetes", []int64{}},
+		{"milk OR pagr", []int64{notebook, tagged}},
+		{"kubernetes xyzzy", []int64{}},
+		{"tag:golang", []int64{tagged}},
+	}
+	for _, c := range cases {
+		page := searchFilter(t, repos.Notes, c.queryText, fuzzy, repository.NoteQuery{Sort: repository.SortByCreated})
+		if ids := hitIDs(page); !reflect.DeepEqual(ids, c.expected) {
+			t.Errorf("Expected fuzzy search: %v to return notes: %v, got: %v", c.queryText, c.expected, ids)
+		}
+	}
+	checkHitIDs(t, searchNotes(t, repos.Notes, "kube", repository.NoteQuery{}))
+
+	page := searchFilter(t, repos.Notes, "kubernets", fuzzy, repository.NoteQuery{})
+	if len(page.Hits) == 1 && page.Hits[0].Snippet != "deploy to <mark>kubernetes</mark>" {
+		t.Errorf("Expected fuzzy match to be highlighted, got: %q", page.Hits[0].Snippet)
+	}
+}
+
+func testSearchFuzzyRanks(t *testing.T, repos *Repositories) {
+	memo := saveNote(t, repos.Notes, newNote("notes", "about kubernetes", 0, []string{}, 0))
+	title := saveNote(t, repos.Notes, newNote("kubernetes", "notes", 0, []string{}, 1))
+	partial := saveNote(t, repos.Notes, newNote("kubectl", "notes", 0, []string{}, 2))
+
+	checkHitIDs(t, searchFilter(t, repos.Notes, "kubernetes", repository.NoteFilter{Fuzzy: true}, repository.NoteQuery{}), title, memo)
+	checkHitIDs(t, searchFilter(t, repos.Notes, "kube", repository.NoteFilter{Fuzzy: true}, repository.NoteQuery{}), partial, title, memo)
+}
+
+func testSearchFuzzyWithFilter(t *testing.T, repos *Repositories) {
+	tagged := saveNote(t, repos.Notes, newNote("cluster", "kubernetes", 0, []string{"ops"}, 0))
+	saveNote(t, repos.Notes, newNote("cluster", "kubernetes", 0, []string{}, 1))
+
+	filter := repository.NoteFilter{Tags: []string{"ops"}, Fuzzy: true}
+	checkHitIDs(t, searchFilter(t, repos.Notes, "kubernets", filter, repository.NoteQuery{}), tagged)
+	pages := collectPages(t, func(query repository.NoteQuery) (*repository.NotePage, error) {
+		return searchNotePage(repos.Notes, "kube", repository.NoteFilter{Fuzzy: true}, query)
+	}, repository.NoteQuery{Limit: 1})
+	if len(pages) != 2 || len(pages[0]) != 1 || len(pages[1]) != 1 || pages[0][0] == pages[1][0] {
+		t.Errorf("Expected 2 pages of fuzzy search, got: %v", pages)
+	}
+}
+
+func testSearchNotebookPath(t *testing.T, repos *Repositories) {
+	work := saveNotebook(t, repos.Notebooks, "work")
+	home := saveNotebook(t, repos.Notebooks, "home")
+	workInfra := saveNote(t, repos.Notes, newNote("cluster", "kubernetes", saveChildNotebook(t, repos.Notebooks, "infra", work), []string{}, 0))
+	homeInfraID := saveChildNotebook(t, repos.Notebooks, "infra", home)
+	homeInfra := saveNote(t, repos.Notes, newNote("router", "kubernetes", homeInfraID, []string{}, 1))
+	fuzzy := repository.NoteFilter{Fuzzy: true}
+
+	for _, filter := range []repository.NoteFilter{{}, fuzzy} {
+		checkHitIDs(t, searchFilter(t, repos.Notes, "kubernetes notebook:work/infra", filter, repository.NoteQuery{}), workInfra)
+		checkHitIDs(t, searchFilter(t, repos.Notes, "kubernetes notebook:infra", filter, repository.NoteQuery{}))
+	}
+	checkHitIDs(t, searchFilter(t, repos.Notes, "infra", fuzzy, repository.NoteQuery{Sort: repository.SortByCreated}), homeInfra, workInfra)
+
+	//notes of a notebook in the trash are in the trash too, they no longer match the notebook
+	if err := repos.Notebooks.DeleteNotebooks([]int64{home, homeInfraID}); err != nil {
+		t.Fatalf("Could not delete notebooks, error msg: %v", err)
+	}
+	trashed := repository.NoteFilter{Trashed: true}
+	checkHitIDs(t, searchFilter(t, repos.Notes, "kubernetes", trashed, repository.NoteQuery{}), homeInfra)
+	for _, filter := range []repository.NoteFilter{trashed, {Trashed: true, Fuzzy: true}} {
+		checkHitIDs(t, searchFilter(t, repos.Notes, "kubernetes notebook:home/infra", filter, repository.NoteQuery{}))
+	}
+	checkHitIDs(t, searchFilter(t, repos.Notes, "home", repository.NoteFilter{Trashed: true, Fuzzy: true}, repository.NoteQuery{}))
+}
+
+func testSearchFuzzyAfterChanges(t *testing.T, repos *Repositories) {
+	notebookID := saveNotebook(t, repos.Notebooks, "operations")
+	noteID := saveNote(t, repos.Notes, newNote("cluster", "kubernetes", notebookID, []string{}, 0))
+	fuzzy := repository.NoteFilter{Fuzzy: true}
+	checkHitIDs(t, searchFilter(t, repos.Notes, "kube", fuzzy, repository.NoteQuery{}), noteID)
+
+	note, err := repos.Notes.GetNote(noteID)
+	if err != nil {
+		t.Fatalf("Could not retrieve note, error msg: %v", err)
+	}
+	note.Memo = "nomad"
+	if err := repos.Notes.UpdateNote(note); err != nil {
+		t.Fatalf("Could not update note, error msg: %v", err)
+	}
+	checkHitIDs(t, searchFilter(t, repos.Notes, "kube", fuzzy, repository.NoteQuery{}))
+	checkHitIDs(t, searchFilter(t, repos.Notes, "noma", fuzzy, repository.NoteQuery{}), noteID)
+
+	notebook := getNotebookByPath(t, repos.Notebooks, "operations")
+	notebook.Title = "infrastructure"
+	if err := repos.Notebooks.UpdateNotebook(notebook); err != nil {
+		t.Fatalf("Could not update notebook, error msg: %v", err)
+	}
+	checkHitIDs(t, searchFilter(t, repos.Notes, "operations", fuzzy, repository.NoteQuery{}))
+	checkHitIDs(t, searchFilter(t, repos.Notes, "infrastructur", fuzzy, repository.NoteQuery{}), noteID)
+}
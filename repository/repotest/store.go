@@ -0,0 +1,113 @@
+package repotest
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"testing"
+)
+
+//StoreFactory returns a Store backed by an empty storage and a function tearing the storage down.
+//It is called once per test case.
+type StoreFactory func(t *testing.T) (repository.Store, func())
+
+//RunStore checks the Store contract and runs the whole suite against the repositories of the store.
+func RunStore(t *testing.T, factory StoreFactory) {
+	Run(t, func(t *testing.T) (*Repositories, func()) {
+		store, tearDown := factory(t)
+		return storeRepositories(store), tearDown
+	})
+	cases := []struct {
+		name string
+		test func(t *testing.T, store repository.Store)
+	}{
+		{"WithTxCommits", testWithTxCommits},
+		{"WithTxRollsBack", testWithTxRollsBack},
+		{"NestedWithTx", testNestedWithTx},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run("Store/"+c.name, func(t *testing.T) {
+			store, tearDown := factory(t)
+			defer func() {
+				store.Close()
+				tearDown()
+			}()
+			c.test(t, store)
+		})
+	}
+}
+
+func storeRepositories(store repository.Store) *Repositories {
+	return &Repositories{Notes: store.Notes(), Notebooks: store.Notebooks(), Accounts: store.Accounts()}
+}
+
+var errAbort = errors.New("abort transaction")
+
+func testWithTxCommits(t *testing.T, store repository.Store) {
+	var noteID int64
+	err := store.WithTx(func(tx repository.Store) error {
+		notebookID := saveNotebook(t, tx.Notebooks(), "lists")
+		noteID = saveNote(t, tx.Notes(), newNote("title", "memo", notebookID, []string{"tag"}, 0))
+		//changes are visible inside the transaction
+		_, err := tx.Notes().GetNote(noteID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed, error msg: %v", err)
+	}
+
+	notebook, _ := store.Notebooks().GetNotebookByTitle("lists")
+	if notebook == nil || notebook.Notes[noteID] == nil {
+		t.Error("Committed notebook and note should be retrieved")
+	}
+}
+
+func testWithTxRollsBack(t *testing.T, store repository.Store) {
+	keptID := saveNote(t, store.Notes(), newNote("kept", "memo", 0, []string{}, 0))
+	err := store.WithTx(func(tx repository.Store) error {
+		notebookID := saveNotebook(t, tx.Notebooks(), "lists")
+		saveNote(t, tx.Notes(), newNote("title", "memo", notebookID, []string{"tag"}, 1))
+		if err := tx.Notes().DeleteNote(keptID); err != nil {
+			return err
+		}
+		if err := tx.Accounts().CreateAccount("user", []byte("hashed")); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx should return the error of fn, got: %v", err)
+	}
+
+	if notebook, _ := store.Notebooks().GetNotebookByTitle("lists"); notebook != nil {
+		t.Error("Notebook of rolled back transaction should not exist")
+	}
+	notes, _ := store.Notes().GetNotes([]int64{})
+	checkNoteIDs(t, notes, keptID)
+	if usernames, _ := store.Accounts().GetUsernames(); len(usernames) != 0 {
+		t.Errorf("Account of rolled back transaction should not exist, got: %v", usernames)
+	}
+
+	//the store should still be usable after a rollback
+	if _, err := store.Notebooks().SaveNotebook(model.NewNotebook("lists")); err != nil {
+		t.Errorf("Could not save notebook after rollback, error msg: %v", err)
+	}
+}
+
+func testNestedWithTx(t *testing.T, store repository.Store) {
+	err := store.WithTx(func(tx repository.Store) error {
+		saveNotebook(t, tx.Notebooks(), "outer")
+		return tx.WithTx(func(nested repository.Store) error {
+			saveNotebook(t, nested.Notebooks(), "inner")
+			return errAbort
+		})
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx should return the error of fn, got: %v", err)
+	}
+	titles, _ := store.Notebooks().GetAllNotebooksTitle()
+	if len(titles) != 1 {
+		t.Errorf("Failed nested transaction should roll back the outer one, got: %v", titles)
+	}
+}
@@ -0,0 +1,192 @@
+package repotest
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/repository"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//RunTags checks that tags can be listed, renamed, merged and deleted across notes.
+func RunTags(t *testing.T, factory Factory) {
+	runCases(t, factory, []testCase{
+		{"ListTags", testListTags},
+		{"FilterNormalizedTags", testFilterNormalizedTags},
+		{"FilterSubTags", testFilterSubTags},
+		{"RenameTag", testRenameTag},
+		{"RenameTagErrors", testRenameTagErrors},
+		{"MergeTags", testMergeTags},
+		{"MergeMissingTags", testMergeMissingTags},
+		{"DeleteTag", testDeleteTag},
+	})
+}
+
+func listTags(t *testing.T, repo repository.NoteRepository) map[string]int {
+	t.Helper()
+	tags, err := repo.ListTags()
+	if err != nil {
+		t.Fatalf("Could not list tags, error msg: %v", err)
+	}
+	counts := make(map[string]int, len(tags))
+	for i, tag := range tags {
+		if i > 0 && tags[i-1].Tag >= tag.Tag {
+			t.Errorf("Expected tags sorted by tag, got: %v before %v", tags[i-1].Tag, tag.Tag)
+		}
+		counts[tag.Tag] = tag.Count
+	}
+	return counts
+}
+
+func checkTags(t *testing.T, repo repository.NoteRepository, expected map[string]int) {
+	t.Helper()
+	if counts := listTags(t, repo); !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Expected tags: %v, got: %v", expected, counts)
+	}
+}
+
+func testListTags(t *testing.T, repos *Repositories) {
+	saveNote(t, repos.Notes, newNote("title1", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"go", "todo"}, 0))
+	saveNote(t, repos.Notes, newNote("title2", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"go"}, 1))
+	trashedID := saveNote(t, repos.Notes, newNote("title3", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"go", "done"}, 2))
+	saveNote(t, repos.Notes, newNote("title4", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{}, 3))
+	if err := repos.Notes.DeleteNote(trashedID); err != nil {
+		t.Fatalf("Could not delete note, error msg: %v", err)
+	}
+
+	checkTags(t, repos.Notes, map[string]int{"go": 2, "todo": 1})
+}
+
+func testFilterNormalizedTags(t *testing.T, repos *Repositories) {
+	id := saveNote(t, repos.Notes, newNote("title", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{" Go "}, 0))
+
+	notes, err := repos.Notes.GetNotesByTag([]string{"GO"})
+	if err != nil {
+		t.Fatalf("Could not retrieve notes by tag, error msg: %v", err)
+	}
+	checkNoteIDs(t, notes, id)
+	expr, err := repository.ParseQuery("tag:Go")
+	if err != nil {
+		t.Fatalf("Could not parse query, error msg: %v", err)
+	}
+	checkNoteIDs(t, listNotes(t, repos.Notes, repository.NoteFilter{Query: expr}, repository.NoteQuery{}).Notes, id)
+}
+
+func testFilterSubTags(t *testing.T, repos *Repositories) {
+	id1 := saveNote(t, repos.Notes, newNote("title1", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"lang/go"}, 0))
+	id2 := saveNote(t, repos.Notes, newNote("title2", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"lang/rust", "lang"}, 1))
+	id3 := saveNote(t, repos.Notes, newNote("title3", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"lang/go/generics"}, 2))
+	saveNote(t, repos.Notes, newNote("title4", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"language", "lang_go"}, 3))
+
+	cases := []struct {
+		tags     []string
+		expected []int64
+	}{
+		{[]string{"Lang"}, []int64{id3, id2, id1}},
+		{[]string{"lang/go"}, []int64{id3, id1}},
+		{[]string{"lang/go/generics", "lang/rust"}, []int64{id3, id2}},
+		//LIKE wildcards of tags are matched literally
+		{[]string{"lang_"}, []int64{}},
+		{[]string{"lang/"}, []int64{}},
+	}
+	for _, c := range cases {
+		notes, err := repos.Notes.GetNotesByTag(c.tags)
+		if err != nil {
+			t.Fatalf("Could not retrieve notes by tag, error msg: %v", err)
+		}
+		checkNoteIDs(t, notes, c.expected...)
+		expr, err := repository.ParseQuery("tag:" + strings.Join(c.tags, " OR tag:"))
+		if err != nil {
+			t.Fatalf("Could not parse query, error msg: %v", err)
+		}
+		checkNoteIDs(t, listNotes(t, repos.Notes, repository.NoteFilter{Query: expr}, repository.NoteQuery{}).Notes, c.expected...)
+	}
+}
+
+func testRenameTag(t *testing.T, repos *Repositories) {
+	id1 := saveNote(t, repos.Notes, newNote("title1", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"golang", "todo"}, 0))
+	id2 := saveNote(t, repos.Notes, newNote("title2", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"golang"}, 1))
+	if err := repos.Notes.DeleteNote(id2); err != nil {
+		t.Fatalf("Could not delete note, error msg: %v", err)
+	}
+
+	renamed, err := repos.Notes.RenameTag(" GoLang", "go")
+	if err != nil || renamed != 2 {
+		t.Fatalf("Expected 2 renamed notes, got: %v, error msg: %v", renamed, err)
+	}
+	checkTags(t, repos.Notes, map[string]int{"go": 1, "todo": 1})
+	//notes in the trash are renamed too
+	if err := repos.Notes.RestoreNotes([]int64{id2}); err != nil {
+		t.Fatalf("Could not restore note, error msg: %v", err)
+	}
+	for _, id := range []int64{id1, id2} {
+		note, err := repos.Notes.GetNote(id)
+		if err != nil || !note.Tags["go"] || note.Tags["golang"] {
+			t.Errorf("Expected note %v to be tagged with go, got: %+v, error msg: %v", id, note, err)
+		}
+	}
+}
+
+func testRenameTagErrors(t *testing.T, repos *Repositories) {
+	saveNote(t, repos.Notes, newNote("title1", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"go"}, 0))
+	saveNote(t, repos.Notes, newNote("title2", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"golang"}, 1))
+
+	if _, err := repos.Notes.RenameTag("rust", "go"); !errors.Is(err, repository.ErrTagNotFound) {
+		t.Errorf("Expected ErrTagNotFound, got: %v", err)
+	}
+	if _, err := repos.Notes.RenameTag("golang", "go"); !errors.Is(err, repository.ErrTagExists) {
+		t.Errorf("Expected ErrTagExists, got: %v", err)
+	}
+	for _, newTag := range []string{" ", "GO"} {
+		if _, err := repos.Notes.RenameTag("go", newTag); !errors.Is(err, repository.ErrValidation) {
+			t.Errorf("Expected ErrValidation renaming to %q, got: %v", newTag, err)
+		}
+	}
+	checkTags(t, repos.Notes, map[string]int{"go": 1, "golang": 1})
+}
+
+func testMergeTags(t *testing.T, repos *Repositories) {
+	id1 := saveNote(t, repos.Notes, newNote("title1", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"go", "golang"}, 0))
+	saveNote(t, repos.Notes, newNote("title2", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"golang", "todo"}, 1))
+	saveNote(t, repos.Notes, newNote("title3", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"go-lang"}, 2))
+	saveNote(t, repos.Notes, newNote("title4", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"go"}, 3))
+
+	merged, err := repos.Notes.MergeTags([]string{"golang", "go-lang", "go"}, "go")
+	if err != nil || merged != 3 {
+		t.Fatalf("Expected 3 merged notes, got: %v, error msg: %v", merged, err)
+	}
+	checkTags(t, repos.Notes, map[string]int{"go": 4, "todo": 1})
+	note, err := repos.Notes.GetNote(id1)
+	if err != nil || len(note.Tags) != 1 || !note.Tags["go"] {
+		t.Errorf("Expected note %v to be tagged only with go, got: %+v, error msg: %v", id1, note, err)
+	}
+}
+
+func testMergeMissingTags(t *testing.T, repos *Repositories) {
+	saveNote(t, repos.Notes, newNote("title", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"golang"}, 0))
+
+	if _, err := repos.Notes.MergeTags([]string{"golang", "goo"}, "go"); !errors.Is(err, repository.ErrTagNotFound) {
+		t.Errorf("Expected ErrTagNotFound, got: %v", err)
+	}
+	if _, err := repos.Notes.MergeTags([]string{"golang"}, "GoLang"); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected ErrValidation merging a tag into itself, got: %v", err)
+	}
+	checkTags(t, repos.Notes, map[string]int{"golang": 1})
+}
+
+func testDeleteTag(t *testing.T, repos *Repositories) {
+	id := saveNote(t, repos.Notes, newNote("title1", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"go", "todo"}, 0))
+	saveNote(t, repos.Notes, newNote("title2", "memo", repository.DEFAULT_NOTEBOOK_ID, []string{"todo"}, 1))
+
+	deleted, err := repos.Notes.DeleteTag("TODO")
+	if err != nil || deleted != 2 {
+		t.Fatalf("Expected tag to be removed from 2 notes, got: %v, error msg: %v", deleted, err)
+	}
+	checkTags(t, repos.Notes, map[string]int{"go": 1})
+	if note, err := repos.Notes.GetNote(id); err != nil || note.Tags["todo"] {
+		t.Errorf("Expected todo to be removed from note %v, got: %+v, error msg: %v", id, note, err)
+	}
+	if _, err := repos.Notes.DeleteTag("todo"); !errors.Is(err, repository.ErrTagNotFound) {
+		t.Errorf("Expected ErrTagNotFound, got: %v", err)
+	}
+}
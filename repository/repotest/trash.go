@@ -0,0 +1,252 @@
+package repotest
+
+import (
+	"errors"
+	"github.com/nicolasmanic/tefter/model"
+	"github.com/nicolasmanic/tefter/repository"
+	"testing"
+	"time"
+)
+
+//RunTrash checks that deleted notes and notebooks are kept in the trash until they are restored or purged.
+func RunTrash(t *testing.T, factory Factory) {
+	runCases(t, factory, []testCase{
+		{"DeletedNotesHidden", testDeletedNotesHidden},
+		{"ListTrashedNotes", testListTrashedNotes},
+		{"RestoreNotes", testRestoreNotes},
+		{"RestoreNotesNotInTrash", testRestoreNotesNotInTrash},
+		{"PurgeNotes", testPurgeNotes},
+		{"DeletedNotebooksHidden", testDeletedNotebooksHidden},
+		{"TrashedNotebookTitleTaken", testTrashedNotebookTitleTaken},
+		{"RestoreNotebooks", testRestoreNotebooks},
+		{"RestoreNoteOfTrashedNotebook", testRestoreNoteOfTrashedNotebook},
+		{"PurgeNotebooks", testPurgeNotebooks},
+	})
+}
+
+func listTrashedNotes(t *testing.T, repo repository.NoteRepository) []*model.Note {
+	t.Helper()
+	page, err := repo.ListNotes(repository.NoteFilter{Trashed: true}, repository.NoteQuery{})
+	if err != nil {
+		t.Fatalf("Could not list notes in the trash, error msg: %v", err)
+	}
+	return page.Notes
+}
+
+func getTrashedNotebooks(t *testing.T, repo repository.NotebookRepository) []*model.Notebook {
+	t.Helper()
+	notebooks, err := repo.GetTrashedNotebooks()
+	if err != nil {
+		t.Fatalf("Could not retrieve notebooks in the trash, error msg: %v", err)
+	}
+	return notebooks
+}
+
+func testDeletedNotesHidden(t *testing.T, repos *Repositories) {
+	deletedID := saveNote(t, repos.Notes, newNote("deleted groceries", "memo", 0, []string{"tag"}, 1))
+	keptID := saveNote(t, repos.Notes, newNote("kept groceries", "memo", 0, []string{"tag"}, 2))
+	if err := repos.Notes.DeleteNote(deletedID); err != nil {
+		t.Fatalf("Could not delete note, error msg: %v", err)
+	}
+
+	notes, _ := repos.Notes.GetNotes([]int64{})
+	checkNoteIDs(t, notes, keptID)
+	if _, err := repos.Notes.GetNote(deletedID); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound for a note in the trash, got: %v", err)
+	}
+	tagged, _ := repos.Notes.GetNotesByTag([]string{"tag"})
+	checkNoteIDs(t, tagged, keptID)
+	checkHitIDs(t, searchNotes(t, repos.Notes, "groceries", repository.NoteQuery{}), keptID)
+	fuzzy := searchFilter(t, repos.Notes, "grocerie", repository.NoteFilter{Fuzzy: true}, repository.NoteQuery{})
+	checkHitIDs(t, fuzzy, keptID)
+}
+
+func testListTrashedNotes(t *testing.T, repos *Repositories) {
+	id1 := saveNote(t, repos.Notes, newNote("first groceries", "memo", 0, []string{"tag"}, 1))
+	id2 := saveNote(t, repos.Notes, newNote("second", "memo", 0, []string{}, 2))
+	saveNote(t, repos.Notes, newNote("kept groceries", "memo", 0, []string{"tag"}, 3))
+	if err := repos.Notes.DeleteNotes([]int64{id1, id2}); err != nil {
+		t.Fatalf("Could not delete notes, error msg: %v", err)
+	}
+
+	trashed := listTrashedNotes(t, repos.Notes)
+	checkNoteIDs(t, trashed, id2, id1)
+	for _, note := range trashed {
+		if note.DeletedAt == nil {
+			t.Errorf("Expected note %v in the trash to have a deletion time", note.ID)
+		}
+	}
+	page := searchFilter(t, repos.Notes, "groceries", repository.NoteFilter{Trashed: true}, repository.NoteQuery{})
+	checkHitIDs(t, page, id1)
+}
+
+func testRestoreNotes(t *testing.T, repos *Repositories) {
+	id := saveNote(t, repos.Notes, newNote("title", "memo", 0, []string{"tag"}, 1))
+	if err := repos.Notes.DeleteNote(id); err != nil {
+		t.Fatalf("Could not delete note, error msg: %v", err)
+	}
+	if err := repos.Notes.RestoreNotes([]int64{id}); err != nil {
+		t.Fatalf("Could not restore note, error msg: %v", err)
+	}
+
+	note, err := repos.Notes.GetNote(id)
+	if err != nil {
+		t.Fatalf("Restored note should be retrieved, error msg: %v", err)
+	}
+	if note.DeletedAt != nil || !note.Tags["tag"] {
+		t.Errorf("Unexpected restored note: %+v", note)
+	}
+	checkNoteIDs(t, listTrashedNotes(t, repos.Notes))
+}
+
+func testRestoreNotesNotInTrash(t *testing.T, repos *Repositories) {
+	keptID := saveNote(t, repos.Notes, newNote("kept", "memo", 0, []string{}, 1))
+	deletedID := saveNote(t, repos.Notes, newNote("deleted", "memo", 0, []string{}, 2))
+	if err := repos.Notes.DeleteNote(deletedID); err != nil {
+		t.Fatalf("Could not delete note, error msg: %v", err)
+	}
+
+	err := repos.Notes.RestoreNotes([]int64{deletedID, keptID, 42})
+	if !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got: %v", err)
+	}
+	checkNoteIDs(t, listTrashedNotes(t, repos.Notes), deletedID)
+}
+
+func testPurgeNotes(t *testing.T, repos *Repositories) {
+	deletedID := saveNote(t, repos.Notes, newNote("deleted", "memo", 0, []string{"tag"}, 1))
+	keptID := saveNote(t, repos.Notes, newNote("kept", "memo", 0, []string{"tag"}, 2))
+	if err := repos.Notes.DeleteNote(deletedID); err != nil {
+		t.Fatalf("Could not delete note, error msg: %v", err)
+	}
+
+	purged, err := repos.Notes.PurgeNotes(time.Now().Add(-time.Hour))
+	if err != nil || purged != 0 {
+		t.Fatalf("Notes deleted after the purge time should be kept, purged: %v, error msg: %v", purged, err)
+	}
+	checkNoteIDs(t, listTrashedNotes(t, repos.Notes), deletedID)
+
+	purged, err = repos.Notes.PurgeNotes(time.Now().Add(time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("Expected a single purged note, purged: %v, error msg: %v", purged, err)
+	}
+	checkNoteIDs(t, listTrashedNotes(t, repos.Notes))
+	if err := repos.Notes.RestoreNotes([]int64{deletedID}); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Purged note should not be restored, got: %v", err)
+	}
+	notes, _ := repos.Notes.GetNotes([]int64{})
+	checkNoteIDs(t, notes, keptID)
+}
+
+func testDeletedNotebooksHidden(t *testing.T, repos *Repositories) {
+	deletedID := saveNotebook(t, repos.Notebooks, "lists")
+	noteID := saveNote(t, repos.Notes, newNote("title", "memo", deletedID, []string{}, 1))
+	if err := repos.Notebooks.DeleteNotebook(deletedID); err != nil {
+		t.Fatalf("Could not delete notebook, error msg: %v", err)
+	}
+
+	if _, err := repos.Notebooks.GetNotebook(deletedID); !errors.Is(err, repository.ErrNotebookNotFound) {
+		t.Errorf("Expected ErrNotebookNotFound for a notebook in the trash, got: %v", err)
+	}
+	if notebook, err := repos.Notebooks.GetNotebookByTitle("lists"); err != nil || notebook != nil {
+		t.Errorf("Notebook in the trash should not be retrieved by title, got: %v, error msg: %v", notebook, err)
+	}
+	titles, _ := repos.Notebooks.GetAllNotebooksTitle()
+	if _, ok := titles[deletedID]; ok || len(titles) != 1 {
+		t.Errorf("Unexpected notebook titles: %v", titles)
+	}
+
+	trashed := getTrashedNotebooks(t, repos.Notebooks)
+	if len(trashed) != 1 || trashed[0].ID != deletedID || trashed[0].DeletedAt == nil {
+		t.Fatalf("Expected notebook %v in the trash, got: %v", deletedID, trashed)
+	}
+	if _, ok := trashed[0].Notes[noteID]; !ok || len(trashed[0].Notes) != 1 {
+		t.Errorf("Expected notebook in the trash to contain note %v, got: %v", noteID, trashed[0].Notes)
+	}
+	checkNoteIDs(t, listTrashedNotes(t, repos.Notes), noteID)
+}
+
+func testTrashedNotebookTitleTaken(t *testing.T, repos *Repositories) {
+	id := saveNotebook(t, repos.Notebooks, "lists")
+	if err := repos.Notebooks.DeleteNotebook(id); err != nil {
+		t.Fatalf("Could not delete notebook, error msg: %v", err)
+	}
+	if _, err := repos.Notebooks.SaveNotebook(model.NewNotebook("lists")); !errors.Is(err, repository.ErrNotebookExists) {
+		t.Errorf("Title of a notebook in the trash should stay taken, got: %v", err)
+	}
+}
+
+func testRestoreNotebooks(t *testing.T, repos *Repositories) {
+	id := saveNotebook(t, repos.Notebooks, "lists")
+	earlierID := saveNote(t, repos.Notes, newNote("deleted earlier", "memo", id, []string{}, 1))
+	noteID := saveNote(t, repos.Notes, newNote("deleted with notebook", "memo", id, []string{}, 2))
+	if err := repos.Notes.DeleteNote(earlierID); err != nil {
+		t.Fatalf("Could not delete note, error msg: %v", err)
+	}
+	if err := repos.Notebooks.DeleteNotebook(id); err != nil {
+		t.Fatalf("Could not delete notebook, error msg: %v", err)
+	}
+	if err := repos.Notebooks.RestoreNotebooks([]int64{id}); err != nil {
+		t.Fatalf("Could not restore notebook, error msg: %v", err)
+	}
+
+	notebook, err := repos.Notebooks.GetNotebook(id)
+	if err != nil {
+		t.Fatalf("Restored notebook should be retrieved, error msg: %v", err)
+	}
+	if _, ok := notebook.Notes[noteID]; !ok || len(notebook.Notes) != 1 {
+		t.Errorf("Expected only the notes deleted with the notebook to be restored, got: %v", notebook.Notes)
+	}
+	checkNoteIDs(t, listTrashedNotes(t, repos.Notes), earlierID)
+	if len(getTrashedNotebooks(t, repos.Notebooks)) != 0 {
+		t.Error("Restored notebook should not be in the trash")
+	}
+	if err := repos.Notebooks.RestoreNotebooks([]int64{id}); !errors.Is(err, repository.ErrNotebookNotFound) {
+		t.Errorf("Expected ErrNotebookNotFound for a notebook not in the trash, got: %v", err)
+	}
+}
+
+func testRestoreNoteOfTrashedNotebook(t *testing.T, repos *Repositories) {
+	id := saveNotebook(t, repos.Notebooks, "lists")
+	noteID := saveNote(t, repos.Notes, newNote("title", "memo", id, []string{}, 1))
+	if err := repos.Notebooks.DeleteNotebook(id); err != nil {
+		t.Fatalf("Could not delete notebook, error msg: %v", err)
+	}
+	if err := repos.Notes.RestoreNotes([]int64{noteID}); err != nil {
+		t.Fatalf("Could not restore note, error msg: %v", err)
+	}
+
+	notebook, err := repos.Notebooks.GetNotebook(id)
+	if err != nil {
+		t.Fatalf("Notebook of a restored note should be restored, error msg: %v", err)
+	}
+	if _, ok := notebook.Notes[noteID]; !ok {
+		t.Errorf("Expected notebook to contain the restored note, got: %v", notebook.Notes)
+	}
+}
+
+func testPurgeNotebooks(t *testing.T, repos *Repositories) {
+	id := saveNotebook(t, repos.Notebooks, "lists")
+	noteID := saveNote(t, repos.Notes, newNote("title", "memo", id, []string{"tag"}, 1))
+	if err := repos.Notebooks.DeleteNotebook(id); err != nil {
+		t.Fatalf("Could not delete notebook, error msg: %v", err)
+	}
+
+	purged, err := repos.Notebooks.PurgeNotebooks(time.Now().Add(-time.Hour))
+	if err != nil || purged != 0 {
+		t.Fatalf("Notebooks deleted after the purge time should be kept, purged: %v, error msg: %v", purged, err)
+	}
+	purged, err = repos.Notebooks.PurgeNotebooks(time.Now().Add(time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("Expected a single purged notebook, purged: %v, error msg: %v", purged, err)
+	}
+	if len(getTrashedNotebooks(t, repos.Notebooks)) != 0 {
+		t.Error("Purged notebook should not be in the trash")
+	}
+	checkNoteIDs(t, listTrashedNotes(t, repos.Notes))
+	if _, err := repos.Notes.GetRevisions(noteID); !errors.Is(err, repository.ErrNoteNotFound) {
+		t.Errorf("Expected revisions of the purged notes to be deleted, got: %v", err)
+	}
+	//the title is free again
+	saveNotebook(t, repos.Notebooks, "lists")
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicolasmanic/tefter/model"
+	"time"
+)
+
+//insertFirstRevision saves the title and memo of a new note as its first revision.
+func insertFirstRevision(tx *sqlx.Tx, noteID int64, note *model.Note) error {
+	_, err := tx.Exec(tx.Rebind(`INSERT INTO note_revision (note_id, revision, title, memo, created) VALUES (?, 1, ?, ?, ?)`),
+		noteID, note.Title, note.Memo, note.LastUpdated)
+	return err
+}
+
+//insertRevision saves the title and memo of note as its next revision, unless they are the same as the stored
+//ones. It must run in the transaction of the update, before the note is updated. Notes that don't exist get no revision.
+func insertRevision(tx *sqlx.Tx, note *model.Note) error {
+	stored := model.Note{}
+	err := tx.Get(&stored, tx.Rebind(`SELECT title, memo FROM note WHERE id = ?`), note.ID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if stored.Title == note.Title && stored.Memo == note.Memo {
+		return nil
+	}
+	_, err = tx.Exec(tx.Rebind(`INSERT INTO note_revision (note_id, revision, title, memo, created)
+		VALUES (?, (SELECT COALESCE(MAX(revision), 0) + 1 FROM note_revision WHERE note_id = ?), ?, ?, ?)`),
+		note.ID, note.ID, note.Title, note.Memo, note.LastUpdated)
+	return err
+}
+
+//selectRevisions returns the revisions of the note oldest first. Every note has at least one revision,
+//if there is none the note doesn't exist.
+func selectRevisions(handle dbHandle, noteID int64) ([]*model.Revision, error) {
+	revisions := []*model.Revision{}
+	err := handle.Select(&revisions, handle.Rebind(`SELECT note_id, revision, title, memo, created
+		FROM note_revision WHERE note_id = ? ORDER BY revision`), noteID)
+	if err != nil {
+		return nil, fmt.Errorf("Could not retrieve revisions, error msg: %v", err)
+	}
+	if len(revisions) == 0 {
+		return nil, newError(ErrNoteNotFound, "Could find note with id: %v", noteID)
+	}
+	return revisions, nil
+}
+
+//selectRevision returns a single revision of the note.
+func selectRevision(handle dbHandle, noteID int64, number int) (*model.Revision, error) {
+	revision := &model.Revision{}
+	err := handle.Get(revision, handle.Rebind(`SELECT note_id, revision, title, memo, created
+		FROM note_revision WHERE note_id = ? AND revision = ?`), noteID, number)
+	if err == sql.ErrNoRows {
+		if _, err := selectRevisions(handle, noteID); err != nil {
+			return nil, err
+		}
+		return nil, newError(ErrRevisionNotFound, "Could not find revision %v of note with id: %v", number, noteID)
+	} else if err != nil {
+		return nil, fmt.Errorf("Could not retrieve revision, error msg: %v", err)
+	}
+	return revision, nil
+}
+
+//restoreRevision implements RestoreRevision with the GetNote, GetRevision and UpdateNote of noteRepo.
+func restoreRevision(noteRepo NoteRepository, noteID int64, number int) (*model.Note, error) {
+	note, err := noteRepo.GetNote(noteID)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := noteRepo.GetRevision(noteID, number)
+	if err != nil {
+		return nil, err
+	}
+	note.Title = revision.Title
+	note.Memo = revision.Memo
+	note.LastUpdated = time.Now().UTC()
+	if err := noteRepo.UpdateNote(note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
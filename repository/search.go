@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"github.com/nicolasmanic/tefter/model"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+//HighlightStart and HighlightEnd wrap the matched terms in the Title and Snippet of search hits.
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+//snippetEllipsis marks text of the memo left out of a snippet
+const snippetEllipsis = "…"
+
+//snippetTokens is the max number of tokens of a snippet
+const snippetTokens = 16
+
+//Column weights of the ranking, a match in the title counts as titleWeight matches in the memo.
+const (
+	titleWeight = 10.0
+	memoWeight  = 1.0
+)
+
+//SearchHit is a note returned by a search. Rank is the relevance of the note, higher is more relevant, it is
+//comparable only to ranks of the same search. Title is the title of the note and Snippet the part of the memo
+//around the matches, matched terms are wrapped with HighlightStart and HighlightEnd in both.
+type SearchHit struct {
+	Note    *model.Note
+	Rank    float64
+	Title   string
+	Snippet string
+}
+
+//SearchPage is a page of search hits, see NotePage.
+type SearchPage struct {
+	Hits []*SearchHit
+	Next string
+}
+
+//searchRow is a row of a search query, it holds the note and what the backend computed for the hit.
+type searchRow struct {
+	model.Note
+	Relevance        float64 `db:"relevance"`
+	HighlightedTitle string  `db:"highlighted_title"`
+	Snippet          string  `db:"snippet"`
+	MatchInfo        []byte  `db:"match_info"`
+}
+
+//termFrequency is the number of matches of a search term in the title and in the memo of a note
+type termFrequency struct {
+	title int
+	memo  int
+}
+
+//rankStats holds the statistics of all notes that bm25 needs
+type rankStats struct {
+	notes     int
+	avgLength float64
+	//docFreq is the number of notes matching each search term
+	docFreq []int
+}
+
+//searchTerms returns the text terms of expr that notes are ranked by, terms of negated expressions are skipped.
+func searchTerms(expr QueryExpr) []*textExpr {
+	switch e := expr.(type) {
+	case *textExpr:
+		return []*textExpr{e}
+	case *andExpr:
+		return joinTerms(e.exprs)
+	case *orExpr:
+		return joinTerms(e.exprs)
+	}
+	return nil
+}
+
+func joinTerms(exprs []QueryExpr) []*textExpr {
+	terms := []*textExpr{}
+	for _, expr := range exprs {
+		terms = append(terms, searchTerms(expr)...)
+	}
+	return terms
+}
+
+//keywordQuery returns the query matching notes containing every word of keyword, words ending with *
+//match as prefix. It returns nil if keyword contains no words.
+func keywordQuery(keyword string) QueryExpr {
+	exprs := []QueryExpr{}
+	for _, word := range strings.Fields(keyword) {
+		tokens := tokenize(word)
+		if len(tokens) == 0 {
+			continue
+		}
+		exprs = append(exprs, &textExpr{tokens: tokens, prefix: strings.HasSuffix(word, "*")})
+	}
+	switch len(exprs) {
+	case 0:
+		return nil
+	case 1:
+		return exprs[0]
+	}
+	return &andExpr{exprs}
+}
+
+//searchNotesByKeyword implements SearchNotesByKeyword with the SearchNotes of noteRepo.
+func searchNotesByKeyword(noteRepo NoteRepository, keyword string) ([]*model.Note, error) {
+	if keyword == "" {
+		return nil, newError(ErrValidation, "Empty search parameter")
+	}
+	expr := keywordQuery(keyword)
+	if expr == nil {
+		return []*model.Note{}, nil
+	}
+	page, err := noteRepo.SearchNotes(NoteFilter{Query: expr}, NoteQuery{})
+	if err != nil {
+		return nil, err
+	}
+	notes := make([]*model.Note, 0, len(page.Hits))
+	for _, hit := range page.Hits {
+		notes = append(notes, hit.Note)
+	}
+	return notes, nil
+}
+
+//plainHits returns the hits of a search without text terms, nothing is highlighted.
+func plainHits(notes []*model.Note) []*SearchHit {
+	hits := make([]*SearchHit, 0, len(notes))
+	for _, note := range notes {
+		hits = append(hits, &SearchHit{Note: note, Title: note.Title, Snippet: snippet(note.Memo, nil)})
+	}
+	return hits
+}
+
+//newSearchPage returns a page of the hits retrieved with the limit of the query increased by one, see newNotePage.
+func newSearchPage(hits []*SearchHit, query NoteQuery) *SearchPage {
+	page := &SearchPage{Hits: hits}
+	if query.Limit > 0 && len(hits) > query.Limit {
+		page.Hits = hits[:query.Limit]
+		page.Next = newNoteCursor(page.Hits[query.Limit-1].Note, query)
+	}
+	return page
+}
+
+//pageHits sorts, skips and limits in memory search hits, see pageNotes. query must be normalized.
+func pageHits(hits []*SearchHit, query NoteQuery) *SearchPage {
+	if query.Sort != SortByRank {
+		byNote := make(map[*model.Note]*SearchHit, len(hits))
+		notes := make([]*model.Note, 0, len(hits))
+		for _, hit := range hits {
+			byNote[hit.Note] = hit
+			notes = append(notes, hit.Note)
+		}
+		notePage := pageNotes(notes, query)
+		page := &SearchPage{Hits: make([]*SearchHit, 0, len(notePage.Notes)), Next: notePage.Next}
+		for _, note := range notePage.Notes {
+			page.Hits = append(page.Hits, byNote[note])
+		}
+		return page
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if query.Order == Ascending {
+			a, b = b, a
+		}
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		return a.Note.ID > b.Note.ID
+	})
+	start := query.Offset
+	if start > len(hits) {
+		start = len(hits)
+	}
+	hits = hits[start:]
+	if query.Limit > 0 && len(hits) > query.Limit+1 {
+		hits = hits[:query.Limit+1]
+	}
+	return newSearchPage(hits, query)
+}
+
+//bm25 returns the relevance of a note of length tokens with freqs matches of the search terms.
+func bm25(freqs []termFrequency, length int, stats rankStats) float64 {
+	const k1, b = 1.2, 0.75
+	norm := 1.0
+	if stats.avgLength > 0 {
+		norm = 1 - b + b*float64(length)/stats.avgLength
+	}
+	score := 0.0
+	for i, freq := range freqs {
+		tf := titleWeight*float64(freq.title) + memoWeight*float64(freq.memo)
+		if tf == 0 {
+			continue
+		}
+		docFreq := float64(stats.docFreq[i])
+		idf := math.Log(1 + (float64(stats.notes)-docFreq+0.5)/(docFreq+0.5))
+		score += idf * tf * (k1 + 1) / (tf + k1*norm)
+	}
+	return score
+}
+
+//textSpan is a token of a text and its position in bytes
+type textSpan struct {
+	token string
+	start int
+	end   int
+}
+
+//tokenSpans splits text to tokens the same way tokenize does, keeping the position of every token.
+func tokenSpans(text string) []textSpan {
+	spans := []textSpan{}
+	start := -1
+	for i, r := range text {
+		isToken := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isToken && start < 0 {
+			start = i
+		} else if !isToken && start >= 0 {
+			spans = append(spans, textSpan{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, textSpan{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return spans
+}
+
+func spanTokens(spans []textSpan) []string {
+	tokens := make([]string, 0, len(spans))
+	for _, span := range spans {
+		tokens = append(tokens, span.token)
+	}
+	return tokens
+}
+
+//matchedSpans flags the spans that are part of a match of any of terms.
+func matchedSpans(spans []textSpan, terms []*textExpr) []bool {
+	matched := make([]bool, len(spans))
+	tokens := spanTokens(spans)
+	for _, term := range terms {
+		for start := range tokens {
+			if term.matchesAt(tokens, start) {
+				for i := range term.tokens {
+					matched[start+i] = true
+				}
+			}
+		}
+	}
+	return matched
+}
+
+//highlightSpans returns text[start:end] with the runs of matched spans between spans from and to wrapped
+//with HighlightStart and HighlightEnd.
+func highlightSpans(text string, spans []textSpan, matched []bool, from, to, start, end int) string {
+	var result strings.Builder
+	position := start
+	for i := from; i < to; i++ {
+		if !matched[i] {
+			continue
+		}
+		if i == from || !matched[i-1] {
+			result.WriteString(text[position:spans[i].start])
+			result.WriteString(HighlightStart)
+			position = spans[i].start
+		}
+		if i == to-1 || !matched[i+1] {
+			result.WriteString(text[position:spans[i].end])
+			result.WriteString(HighlightEnd)
+			position = spans[i].end
+		}
+	}
+	result.WriteString(text[position:end])
+	return result.String()
+}
+
+//highlight returns text with the matches of terms highlighted.
+func highlight(text string, terms []*textExpr) string {
+	spans := tokenSpans(text)
+	return highlightSpans(text, spans, matchedSpans(spans, terms), 0, len(spans), 0, len(text))
+}
+
+//snippet returns up to snippetTokens tokens of text starting a little before the first match of terms,
+//with the matches highlighted. It starts at the beginning of text if nothing matches.
+func snippet(text string, terms []*textExpr) string {
+	spans := tokenSpans(text)
+	if len(spans) == 0 {
+		return text
+	}
+	matched := matchedSpans(spans, terms)
+	from := 0
+	for i := range matched {
+		if matched[i] {
+			from = i - snippetTokens/4
+			break
+		}
+	}
+	to := from + snippetTokens
+	if to > len(spans) {
+		to = len(spans)
+		from = to - snippetTokens
+	}
+	if from < 0 {
+		from = 0
+	}
+
+	start, end := spans[from].start, spans[to-1].end
+	prefix, suffix := snippetEllipsis, snippetEllipsis
+	if from == 0 {
+		start, prefix = 0, ""
+	}
+	if to == len(spans) {
+		end, suffix = len(text), ""
+	}
+	return prefix + highlightSpans(text, spans, matched, from, to, start, end) + suffix
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"log"
+)
+
+//sqlStore hands out the sqlite or postgres repositories, depending on driver, all sharing handle.
+type sqlStore struct {
+	handle dbHandle
+	driver string
+	//fts5 is set if the sqlite full text index is an fts5 table
+	fts5 bool
+	//fuzzy is the corpus of fuzzy searches shared by the note repositories of the store
+	fuzzy *fuzzyCache
+}
+
+//NewStore returns a Store backed by the sqlite DB at dbPath, pending migrations are applied while connecting.
+func NewStore(dbPath string) Store {
+	db := connect2DB(dbPath)
+	fts5, err := isSearchIndexFTS5(db)
+	if err != nil {
+		db.Close()
+		log.Fatalf("Could not connect to DB, error msg: %v", err)
+	}
+	return &sqlStore{db, databaseDriver, fts5, &fuzzyCache{}}
+}
+
+//NewPostgresStore returns a Store backed by the postgres DB described by dsn, pending migrations are applied while connecting.
+func NewPostgresStore(dsn string) Store {
+	return &sqlStore{connect2Postgres(dsn), postgresDriver, false, &fuzzyCache{}}
+}
+
+func (store *sqlStore) Notes() NoteRepository {
+	if store.driver == postgresDriver {
+		return &postgresNoteRepository{store.handle, store.fuzzy}
+	}
+	return &sqliteNoteRepository{store.handle, store.fts5, store.fuzzy}
+}
+
+func (store *sqlStore) Notebooks() NotebookRepository {
+	if store.driver == postgresDriver {
+		return &postgresNotebookRepository{store.handle}
+	}
+	return &sqliteNotebookRepository{store.handle}
+}
+
+func (store *sqlStore) Accounts() AccountRepository {
+	if store.driver == postgresDriver {
+		return &postgresAccountRepository{store.handle}
+	}
+	return &sqliteAccountRepository{store.handle}
+}
+
+func (store *sqlStore) WithTx(fn func(tx Store) error) error {
+	return transaction(store.handle, func(tx *sqlx.Tx) error {
+		return fn(&sqlStore{tx, store.driver, store.fts5, store.fuzzy})
+	})
+}
+
+//Close closes the connection shared by every repository of the store.
+func (store *sqlStore) Close() error {
+	return closeHandle(store.handle)
+}
@@ -2,91 +2,61 @@ package repository
 
 import (
 	"fmt"
-	"github.com/jmoiron/sqlx"
 	"github.com/nicolasmanic/tefter/model"
 )
 
 type sqliteAccountRepository struct {
-	dbPath string
-	*sqlx.DB
+	dbHandle
 }
 
-//NewAccountRepository returns a AccountRepository interface
+//NewAccountRepository returns a AccountRepository interface with its own connection to the DB at dbPath,
+//use NewStore to share a connection between repositories.
 func NewAccountRepository(dbPath string) AccountRepository {
-	db := connect2DB(dbPath)
-	return &sqliteAccountRepository{dbPath, db}
+	return NewStore(dbPath).Accounts()
 }
 
 func (accountRepo *sqliteAccountRepository) CreateAccount(username string, password []byte) error {
 	if username == "" || len(password) == 0 {
-		return fmt.Errorf("Username or/and password are empty")
+		return newError(ErrValidation, "Username or/and password are empty")
 	}
 
-	tx, err := accountRepo.Beginx()
+	_, err := accountRepo.Exec(`INSERT INTO account (username, password) VALUES(?,?)`, username, password)
+	if isUniqueViolation(err) {
+		return newError(ErrAccountExists, "Account for username: %v already exists", username)
+	}
 	if err != nil {
-		return err
+		return fmt.Errorf("Could not create account, error msg: %v", err)
 	}
-
-	defer func() {
-		if r := recover(); r != nil {
-			panicErr, _ := r.(error)
-			tx.Rollback()
-			err = panicErr
-		}
-	}()
-
-	tx.MustExec(`INSERT INTO account (username, password) VALUES(?,?)`, username, password)
-	checkError(err)
-
-	err = tx.Commit()
-	checkError(err)
-	return err
+	return nil
 }
 
 func (accountRepo *sqliteAccountRepository) GetAccount(username string) (*model.Account, error) {
-
-	selectNotebook := "SELECT username, password FROM account WHERE username = ?"
+	selectAccount := "SELECT username, password FROM account WHERE username = ?"
 	accounts := []*model.Account{}
-	err := accountRepo.Select(&accounts, selectNotebook, []interface{}{username}...)
-	checkError(err)
-
+	if err := accountRepo.Select(&accounts, selectAccount, username); err != nil {
+		return nil, fmt.Errorf("Could not retrieve account, error msg: %v", err)
+	}
 	if len(accounts) == 0 {
-		return nil, fmt.Errorf("No account found for username: %v", username)
+		return nil, newError(ErrAccountNotFound, "No account found for username: %v", username)
 	}
-
-	return accounts[0], err
+	return accounts[0], nil
 }
-func (accountRepo *sqliteAccountRepository) DeleteAccount(username string) error {
-	deleteAccount := "DELETE FROM account WHERE username = ?"
 
-	tx, err := accountRepo.Beginx()
-	if err != nil {
-		return err
+func (accountRepo *sqliteAccountRepository) DeleteAccount(username string) error {
+	if _, err := accountRepo.Exec("DELETE FROM account WHERE username = ?", username); err != nil {
+		return fmt.Errorf("Could not delete account, error msg: %v", err)
 	}
-
-	defer func() {
-		if r := recover(); r != nil {
-			panicErr, _ := r.(error)
-			tx.Rollback()
-			err = panicErr
-		}
-	}()
-
-	tx.MustExec(deleteAccount, []interface{}{username}...)
-	err = tx.Commit()
-	checkError(err)
-
-	return err
+	return nil
 }
 
-func (accountRepo *sqliteAccountRepository) GetUsernames() []string {
-	getUsernames := "SELECT username FROM account"
+func (accountRepo *sqliteAccountRepository) GetUsernames() ([]string, error) {
 	usernames := []string{}
-	err := accountRepo.Select(&usernames, getUsernames, []interface{}{}...)
-	checkError(err)
-	return usernames
+	if err := accountRepo.Select(&usernames, "SELECT username FROM account"); err != nil {
+		return nil, fmt.Errorf("Could not retrieve usernames, error msg: %v", err)
+	}
+	return usernames, nil
 }
 
 func (accountRepo *sqliteAccountRepository) CloseDB() error {
-	return accountRepo.Close()
+	return closeHandle(accountRepo.dbHandle)
 }
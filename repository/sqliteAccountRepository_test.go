@@ -1,16 +1,15 @@
 package repository
 
 import (
-	"os"
 	"testing"
 )
 
 func TestCreateAccount(t *testing.T) {
-	testRepo := NewAccountRepository("test.db")
+	testRepo := newTestAccountRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	err := testRepo.CreateAccount("nick", []byte("pass123"))
@@ -20,11 +19,11 @@ func TestCreateAccount(t *testing.T) {
 }
 
 func TestCreateAccountShouldFail(t *testing.T) {
-	testRepo := NewAccountRepository("test.db")
+	testRepo := newTestAccountRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	err := testRepo.CreateAccount("", []byte{})
@@ -34,11 +33,11 @@ func TestCreateAccountShouldFail(t *testing.T) {
 }
 
 func TestGetAccount(t *testing.T) {
-	testRepo := NewAccountRepository("test.db")
+	testRepo := newTestAccountRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	testRepo.CreateAccount("nick1", []byte("pass123"))
@@ -53,11 +52,11 @@ func TestGetAccount(t *testing.T) {
 }
 
 func TestDeleteAccount(t *testing.T) {
-	testRepo := NewAccountRepository("test.db")
+	testRepo := newTestAccountRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	testRepo.CreateAccount("nick1", []byte("pass123"))
@@ -75,18 +74,31 @@ func TestDeleteAccount(t *testing.T) {
 }
 
 func TestGetUsernames(t *testing.T) {
-	testRepo := NewAccountRepository("test.db")
+	testRepo := newTestAccountRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	testRepo.CreateAccount("nick1", []byte("pass123"))
 	testRepo.CreateAccount("nick2", []byte("pass1234"))
-	users := testRepo.GetUsernames()
+	users, err := testRepo.GetUsernames()
 
-	if len(users) != 2 {
+	if err != nil || len(users) != 2 {
 		t.Error("Could not correctly retrieve users from DB")
 	}
 }
+
+func TestGetUsernamesClosedDB(t *testing.T) {
+	testRepo := newTestAccountRepository()
+	defer tearDownTestDB()
+	if _, ok := testRepo.(dbHandle); !ok {
+		t.Skip("the memory DB can not fail")
+	}
+	testRepo.CloseDB()
+
+	if users, err := testRepo.GetUsernames(); err == nil {
+		t.Errorf("Expected an error for a closed DB, got users: %v", users)
+	}
+}
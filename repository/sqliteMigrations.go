@@ -0,0 +1,270 @@
+package repository
+
+//sqliteMigrations holds every schema change of the sqlite DB in ascending version order.
+//Already released migrations must never be edited, new changes should be appended as a new version.
+var sqliteMigrations = []migration{
+	{
+		//Version 1 is the schema that was created before migrations were introduced,
+		//statements are idempotent so that DBs created by older versions can be adopted.
+		version:     1,
+		description: "initial schema",
+		destructive: true,
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS account (
+				username TEXT NOT NULL,
+				password TEXT NOT NULL,
+				CONSTRAINT account_PK PRIMARY KEY(username))`,
+			`CREATE TABLE IF NOT EXISTS notebook (
+				id INTEGER NOT NULL,
+				title TEXT NOT NULL,
+				CONSTRAINT title_UN UNIQUE(title),
+				CONSTRAINT notebook_PK PRIMARY KEY(id))`,
+			//Default Notebook has id = 1
+			`INSERT OR IGNORE INTO notebook (id, title) VALUES (1, 'Default Notebook')`,
+			`CREATE TABLE IF NOT EXISTS note (
+				id INTEGER NOT NULL,
+				title TEXT NOT NULL,
+				memo TEXT NOT NULL,
+				created DATETIME NOT NULL,
+				lastUpdated DATETIME NOT NULL,
+				notebook_id INTEGER NOT NULL,
+				CONSTRAINT note_PK PRIMARY KEY(id),
+				CONSTRAINT notebook_id_FK FOREIGN KEY(notebook_id) REFERENCES notebook(id))`,
+			`CREATE TABLE IF NOT EXISTS notebook_note (
+				note_id INTEGER NOT NULL,
+				notebook_id		INTEGER NOT NULL,
+				CONSTRAINT notebook_note_PK PRIMARY KEY(note_id, notebook_id),
+				CONSTRAINT note_id_FK FOREIGN KEY(note_id) REFERENCES note(id),
+				CONSTRAINT notebook_id_FK FOREIGN KEY(notebook_id) REFERENCES notebook(id))`,
+			`CREATE TABLE IF NOT EXISTS note_tag (
+				note_id INTEGER NOT NULL,
+				tag		TEXT NOT NULL,
+				CONSTRAINT note_tag_PK PRIMARY KEY(tag, note_id),
+				CONSTRAINT note_id_FK FOREIGN KEY(note_id) REFERENCES note(id))`,
+			`CREATE VIRTUAL TABLE IF NOT EXISTS note_fts USING fts4(content='note', title, memo)`,
+			`CREATE TRIGGER IF NOT EXISTS note_bu BEFORE UPDATE ON note BEGIN
+				DELETE FROM note_fts WHERE docid = old.rowid;
+				END;`,
+			`CREATE TRIGGER IF NOT EXISTS note_bd BEFORE DELETE ON note BEGIN
+				DELETE FROM note_fts WHERE docid = old.rowid;
+				END;`,
+			`CREATE TRIGGER IF NOT EXISTS note_au AFTER UPDATE ON note BEGIN
+				INSERT INTO note_fts(docid, title, memo) VALUES(new.rowid, new.title, new.memo);
+				END;`,
+			`CREATE TRIGGER IF NOT EXISTS note_ai AFTER INSERT ON note BEGIN
+				INSERT INTO note_fts(docid, title, memo) VALUES(new.rowid, new.title, new.memo);
+				END;`,
+		},
+		down: []string{
+			`DROP TRIGGER IF EXISTS note_ai`,
+			`DROP TRIGGER IF EXISTS note_au`,
+			`DROP TRIGGER IF EXISTS note_bd`,
+			`DROP TRIGGER IF EXISTS note_bu`,
+			`DROP TABLE IF EXISTS note_fts`,
+			`DROP TABLE IF EXISTS note_tag`,
+			`DROP TABLE IF EXISTS notebook_note`,
+			`DROP TABLE IF EXISTS note`,
+			`DROP TABLE IF EXISTS notebook`,
+			`DROP TABLE IF EXISTS account`,
+		},
+	},
+	{
+		version:     2,
+		description: "index note relations",
+		up: []string{
+			`CREATE INDEX IF NOT EXISTS note_tag_note_id_IX ON note_tag(note_id)`,
+			`CREATE INDEX IF NOT EXISTS notebook_note_notebook_id_IX ON notebook_note(notebook_id)`,
+			`CREATE INDEX IF NOT EXISTS note_notebook_id_IX ON note(notebook_id)`,
+		},
+		down: []string{
+			`DROP INDEX IF EXISTS note_notebook_id_IX`,
+			`DROP INDEX IF EXISTS notebook_note_notebook_id_IX`,
+			`DROP INDEX IF EXISTS note_tag_note_id_IX`,
+		},
+	},
+	{
+		version:     3,
+		description: "note revisions",
+		destructive: true,
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS note_revision (
+				note_id INTEGER NOT NULL,
+				revision INTEGER NOT NULL,
+				title TEXT,
+				memo TEXT NOT NULL,
+				created DATETIME NOT NULL,
+				CONSTRAINT note_revision_PK PRIMARY KEY(note_id, revision),
+				CONSTRAINT note_id_FK FOREIGN KEY(note_id) REFERENCES note(id))`,
+			//the current content of existing notes is their first revision
+			`INSERT INTO note_revision (note_id, revision, title, memo, created)
+				SELECT id, 1, title, memo, lastUpdated FROM note`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS note_revision`,
+		},
+	},
+	{
+		version:     4,
+		description: "trash",
+		up: []string{
+			`ALTER TABLE note ADD COLUMN deleted_at DATETIME`,
+			`ALTER TABLE notebook ADD COLUMN deleted_at DATETIME`,
+			`CREATE INDEX IF NOT EXISTS note_deleted_at_IX ON note(deleted_at)`,
+		},
+		//rolling back restores the notes and notebooks of the trash
+		down: []string{
+			`DROP INDEX IF EXISTS note_deleted_at_IX`,
+			`ALTER TABLE notebook DROP COLUMN deleted_at`,
+			`ALTER TABLE note DROP COLUMN deleted_at`,
+		},
+	},
+	{
+		version:     5,
+		description: "nested notebooks",
+		//titles are unique among the children of a notebook, sqlite can not drop a constraint so the table is rebuilt
+		up: []string{
+			`CREATE TABLE notebook_nested (
+				id INTEGER NOT NULL,
+				title TEXT NOT NULL,
+				deleted_at DATETIME,
+				parent_id INTEGER NOT NULL DEFAULT 0,
+				CONSTRAINT parent_title_UN UNIQUE(parent_id, title),
+				CONSTRAINT notebook_PK PRIMARY KEY(id))`,
+			`INSERT INTO notebook_nested (id, title, deleted_at) SELECT id, title, deleted_at FROM notebook`,
+			`DROP TABLE notebook`,
+			`ALTER TABLE notebook_nested RENAME TO notebook`,
+		},
+		//rolling back moves every notebook to the top level, it fails if two notebooks have the same title
+		down: []string{
+			`CREATE TABLE notebook_flat (
+				id INTEGER NOT NULL,
+				title TEXT NOT NULL,
+				deleted_at DATETIME,
+				CONSTRAINT title_UN UNIQUE(title),
+				CONSTRAINT notebook_PK PRIMARY KEY(id))`,
+			`INSERT INTO notebook_flat (id, title, deleted_at) SELECT id, title, deleted_at FROM notebook`,
+			`DROP TABLE notebook`,
+			`ALTER TABLE notebook_flat RENAME TO notebook`,
+		},
+	},
+	{
+		version:     6,
+		description: "normalize tags",
+		//tags are lower case with single inner spaces since Note.AddTags normalizes them, see model.NormalizeTag
+		up: []string{
+			`INSERT OR IGNORE INTO note_tag (note_id, tag)
+				SELECT note_id, ` + sqliteNormalizedTag + ` FROM note_tag
+				WHERE tag <> ` + sqliteNormalizedTag + ` AND ` + sqliteNormalizedTag + ` <> ''`,
+			`DELETE FROM note_tag WHERE tag <> ` + sqliteNormalizedTag + ` OR tag = ''`,
+		},
+		//normalized tags can not be told apart from the tags they replaced, rolling back keeps them
+		irreversible: true,
+	},
+	{
+		version:     7,
+		description: "note links",
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS note_link (
+				note_id INTEGER NOT NULL,
+				target_id INTEGER NOT NULL DEFAULT 0,
+				target_title TEXT NOT NULL DEFAULT '',
+				CONSTRAINT note_link_PK PRIMARY KEY(note_id, target_id, target_title),
+				CONSTRAINT note_id_FK FOREIGN KEY(note_id) REFERENCES note(id))`,
+			`CREATE INDEX IF NOT EXISTS note_link_target_id_IX ON note_link(target_id)`,
+			`CREATE INDEX IF NOT EXISTS note_link_target_title_IX ON note_link(target_title)`,
+		},
+		fill: fillLinks,
+		down: []string{
+			`DROP TABLE IF EXISTS note_link`,
+		},
+	},
+	{
+		version:     8,
+		description: "attachments",
+		destructive: true,
+		//contents are stored once per sha256 hash, see model.ContentHash
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS attachment_blob (
+				hash TEXT NOT NULL,
+				content BLOB NOT NULL,
+				CONSTRAINT attachment_blob_PK PRIMARY KEY(hash))`,
+			`CREATE TABLE IF NOT EXISTS attachment (
+				id INTEGER NOT NULL,
+				note_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				hash TEXT NOT NULL,
+				size INTEGER NOT NULL,
+				created DATETIME NOT NULL,
+				CONSTRAINT attachment_PK PRIMARY KEY(id),
+				CONSTRAINT note_id_FK FOREIGN KEY(note_id) REFERENCES note(id),
+				CONSTRAINT hash_FK FOREIGN KEY(hash) REFERENCES attachment_blob(hash))`,
+			`CREATE INDEX IF NOT EXISTS attachment_note_id_IX ON attachment(note_id)`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS attachment`,
+			`DROP TABLE IF EXISTS attachment_blob`,
+		},
+	},
+	{
+		version:     9,
+		description: "fts5 full text index",
+		//the index only holds copies of the notes, rolling back rebuilds the fts4 index from the note table
+		fill: fillFTS5Index,
+		down: sqliteFTS4Index,
+	},
+	{
+		version:     10,
+		description: "change generation",
+		//the generation counts the changes of notes, tags and notebooks, fuzzy searches index the notes again
+		//only after it changed, see fuzzyCache
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS change_generation (value INTEGER NOT NULL)`,
+			`INSERT INTO change_generation (value) VALUES (0)`,
+			`CREATE TRIGGER IF NOT EXISTS note_generation_ai AFTER INSERT ON note BEGIN
+				UPDATE change_generation SET value = value + 1;
+				END;`,
+			`CREATE TRIGGER IF NOT EXISTS note_generation_au AFTER UPDATE ON note BEGIN
+				UPDATE change_generation SET value = value + 1;
+				END;`,
+			`CREATE TRIGGER IF NOT EXISTS note_generation_ad AFTER DELETE ON note BEGIN
+				UPDATE change_generation SET value = value + 1;
+				END;`,
+			`CREATE TRIGGER IF NOT EXISTS note_tag_generation_ai AFTER INSERT ON note_tag BEGIN
+				UPDATE change_generation SET value = value + 1;
+				END;`,
+			`CREATE TRIGGER IF NOT EXISTS note_tag_generation_au AFTER UPDATE ON note_tag BEGIN
+				UPDATE change_generation SET value = value + 1;
+				END;`,
+			`CREATE TRIGGER IF NOT EXISTS note_tag_generation_ad AFTER DELETE ON note_tag BEGIN
+				UPDATE change_generation SET value = value + 1;
+				END;`,
+			`CREATE TRIGGER IF NOT EXISTS notebook_generation_ai AFTER INSERT ON notebook BEGIN
+				UPDATE change_generation SET value = value + 1;
+				END;`,
+			`CREATE TRIGGER IF NOT EXISTS notebook_generation_au AFTER UPDATE ON notebook BEGIN
+				UPDATE change_generation SET value = value + 1;
+				END;`,
+			`CREATE TRIGGER IF NOT EXISTS notebook_generation_ad AFTER DELETE ON notebook BEGIN
+				UPDATE change_generation SET value = value + 1;
+				END;`,
+		},
+		down: []string{
+			`DROP TRIGGER IF EXISTS notebook_generation_ad`,
+			`DROP TRIGGER IF EXISTS notebook_generation_au`,
+			`DROP TRIGGER IF EXISTS notebook_generation_ai`,
+			`DROP TRIGGER IF EXISTS note_tag_generation_ad`,
+			`DROP TRIGGER IF EXISTS note_tag_generation_au`,
+			`DROP TRIGGER IF EXISTS note_tag_generation_ai`,
+			`DROP TRIGGER IF EXISTS note_generation_ad`,
+			`DROP TRIGGER IF EXISTS note_generation_au`,
+			`DROP TRIGGER IF EXISTS note_generation_ai`,
+			`DROP TABLE IF EXISTS change_generation`,
+		},
+	},
+}
+
+//sqliteNormalizedTag is model.NormalizeTag in sql, sqlite has no regular expressions so runs of up to 8 spaces
+//are collapsed and lower only handles ascii letters.
+const sqliteNormalizedTag = `lower(trim(replace(replace(replace(
+	replace(replace(replace(tag, char(9), ' '), char(10), ' '), char(13), ' '),
+	'  ', ' '), '  ', ' '), '  ', ' ')))`
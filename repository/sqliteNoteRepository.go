@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"github.com/nicolasmanic/tefter/model"
@@ -11,14 +12,20 @@ import (
 //should never be deleted.
 const DEFAULT_NOTEBOOK_ID = 1
 
+const sqliteNoteColumns = "n.id, n.title, n.memo, n.created, n.lastUpdated, n.notebook_id, n.deleted_at"
+
 type sqliteNoteRepository struct {
-	*sqlx.DB
+	dbHandle
+	//fts5 is set if the full text index is an fts5 table, see isSearchIndexFTS5
+	fts5 bool
+	//fuzzy caches the corpus of fuzzy searches, nil builds it on every search
+	fuzzy *fuzzyCache
 }
 
-//NewNoteRepository returns a NoteRepository interface.
+//NewNoteRepository returns a NoteRepository interface with its own connection to the DB at dbPath,
+//use NewStore to share a connection between repositories.
 func NewNoteRepository(dbPath string) NoteRepository {
-	db := connect2DB(dbPath)
-	return &sqliteNoteRepository{db}
+	return NewStore(dbPath).Notes()
 }
 
 //SaveNote persist a note to DB. For a note to be valid the memo field must not be empty.
@@ -28,9 +35,9 @@ func NewNoteRepository(dbPath string) NoteRepository {
 //Created: current time
 //LastUpdated: current time
 //NotepadId: 1 (Default notepad)
-func (noteRepo *sqliteNoteRepository) SaveNote(note *model.Note) (noteID int64, err error) {
+func (noteRepo *sqliteNoteRepository) SaveNote(note *model.Note) (int64, error) {
 	if note.Memo == "" {
-		return -1, fmt.Errorf("Note should contain memo")
+		return -1, newError(ErrValidation, "Note should contain memo")
 	}
 	if note.Created.IsZero() {
 		note.Created = time.Now().UTC()
@@ -43,102 +50,76 @@ func (noteRepo *sqliteNoteRepository) SaveNote(note *model.Note) (noteID int64,
 		note.NotebookID = DEFAULT_NOTEBOOK_ID
 	}
 
-	tx, err := noteRepo.Beginx()
+	var noteID int64
+	err := transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(`INSERT INTO note (
+			title, memo, created, lastUpdated, notebook_id)
+			VALUES(?, ?, ?, ?, ?)`,
+			note.Title,
+			note.Memo,
+			note.Created,
+			note.LastUpdated,
+			note.NotebookID)
+		if err != nil {
+			return err
+		}
+		noteID, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO notebook_note (note_id, notebook_id)
+			VALUES (?, ?)`, noteID, note.NotebookID); err != nil {
+			return err
+		}
+		if err := insertFirstRevision(tx, noteID, note); err != nil {
+			return err
+		}
+		if err := insertLinks(tx, noteID, note.Memo); err != nil {
+			return err
+		}
+		return insertSqliteTags(tx, noteID, note.Tags)
+	})
 	if err != nil {
-		return -1, err
+		return -1, fmt.Errorf("Could not save note, error msg: %v", err)
 	}
-
-	defer func() {
-		if r := recover(); r != nil {
-			panicErr, _ := r.(error)
-			tx.Rollback()
-			noteID = -1
-			err = panicErr
-		}
-	}()
-
-	result := tx.MustExec(`INSERT INTO note (
-		title, memo, created, lastUpdated, notebook_id) 
-		VALUES(?, ?, ?, ?, ?)`,
-		note.Title,
-		note.Memo,
-		note.Created,
-		note.LastUpdated,
-		note.NotebookID)
-
-	noteID, err = result.LastInsertId()
-	checkError(err)
 	note.ID = noteID
-
-	tx.MustExec(`INSERT INTO notebook_note (note_id, notebook_id)
-	VALUES (?, ?)`, note.ID, note.NotebookID)
-
-	tagInsertStmt, err := tx.Preparex(`INSERT INTO note_tag (note_id, tag) VALUES(?,?)`)
-	checkError(err)
-
-	for tag := range note.Tags {
-		tagInsertStmt.MustExec(noteID, tag)
-	}
-
-	err = tx.Commit()
-	checkError(err)
-
-	return noteID, err
+	return noteID, nil
 }
 
 //GetNotes return a slice of notes based on the given slice of ids,
 //if ids slice is empty all notes are returned
-func (noteRepo *sqliteNoteRepository) GetNotes(noteIDs []int64) (notes []*model.Note, err error) {
-	noteIDs = removeDups(noteIDs)
-	selectNote := "SELECT id, title, memo, created, lastUpdated, notebook_id FROM note "
-	var whereNote string
-	args := []interface{}{}
-	if len(noteIDs) != 0 {
-		whereNote = "WHERE id IN ("
-		for _, id := range noteIDs {
-			whereNote = whereNote + "?,"
-			args = append(args, id)
-		}
-		whereNote = whereNote[:len(whereNote)-1]
-		whereNote = whereNote + ") ORDER BY created desc"
-	} else {
-		whereNote = "WHERE 1 ORDER BY created desc"
-	}
-
-	querynote := selectNote + whereNote
-	err = noteRepo.Select(&notes, querynote, args...)
-	checkError(err)
-
-	selectTagStmt, err := noteRepo.Preparex("SELECT tag FROM note_tag WHERE note_id = ?")
-	checkError(err)
-
-	for _, note := range notes {
-		tags := []string{}
-		err = selectTagStmt.Select(&tags, note.ID)
-		checkError(err)
-		note.Tags = make(map[string]bool)
-		for _, tag := range tags {
-			note.Tags[tag] = true
-		}
+func (noteRepo *sqliteNoteRepository) GetNotes(noteIDs []int64) ([]*model.Note, error) {
+	page, err := noteRepo.ListNotes(NoteFilter{IDs: removeDups(noteIDs)}, NoteQuery{})
+	if err != nil {
+		return nil, err
 	}
+	return page.Notes, nil
+}
 
-	return notes, err
+//ListNotes returns a page of the notes matching filter, see NoteQuery for paging and sorting.
+func (noteRepo *sqliteNoteRepository) ListNotes(filter NoteFilter, query NoteQuery) (*NotePage, error) {
+	if err := query.normalizeList(); err != nil {
+		return nil, err
+	}
+	return noteRepo.listNotes("SELECT "+sqliteNoteColumns+" FROM note n", nil, nil, filter, query)
 }
 
 //GetNote returns a single note based on an id, returns error if note with id doesn't exist
-func (noteRepo *sqliteNoteRepository) GetNote(noteID int64) (note *model.Note, err error) {
+func (noteRepo *sqliteNoteRepository) GetNote(noteID int64) (*model.Note, error) {
 	notes, err := noteRepo.GetNotes([]int64{noteID})
-	checkError(err)
+	if err != nil {
+		return nil, err
+	}
 	if len(notes) != 1 {
-		return nil, fmt.Errorf("Could find note with id: %v", noteID)
+		return nil, newError(ErrNoteNotFound, "Could find note with id: %v", noteID)
 	}
-	return notes[0], err
+	return notes[0], nil
 }
 
 //UpdateNote updates an existing note. For a note to be valid the memo field must not be empty.
-func (noteRepo *sqliteNoteRepository) UpdateNote(note *model.Note) (err error) {
+func (noteRepo *sqliteNoteRepository) UpdateNote(note *model.Note) error {
 	if note.Memo == "" {
-		return fmt.Errorf("Note should contain memo")
+		return newError(ErrValidation, "Note should contain memo")
 	}
 	if note.Created.IsZero() {
 		note.Created = time.Now().UTC()
@@ -147,161 +128,395 @@ func (noteRepo *sqliteNoteRepository) UpdateNote(note *model.Note) (err error) {
 		note.LastUpdated = time.Now().UTC()
 	}
 
-	tx, err := noteRepo.Beginx()
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		if r := recover(); r != nil {
-			panicErr, _ := r.(error)
-			tx.Rollback()
-			err = panicErr
-		}
-	}()
-
 	updateNoteQuery := `UPDATE note SET
-		title = ?, memo = ?, created = ?, lastUpdated = ?, notebook_id =?  
+		title = ?, memo = ?, created = ?, lastUpdated = ?, notebook_id =?
 		WHERE id = ?`
 	deleteNoteNotebook := `DELETE FROM notebook_note WHERE note_id = ?`
 	insertNoteNotebook := `INSERT INTO notebook_note (note_id, notebook_id) VALUES (?, ?)`
-
 	deleteNoteTagQuery := `DELETE FROM note_tag WHERE note_id = ?`
-	insertNoteTagStmt, err := tx.Preparex(`INSERT INTO note_tag (note_id, tag) VALUES(?,?)`)
-	checkError(err)
-
-	tx.MustExec(updateNoteQuery,
-		note.Title,
-		note.Memo,
-		note.Created,
-		note.LastUpdated,
-		note.NotebookID,
-		note.ID)
 
-	tx.MustExec(deleteNoteNotebook, note.ID)
+	err := transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		if err := insertRevision(tx, note); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(updateNoteQuery,
+			note.Title,
+			note.Memo,
+			note.Created,
+			note.LastUpdated,
+			note.NotebookID,
+			note.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(deleteNoteNotebook, note.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(insertNoteNotebook, note.ID, note.NotebookID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(deleteNoteTagQuery, note.ID); err != nil {
+			return err
+		}
+		if err := insertLinks(tx, note.ID, note.Memo); err != nil {
+			return err
+		}
+		return insertSqliteTags(tx, note.ID, note.Tags)
+	})
+	if err != nil {
+		return fmt.Errorf("Could not update note with id: %v, error msg: %v", note.ID, err)
+	}
+	return nil
+}
 
-	tx.MustExec(insertNoteNotebook,
-		note.ID,
-		note.NotebookID)
+//DeleteNotes moves the notes to the trash, see PurgeNotes for deleting them for good.
+func (noteRepo *sqliteNoteRepository) DeleteNotes(noteIDs []int64) error {
+	noteIDs = removeDups(noteIDs)
+	if len(noteIDs) == 0 {
+		return nil
+	}
+	err := transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		return trashNotes(tx, noteIDs, time.Now().UTC())
+	})
+	if err != nil {
+		return fmt.Errorf("Could not delete notes, error msg: %v", err)
+	}
+	return nil
+}
 
-	tx.MustExec(deleteNoteTagQuery, note.ID)
+func (noteRepo *sqliteNoteRepository) DeleteNote(noteID int64) error {
+	return noteRepo.DeleteNotes([]int64{noteID})
+}
 
-	for tag := range note.Tags {
-		insertNoteTagStmt.MustExec(note.ID, tag)
+//RestoreNotes takes the notes out of the trash, returns error if any of the notes is not in the trash.
+func (noteRepo *sqliteNoteRepository) RestoreNotes(noteIDs []int64) error {
+	noteIDs = removeDups(noteIDs)
+	if len(noteIDs) == 0 {
+		return nil
+	}
+	err := transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		return restoreNotes(tx, noteIDs)
+	})
+	if err != nil && !errors.Is(err, ErrNoteNotFound) {
+		return fmt.Errorf("Could not restore notes, error msg: %v", err)
 	}
-
-	err = tx.Commit()
-	checkError(err)
 	return err
 }
 
-func (noteRepo *sqliteNoteRepository) DeleteNotes(noteIDs []int64) (err error) {
-	noteIDs = removeDups(noteIDs)
-	whereIDIn := " WHERE id IN ("
-	whereNoteIDIn := " WHERE note_id IN ("
-	args := []interface{}{}
-	for _, id := range noteIDs {
-		args = append(args, id)
-		whereIDIn += "?,"
-		whereNoteIDIn += "?,"
+//PurgeNotes deletes for good the notes moved to the trash before deletedBefore, along with their tags, revisions
+//and attachments.
+func (noteRepo *sqliteNoteRepository) PurgeNotes(deletedBefore time.Time) (int, error) {
+	purged, err := purgeNotes(noteRepo.dbHandle, deletedBefore, deleteSqliteNotes)
+	if err != nil {
+		return 0, fmt.Errorf("Could not purge notes, error msg: %v", err)
 	}
+	return purged, nil
+}
 
-	whereIDIn = whereIDIn[:len(whereIDIn)-1]
-	whereIDIn = whereIDIn + ")"
-	whereNoteIDIn = whereNoteIDIn[:len(whereNoteIDIn)-1]
-	whereNoteIDIn = whereNoteIDIn + ")"
+//SearchNotesByKeyword searches the DB for notes containing every word of keyword. Keyword cannot be empty,
+//words must be complete unless they end with *. Notes are sorted by relevance.
+func (noteRepo *sqliteNoteRepository) SearchNotesByKeyword(keyword string) ([]*model.Note, error) {
+	return searchNotesByKeyword(noteRepo, keyword)
+}
+
+//SearchNotes returns a page of the notes matching filter ranked by the text terms of filter.Query,
+//see NoteRepository. Notes are ranked by the fts5 bm25 if available, otherwise by the matchinfo of fts4.
+func (noteRepo *sqliteNoteRepository) SearchNotes(filter NoteFilter, query NoteQuery) (*SearchPage, error) {
+	terms := searchTerms(filter.Query)
+	if err := query.normalizeSearch(len(terms) > 0); err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		page, err := noteRepo.listNotes("SELECT "+sqliteNoteColumns+" FROM note n", nil, nil, filter, query)
+		if err != nil {
+			return nil, err
+		}
+		return &SearchPage{Hits: plainHits(page.Notes), Next: page.Next}, nil
+	}
+	if filter.Fuzzy {
+		return searchFuzzySQL(noteRepo, noteRepo.dbHandle, noteRepo.fuzzy, filter, query)
+	}
+	if noteRepo.fts5 {
+		return noteRepo.searchFTS5(terms, filter, query)
+	}
+	return noteRepo.searchFTS4(terms, filter, query)
+}
 
-	tx, err := noteRepo.Beginx()
+//searchFTS5 ranks, highlights and pages the notes in the DB.
+func (noteRepo *sqliteNoteRepository) searchFTS5(terms []*textExpr, filter NoteFilter, query NoteQuery) (*SearchPage, error) {
+	selectFrom := fmt.Sprintf(`SELECT %s, -bm25(note_fts, %v, %v) AS relevance,
+		highlight(note_fts, 0, '%s', '%s') AS highlighted_title,
+		snippet(note_fts, 1, '%s', '%s', '%s', %d) AS snippet
+		FROM note n INNER JOIN note_fts ON note_fts.rowid = n.id`,
+		sqliteNoteColumns, titleWeight, memoWeight, HighlightStart, HighlightEnd,
+		HighlightStart, HighlightEnd, snippetEllipsis, snippetTokens)
+	rows, err := noteRepo.selectSearchRows(selectFrom, terms, filter, query)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	hits := make([]*SearchHit, 0, len(rows))
+	for _, row := range rows {
+		hits = append(hits, &SearchHit{Note: &row.Note, Rank: row.Relevance, Title: row.HighlightedTitle, Snippet: row.Snippet})
 	}
+	return newSearchPage(hits, query), nil
+}
 
-	defer func() {
-		if r := recover(); r != nil {
-			panicErr, _ := r.(error)
-			tx.Rollback()
-			err = panicErr
+//searchFTS4 retrieves every note matching the search with the matchinfo of fts4, notes are ranked,
+//highlighted and paged in memory.
+func (noteRepo *sqliteNoteRepository) searchFTS4(terms []*textExpr, filter NoteFilter, query NoteQuery) (*SearchPage, error) {
+	selectFrom := "SELECT " + sqliteNoteColumns + ", matchinfo(note_fts, 'pcnalx') AS match_info " +
+		"FROM note n INNER JOIN note_fts ON note_fts.docid = n.id"
+	all := NoteQuery{}
+	if err := all.normalize(); err != nil {
+		return nil, err
+	}
+	rows, err := noteRepo.selectSearchRows(selectFrom, terms, filter, all)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]*SearchHit, 0, len(rows))
+	for _, row := range rows {
+		freqs, length, stats, err := matchInfoFrequencies(row.MatchInfo)
+		if err != nil {
+			return nil, err
 		}
-	}()
+		hits = append(hits, &SearchHit{
+			Note:    &row.Note,
+			Rank:    bm25(freqs, length, stats),
+			Title:   highlight(row.Title, terms),
+			Snippet: snippet(row.Memo, terms),
+		})
+	}
+	return pageHits(hits, query), nil
+}
 
-	deleteNoteQuery := "DELETE FROM note " + whereIDIn
-	deleteTagQuery := "DELETE FROM note_tag " + whereNoteIDIn
-	deleteNoteNotebookQuery := "DELETE FROM notebook_note " + whereNoteIDIn
+//selectSearchRows runs a search query matching any of terms in note_fts, and loads the tags of every returned note.
+func (noteRepo *sqliteNoteRepository) selectSearchRows(selectFrom string, terms []*textExpr, filter NoteFilter,
+	query NoteQuery) ([]*searchRow, error) {
+	sqlQuery, args, err := listNotesSQL(noteRepo.dialect(), selectFrom, []string{"note_fts MATCH ?"},
+		[]interface{}{ftsMatch(terms, noteRepo.fts5)}, filter, query)
+	if err != nil {
+		return nil, err
+	}
+	rows := []*searchRow{}
+	if err := noteRepo.Select(&rows, sqlQuery, args...); err != nil {
+		return nil, fmt.Errorf("Could not search notes, error msg: %v", err)
+	}
+	notes := make([]*model.Note, 0, len(rows))
+	for _, row := range rows {
+		notes = append(notes, &row.Note)
+	}
+	if err := loadTags(noteRepo.dbHandle, notes); err != nil {
+		return nil, fmt.Errorf("Could not retrieve tags, error msg: %v", err)
+	}
+	return rows, nil
+}
 
-	tx.MustExec(deleteNoteQuery, args...)
-	tx.MustExec(deleteTagQuery, args...)
-	tx.MustExec(deleteNoteNotebookQuery, args...)
+//GetRevisions returns the revisions of a note oldest first, see NoteRepository.
+func (noteRepo *sqliteNoteRepository) GetRevisions(noteID int64) ([]*model.Revision, error) {
+	return selectRevisions(noteRepo.dbHandle, noteID)
+}
 
-	err = tx.Commit()
-	checkError(err)
-	return err
+//GetRevision returns a revision of a note, returns error if the note or the revision doesn't exist
+func (noteRepo *sqliteNoteRepository) GetRevision(noteID int64, revision int) (*model.Revision, error) {
+	return selectRevision(noteRepo.dbHandle, noteID, revision)
 }
 
-func (noteRepo *sqliteNoteRepository) DeleteNote(noteID int64) (err error) {
-	return noteRepo.DeleteNotes([]int64{noteID})
+//RestoreRevision sets the title and memo of a note to those of the revision, see NoteRepository.
+func (noteRepo *sqliteNoteRepository) RestoreRevision(noteID int64, revision int) (*model.Note, error) {
+	return restoreRevision(noteRepo, noteID, revision)
 }
 
-//SearchNotesByKeyword searches the DB for notes containing the keyword. Keyword cannot be empty
-//also keyword must be a complete word, partial words can not be matched
-func (noteRepo *sqliteNoteRepository) SearchNotesByKeyword(keyword string) (notes []*model.Note, err error) {
-	if keyword == "" {
-		return nil, fmt.Errorf("Empty search parameter")
+//GetLinks returns the notes out of the trash that the memo of the note links to, see NoteRepository.
+func (noteRepo *sqliteNoteRepository) GetLinks(noteID int64) ([]*model.Note, error) {
+	return getLinks(noteRepo, noteRepo.dbHandle, noteID)
+}
+
+//GetBacklinks returns the notes out of the trash whose memo links to the note.
+func (noteRepo *sqliteNoteRepository) GetBacklinks(noteID int64) ([]*model.Note, error) {
+	return getBacklinks(noteRepo, noteRepo.dbHandle, noteID)
+}
+
+//AddAttachment attaches a file to a note out of the trash, see NoteRepository.
+func (noteRepo *sqliteNoteRepository) AddAttachment(attachment *model.Attachment) (int64, error) {
+	return addAttachment(noteRepo.dbHandle, attachment, insertSqliteAttachment)
+}
+
+//GetAttachments returns the attachments of the note without their content, oldest first.
+func (noteRepo *sqliteNoteRepository) GetAttachments(noteID int64) ([]*model.Attachment, error) {
+	return getAttachments(noteRepo.dbHandle, noteID)
+}
+
+//GetAttachment returns an attachment with its content, returns error if it doesn't exist or its note is in the trash.
+func (noteRepo *sqliteNoteRepository) GetAttachment(attachmentID int64) (*model.Attachment, error) {
+	return getAttachment(noteRepo.dbHandle, attachmentID)
+}
+
+//GetNotesByTag returns all notes tagged with one or more of tags given as inputs or their descendants, eg: lang/go for lang
+func (noteRepo *sqliteNoteRepository) GetNotesByTag(tags []string) ([]*model.Note, error) {
+	if len(tags) == 0 {
+		return []*model.Note{}, nil
 	}
-	query := `SELECT n.id, n.title, n.memo, n.created, n.lastUpdated, n.notebook_id FROM note n 
-			  INNER JOIN note_fts nfs ON n.id = nfs.docid WHERE note_fts MATCH ? ORDER BY n.created desc`
+	page, err := noteRepo.ListNotes(NoteFilter{Tags: tags}, NoteQuery{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Notes, nil
+}
 
-	err = noteRepo.Select(&notes, query, []interface{}{keyword}...)
-	checkError(err)
-	selectTagStmt, err := noteRepo.Preparex("SELECT tag FROM note_tag WHERE note_id = ?")
-	checkError(err)
+//ListTags returns the tags of the notes out of the trash with the number of notes tagged with them, sorted by tag.
+func (noteRepo *sqliteNoteRepository) ListTags() ([]*TagCount, error) {
+	tags, err := listTags(noteRepo.dbHandle)
+	if err != nil {
+		return nil, fmt.Errorf("Could not retrieve tags, error msg: %v", err)
+	}
+	return tags, nil
+}
 
-	for _, note := range notes {
-		tags := []string{}
-		err = selectTagStmt.Select(&tags, note.ID)
-		checkError(err)
-		note.Tags = make(map[string]bool)
-		for _, tag := range tags {
-			note.Tags[tag] = true
-		}
+//RenameTag renames the tag on every note and returns the number of renamed notes, see NoteRepository.
+func (noteRepo *sqliteNoteRepository) RenameTag(oldTag, newTag string) (int, error) {
+	oldTag, newTag, err := renameTagArgs(oldTag, newTag)
+	if err != nil {
+		return 0, err
 	}
-	return notes, err
+	renamed := 0
+	err = transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		renamed, err = renameTag(tx, oldTag, newTag)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrTagNotFound) && !errors.Is(err, ErrTagExists) {
+		return 0, fmt.Errorf("Could not rename tag: %v, error msg: %v", oldTag, err)
+	}
+	return renamed, err
 }
 
-//GetNotesByTag returns all notes tagged with one or more of tags given as inputs
-func (noteRepo *sqliteNoteRepository) GetNotesByTag(tags []string) (notes []*model.Note, err error) {
-	selectNote := `SELECT id, title, memo, created, lastUpdated, notebook_id FROM note n 
-				   INNER JOIN note_tag nt ON n.id = nt.note_id `
-	whereNote := "WHERE nt.tag IN ("
-	args := []interface{}{}
+//MergeTags replaces every one of tags with into and returns the number of merged notes, see NoteRepository.
+func (noteRepo *sqliteNoteRepository) MergeTags(tags []string, into string) (int, error) {
+	tags, into, err := mergeTagsArgs(tags, into)
+	if err != nil {
+		return 0, err
+	}
+	merged := 0
+	err = transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) error {
+		merged, err = mergeTags(tx, tags, into)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrTagNotFound) {
+		return 0, fmt.Errorf("Could not merge tags: %v, error msg: %v", tags, err)
+	}
+	return merged, err
+}
 
-	for _, tag := range tags {
-		whereNote = whereNote + "?,"
-		args = append(args, tag)
+//DeleteTag removes the tag from every note and returns the number of notes it was removed from.
+func (noteRepo *sqliteNoteRepository) DeleteTag(tag string) (int, error) {
+	deleted := 0
+	err := transaction(noteRepo.dbHandle, func(tx *sqlx.Tx) (err error) {
+		deleted, err = deleteTag(tx, model.NormalizeTag(tag))
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrTagNotFound) {
+		return 0, fmt.Errorf("Could not delete tag: %v, error msg: %v", tag, err)
 	}
+	return deleted, err
+}
 
-	whereNote = whereNote[:len(whereNote)-1]
-	whereNote = whereNote + ") ORDER BY n.created desc"
+func (noteRepo *sqliteNoteRepository) CloseDB() error {
+	return closeHandle(noteRepo.dbHandle)
+}
 
-	queryNote := selectNote + whereNote
-	err = noteRepo.Select(&notes, queryNote, args...)
-	checkError(err)
+//listNotes runs the query built by listNotesSQL and returns the page of notes, query must be normalized.
+func (noteRepo *sqliteNoteRepository) listNotes(selectFrom string, conditions []string, args []interface{},
+	filter NoteFilter, query NoteQuery) (*NotePage, error) {
+	sqlQuery, args, err := listNotesSQL(noteRepo.dialect(), selectFrom, conditions, args, filter, query)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := noteRepo.selectNotes(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return newNotePage(notes, query), nil
+}
+
+//dialect returns the query dialect of the full text index.
+func (noteRepo *sqliteNoteRepository) dialect() queryDialect {
+	if noteRepo.fts5 {
+		return sqliteFTS5Dialect
+	}
+	return sqliteDialect
+}
 
-	selectTagStmt, err := noteRepo.Preparex("SELECT tag FROM note_tag WHERE note_id = ?")
-	checkError(err)
+//selectNotes runs a query returning notes and loads the tags of every returned note.
+func (noteRepo *sqliteNoteRepository) selectNotes(query string, args ...interface{}) ([]*model.Note, error) {
+	notes := []*model.Note{}
+	if err := noteRepo.Select(&notes, query, args...); err != nil {
+		return nil, fmt.Errorf("Could not retrieve notes, error msg: %v", err)
+	}
+
+	if err := loadTags(noteRepo.dbHandle, notes); err != nil {
+		return nil, fmt.Errorf("Could not retrieve tags, error msg: %v", err)
+	}
+	return notes, nil
+}
 
-	for _, note := range notes {
-		tags := []string{}
-		err = selectTagStmt.Select(&tags, note.ID)
-		checkError(err)
-		note.Tags = make(map[string]bool)
-		for _, tag := range tags {
-			note.Tags[tag] = true
+func insertSqliteTags(tx *sqlx.Tx, noteID int64, tags map[string]bool) error {
+	tagInsertStmt, err := tx.Preparex(`INSERT INTO note_tag (note_id, tag) VALUES(?,?)`)
+	if err != nil {
+		return err
+	}
+	defer tagInsertStmt.Close()
+	for tag := range tags {
+		if _, err := tagInsertStmt.Exec(noteID, tag); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	return notes, err
+//insertSqliteAttachment stores the content unless it is already stored and the attachment referring to it.
+func insertSqliteAttachment(tx *sqlx.Tx, attachment *model.Attachment) (int64, error) {
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO attachment_blob (hash, content) VALUES (?, ?)`,
+		attachment.Hash, attachment.Content); err != nil {
+		return -1, err
+	}
+	result, err := tx.Exec(`INSERT INTO attachment (note_id, name, hash, size, created) VALUES (?, ?, ?, ?, ?)`,
+		attachment.NoteID, attachment.Name, attachment.Hash, attachment.Size, attachment.Created)
+	if err != nil {
+		return -1, err
+	}
+	return result.LastInsertId()
 }
 
-func (noteRepo *sqliteNoteRepository) CloseDB() error {
-	return noteRepo.Close()
+//deleteSqliteNotes deletes the notes, their tags, revisions, links, attachments and notebook relations
+func deleteSqliteNotes(tx *sqlx.Tx, noteIDs []int64) error {
+	noteIDs = removeDups(noteIDs)
+	if len(noteIDs) == 0 {
+		return nil
+	}
+	whereIDIn := " WHERE id IN ("
+	whereNoteIDIn := " WHERE note_id IN ("
+	args := []interface{}{}
+	for _, id := range noteIDs {
+		args = append(args, id)
+		whereIDIn += "?,"
+		whereNoteIDIn += "?,"
+	}
+
+	whereIDIn = whereIDIn[:len(whereIDIn)-1]
+	whereIDIn = whereIDIn + ")"
+	whereNoteIDIn = whereNoteIDIn[:len(whereNoteIDIn)-1]
+	whereNoteIDIn = whereNoteIDIn + ")"
+
+	for _, query := range []string{
+		"DELETE FROM note " + whereIDIn,
+		"DELETE FROM note_tag " + whereNoteIDIn,
+		"DELETE FROM notebook_note " + whereNoteIDIn,
+		"DELETE FROM note_revision " + whereNoteIDIn,
+		"DELETE FROM note_link " + whereNoteIDIn,
+		"DELETE FROM attachment " + whereNoteIDIn,
+	} {
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+	return deleteOrphanBlobs(tx)
 }
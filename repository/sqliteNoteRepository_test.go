@@ -2,17 +2,16 @@ package repository
 
 import (
 	"github.com/nicolasmanic/tefter/model"
-	"os"
 	"testing"
 	"time"
 )
 
 func TestSaveNoteCompleteData(t *testing.T) {
-	testRepo := NewNoteRepository("test.db")
+	testRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNote := model.NewNote("testTitle", "test Memo", DEFAULT_NOTEBOOK_ID, []string{"testTag1", "testTag2"})
@@ -27,11 +26,11 @@ func TestSaveNoteCompleteData(t *testing.T) {
 }
 
 func TestSaveNoteMissingData(t *testing.T) {
-	testRepo := NewNoteRepository("test.db")
+	testRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNoteShouldBeSaved := model.NewNote("", "test Memo", 0, []string{})
@@ -47,11 +46,11 @@ func TestSaveNoteMissingData(t *testing.T) {
 }
 
 func TestSaveNoteWithEmptyMemoField(t *testing.T) {
-	testRepo := NewNoteRepository("test.db")
+	testRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNoteShouldNotBeSaved := model.NewNote("", "", 0, []string{})
@@ -62,11 +61,11 @@ func TestSaveNoteWithEmptyMemoField(t *testing.T) {
 }
 
 func TestGetNotes(t *testing.T) {
-	testRepo := NewNoteRepository("test.db")
+	testRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNote1 := model.NewNote("testTitle", "test Memo", DEFAULT_NOTEBOOK_ID, []string{"testTag1", "testTag2"})
@@ -94,11 +93,11 @@ func TestGetNotes(t *testing.T) {
 }
 
 func TestGetNote(t *testing.T) {
-	testRepo := NewNoteRepository("test.db")
+	testRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNote := model.NewNote("testTitle", "test Memo", 1, []string{"testTag1", "testTag2"})
@@ -114,11 +113,11 @@ func TestGetNote(t *testing.T) {
 }
 
 func TestGetNoteNotExistingID(t *testing.T) {
-	testRepo := NewNoteRepository("test.db")
+	testRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 	_, err := testRepo.GetNote(12345)
 	if err.Error() != "Could find note with id: 12345" {
@@ -127,11 +126,11 @@ func TestGetNoteNotExistingID(t *testing.T) {
 }
 
 func TestUpdateNote(t *testing.T) {
-	testRepo := NewNoteRepository("test.db")
+	testRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNote := model.NewNote("testTitle", "test Memo", 1, []string{"testTag1", "testTag2"})
@@ -150,7 +149,7 @@ func TestUpdateNote(t *testing.T) {
 	if updatedNote[0].Memo != "Updated Memo" {
 		t.Error("Could not update note with changed memo")
 	}
-	if len(updatedNote[0].Tags) != 1 || !updatedNote[0].Tags["testTag1"] {
+	if len(updatedNote[0].Tags) != 1 || !updatedNote[0].Tags["testtag1"] {
 		t.Error("Could not update note with changed tags")
 	}
 
@@ -162,11 +161,11 @@ func TestUpdateNote(t *testing.T) {
 }
 
 func TestDeleteNotes(t *testing.T) {
-	testRepo := NewNoteRepository("test.db")
+	testRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNote1 := model.NewNote("testTitle", "test Memo", 1, []string{"testTag1", "testTag2"})
@@ -194,11 +193,11 @@ func TestDeleteNotes(t *testing.T) {
 }
 
 func TestSearchNotesByKeyword(t *testing.T) {
-	testRepo := NewNoteRepository("test.db")
+	testRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNote1 := model.NewNote("title1", "test Memo 1", 1, []string{"testTag1", "testTag2"})
@@ -227,11 +226,11 @@ func TestSearchNotesByKeyword(t *testing.T) {
 }
 
 func TestSearchNotesByKeywordEmptyKeyword(t *testing.T) {
-	testRepo := NewNoteRepository("test.db")
+	testRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 	_, err := testRepo.SearchNotesByKeyword("")
 	if err.Error() != "Empty search parameter" {
@@ -240,11 +239,11 @@ func TestSearchNotesByKeywordEmptyKeyword(t *testing.T) {
 }
 
 func TestGetNoteByTag(t *testing.T) {
-	testRepo := NewNoteRepository("test.db")
+	testRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNote1 := model.NewNote("testTitle", "test Memo", 1, []string{"testTag1", "testTag2"})
@@ -267,3 +266,34 @@ func TestGetNoteByTag(t *testing.T) {
 		t.Errorf("Could not search notes by tag")
 	}
 }
+
+func TestPurgeNotesDeletesUnusedContent(t *testing.T) {
+	testRepo := newTestNoteRepository()
+	//tear down test
+	defer func() {
+		testRepo.CloseDB()
+		tearDownTestDB()
+	}()
+
+	deletedID, _ := testRepo.SaveNote(model.NewNote("deleted", "memo", 1, []string{}))
+	keptID, _ := testRepo.SaveNote(model.NewNote("kept", "memo", 1, []string{}))
+	testRepo.AddAttachment(model.NewAttachment(deletedID, "shared.txt", []byte("shared")))
+	testRepo.AddAttachment(model.NewAttachment(deletedID, "deleted.txt", []byte("deleted")))
+	keptAttachmentID, _ := testRepo.AddAttachment(model.NewAttachment(keptID, "shared.txt", []byte("shared")))
+	testRepo.DeleteNote(deletedID)
+	if _, err := testRepo.PurgeNotes(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Could not purge notes, error msg: %v", err)
+	}
+
+	if attachment, err := testRepo.GetAttachment(keptAttachmentID); err != nil || string(attachment.Content) != "shared" {
+		t.Errorf("Expected the attachment of the kept note to keep its content, got: %v, error msg: %v", attachment, err)
+	}
+	//the sql DBs store every content once, the memory DB stores contents along with their attachments
+	if handle, ok := testRepo.(dbHandle); ok {
+		hashes := []string{}
+		handle.Select(&hashes, "SELECT hash FROM attachment_blob")
+		if len(hashes) != 1 || hashes[0] != model.ContentHash([]byte("shared")) {
+			t.Errorf("Expected only the content attached to the kept note to be stored, got: %v", hashes)
+		}
+	}
+}
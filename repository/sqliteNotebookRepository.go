@@ -1,59 +1,55 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"github.com/nicolasmanic/tefter/model"
+	"time"
 )
 
 type sqliteNotebookRepository struct {
-	dbPath string
-	*sqlx.DB
+	dbHandle
 }
 
-//NewNotebookRepository returns a NotebookRepository interface
+//NewNotebookRepository returns a NotebookRepository interface with its own connection to the DB at dbPath,
+//use NewStore to share a connection between repositories.
 func NewNotebookRepository(dbPath string) NotebookRepository {
-	db := connect2DB(dbPath)
-	return &sqliteNotebookRepository{dbPath, db}
+	return NewStore(dbPath).Notebooks()
 }
 
-func (notebookRepo *sqliteNotebookRepository) SaveNotebook(notebook *model.Notebook) (notebookID int64, err error) {
-	if notebook.Title == "" {
-		return -1, fmt.Errorf("Notebook should contain title")
+//SaveNotebook saves a notebook under the notebook with id notebook.ParentID, or at the top level if it is 0.
+func (notebookRepo *sqliteNotebookRepository) SaveNotebook(notebook *model.Notebook) (int64, error) {
+	if err := validateNotebookTitle(notebook.Title); err != nil {
+		return -1, err
 	}
-
-	tx, err := notebookRepo.Beginx()
-	if err != nil {
+	if err := checkNotebookParent(notebookRepo.dbHandle, notebook); err != nil {
 		return -1, err
 	}
 
-	defer func() {
-		if r := recover(); r != nil {
-			panicErr, _ := r.(error)
-			tx.Rollback()
-			notebookID = -1
-			err = panicErr
-		}
-	}()
-
-	result := tx.MustExec(`INSERT INTO notebook (title)	VALUES(?)`, notebook.Title)
-	notebookID, err = result.LastInsertId()
-	checkError(err)
+	result, err := notebookRepo.Exec(`INSERT INTO notebook (title, parent_id) VALUES(?, ?)`, notebook.Title, notebook.ParentID)
+	if isUniqueViolation(err) {
+		return -1, notebookExistsError(notebookRepo.dbHandle, notebook)
+	}
+	if err != nil {
+		return -1, fmt.Errorf("Could not save notebook, error msg: %v", err)
+	}
+	notebookID, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("Could not save notebook, error msg: %v", err)
+	}
 	notebook.ID = notebookID
-
-	err = tx.Commit()
-	checkError(err)
-	return notebookID, err
+	return notebookID, nil
 }
 
-func (notebookRepo *sqliteNotebookRepository) GetNotebooks(notebooksIDs []int64) (notebooks []*model.Notebook, err error) {
+func (notebookRepo *sqliteNotebookRepository) GetNotebooks(notebooksIDs []int64) ([]*model.Notebook, error) {
 	notebooksIDs = removeDups(notebooksIDs)
 
-	selectNotebook := "SELECT id, title FROM notebook "
-	whereIDIn := "WHERE id IN ("
+	selectNotebook := "SELECT id, title, parent_id FROM notebook WHERE deleted_at IS NULL "
+	whereIDIn := "AND id IN ("
 	args := []interface{}{}
 	if len(notebooksIDs) == 0 {
-		whereIDIn = "WHERE 1"
+		whereIDIn = ""
 	} else {
 		for _, id := range notebooksIDs {
 			whereIDIn = whereIDIn + "?,"
@@ -63,138 +59,80 @@ func (notebookRepo *sqliteNotebookRepository) GetNotebooks(notebooksIDs []int64)
 		whereIDIn = whereIDIn + ")"
 	}
 
-	querynotebook := selectNotebook + whereIDIn
-	err = notebookRepo.Select(&notebooks, querynotebook, args...)
-	checkError(err)
-
-	//Use note repository to get the all notes of this notebook.
-	noteRepo := NewNoteRepository(notebookRepo.dbPath)
-	defer noteRepo.CloseDB()
-
-	for _, notebook := range notebooks {
-		noteIDs := notebookRepo.getNoteIDs(notebook.ID)
-		notes, err := noteRepo.GetNotes(noteIDs)
-		checkError(err)
-		notebook.Notes = make(map[int64]*model.Note)
-		for _, note := range notes {
-			notebook.Notes[note.ID] = note
-		}
+	notebooks := []*model.Notebook{}
+	if err := notebookRepo.Select(&notebooks, selectNotebook+whereIDIn, args...); err != nil {
+		return nil, fmt.Errorf("Could not retrieve notebooks, error msg: %v", err)
+	}
+	if err := notebookRepo.loadNotes(notebooks, false); err != nil {
+		return nil, err
 	}
-	return notebooks, err
+	return notebooks, nil
 }
 
 func (notebookRepo *sqliteNotebookRepository) GetNotebook(notebookID int64) (*model.Notebook, error) {
 	notebooks, err := notebookRepo.GetNotebooks([]int64{notebookID})
-	checkError(err)
-
+	if err != nil {
+		return nil, err
+	}
 	if len(notebooks) != 1 {
-		return nil, fmt.Errorf("Could find notebook with id: %v", notebookID)
+		return nil, newError(ErrNotebookNotFound, "Could find notebook with id: %v", notebookID)
 	}
-	return notebooks[0], err
+	return notebooks[0], nil
 }
 
-func (notebookRepo *sqliteNotebookRepository) GetNotebookByTitle(notebookTitle string) (notebook *model.Notebook, err error) {
-
-	query := "SELECT id, title FROM notebook WHERE title = ? "
-	var notebooks []*model.Notebook
-	err = notebookRepo.Select(&notebooks, query, []interface{}{notebookTitle}...)
-
-	if len(notebooks) > 1 {
-		return nil, fmt.Errorf(`Found more than one notebooks with the same title,
-			 this should not have happened since titles are unique in DB`)
-	}
-
-	checkError(err)
-	//Use note repository to get the all notes of this notebook.
-	noteRepo := NewNoteRepository(notebookRepo.dbPath)
-	defer noteRepo.CloseDB()
-
-	for _, notebook := range notebooks {
-		noteIDs := notebookRepo.getNoteIDs(notebook.ID)
-		notes, err := noteRepo.GetNotes(noteIDs)
-		checkError(err)
-		notebook.Notes = make(map[int64]*model.Note)
-		for _, note := range notes {
-			notebook.Notes[note.ID] = note
-		}
+//GetNotebookByTitle returns the notebook at path, eg: work/infra, or nil if no such notebook exists.
+func (notebookRepo *sqliteNotebookRepository) GetNotebookByTitle(path string) (*model.Notebook, error) {
+	notebookID, err := resolveNotebookPath(notebookRepo.dbHandle, path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not retrieve notebook with path: %v, error msg: %v", path, err)
 	}
-
-	if len(notebooks) > 0 {
-		return notebooks[0], err
+	if notebookID == 0 {
+		return nil, nil
 	}
-
-	return nil, err
+	return notebookRepo.GetNotebook(notebookID)
 }
 
-func (notebookRepo *sqliteNotebookRepository) UpdateNotebook(notebook *model.Notebook) (err error) {
-	if notebook.Title == "" {
-		return fmt.Errorf("Notebook should contain title")
+//UpdateNotebook sets the title and the parent of an existing notebook.
+func (notebookRepo *sqliteNotebookRepository) UpdateNotebook(notebook *model.Notebook) error {
+	if err := validateNotebookTitle(notebook.Title); err != nil {
+		return err
 	}
-
-	tx, err := notebookRepo.Beginx()
-	if err != nil {
+	if err := checkNotebookParent(notebookRepo.dbHandle, notebook); err != nil {
 		return err
 	}
 
-	defer func() {
-		if r := recover(); r != nil {
-			panicErr, _ := r.(error)
-			tx.Rollback()
-			err = panicErr
-		}
-	}()
-
-	updateNotebookQuery := `UPDATE notebook SET	title = ? WHERE id = ?`
-	tx.MustExec(updateNotebookQuery, notebook.Title, notebook.ID)
-	err = tx.Commit()
-	checkError(err)
-
-	return err
+	_, err := notebookRepo.Exec(`UPDATE notebook SET title = ?, parent_id = ? WHERE id = ?`, notebook.Title, notebook.ParentID, notebook.ID)
+	if isUniqueViolation(err) {
+		return notebookExistsError(notebookRepo.dbHandle, notebook)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not update notebook with id: %v, error msg: %v", notebook.ID, err)
+	}
+	return nil
 }
 
+//DeleteNotebooks moves the notebooks and all of their notes to the trash, the default notebook and notebooks
+//with children that are not deleted along with them can not be deleted.
 func (notebookRepo *sqliteNotebookRepository) DeleteNotebooks(notebooksIDs []int64) error {
 	notebooksIDs = removeDups(notebooksIDs)
 	if len(notebooksIDs) == 0 {
 		return nil
 	}
-
-	whereIDIn := "WHERE id IN ("
-
-	args := []interface{}{}
 	for _, id := range notebooksIDs {
-		whereIDIn = whereIDIn + "?,"
-		args = append(args, id)
-	}
-	whereIDIn = whereIDIn[:len(whereIDIn)-1]
-	whereIDIn = whereIDIn + ")"
-	deleteNotebook := "DELETE FROM notebook " + whereIDIn
-
-	tx, err := notebookRepo.Beginx()
-	if err != nil {
-		return err
+		if id == DEFAULT_NOTEBOOK_ID {
+			return newError(ErrDefaultNotebookProtected, "Default notebook can not be deleted")
+		}
 	}
 
-	defer func() {
-		if r := recover(); r != nil {
-			panicErr, _ := r.(error)
-			tx.Rollback()
-			err = panicErr
+	err := transaction(notebookRepo.dbHandle, func(tx *sqlx.Tx) error {
+		if err := checkNoChildren(tx, notebooksIDs); err != nil {
+			return err
 		}
-	}()
-
-	tx.MustExec(deleteNotebook, args...)
-	err = tx.Commit()
-	checkError(err)
-
-	//use note repository to delete all notes of deleted notebooks.
-	noteRepo := NewNoteRepository(notebookRepo.dbPath)
-	defer noteRepo.CloseDB()
-	for _, notebookID := range notebooksIDs {
-		noteIDs := notebookRepo.getNoteIDs(notebookID)
-		err := noteRepo.DeleteNotes(noteIDs)
-		checkError(err)
+		return trashNotebooks(tx, notebooksIDs, time.Now().UTC())
+	})
+	if err != nil && !errors.Is(err, ErrNotebookHasChildren) {
+		return fmt.Errorf("Could not delete notebooks, error msg: %v", err)
 	}
-
 	return err
 }
 
@@ -202,28 +140,80 @@ func (notebookRepo *sqliteNotebookRepository) DeleteNotebook(notebookID int64) e
 	return notebookRepo.DeleteNotebooks([]int64{notebookID})
 }
 
+//GetAllNotebooksTitle returns the path of every notebook out of the trash, eg: work/infra.
 func (notebookRepo *sqliteNotebookRepository) GetAllNotebooksTitle() (map[int64]string, error) {
-	selectNotebook := "SELECT id, title FROM notebook"
-	var notebooks = []model.Notebook{}
-	err := notebookRepo.Select(&notebooks, selectNotebook, []interface{}{}...)
-	checkError(err)
+	notebooks := []*model.Notebook{}
+	if err := notebookRepo.Select(&notebooks, "SELECT id, title, parent_id FROM notebook WHERE deleted_at IS NULL"); err != nil {
+		return nil, fmt.Errorf("Could not retrieve notebook titles, error msg: %v", err)
+	}
+	return notebookPaths(notebooks), nil
+}
 
-	var notebookNamesMap = make(map[int64]string)
-	for _, notebook := range notebooks {
-		notebookNamesMap[notebook.ID] = notebook.Title
+//GetTrashedNotebooks returns the notebooks in the trash with their notes, most recently deleted first.
+func (notebookRepo *sqliteNotebookRepository) GetTrashedNotebooks() ([]*model.Notebook, error) {
+	notebooks := []*model.Notebook{}
+	if err := notebookRepo.Select(&notebooks, `SELECT id, title, parent_id, deleted_at FROM notebook
+		WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id`); err != nil {
+		return nil, fmt.Errorf("Could not retrieve notebooks, error msg: %v", err)
+	}
+	if err := notebookRepo.loadNotes(notebooks, true); err != nil {
+		return nil, err
 	}
+	return notebooks, nil
+}
 
-	return notebookNamesMap, err
+//RestoreNotebooks takes the notebooks out of the trash, returns error if any of the notebooks is not in the trash.
+func (notebookRepo *sqliteNotebookRepository) RestoreNotebooks(notebooksIDs []int64) error {
+	notebooksIDs = removeDups(notebooksIDs)
+	if len(notebooksIDs) == 0 {
+		return nil
+	}
+	err := transaction(notebookRepo.dbHandle, func(tx *sqlx.Tx) error {
+		return restoreNotebooks(tx, notebooksIDs)
+	})
+	if err != nil && !errors.Is(err, ErrNotebookNotFound) {
+		return fmt.Errorf("Could not restore notebooks, error msg: %v", err)
+	}
+	return err
+}
+
+//PurgeNotebooks deletes for good the notebooks moved to the trash before deletedBefore and all of their notes.
+func (notebookRepo *sqliteNotebookRepository) PurgeNotebooks(deletedBefore time.Time) (int, error) {
+	purged, err := purgeNotebooks(notebookRepo.dbHandle, deletedBefore, deleteSqliteNotes)
+	if err != nil {
+		return 0, fmt.Errorf("Could not purge notebooks, error msg: %v", err)
+	}
+	return purged, nil
 }
 
 func (notebookRepo *sqliteNotebookRepository) CloseDB() error {
-	return notebookRepo.Close()
+	return closeHandle(notebookRepo.dbHandle)
 }
 
-func (notebookRepo *sqliteNotebookRepository) getNoteIDs(notebookID int64) []int64 {
-	query := "SELECT note_id FROM notebook_note WHERE notebook_id = ?"
-	noteIDs := []int64{}
-	err := notebookRepo.Select(&noteIDs, query, []interface{}{notebookID}...)
-	checkError(err)
-	return noteIDs
+//loadNotes sets the notes of every notebook, notes are retrieved with one query per maxBatchSize notebooks.
+//Only the notes in the trash are loaded if trashed is set, the notes out of it otherwise.
+func (notebookRepo *sqliteNotebookRepository) loadNotes(notebooks []*model.Notebook, trashed bool) error {
+	noteRepo := &sqliteNoteRepository{dbHandle: notebookRepo.dbHandle}
+	notebooksByID := make(map[int64]*model.Notebook, len(notebooks))
+	notebookIDs := make([]int64, 0, len(notebooks))
+	for _, notebook := range notebooks {
+		notebook.Notes = make(map[int64]*model.Note)
+		notebooksByID[notebook.ID] = notebook
+		notebookIDs = append(notebookIDs, notebook.ID)
+	}
+	for _, batch := range batchIDs(notebookIDs) {
+		query, args, err := sqlx.In("SELECT "+sqliteNoteColumns+" FROM note n WHERE n.notebook_id IN (?) AND "+
+			trashedCondition(trashed)+" ORDER BY n.created desc", batch)
+		if err != nil {
+			return fmt.Errorf("Could not retrieve notes of notebooks, error msg: %v", err)
+		}
+		notes, err := noteRepo.selectNotes(query, args...)
+		if err != nil {
+			return err
+		}
+		for _, note := range notes {
+			notebooksByID[note.NotebookID].Notes[note.ID] = note
+		}
+	}
+	return nil
 }
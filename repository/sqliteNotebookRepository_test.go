@@ -2,16 +2,15 @@ package repository
 
 import (
 	"github.com/nicolasmanic/tefter/model"
-	"os"
 	"testing"
 )
 
 func TestSaveNotebook(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
+	testRepo := newTestNotebookRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNotebook := model.NewNotebook("testTitle")
@@ -26,11 +25,11 @@ func TestSaveNotebook(t *testing.T) {
 }
 
 func TestSaveNotebookShouldFail(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
+	testRepo := newTestNotebookRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	notebookShouldNotBeSaved := model.NewNotebook("")
@@ -41,13 +40,13 @@ func TestSaveNotebookShouldFail(t *testing.T) {
 }
 
 func TestGetNotebooks(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
-	testNoteRepo := NewNoteRepository("test.db")
+	testRepo := newTestNotebookRepository()
+	testNoteRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
 		testNoteRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNotebook1 := model.NewNotebook("notebook 1")
@@ -77,11 +76,11 @@ func TestGetNotebooks(t *testing.T) {
 }
 
 func TestGetNotebooksWithEmptyInput(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
+	testRepo := newTestNotebookRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNotebook1 := model.NewNotebook("notebook 1")
@@ -99,13 +98,13 @@ func TestGetNotebooksWithEmptyInput(t *testing.T) {
 }
 
 func TestGetNotebook(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
-	testNoteRepo := NewNoteRepository("test.db")
+	testRepo := newTestNotebookRepository()
+	testNoteRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
 		testNoteRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNotebook1 := model.NewNotebook("notebook 1")
@@ -132,11 +131,11 @@ func TestGetNotebook(t *testing.T) {
 }
 
 func TestGetNotebookForNotUsedID(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
+	testRepo := newTestNotebookRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	_, err := testRepo.GetNotebook(123)
@@ -146,11 +145,11 @@ func TestGetNotebookForNotUsedID(t *testing.T) {
 }
 
 func TestGetNotebookByTitle(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
+	testRepo := newTestNotebookRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNotebook2 := model.NewNotebook("notebook 2")
@@ -169,11 +168,11 @@ func TestGetNotebookByTitle(t *testing.T) {
 }
 
 func TestGetNotebookByTitleForNotExistingTitle(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
+	testRepo := newTestNotebookRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	notebook, _ := testRepo.GetNotebookByTitle("notebook 2")
@@ -183,11 +182,11 @@ func TestGetNotebookByTitleForNotExistingTitle(t *testing.T) {
 }
 
 func TestUpdateNotebook(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
+	testRepo := newTestNotebookRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNotebook := model.NewNotebook("test Title")
@@ -206,11 +205,11 @@ func TestUpdateNotebook(t *testing.T) {
 }
 
 func TestUpdateNotebookWithEmptyTitle(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
+	testRepo := newTestNotebookRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNotebook := model.NewNotebook("")
@@ -222,13 +221,13 @@ func TestUpdateNotebookWithEmptyTitle(t *testing.T) {
 }
 
 func TestDeleteNotebooks(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
-	testNoteRepo := NewNoteRepository("test.db")
+	testRepo := newTestNotebookRepository()
+	testNoteRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
 		testNoteRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNotebook1 := model.NewNotebook("notebook 1")
@@ -257,11 +256,11 @@ func TestDeleteNotebooks(t *testing.T) {
 }
 
 func TestDeleteNotebooksEmptyID(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
+	testRepo := newTestNotebookRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	err := testRepo.DeleteNotebooks([]int64{})
@@ -272,13 +271,13 @@ func TestDeleteNotebooksEmptyID(t *testing.T) {
 }
 
 func TestDeleteNotebook(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
-	testNoteRepo := NewNoteRepository("test.db")
+	testRepo := newTestNotebookRepository()
+	testNoteRepo := newTestNoteRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
 		testNoteRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNotebook := model.NewNotebook("notebook")
@@ -304,11 +303,11 @@ func TestDeleteNotebook(t *testing.T) {
 }
 
 func TestGetAllNotebooksTitle(t *testing.T) {
-	testRepo := NewNotebookRepository("test.db")
+	testRepo := newTestNotebookRepository()
 	//tear down test
 	defer func() {
 		testRepo.CloseDB()
-		os.Remove("test.db")
+		tearDownTestDB()
 	}()
 
 	mockNotebook1 := model.NewNotebook("notebook 1")
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"unsafe"
+)
+
+//sqliteFTS5Index replaces the fts4 note_fts table of the initial schema with an fts5 table. Triggers keep their
+//names so that rolling back the initial schema drops them, the index is rebuilt from the note table.
+var sqliteFTS5Index = []string{
+	`DROP TRIGGER IF EXISTS note_ai`,
+	`DROP TRIGGER IF EXISTS note_au`,
+	`DROP TRIGGER IF EXISTS note_bd`,
+	`DROP TRIGGER IF EXISTS note_bu`,
+	`DROP TABLE IF EXISTS note_fts`,
+	`CREATE VIRTUAL TABLE note_fts USING fts5(title, memo, content='note', content_rowid='id', prefix='2 3')`,
+	`CREATE TRIGGER note_bu BEFORE UPDATE ON note BEGIN
+		INSERT INTO note_fts(note_fts, rowid, title, memo) VALUES('delete', old.id, old.title, old.memo);
+		END;`,
+	`CREATE TRIGGER note_bd BEFORE DELETE ON note BEGIN
+		INSERT INTO note_fts(note_fts, rowid, title, memo) VALUES('delete', old.id, old.title, old.memo);
+		END;`,
+	`CREATE TRIGGER note_au AFTER UPDATE ON note BEGIN
+		INSERT INTO note_fts(rowid, title, memo) VALUES(new.id, new.title, new.memo);
+		END;`,
+	`CREATE TRIGGER note_ai AFTER INSERT ON note BEGIN
+		INSERT INTO note_fts(rowid, title, memo) VALUES(new.id, new.title, new.memo);
+		END;`,
+	`INSERT INTO note_fts(note_fts) VALUES('rebuild')`,
+}
+
+//sqliteFTS4Index restores the fts4 note_fts table of the initial schema, fts4 is supported by every build.
+var sqliteFTS4Index = []string{
+	`DROP TRIGGER IF EXISTS note_ai`,
+	`DROP TRIGGER IF EXISTS note_au`,
+	`DROP TRIGGER IF EXISTS note_bd`,
+	`DROP TRIGGER IF EXISTS note_bu`,
+	`DROP TABLE IF EXISTS note_fts`,
+	`CREATE VIRTUAL TABLE note_fts USING fts4(content='note', title, memo)`,
+	`CREATE TRIGGER note_bu BEFORE UPDATE ON note BEGIN
+		DELETE FROM note_fts WHERE docid = old.rowid;
+		END;`,
+	`CREATE TRIGGER note_bd BEFORE DELETE ON note BEGIN
+		DELETE FROM note_fts WHERE docid = old.rowid;
+		END;`,
+	`CREATE TRIGGER note_au AFTER UPDATE ON note BEGIN
+		INSERT INTO note_fts(docid, title, memo) VALUES(new.rowid, new.title, new.memo);
+		END;`,
+	`CREATE TRIGGER note_ai AFTER INSERT ON note BEGIN
+		INSERT INTO note_fts(docid, title, memo) VALUES(new.rowid, new.title, new.memo);
+		END;`,
+	`INSERT INTO note_fts(note_fts) VALUES('rebuild')`,
+}
+
+//fillFTS5Index upgrades the fts4 note_fts table to fts5, see sqliteFTS5Index. Builds whose sqlite library lacks fts5,
+//see the sqlite_fts5 build tag of go-sqlite3, keep the fts4 table so that they can still open the DB.
+func fillFTS5Index(tx *sqlx.Tx) error {
+	var supported bool
+	if err := tx.Get(&supported, "SELECT sqlite_compileoption_used('ENABLE_FTS5')"); err != nil {
+		return err
+	}
+	if !supported {
+		return nil
+	}
+	for _, statement := range sqliteFTS5Index {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//isSearchIndexFTS5 returns whether note_fts is an fts5 table, see the fts5 full text index migration. An fts5 table
+//can not be opened by a build without fts5, it should be rolled back by a build with it first.
+func isSearchIndexFTS5(db *sqlx.DB) (bool, error) {
+	var definition string
+	if err := db.Get(&definition, "SELECT sql FROM sqlite_master WHERE name = 'note_fts'"); err != nil {
+		return false, fmt.Errorf("Could not find full text index, error msg: %v", err)
+	}
+	var supported bool
+	if err := db.Get(&supported, "SELECT sqlite_compileoption_used('ENABLE_FTS5')"); err != nil {
+		return false, err
+	}
+	isFTS5 := strings.Contains(strings.ToLower(definition), "fts5")
+	if isFTS5 && !supported {
+		return false, fmt.Errorf("Full text index of the DB uses fts5, tefter should be built with -tags sqlite_fts5, " +
+			"or the DB rolled back to version 8 with 'tefter db rollback' by such a build")
+	}
+	return isFTS5, nil
+}
+
+//ftsPhrase returns the fts phrase matching tokens in sequence. Tokens are quoted so that fts operators
+//can't be injected, fts5 expects the prefix marker after the closing quote.
+func ftsPhrase(tokens []string, prefix, fts5 bool) string {
+	phrase := `"` + strings.Join(tokens, " ")
+	switch {
+	case prefix && fts5:
+		return phrase + `"*`
+	case prefix:
+		return phrase + `*"`
+	}
+	return phrase + `"`
+}
+
+//ftsMatch returns the fts query matching notes containing any of terms, used to rank the notes of a search.
+func ftsMatch(terms []*textExpr, fts5 bool) string {
+	phrases := make([]string, 0, len(terms))
+	for _, term := range terms {
+		phrases = append(phrases, ftsPhrase(term.tokens, term.prefix, fts5))
+	}
+	return strings.Join(phrases, " OR ")
+}
+
+//sqliteDialect matches text terms of queries with the fts4 table.
+var sqliteDialect = queryDialect{
+	fullText: func(tokens []string, prefix bool) (string, []interface{}) {
+		return "n.id IN (SELECT docid FROM note_fts WHERE note_fts MATCH ?)", []interface{}{ftsPhrase(tokens, prefix, false)}
+	},
+	time: sqliteTime,
+}
+
+//sqliteFTS5Dialect matches text terms of queries with the fts5 table.
+var sqliteFTS5Dialect = queryDialect{
+	fullText: func(tokens []string, prefix bool) (string, []interface{}) {
+		return "n.id IN (SELECT rowid FROM note_fts WHERE note_fts MATCH ?)", []interface{}{ftsPhrase(tokens, prefix, true)}
+	},
+	time: sqliteTime,
+}
+
+//sqliteTime converts the time to a julian day number, times are stored as text with the zone they were saved in so
+//comparing them as text is wrong for times of different zones.
+func sqliteTime(value string) string {
+	return "julianday(" + value + ")"
+}
+
+//nativeEndian is the byte order of the machine.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	one := uint16(1)
+	if *(*byte)(unsafe.Pointer(&one)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+//matchInfoFrequencies decodes the fts4 matchinfo(note_fts, 'pcnalx') of a row to the frequencies of the phrases
+//in the title and memo of the note, the length of the note and the statistics of all notes.
+func matchInfoFrequencies(info []byte) ([]termFrequency, int, rankStats, error) {
+	values := make([]int, len(info)/4)
+	for i := range values {
+		//matchinfo is an array of unsigned integers in the byte order of the machine
+		values[i] = int(nativeEndian.Uint32(info[4*i:]))
+	}
+	if len(values) < 3 {
+		return nil, 0, rankStats{}, fmt.Errorf("Invalid matchinfo of %d bytes", len(info))
+	}
+	phrases, columns := values[0], values[1]
+	if len(values) != 3+2*columns+3*phrases*columns || columns != 2 {
+		return nil, 0, rankStats{}, fmt.Errorf("Invalid matchinfo of %d phrases and %d columns", phrases, columns)
+	}
+	averages, lengths, hits := values[3:3+columns], values[3+columns:3+2*columns], values[3+2*columns:]
+
+	stats := rankStats{notes: values[2], avgLength: float64(averages[0] + averages[1]), docFreq: make([]int, phrases)}
+	freqs := make([]termFrequency, phrases)
+	for i := 0; i < phrases; i++ {
+		title, memo := hits[3*(i*columns):], hits[3*(i*columns+1):]
+		freqs[i] = termFrequency{title: title[0], memo: memo[0]}
+		//notes matching the phrase in either column, fts4 only counts them per column
+		stats.docFreq[i] = title[2]
+		if memo[2] > title[2] {
+			stats.docFreq[i] = memo[2]
+		}
+	}
+	return freqs, lengths[0] + lengths[1], stats, nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/nicolasmanic/tefter/model"
+	"sort"
+	"strings"
+)
+
+//TagCount is a tag along with the number of notes out of the trash tagged with it.
+type TagCount struct {
+	Tag   string `db:"tag"`
+	Count int    `db:"count"`
+}
+
+//normalizeTags returns the normalized tags without duplicates and empty tags.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = model.NormalizeTag(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+//renameTagArgs normalizes the tags of a rename, both should be set and differ once normalized.
+func renameTagArgs(oldTag, newTag string) (string, string, error) {
+	oldTag, newTag = model.NormalizeTag(oldTag), model.NormalizeTag(newTag)
+	if oldTag == "" || newTag == "" {
+		return "", "", newError(ErrValidation, "Tags of a rename should not be empty")
+	}
+	if oldTag == newTag {
+		return "", "", newError(ErrValidation, "Tag: %v can not be renamed to itself", oldTag)
+	}
+	return oldTag, newTag, nil
+}
+
+//mergeTagsArgs normalizes the tags of a merge, tags merged into themselves are dropped.
+func mergeTagsArgs(tags []string, into string) ([]string, string, error) {
+	into = model.NormalizeTag(into)
+	if into == "" {
+		return nil, "", newError(ErrValidation, "Tag to merge into should not be empty")
+	}
+	sources := []string{}
+	for _, tag := range normalizeTags(tags) {
+		if tag != into {
+			sources = append(sources, tag)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, "", newError(ErrValidation, "At least one tag other than: %v should be merged", into)
+	}
+	return sources, into, nil
+}
+
+//subTagsCondition returns the condition of the note_tag rows with one of tags or their descendants,
+//tags should be normalized.
+func subTagsCondition(tags []string) (string, []interface{}) {
+	conditions := make([]string, 0, len(tags))
+	args := make([]interface{}, 0, 2*len(tags))
+	for _, tag := range tags {
+		conditions = append(conditions, `tag = ? OR tag LIKE ? ESCAPE '\'`)
+		args = append(args, tag, likeEscaper.Replace(tag)+model.TagSeparator+"%")
+	}
+	return strings.Join(conditions, " OR "), args
+}
+
+//missingTags returns ErrTagNotFound for the tags that are not used, or nil if every tag is used.
+func missingTags(tags []string, used map[string]bool) error {
+	missing := []string{}
+	for _, tag := range tags {
+		if !used[tag] {
+			missing = append(missing, tag)
+		}
+	}
+	if len(missing) > 0 {
+		return newError(ErrTagNotFound, "Could not find notes tagged with: %v", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+//listTags returns the tags of the notes out of the trash with their number of notes, sorted by tag.
+func listTags(handle dbHandle) ([]*TagCount, error) {
+	tags := []*TagCount{}
+	err := handle.Select(&tags, `SELECT t.tag, COUNT(*) AS count FROM note_tag t
+		JOIN note n ON n.id = t.note_id WHERE n.deleted_at IS NULL GROUP BY t.tag ORDER BY t.tag`)
+	return tags, err
+}
+
+//usedTags returns which of the tags are attached to a note, in the trash or not.
+func usedTags(handle dbHandle, tags []string) (map[string]bool, error) {
+	query, args, err := sqlx.In("SELECT DISTINCT tag FROM note_tag WHERE tag IN (?)", tags)
+	if err != nil {
+		return nil, err
+	}
+	found := []string{}
+	if err := handle.Select(&found, handle.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool, len(found))
+	for _, tag := range found {
+		used[tag] = true
+	}
+	return used, nil
+}
+
+//renameTag renames the tag on every note and returns the number of renamed notes, tags must be normalized.
+func renameTag(tx *sqlx.Tx, oldTag, newTag string) (int, error) {
+	used, err := usedTags(tx, []string{oldTag, newTag})
+	if err != nil {
+		return 0, err
+	}
+	if err := missingTags([]string{oldTag}, used); err != nil {
+		return 0, err
+	}
+	if used[newTag] {
+		return 0, newError(ErrTagExists, "Tag: %v is already in use, merge the tags instead", newTag)
+	}
+	result, err := tx.Exec(tx.Rebind("UPDATE note_tag SET tag = ? WHERE tag = ?"), newTag, oldTag)
+	if err != nil {
+		return 0, err
+	}
+	renamed, err := result.RowsAffected()
+	return int(renamed), err
+}
+
+//mergeTags replaces the tags with into on every note and returns the number of notes tagged with any of tags,
+//tags must be normalized by mergeTagsArgs.
+func mergeTags(tx *sqlx.Tx, tags []string, into string) (int, error) {
+	used, err := usedTags(tx, tags)
+	if err != nil {
+		return 0, err
+	}
+	if err := missingTags(tags, used); err != nil {
+		return 0, err
+	}
+	noteIDs, err := selectIDsIn(tx, "SELECT DISTINCT note_id FROM note_tag WHERE tag IN (?)", tags)
+	if err != nil {
+		return 0, err
+	}
+	if err := execIn(tx, `INSERT INTO note_tag (note_id, tag) SELECT DISTINCT note_id, CAST(? AS TEXT) FROM note_tag
+		WHERE tag IN (?) AND note_id NOT IN (SELECT note_id FROM note_tag WHERE tag = ?)`, into, tags, into); err != nil {
+		return 0, err
+	}
+	if err := execIn(tx, "DELETE FROM note_tag WHERE tag IN (?)", tags); err != nil {
+		return 0, err
+	}
+	return len(noteIDs), nil
+}
+
+//deleteTag removes the tag from every note and returns the number of notes it was removed from.
+func deleteTag(tx *sqlx.Tx, tag string) (int, error) {
+	result, err := tx.Exec(tx.Rebind("DELETE FROM note_tag WHERE tag = ?"), tag)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if deleted == 0 {
+		return 0, newError(ErrTagNotFound, "Could not find notes tagged with: %v", tag)
+	}
+	return int(deleted), nil
+}
+
+//sortTagCounts sorts the tags of the memory DB the way listTags does.
+func sortTagCounts(tags []*TagCount) {
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Tag < tags[j].Tag })
+}
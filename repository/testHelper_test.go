@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"os"
+)
+
+//The repository tests run against sqlite by default, the backend can be switched with environment variables:
+//TEFTER_TEST_BACKEND=memory go test ./repository
+//TEFTER_TEST_POSTGRES_DSN="postgres://postgres@localhost/tefter_test?sslmode=disable" go test ./repository
+const (
+	testBackendEnv     = "TEFTER_TEST_BACKEND"
+	postgresTestDSNEnv = "TEFTER_TEST_POSTGRES_DSN"
+)
+
+//testMemoryDB is shared by all memory repositories of a test, the same way test.db is shared.
+var testMemoryDB = newMemoryDB()
+
+func newTestNoteRepository() NoteRepository {
+	if dsn := os.Getenv(postgresTestDSNEnv); dsn != "" {
+		return NewPostgresNoteRepository(dsn)
+	}
+	if os.Getenv(testBackendEnv) == "memory" {
+		return &memoryNoteRepository{testMemoryDB}
+	}
+	return NewNoteRepository("test.db")
+}
+
+func newTestNotebookRepository() NotebookRepository {
+	if dsn := os.Getenv(postgresTestDSNEnv); dsn != "" {
+		return NewPostgresNotebookRepository(dsn)
+	}
+	if os.Getenv(testBackendEnv) == "memory" {
+		return &memoryNotebookRepository{testMemoryDB}
+	}
+	return NewNotebookRepository("test.db")
+}
+
+func newTestAccountRepository() AccountRepository {
+	if dsn := os.Getenv(postgresTestDSNEnv); dsn != "" {
+		return NewPostgresAccountRepository(dsn)
+	}
+	if os.Getenv(testBackendEnv) == "memory" {
+		return &memoryAccountRepository{testMemoryDB}
+	}
+	return NewAccountRepository("test.db")
+}
+
+//tearDownTestDB removes the sqlite test DB, resets the memory DB or rolls back every migration of the postgres test DB.
+func tearDownTestDB() {
+	testMemoryDB = newMemoryDB()
+	dsn := os.Getenv(postgresTestDSNEnv)
+	if dsn == "" {
+		os.Remove("test.db")
+		return
+	}
+	db := sqlx.MustConnect(postgresDriver, dsn)
+	defer db.Close()
+	for {
+		version, err := migrateDown(db, postgresMigrations, true)
+		if err != nil || version == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/nicolasmanic/tefter/model"
+	"time"
+)
+
+//trashedCondition returns the condition on the note n that selects the notes in the trash if trashed is set,
+//the other notes otherwise.
+func trashedCondition(trashed bool) string {
+	if trashed {
+		return "n.deleted_at IS NOT NULL"
+	}
+	return "n.deleted_at IS NULL"
+}
+
+//notebookExistsError returns the error of saving a notebook with the title of an existing sibling. Titles of notebooks
+//in the trash stay taken until the notebook is purged, so the error tells whether the notebook is in the trash.
+func notebookExistsError(handle dbHandle, notebook *model.Notebook) error {
+	trashed := []int64{}
+	err := handle.Select(&trashed, handle.Rebind(`SELECT id FROM notebook
+		WHERE parent_id = ? AND title = ? AND deleted_at IS NOT NULL`), notebook.ParentID, notebook.Title)
+	return notebookTitleError(notebook.Title, err == nil && len(trashed) > 0)
+}
+
+//notebookTitleError returns the error of saving a notebook with a taken title, trashed tells whether
+//the notebook holding the title is in the trash.
+func notebookTitleError(title string, trashed bool) error {
+	if trashed {
+		return newError(ErrNotebookExists, "Notebook with title: %v is in the trash, restore it or empty the trash", title)
+	}
+	return newError(ErrNotebookExists, "Notebook with title: %v already exists", title)
+}
+
+//execIn runs a query with ? placeholders through sqlx.In so that slices of args expand to IN lists.
+func execIn(tx *sqlx.Tx, query string, args ...interface{}) error {
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(tx.Rebind(query), args...)
+	return err
+}
+
+//selectIDsIn runs a query with ? placeholders returning ids through sqlx.In.
+func selectIDsIn(handle dbHandle, query string, args ...interface{}) ([]int64, error) {
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int64{}
+	if err := handle.Select(&ids, handle.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+//missingIDs returns the ids not found in found.
+func missingIDs(ids, found []int64) []int64 {
+	isFound := make(map[int64]bool, len(found))
+	for _, id := range found {
+		isFound[id] = true
+	}
+	missing := []int64{}
+	for _, id := range ids {
+		if !isFound[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+//trashNotes moves the notes to the trash, notes already in the trash keep their deletion time.
+func trashNotes(tx *sqlx.Tx, noteIDs []int64, deletedAt time.Time) error {
+	return execIn(tx, "UPDATE note SET deleted_at = ? WHERE id IN (?) AND deleted_at IS NULL", deletedAt, noteIDs)
+}
+
+//restoreNotes takes the notes out of the trash, along with their notebooks and the ancestors of their notebooks
+//if those are in the trash too.
+//It fails with ErrNoteNotFound if any of the notes is not in the trash.
+func restoreNotes(tx *sqlx.Tx, noteIDs []int64) error {
+	trashed, err := selectIDsIn(tx, "SELECT id FROM note WHERE id IN (?) AND deleted_at IS NOT NULL", noteIDs)
+	if err != nil {
+		return err
+	}
+	if missing := missingIDs(noteIDs, trashed); len(missing) > 0 {
+		return newError(ErrNoteNotFound, "Could not find notes with ids: %v in the trash", missing)
+	}
+	notebookIDs, err := selectIDsIn(tx, "SELECT DISTINCT notebook_id FROM note WHERE id IN (?)", noteIDs)
+	if err != nil {
+		return err
+	}
+	if err := execIn(tx, "UPDATE notebook SET deleted_at = NULL WHERE id IN (?)", notebookIDs); err != nil {
+		return err
+	}
+	if err := restoreNotebookAncestors(tx, notebookIDs); err != nil {
+		return err
+	}
+	return execIn(tx, "UPDATE note SET deleted_at = NULL WHERE id IN (?)", noteIDs)
+}
+
+//purgeNotes deletes for good the notes moved to the trash before deletedBefore with deleteNotes,
+//the delete function of the backend. It returns the number of deleted notes.
+func purgeNotes(handle dbHandle, deletedBefore time.Time, deleteNotes func(tx *sqlx.Tx, noteIDs []int64) error) (int, error) {
+	noteIDs := []int64{}
+	err := transaction(handle, func(tx *sqlx.Tx) error {
+		if err := tx.Select(&noteIDs, tx.Rebind("SELECT id FROM note WHERE deleted_at < ?"), deletedBefore.UTC()); err != nil {
+			return err
+		}
+		if len(noteIDs) == 0 {
+			return nil
+		}
+		return deleteNotes(tx, noteIDs)
+	})
+	return len(noteIDs), err
+}
+
+//trashNotebooks moves the notebooks and their notes to the trash. Notes share the deletion time of their notebook,
+//so that restoring the notebook restores them but not the notes that were in the trash before.
+func trashNotebooks(tx *sqlx.Tx, notebookIDs []int64, deletedAt time.Time) error {
+	if err := execIn(tx, "UPDATE note SET deleted_at = ? WHERE notebook_id IN (?) AND deleted_at IS NULL", deletedAt, notebookIDs); err != nil {
+		return err
+	}
+	return execIn(tx, "UPDATE notebook SET deleted_at = ? WHERE id IN (?) AND deleted_at IS NULL", deletedAt, notebookIDs)
+}
+
+//restoreNotebooks takes the notebooks out of the trash, along with the child notebooks and notes moved to the trash
+//with them and their ancestors. It fails with ErrNotebookNotFound if any of the notebooks is not in the trash.
+func restoreNotebooks(tx *sqlx.Tx, notebookIDs []int64) error {
+	trashed, err := selectIDsIn(tx, "SELECT id FROM notebook WHERE id IN (?) AND deleted_at IS NOT NULL", notebookIDs)
+	if err != nil {
+		return err
+	}
+	if missing := missingIDs(notebookIDs, trashed); len(missing) > 0 {
+		return newError(ErrNotebookNotFound, "Could not find notebooks with ids: %v in the trash", missing)
+	}
+	restored := notebookIDs
+	for children := notebookIDs; len(children) > 0; {
+		children, err = selectIDsIn(tx, `SELECT c.id FROM notebook c JOIN notebook p ON c.parent_id = p.id
+			WHERE p.id IN (?) AND c.deleted_at = p.deleted_at`, children)
+		if err != nil {
+			return err
+		}
+		restored = append(restored, children...)
+	}
+	if err := execIn(tx, `UPDATE note SET deleted_at = NULL WHERE notebook_id IN (?)
+		AND deleted_at = (SELECT b.deleted_at FROM notebook b WHERE b.id = note.notebook_id)`, restored); err != nil {
+		return err
+	}
+	if err := execIn(tx, "UPDATE notebook SET deleted_at = NULL WHERE id IN (?)", restored); err != nil {
+		return err
+	}
+	return restoreNotebookAncestors(tx, notebookIDs)
+}
+
+//purgeNotebooks deletes for good the notebooks moved to the trash before deletedBefore and all of their notes,
+//notes are deleted with deleteNotes, the delete function of the backend. It returns the number of deleted notebooks.
+func purgeNotebooks(handle dbHandle, deletedBefore time.Time, deleteNotes func(tx *sqlx.Tx, noteIDs []int64) error) (int, error) {
+	notebookIDs := []int64{}
+	err := transaction(handle, func(tx *sqlx.Tx) error {
+		if err := tx.Select(&notebookIDs, tx.Rebind("SELECT id FROM notebook WHERE deleted_at < ?"), deletedBefore.UTC()); err != nil {
+			return err
+		}
+		if len(notebookIDs) == 0 {
+			return nil
+		}
+		noteIDs, err := selectIDsIn(tx, "SELECT id FROM note WHERE notebook_id IN (?)", notebookIDs)
+		if err != nil {
+			return err
+		}
+		if len(noteIDs) > 0 {
+			if err := deleteNotes(tx, noteIDs); err != nil {
+				return err
+			}
+		}
+		return execIn(tx, "DELETE FROM notebook WHERE id IN (?)", notebookIDs)
+	})
+	return len(notebookIDs), err
+}